@@ -0,0 +1,14 @@
+-- migrate:up
+UPDATE users SET role = 'ADMIN' WHERE role = 'notblessy';
+UPDATE users SET role = 'USER' WHERE role <> 'ADMIN';
+
+CREATE TABLE allowed_emails (
+    id BIGSERIAL PRIMARY KEY,
+    email VARCHAR(150) NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT allowed_emails_email_idx UNIQUE (email)
+);
+
+-- migrate:down
+DROP TABLE IF EXISTS allowed_emails;
@@ -0,0 +1,32 @@
+-- migrate:up
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMPTZ;
+
+-- Every existing account signed in through Google, which verifies the address.
+UPDATE users SET email_verified_at = created_at WHERE email_verified_at IS NULL;
+
+CREATE TABLE user_identities (
+    id BIGSERIAL PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL,
+    provider VARCHAR(32) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT user_identities_user_id_fk FOREIGN KEY (user_id) REFERENCES users(id),
+    CONSTRAINT user_identities_provider_subject_idx UNIQUE (provider, subject)
+);
+
+CREATE TABLE user_tokens (
+    id VARCHAR(255) PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL,
+    purpose VARCHAR(32) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT user_tokens_user_id_fk FOREIGN KEY (user_id) REFERENCES users(id),
+    CONSTRAINT user_tokens_token_hash_idx UNIQUE (token_hash)
+);
+
+-- migrate:down
+DROP TABLE IF EXISTS user_tokens;
+DROP TABLE IF EXISTS user_identities;
+ALTER TABLE users DROP COLUMN IF EXISTS email_verified_at;
@@ -0,0 +1,20 @@
+-- migrate:up
+CREATE TABLE api_tokens (
+    id VARCHAR(255) PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(32) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL,
+    scopes JSONB NOT NULL DEFAULT '[]',
+    expires_at TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT api_tokens_user_id_fk FOREIGN KEY (user_id) REFERENCES users(id),
+    CONSTRAINT api_tokens_token_hash_idx UNIQUE (token_hash)
+);
+
+CREATE INDEX api_tokens_user_id_idx ON api_tokens(user_id);
+
+-- migrate:down
+DROP TABLE IF EXISTS api_tokens;
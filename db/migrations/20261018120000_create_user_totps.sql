@@ -0,0 +1,24 @@
+-- migrate:up
+CREATE TABLE user_totps (
+    user_id VARCHAR(255) PRIMARY KEY,
+    secret VARCHAR(64) NOT NULL,
+    confirmed_at TIMESTAMPTZ,
+    last_used_step BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT user_totps_user_id_fk FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE TABLE recovery_codes (
+    id BIGSERIAL PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL,
+    code_hash VARCHAR(64) NOT NULL,
+    used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT recovery_codes_user_id_fk FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE INDEX recovery_codes_user_id_idx ON recovery_codes(user_id);
+
+-- migrate:down
+DROP TABLE IF EXISTS recovery_codes;
+DROP TABLE IF EXISTS user_totps;
@@ -0,0 +1,20 @@
+-- migrate:up
+CREATE TABLE user_preferences (
+    user_id VARCHAR(255) PRIMARY KEY,
+    base_currency VARCHAR(3) NOT NULL DEFAULT 'IDR',
+    timezone VARCHAR(64) NOT NULL DEFAULT 'Asia/Jakarta',
+    locale VARCHAR(16) NOT NULL DEFAULT 'id-ID',
+    week_start SMALLINT NOT NULL DEFAULT 1,
+    default_wallet_id VARCHAR(255),
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT user_preferences_user_id_fk FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+ALTER TABLE transactions ADD COLUMN wallet_id VARCHAR(255);
+CREATE INDEX transactions_wallet_id_idx ON transactions(wallet_id);
+
+-- migrate:down
+DROP INDEX IF EXISTS transactions_wallet_id_idx;
+ALTER TABLE transactions DROP COLUMN IF EXISTS wallet_id;
+DROP TABLE IF EXISTS user_preferences;
@@ -0,0 +1,28 @@
+-- migrate:up
+CREATE TABLE exchange_rates (
+    id BIGSERIAL PRIMARY KEY,
+    base VARCHAR(3) NOT NULL,
+    quote VARCHAR(3) NOT NULL,
+    rate NUMERIC(20,10) NOT NULL,
+    rate_date DATE NOT NULL,
+    source VARCHAR(32) NOT NULL DEFAULT 'manual',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT exchange_rates_pair_date_idx UNIQUE (base, quote, rate_date)
+);
+
+ALTER TABLE transactions
+    ADD COLUMN currency VARCHAR(3),
+    ADD COLUMN original_amount NUMERIC(20,2),
+    ADD COLUMN exchange_rate NUMERIC(20,10),
+    ADD COLUMN rate_date DATE;
+
+UPDATE transactions SET currency = 'IDR', original_amount = amount, exchange_rate = 1 WHERE currency IS NULL;
+
+-- migrate:down
+ALTER TABLE transactions
+    DROP COLUMN IF EXISTS rate_date,
+    DROP COLUMN IF EXISTS exchange_rate,
+    DROP COLUMN IF EXISTS original_amount,
+    DROP COLUMN IF EXISTS currency;
+
+DROP TABLE IF EXISTS exchange_rates;
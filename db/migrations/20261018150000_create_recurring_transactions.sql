@@ -0,0 +1,42 @@
+-- migrate:up
+CREATE TABLE recurring_transactions (
+    id VARCHAR(255) PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL,
+    wallet_id VARCHAR(255),
+    category VARCHAR(100) NOT NULL,
+    transaction_type VARCHAR(20) NOT NULL,
+    description VARCHAR(255) NOT NULL,
+    amount NUMERIC(20,2) NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    frequency VARCHAR(20) NOT NULL,
+    interval INTEGER NOT NULL DEFAULT 1,
+    day_of_month INTEGER NOT NULL DEFAULT 0,
+    rrule VARCHAR(255) NOT NULL,
+    start_at TIMESTAMPTZ NOT NULL,
+    end_at TIMESTAMPTZ,
+    next_run_at TIMESTAMPTZ,
+    remind_days_before INTEGER NOT NULL DEFAULT 0,
+    reminded_for TIMESTAMPTZ,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMPTZ,
+    CONSTRAINT recurring_transactions_user_id_fk FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE INDEX recurring_transactions_next_run_at_idx ON recurring_transactions(next_run_at) WHERE deleted_at IS NULL;
+CREATE INDEX recurring_transactions_user_id_idx ON recurring_transactions(user_id);
+
+CREATE TABLE recurring_occurrences (
+    id BIGSERIAL PRIMARY KEY,
+    recurring_id VARCHAR(255) NOT NULL,
+    occurrence_at TIMESTAMPTZ NOT NULL,
+    transaction_id VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT recurring_occurrences_recurring_id_fk FOREIGN KEY (recurring_id) REFERENCES recurring_transactions(id) ON DELETE CASCADE,
+    CONSTRAINT recurring_occurrences_unique_idx UNIQUE (recurring_id, occurrence_at)
+);
+
+-- migrate:down
+DROP TABLE IF EXISTS recurring_occurrences;
+DROP TABLE IF EXISTS recurring_transactions;
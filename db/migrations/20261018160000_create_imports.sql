@@ -0,0 +1,49 @@
+-- migrate:up
+CREATE TABLE import_mappings (
+    id VARCHAR(255) PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    delimiter VARCHAR(3) NOT NULL DEFAULT ',',
+    has_header BOOLEAN NOT NULL DEFAULT TRUE,
+    skip_rows INTEGER NOT NULL DEFAULT 0,
+    date_column VARCHAR(100) NOT NULL,
+    date_format VARCHAR(50) NOT NULL,
+    description_column VARCHAR(100) NOT NULL,
+    amount_column VARCHAR(100),
+    debit_column VARCHAR(100),
+    credit_column VARCHAR(100),
+    decimal_separator VARCHAR(1) NOT NULL DEFAULT '.',
+    invert_sign BOOLEAN NOT NULL DEFAULT FALSE,
+    currency VARCHAR(3),
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT import_mappings_user_id_fk FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE TABLE import_batches (
+    id VARCHAR(255) PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL,
+    wallet_id VARCHAR(255),
+    mapping_id VARCHAR(255),
+    format VARCHAR(10) NOT NULL,
+    file_name VARCHAR(255),
+    status VARCHAR(20) NOT NULL,
+    rows JSONB,
+    transaction_count INTEGER NOT NULL DEFAULT 0,
+    committed_at TIMESTAMPTZ,
+    undone_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT import_batches_user_id_fk FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE INDEX import_batches_user_id_idx ON import_batches(user_id);
+
+ALTER TABLE transactions ADD COLUMN import_batch_id VARCHAR(255);
+CREATE INDEX transactions_import_batch_id_idx ON transactions(import_batch_id);
+
+-- migrate:down
+DROP INDEX IF EXISTS transactions_import_batch_id_idx;
+ALTER TABLE transactions DROP COLUMN IF EXISTS import_batch_id;
+DROP TABLE IF EXISTS import_batches;
+DROP TABLE IF EXISTS import_mappings;
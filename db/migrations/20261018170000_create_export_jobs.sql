@@ -0,0 +1,24 @@
+-- migrate:up
+CREATE TABLE export_jobs (
+    id VARCHAR(255) PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL,
+    kind VARCHAR(20) NOT NULL,
+    format VARCHAR(10) NOT NULL,
+    params JSONB,
+    status VARCHAR(20) NOT NULL,
+    file_name VARCHAR(255) NOT NULL,
+    file_path VARCHAR(512) NOT NULL DEFAULT '',
+    size BIGINT NOT NULL DEFAULT 0,
+    error TEXT,
+    expires_at TIMESTAMPTZ,
+    finished_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT export_jobs_user_id_fk FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE INDEX export_jobs_status_idx ON export_jobs(status, created_at);
+CREATE INDEX export_jobs_user_id_idx ON export_jobs(user_id);
+
+-- migrate:down
+DROP TABLE IF EXISTS export_jobs;
@@ -0,0 +1,12 @@
+-- migrate:up
+CREATE TABLE account_deletions (
+    user_id VARCHAR(255) PRIMARY KEY,
+    requested_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    purge_at TIMESTAMPTZ NOT NULL,
+    CONSTRAINT account_deletions_user_id_fk FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE INDEX account_deletions_purge_at_idx ON account_deletions(purge_at);
+
+-- migrate:down
+DROP TABLE IF EXISTS account_deletions;
@@ -0,0 +1,26 @@
+-- migrate:up
+CREATE TABLE tags (
+    id VARCHAR(255) PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL,
+    name VARCHAR(50) NOT NULL,
+    color VARCHAR(20) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT tags_user_id_fk FOREIGN KEY (user_id) REFERENCES users(id),
+    CONSTRAINT tags_user_id_name_key UNIQUE (user_id, name)
+);
+
+CREATE TABLE transaction_tags (
+    transaction_id VARCHAR(255) NOT NULL,
+    tag_id VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (transaction_id, tag_id),
+    CONSTRAINT transaction_tags_transaction_id_fk FOREIGN KEY (transaction_id) REFERENCES transactions(id) ON DELETE CASCADE,
+    CONSTRAINT transaction_tags_tag_id_fk FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+);
+
+CREATE INDEX transaction_tags_tag_id_idx ON transaction_tags(tag_id);
+
+-- migrate:down
+DROP TABLE IF EXISTS transaction_tags;
+DROP TABLE IF EXISTS tags;
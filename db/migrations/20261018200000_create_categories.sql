@@ -0,0 +1,75 @@
+-- migrate:up
+CREATE TABLE categories (
+    id VARCHAR(255) PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL,
+    parent_id VARCHAR(255),
+    slug VARCHAR(100) NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    icon VARCHAR(20) NOT NULL DEFAULT '',
+    color VARCHAR(20) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT categories_user_id_fk FOREIGN KEY (user_id) REFERENCES users(id),
+    CONSTRAINT categories_parent_id_fk FOREIGN KEY (parent_id) REFERENCES categories(id) ON DELETE SET NULL,
+    CONSTRAINT categories_user_id_slug_key UNIQUE (user_id, slug)
+);
+
+CREATE INDEX categories_parent_id_idx ON categories(parent_id);
+
+-- the categories every user had before they could be edited
+INSERT INTO categories (id, user_id, slug, name, icon, color)
+SELECT gen_random_uuid()::text, users.id, defaults.slug, defaults.name, defaults.icon, defaults.color
+FROM users
+CROSS JOIN (VALUES
+    ('utilities', 'Utilities', '💡', '#F59E0B'),
+    ('transportation', 'Transportation', '🚗', '#3B82F6'),
+    ('home', 'Home', '🏠', '#8B5CF6'),
+    ('shopping', 'Shopping', '🛍️', '#EC4899'),
+    ('groceries', 'Groceries', '🛒', '#10B981'),
+    ('entertainment', 'Entertainment', '🎬', '#EF4444'),
+    ('food', 'Food & Beverage', '🍜', '#F97316'),
+    ('other', 'Other', '📦', '#6B7280')
+) AS defaults (slug, name, icon, color)
+WHERE users.deleted_at IS NULL;
+
+-- anything else already in use keeps working as a category of its own
+INSERT INTO categories (id, user_id, slug, name)
+SELECT gen_random_uuid()::text, used.user_id, used.slug, INITCAP(REPLACE(used.slug, '-', ' '))
+FROM (
+    SELECT DISTINCT user_id, category AS slug FROM transactions
+    WHERE category IS NOT NULL AND category NOT IN ('', 'opname')
+    UNION
+    SELECT DISTINCT scopes.user_id, scope_categories.category FROM scope_categories
+    JOIN scopes ON scopes.id = scope_categories.scope_id
+    WHERE scope_categories.category IS NOT NULL AND scope_categories.category <> ''
+) AS used
+JOIN users ON users.id = used.user_id
+ON CONFLICT (user_id, slug) DO NOTHING;
+
+ALTER TABLE scope_categories ADD COLUMN category_id VARCHAR(255);
+
+UPDATE scope_categories SET category_id = categories.id
+FROM scopes, categories
+WHERE scopes.id = scope_categories.scope_id
+    AND categories.user_id = scopes.user_id
+    AND categories.slug = scope_categories.category;
+
+DELETE FROM scope_categories WHERE category_id IS NULL;
+
+ALTER TABLE scope_categories
+    ALTER COLUMN category_id SET NOT NULL,
+    ADD CONSTRAINT scope_categories_category_id_fk FOREIGN KEY (category_id) REFERENCES categories(id),
+    DROP COLUMN category;
+
+-- migrate:down
+ALTER TABLE scope_categories ADD COLUMN category VARCHAR(255);
+
+UPDATE scope_categories SET category = categories.slug
+FROM categories
+WHERE categories.id = scope_categories.category_id;
+
+ALTER TABLE scope_categories
+    DROP CONSTRAINT scope_categories_category_id_fk,
+    DROP COLUMN category_id;
+
+DROP TABLE IF EXISTS categories;
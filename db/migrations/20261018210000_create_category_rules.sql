@@ -0,0 +1,30 @@
+-- migrate:up
+CREATE TABLE category_rules (
+    user_id VARCHAR(255) NOT NULL,
+    keyword VARCHAR(255) NOT NULL,
+    category VARCHAR(100) NOT NULL,
+    hits INT NOT NULL DEFAULT 1,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, keyword, category),
+    CONSTRAINT category_rules_user_id_fk FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE TABLE category_suggestions (
+    transaction_id VARCHAR(255) PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL,
+    text TEXT NOT NULL DEFAULT '',
+    source VARCHAR(20) NOT NULL,
+    category VARCHAR(100) NOT NULL,
+    corrected_category VARCHAR(100),
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT category_suggestions_transaction_id_fk FOREIGN KEY (transaction_id) REFERENCES transactions(id) ON DELETE CASCADE,
+    CONSTRAINT category_suggestions_user_id_fk FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE INDEX category_suggestions_user_id_created_at_idx ON category_suggestions(user_id, created_at);
+
+-- migrate:down
+DROP TABLE IF EXISTS category_suggestions;
+DROP TABLE IF EXISTS category_rules;
@@ -0,0 +1,32 @@
+-- migrate:up
+CREATE TABLE payees (
+    id VARCHAR(255) PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL,
+    name VARCHAR(150) NOT NULL,
+    default_category VARCHAR(100) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT payees_user_id_fk FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE UNIQUE INDEX payees_user_id_name_key ON payees(user_id, LOWER(name));
+
+CREATE TABLE payee_aliases (
+    id VARCHAR(255) PRIMARY KEY,
+    payee_id VARCHAR(255) NOT NULL,
+    user_id VARCHAR(255) NOT NULL,
+    pattern VARCHAR(150) NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT payee_aliases_payee_id_fk FOREIGN KEY (payee_id) REFERENCES payees(id) ON DELETE CASCADE,
+    CONSTRAINT payee_aliases_user_id_pattern_key UNIQUE (user_id, pattern)
+);
+
+ALTER TABLE transactions ADD COLUMN payee_id VARCHAR(255),
+    ADD CONSTRAINT transactions_payee_id_fk FOREIGN KEY (payee_id) REFERENCES payees(id) ON DELETE SET NULL;
+
+CREATE INDEX transactions_payee_id_idx ON transactions(payee_id);
+
+-- migrate:down
+ALTER TABLE transactions DROP COLUMN IF EXISTS payee_id;
+DROP TABLE IF EXISTS payee_aliases;
+DROP TABLE IF EXISTS payees;
@@ -0,0 +1,19 @@
+-- migrate:up
+CREATE TABLE attachments (
+    id VARCHAR(255) PRIMARY KEY,
+    transaction_id VARCHAR(255) NOT NULL,
+    user_id VARCHAR(255) NOT NULL,
+    file_name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    size BIGINT NOT NULL,
+    storage_key VARCHAR(500) NOT NULL,
+    thumbnail_key VARCHAR(500),
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT attachments_transaction_id_fk FOREIGN KEY (transaction_id) REFERENCES transactions(id),
+    CONSTRAINT attachments_user_id_fk FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE INDEX attachments_transaction_id_idx ON attachments(transaction_id);
+
+-- migrate:down
+DROP TABLE IF EXISTS attachments;
@@ -0,0 +1,33 @@
+-- migrate:up
+-- No foreign keys: entries outlive the transactions and users they mention.
+CREATE TABLE audit_logs (
+    id VARCHAR(255) PRIMARY KEY,
+    actor_id VARCHAR(255) NOT NULL,
+    channel VARCHAR(20) NOT NULL,
+    action VARCHAR(20) NOT NULL,
+    entity_type VARCHAR(20) NOT NULL,
+    entity_id VARCHAR(255) NOT NULL,
+    transaction_id VARCHAR(255) NOT NULL,
+    before JSONB,
+    after JSONB,
+    changes JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX audit_logs_transaction_id_idx ON audit_logs(transaction_id, created_at);
+CREATE INDEX audit_logs_created_at_idx ON audit_logs(created_at);
+
+-- Entries are never edited. Rows are only deleted when an account is purged.
+CREATE FUNCTION audit_logs_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_logs is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_logs_no_update
+    BEFORE UPDATE ON audit_logs
+    FOR EACH ROW EXECUTE FUNCTION audit_logs_append_only();
+
+-- migrate:down
+DROP TABLE IF EXISTS audit_logs;
+DROP FUNCTION IF EXISTS audit_logs_append_only();
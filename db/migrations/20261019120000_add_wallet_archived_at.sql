@@ -0,0 +1,5 @@
+-- migrate:up
+ALTER TABLE wallets ADD COLUMN archived_at TIMESTAMPTZ;
+
+-- migrate:down
+ALTER TABLE wallets DROP COLUMN IF EXISTS archived_at;
@@ -0,0 +1,21 @@
+-- migrate:up
+ALTER TABLE wallets
+    ADD COLUMN type VARCHAR(20) NOT NULL DEFAULT 'cash',
+    ADD COLUMN credit_limit NUMERIC(20,2) NOT NULL DEFAULT 0,
+    ADD COLUMN statement_closing_day SMALLINT NOT NULL DEFAULT 0,
+    ADD COLUMN payment_due_day SMALLINT NOT NULL DEFAULT 0,
+    ADD COLUMN due_reminded_for DATE;
+
+ALTER TABLE transactions ADD COLUMN transfer_id VARCHAR(255);
+
+CREATE INDEX transactions_transfer_id_idx ON transactions(transfer_id) WHERE transfer_id IS NOT NULL;
+
+-- migrate:down
+ALTER TABLE transactions DROP COLUMN IF EXISTS transfer_id;
+
+ALTER TABLE wallets
+    DROP COLUMN IF EXISTS type,
+    DROP COLUMN IF EXISTS credit_limit,
+    DROP COLUMN IF EXISTS statement_closing_day,
+    DROP COLUMN IF EXISTS payment_due_day,
+    DROP COLUMN IF EXISTS due_reminded_for;
@@ -0,0 +1,33 @@
+-- migrate:up
+CREATE TABLE goals (
+    id VARCHAR(255) PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL,
+    name VARCHAR(150) NOT NULL,
+    target_amount NUMERIC(20,2) NOT NULL,
+    deadline DATE,
+    wallet_id VARCHAR(255),
+    milestone SMALLINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT goals_user_id_fk FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE INDEX goals_user_id_idx ON goals(user_id);
+CREATE INDEX goals_wallet_id_idx ON goals(wallet_id) WHERE wallet_id IS NOT NULL;
+
+CREATE TABLE goal_contributions (
+    id VARCHAR(255) PRIMARY KEY,
+    goal_id VARCHAR(255) NOT NULL,
+    user_id VARCHAR(255) NOT NULL,
+    amount NUMERIC(20,2) NOT NULL,
+    contributed_at TIMESTAMPTZ NOT NULL,
+    note VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT goal_contributions_goal_id_fk FOREIGN KEY (goal_id) REFERENCES goals(id) ON DELETE CASCADE
+);
+
+CREATE INDEX goal_contributions_goal_id_idx ON goal_contributions(goal_id, contributed_at);
+
+-- migrate:down
+DROP TABLE IF EXISTS goal_contributions;
+DROP TABLE IF EXISTS goals;
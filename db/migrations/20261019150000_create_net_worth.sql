@@ -0,0 +1,30 @@
+-- migrate:up
+CREATE TABLE assets (
+    id VARCHAR(255) PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL,
+    name VARCHAR(150) NOT NULL,
+    kind VARCHAR(20) NOT NULL,
+    value NUMERIC(20,2) NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT assets_user_id_fk FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE INDEX assets_user_id_idx ON assets(user_id);
+
+-- No foreign key on source_id: snapshots outlive the wallets and assets they describe.
+CREATE TABLE balance_snapshots (
+    user_id VARCHAR(255) NOT NULL,
+    date DATE NOT NULL,
+    source_type VARCHAR(20) NOT NULL,
+    source_id VARCHAR(255) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    balance NUMERIC(20,2) NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, date, source_type, source_id),
+    CONSTRAINT balance_snapshots_user_id_fk FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+-- migrate:down
+DROP TABLE IF EXISTS balance_snapshots;
+DROP TABLE IF EXISTS assets;
@@ -0,0 +1,7 @@
+-- migrate:up
+ALTER TABLE user_totps ADD COLUMN failed_attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE user_totps ADD COLUMN locked_until TIMESTAMPTZ;
+
+-- migrate:down
+ALTER TABLE user_totps DROP COLUMN IF EXISTS locked_until;
+ALTER TABLE user_totps DROP COLUMN IF EXISTS failed_attempts;
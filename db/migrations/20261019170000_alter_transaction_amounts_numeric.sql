@@ -0,0 +1,7 @@
+-- migrate:up
+ALTER TABLE transactions ALTER COLUMN amount TYPE NUMERIC(20,2);
+ALTER TABLE transaction_shares ALTER COLUMN amount TYPE NUMERIC(20,2);
+
+-- migrate:down
+ALTER TABLE transaction_shares ALTER COLUMN amount TYPE INTEGER USING ROUND(amount);
+ALTER TABLE transactions ALTER COLUMN amount TYPE INTEGER USING ROUND(amount);
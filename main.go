@@ -64,8 +64,28 @@ func main() {
 	walletRepo := repository.NewWalletRepository(postgres)
 	budgetRepo := repository.NewScopeRepository(postgres)
 	transactionRepo := repository.NewTransactionRepository(postgres)
+	adminRepo := repository.NewAdminRepository(postgres)
+	sessionRepo := repository.NewSessionRepository(postgres)
+	apiTokenRepo := repository.NewAPITokenRepository(postgres)
+	mfaRepo := repository.NewMFARepository(postgres)
+	preferenceRepo := repository.NewPreferenceRepository(postgres)
+	exchangeRateRepo := repository.NewExchangeRateRepository(postgres, repository.NewRateProviderFromEnv())
+	categoryRepo := repository.NewCategoryRepository(postgres)
+	payeeRepo := repository.NewPayeeRepository(postgres)
 	openAiRepo := repository.NewHandler(openAi)
-	capitalBotRepo := repository.NewCapitalBotRepository(postgres, bot, openAiRepo)
+	capitalBotRepo := repository.NewCapitalBotRepository(postgres, bot, openAiRepo, preferenceRepo, exchangeRateRepo, categoryRepo, payeeRepo)
+	importRepo := repository.NewImportRepository(postgres)
+	exportRepo := repository.NewExportRepository(postgres, preferenceRepo)
+	fileStorage := repository.NewStorageFromEnv()
+	accountRepo := repository.NewAccountRepository(postgres, preferenceRepo, fileStorage)
+	attachmentRepo := repository.NewAttachmentRepository(postgres)
+	auditRepo := repository.NewAuditRepository(postgres)
+	trashRepo := repository.NewTrashRepository(postgres, fileStorage)
+	tagRepo := repository.NewTagRepository(postgres)
+	recurringRepo := repository.NewRecurringRepository(postgres, preferenceRepo, exchangeRateRepo, capitalBotRepo)
+	creditCardRepo := repository.NewCreditCardRepository(postgres, preferenceRepo, capitalBotRepo)
+	goalRepo := repository.NewGoalRepository(postgres, preferenceRepo, capitalBotRepo)
+	netWorthRepo := repository.NewNetWorthRepository(postgres, preferenceRepo)
 
 	httpService := router.NewHTTPService()
 	httpService.RegisterPostgres(postgres)
@@ -73,6 +93,31 @@ func main() {
 	httpService.RegisterWalletRepository(walletRepo)
 	httpService.RegisterScopeRepository(budgetRepo)
 	httpService.RegisterTransactionRepository(transactionRepo)
+	httpService.RegisterAdminRepository(adminRepo)
+	httpService.RegisterSessionRepository(sessionRepo)
+	httpService.RegisterAPITokenRepository(apiTokenRepo)
+	httpService.RegisterMFARepository(mfaRepo)
+	httpService.RegisterPreferenceRepository(preferenceRepo)
+	httpService.RegisterExchangeRateRepository(exchangeRateRepo)
+	httpService.RegisterRecurringTransactionRepository(recurringRepo)
+	httpService.RegisterImportRepository(importRepo)
+	httpService.RegisterExportRepository(exportRepo)
+	httpService.RegisterAccountRepository(accountRepo)
+	httpService.RegisterTagRepository(tagRepo)
+	httpService.RegisterCategoryRepository(categoryRepo)
+	httpService.RegisterPayeeRepository(payeeRepo)
+	httpService.RegisterAttachmentRepository(attachmentRepo)
+	httpService.RegisterAuditRepository(auditRepo)
+	httpService.RegisterTrashRepository(trashRepo)
+	httpService.RegisterCreditCardRepository(creditCardRepo)
+	httpService.RegisterGoalRepository(goalRepo)
+	httpService.RegisterNetWorthRepository(netWorthRepo)
+	httpService.RegisterFileStorage(fileStorage)
+	httpService.RegisterMailer(repository.NewMailer())
+
+	for _, provider := range repository.NewOIDCProvidersFromEnv() {
+		httpService.RegisterOIDCProvider(provider)
+	}
 
 	httpService.Router(e)
 
@@ -80,21 +125,54 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	wg := &sync.WaitGroup{}
 
-	// Bot listener
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		log.Println("Bot listener started")
+	// Background workers, each running until ctx is canceled
+	workers := []struct {
+		name string
+		run  func() error
+	}{
+		{"Bot listener", func() error {
+			return capitalBotRepo.ListenMessage(ctx)
+		}},
+		// recurring transactions and reminders
+		{"Recurring scheduler", func() error {
+			return repository.RunRecurringScheduler(ctx, recurringRepo, 15*time.Minute)
+		}},
+		{"Export worker", func() error {
+			return repository.RunExportWorker(ctx, exportRepo, repository.ExportDir(), 10*time.Second)
+		}},
+		// account deletions past their grace period
+		{"Account purger", func() error {
+			return repository.RunAccountPurger(ctx, accountRepo, time.Hour)
+		}},
+		// credit card due-date reminders
+		{"Card reminder", func() error {
+			return repository.RunCreditCardReminder(ctx, creditCardRepo, time.Hour)
+		}},
+		// goal milestone messages
+		{"Goal milestones", func() error {
+			return repository.RunGoalMilestones(ctx, goalRepo, time.Hour)
+		}},
+		// daily balance snapshots for net worth
+		{"Net worth snapshots", func() error {
+			return repository.RunNetWorthSnapshots(ctx, netWorthRepo, time.Hour)
+		}},
+		// trashed items past their retention window
+		{"Trash purger", func() error {
+			return repository.RunTrashPurger(ctx, trashRepo, time.Hour)
+		}},
+	}
 
-		if err := capitalBotRepo.ListenMessage(ctx); err != nil {
-			if err == context.Canceled {
-				log.Println("Bot listener canceled")
-				return
-			}
+	for _, worker := range workers {
+		wg.Add(1)
+		go func(name string, run func() error) {
+			defer wg.Done()
+			log.Printf("%s started", name)
 
-			log.Printf("Bot listener error: %v", err)
-		}
-	}()
+			if err := run(); err != nil && err != context.Canceled {
+				log.Printf("%s error: %v", name, err)
+			}
+		}(worker.name, worker.run)
+	}
 
 	// HTTP server
 	wg.Add(1)
@@ -115,7 +193,7 @@ func main() {
 	log.Println("Shutdown signal received")
 
 	// Initiate graceful shutdown
-	cancel() // stop bot listener
+	cancel() // stop the background workers
 	ctxTimeout, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer shutdownCancel()
 	if err := e.Shutdown(ctxTimeout); err != nil {
@@ -0,0 +1,101 @@
+package model
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// AccountArchiveVersion is bumped whenever the archive layout changes so
+	// restores can tell which shape they are reading.
+	AccountArchiveVersion = 2
+
+	AccountDeletionGracePeriod = 30 * 24 * time.Hour
+
+	DeletedUserName = "Deleted user"
+
+	AccountArchiveMaxSize = 50 << 20
+
+	// RestoreSkip reuses matching records already in the account and skips
+	// transactions that look identical; RestoreDuplicate always creates new ones.
+	RestoreSkip      = "skip"
+	RestoreDuplicate = "duplicate"
+)
+
+type AccountRepository interface {
+	Archive(ctx context.Context, userID string) (AccountArchive, error)
+	// Restore loads an archive into the user's account under fresh IDs.
+	Restore(ctx context.Context, userID string, archive AccountArchive, onConflict string) (RestoreResult, error)
+
+	FindDeletion(ctx context.Context, userID string) (AccountDeletion, error)
+	RequestDeletion(ctx context.Context, userID string, purgeAt time.Time) (AccountDeletion, error)
+	CancelDeletion(ctx context.Context, userID string) error
+	// PurgeDue erases every account whose grace period ended before now.
+	PurgeDue(ctx context.Context, now time.Time) (int, error)
+}
+
+// AccountArchive is everything tied to an account, as downloaded by the user.
+type AccountArchive struct {
+	Version               int                    `json:"version"`
+	ExportedAt            time.Time              `json:"exported_at"`
+	Profile               User                   `json:"profile"`
+	Preference            UserPreference         `json:"preference"`
+	Identities            []UserIdentity         `json:"identities"`
+	BotLink               *BotLink               `json:"bot_link"`
+	Wallets               []Wallet               `json:"wallets"`
+	Categories            []Category             `json:"categories"` // since version 2
+	CategoryRules         []CategoryRule         `json:"category_rules"`
+	Scopes                []Scope                `json:"scopes"`
+	Tags                  []Tag                  `json:"tags"`
+	Payees                []Payee                `json:"payees"`
+	Transactions          []Transaction          `json:"transactions"`           // created by the user, with every share
+	Shares                []TransactionShare     `json:"shares"`                 // held by the user on other people's transactions
+	RecurringTransactions []RecurringTransaction `json:"recurring_transactions"` // schedules only, generated rows are in transactions
+	ImportMappings        []ImportMapping        `json:"import_mappings"`
+	Goals                 []Goal                 `json:"goals"` // with their contributions
+	Assets                []Asset                `json:"assets"`
+}
+
+// AccountDeletion is a pending request to erase an account. Until PurgeAt the
+// owner can still sign in and cancel it.
+type AccountDeletion struct {
+	UserID      string    `json:"user_id" gorm:"primaryKey"`
+	RequestedAt time.Time `json:"requested_at"`
+	PurgeAt     time.Time `json:"purge_at"`
+}
+
+// AccountDeletionRequest re-authenticates the owner before the request is
+// accepted. Password is needed when the account has one, a code when MFA is on.
+type AccountDeletionRequest struct {
+	Password string `json:"password"`
+	MFACodeRequest
+}
+
+type RestoreInput struct {
+	OnConflict string `query:"on_conflict"` // skip (default) or duplicate
+}
+
+// RestoreCount tells how many records of one kind were written and how many
+// were left out, either as duplicates or because they could not be linked.
+type RestoreCount struct {
+	Created int `json:"created"`
+	Skipped int `json:"skipped"`
+}
+
+type RestoreResult struct {
+	Wallets               RestoreCount `json:"wallets"`
+	Categories            RestoreCount `json:"categories"`
+	CategoryRules         RestoreCount `json:"category_rules"`
+	Scopes                RestoreCount `json:"scopes"`
+	Tags                  RestoreCount `json:"tags"`
+	Payees                RestoreCount `json:"payees"`
+	Transactions          RestoreCount `json:"transactions"`
+	Shares                RestoreCount `json:"shares"`
+	RecurringTransactions RestoreCount `json:"recurring_transactions"`
+	ImportMappings        RestoreCount `json:"import_mappings"`
+	Goals                 RestoreCount `json:"goals"`
+	Assets                RestoreCount `json:"assets"`
+	Preference            bool         `json:"preference"`
+	BotLink               bool         `json:"bot_link"`
+	Warnings              []string     `json:"warnings"`
+}
@@ -0,0 +1,63 @@
+package model
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	RoleUser  = "USER"
+	RoleAdmin = "ADMIN"
+)
+
+type AdminRepository interface {
+	FindAllUsers(ctx context.Context, query UserQueryInput) ([]User, int64, error)
+	UpdateRole(ctx context.Context, userID, role string) error
+	DeleteUser(ctx context.Context, userID string) error
+
+	FindAllowedEmails(ctx context.Context) ([]AllowedEmail, error)
+	CreateAllowedEmail(ctx context.Context, allowed *AllowedEmail) error
+	DeleteAllowedEmail(ctx context.Context, id int64) error
+
+	FindBotLinks(ctx context.Context) ([]BotLink, error)
+	Unlink(ctx context.Context, userID string) error
+
+	Stats(ctx context.Context) (SystemStats, error)
+}
+
+// AllowedEmail is an address permitted to sign in when the allow list is not empty.
+type AllowedEmail struct {
+	ID        int64     `json:"id"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type AllowedEmailInput struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type RoleInput struct {
+	Role string `json:"role" validate:"required,oneof=USER ADMIN"`
+}
+
+type UserQueryInput struct {
+	Keyword string `query:"keyword"`
+	Role    string `query:"role"`
+	PaginatedRequest
+}
+
+type BotLink struct {
+	UserID     string `json:"user_id"`
+	Email      string `json:"email"`
+	Name       string `json:"name"`
+	TelegramID int64  `json:"telegram_id"`
+}
+
+type SystemStats struct {
+	Users        int64 `json:"users"`
+	Admins       int64 `json:"admins"`
+	LinkedBots   int64 `json:"linked_bots"`
+	Wallets      int64 `json:"wallets"`
+	Scopes       int64 `json:"scopes"`
+	Transactions int64 `json:"transactions"`
+}
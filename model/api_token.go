@@ -0,0 +1,67 @@
+package model
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+const (
+	// APITokenPrefix marks personal access tokens so they are not parsed as JWTs.
+	APITokenPrefix = "anggar_pat_"
+
+	TokenScopeRead              = "read"
+	TokenScopeTransactionsWrite = "transactions:write"
+	TokenScopeWalletsWrite      = "wallets:write"
+	TokenScopeScopesWrite       = "scopes:write"
+)
+
+var TokenScopes = []string{
+	TokenScopeRead,
+	TokenScopeTransactionsWrite,
+	TokenScopeWalletsWrite,
+	TokenScopeScopesWrite,
+}
+
+type APITokenRepository interface {
+	// Create stores the token and returns the raw secret, which is never retrievable again.
+	Create(ctx context.Context, token *APIToken) (string, error)
+	FindAll(ctx context.Context, userID string) ([]APIToken, error)
+	// Authenticate resolves a raw token to its active record with the owner preloaded.
+	Authenticate(ctx context.Context, rawToken string) (APIToken, error)
+	Revoke(ctx context.Context, userID, id string) error
+}
+
+type APIToken struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	TokenHash  string     `json:"-"`
+	Scopes     []string   `json:"scopes" gorm:"serializer:json"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	User       User       `json:"-" gorm:"foreignKey:UserID;->"`
+}
+
+// IsActive reports whether the token is neither revoked nor expired.
+func (t *APIToken) IsActive(now time.Time) bool {
+	if t.RevokedAt != nil {
+		return false
+	}
+
+	return t.ExpiresAt == nil || now.Before(*t.ExpiresAt)
+}
+
+type APITokenInput struct {
+	Name      string     `json:"name" validate:"required,max=100"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1,dive,oneof=read transactions:write wallets:write scopes:write"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// IsAPIToken reports whether a bearer credential is a personal access token.
+func IsAPIToken(token string) bool {
+	return strings.HasPrefix(token, APITokenPrefix)
+}
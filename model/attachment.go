@@ -0,0 +1,60 @@
+package model
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+const (
+	AttachmentMaxSize           = 10 << 20
+	AttachmentsPerTransaction   = 10
+	AttachmentThumbnailSize     = 320 // longest side, in pixels
+	AttachmentThumbnailMimeType = "image/jpeg"
+)
+
+// AttachmentContentTypes are the files that can be attached, as sniffed from
+// their content rather than trusted from the upload.
+var AttachmentContentTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+}
+
+// FileStorage keeps attachment bytes outside the database.
+type FileStorage interface {
+	Put(ctx context.Context, key, contentType string, data []byte) error
+	// Get opens a stored file; ErrFileNotFound when there is none.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes a file. Removing a missing file is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+type AttachmentRepository interface {
+	Create(ctx context.Context, attachment *Attachment) error
+	FindByID(ctx context.Context, id string) (Attachment, error)
+	FindByTransaction(ctx context.Context, transactionID string) ([]Attachment, error)
+	CountByTransaction(ctx context.Context, transactionID string) (int64, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// Attachment is a receipt, invoice or other file kept with a transaction.
+type Attachment struct {
+	ID            string    `json:"id" gorm:"primaryKey"`
+	TransactionID string    `json:"transaction_id"`
+	UserID        string    `json:"user_id"` // uploader
+	FileName      string    `json:"file_name"`
+	ContentType   string    `json:"content_type"`
+	Size          int64     `json:"size"`
+	StorageKey    string    `json:"-"`
+	ThumbnailKey  *string   `json:"-"`
+	HasThumbnail  bool      `json:"has_thumbnail" gorm:"-"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// AttachmentKey is where an attachment's bytes are stored.
+func AttachmentKey(userID, attachmentID string) string {
+	return "attachments/" + userID + "/" + attachmentID
+}
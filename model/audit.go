@@ -0,0 +1,153 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// Channels an audited change came through.
+const (
+	AuditChannelWeb       = "web"
+	AuditChannelAPIToken  = "api_token"
+	AuditChannelBot       = "bot"
+	AuditChannelImport    = "import"
+	AuditChannelRecurring = "recurring"
+)
+
+const (
+	AuditActionCreated  = "created"
+	AuditActionUpdated  = "updated"
+	AuditActionDeleted  = "deleted"
+	AuditActionRestored = "restored"
+
+	AuditEntityTransaction = "transaction"
+	AuditEntityShare       = "share"
+)
+
+// AuditRepository only ever adds entries; nothing in the application edits
+// or removes them.
+type AuditRepository interface {
+	Record(ctx context.Context, log *AuditLog) error
+	// FindByTransaction is the history of a transaction and its shares, oldest first.
+	FindByTransaction(ctx context.Context, transactionID string) ([]AuditLog, error)
+	// Feed lists changes to the transactions a user takes part in, newest first.
+	Feed(ctx context.Context, query AuditFeedQueryInput) ([]AuditLog, int64, error)
+}
+
+// AuditActor is who makes a change and through which channel. Repositories
+// record the audit entry in the same database transaction as the change.
+type AuditActor struct {
+	ID      string
+	Channel string
+}
+
+// AuditLog records who changed a transaction or share, through which channel,
+// and what it looked like before and after.
+type AuditLog struct {
+	ID            string                 `json:"id" gorm:"primaryKey"`
+	ActorID       string                 `json:"actor_id"`
+	ActorName     string                 `json:"actor_name" gorm:"->"`
+	Channel       string                 `json:"channel"`
+	Action        string                 `json:"action"`
+	EntityType    string                 `json:"entity_type"`
+	EntityID      string                 `json:"entity_id"`
+	TransactionID string                 `json:"transaction_id"`
+	Before        map[string]interface{} `json:"before" gorm:"serializer:json"`
+	After         map[string]interface{} `json:"after" gorm:"serializer:json"`
+	Changes       []string               `json:"changes" gorm:"serializer:json"` // fields that differ, for updates
+	CreatedAt     time.Time              `json:"created_at"`
+}
+
+type AuditFeedQueryInput struct {
+	Channel string `query:"channel"`
+	ActorID string `query:"actor_id"`
+	// ParticipantID limits the feed to transactions the user created or shares in.
+	ParticipantID string
+	PaginatedRequest
+}
+
+// NewAuditLog describes a change to a transaction. Before is nil for
+// creations and after is nil for deletions.
+func NewAuditLog(actorID, channel, action string, transaction Transaction, before, after map[string]interface{}) AuditLog {
+	return AuditLog{
+		ActorID:       actorID,
+		Channel:       channel,
+		Action:        action,
+		EntityType:    AuditEntityTransaction,
+		EntityID:      transaction.ID,
+		TransactionID: transaction.ID,
+		Before:        before,
+		After:         after,
+		Changes:       AuditChanges(before, after),
+	}
+}
+
+// NewShareAuditLog describes a change to one share of a transaction.
+func NewShareAuditLog(actorID, channel, action string, share TransactionShare, before, after map[string]interface{}) AuditLog {
+	return AuditLog{
+		ActorID:       actorID,
+		Channel:       channel,
+		Action:        action,
+		EntityType:    AuditEntityShare,
+		EntityID:      share.ID,
+		TransactionID: share.TransactionID,
+		Before:        before,
+		After:         after,
+		Changes:       AuditChanges(before, after),
+	}
+}
+
+// TransactionAuditState is the part of a transaction worth keeping in the log.
+func TransactionAuditState(t Transaction) map[string]interface{} {
+	shares := make([]map[string]interface{}, len(t.TransactionShares))
+	for i, share := range t.TransactionShares {
+		shares[i] = ShareAuditState(share)
+	}
+
+	return map[string]interface{}{
+		"wallet_id":        t.WalletID,
+		"category":         t.Category,
+		"transaction_type": t.TransactionType,
+		"description":      t.Description,
+		"spent_at":         t.SpentAt,
+		"amount":           t.Amount,
+		"currency":         t.Currency,
+		"original_amount":  t.OriginalAmount,
+		"is_shared":        t.IsShared,
+		"payee_id":         t.PayeeID,
+		"shares":           shares,
+	}
+}
+
+func ShareAuditState(s TransactionShare) map[string]interface{} {
+	return map[string]interface{}{
+		"id":         s.ID,
+		"user_id":    s.UserID,
+		"percentage": s.Percentage,
+		"amount":     s.Amount,
+	}
+}
+
+// AuditChanges lists the fields whose values differ, comparing them as JSON
+// so decimals and times compare by value. Only updates have changes.
+func AuditChanges(before, after map[string]interface{}) []string {
+	changes := []string{}
+	if before == nil || after == nil {
+		return changes
+	}
+
+	for field, value := range after {
+		was, _ := json.Marshal(before[field])
+		is, _ := json.Marshal(value)
+
+		if string(was) != string(is) {
+			changes = append(changes, field)
+		}
+	}
+
+	sort.Strings(changes)
+
+	return changes
+}
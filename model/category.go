@@ -0,0 +1,292 @@
+package model
+
+import (
+	"context"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/shopspring/decimal"
+)
+
+type CategoryRepository interface {
+	// FindAll lists the user's categories, seeding the defaults for users who have none yet.
+	FindAll(ctx context.Context, userID string) ([]Category, error)
+	// FindTree is FindAll arranged as top-level categories with their children.
+	FindTree(ctx context.Context, userID string) ([]Category, error)
+	FindByID(ctx context.Context, id string) (Category, error)
+	Create(ctx context.Context, category *Category) error
+	// Update saves the category and, when its slug changed, moves the user's
+	// transactions and recurring transactions over to the new slug.
+	Update(ctx context.Context, category Category) (int64, error)
+	// Merge moves everything filed under source to target, then removes source.
+	Merge(ctx context.Context, source, target Category) (int64, error)
+	Delete(ctx context.Context, id string) error
+
+	// Suggest returns the category the user's past corrections point to for a
+	// message, or "" when they have not taught one yet.
+	Suggest(ctx context.Context, userID, text string) (string, error)
+	// Learn records that the user moved a recognized transaction to another
+	// category. Transactions the recognizer did not categorize are ignored.
+	Learn(ctx context.Context, userID, transactionID, category string) error
+	Accuracy(ctx context.Context, query CategoryAccuracyQueryInput) (CategoryAccuracyReport, error)
+}
+
+const (
+	// CategorySourceLearned marks a category taken from the user's corrections,
+	// CategorySourcePayee the default of the matched payee and
+	// CategorySourceRecognizer one the recognizer guessed.
+	CategorySourceLearned    = "learned"
+	CategorySourcePayee      = "payee"
+	CategorySourceRecognizer = "recognizer"
+
+	// CategoryKeywordMinLength skips words too short to say anything, like "di" or "rb".
+	CategoryKeywordMinLength = 3
+)
+
+// Category is one entry of a user's two-level category tree. Transactions
+// store the slug, so renaming a category rewrites them.
+type Category struct {
+	ID        string     `json:"id" gorm:"primaryKey"`
+	UserID    string     `json:"user_id"`
+	ParentID  *string    `json:"parent_id"`
+	Slug      string     `json:"slug"`
+	Name      string     `json:"name"`
+	Icon      string     `json:"icon"`
+	Color     string     `json:"color"`
+	Children  []Category `json:"children,omitempty" gorm:"foreignKey:ParentID"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// DefaultCategories are given to every new user. Their slugs are the ones
+// transactions used before categories could be edited.
+var DefaultCategories = []Category{
+	{Slug: "utilities", Name: "Utilities", Icon: "💡", Color: "#F59E0B"},
+	{Slug: "transportation", Name: "Transportation", Icon: "🚗", Color: "#3B82F6"},
+	{Slug: "home", Name: "Home", Icon: "🏠", Color: "#8B5CF6"},
+	{Slug: "shopping", Name: "Shopping", Icon: "🛍️", Color: "#EC4899"},
+	{Slug: "groceries", Name: "Groceries", Icon: "🛒", Color: "#10B981"},
+	{Slug: "entertainment", Name: "Entertainment", Icon: "🎬", Color: "#EF4444"},
+	{Slug: "food", Name: "Food & Beverage", Icon: "🍜", Color: "#F97316"},
+	{Slug: CategoryOther, Name: "Other", Icon: "📦", Color: "#6B7280"},
+}
+
+// CategoryRule counts how often the user corrected messages containing a
+// keyword to a category. A keyword is a single word or the whole message.
+type CategoryRule struct {
+	UserID    string    `json:"user_id" gorm:"primaryKey"`
+	Keyword   string    `json:"keyword" gorm:"primaryKey"`
+	Category  string    `json:"category" gorm:"primaryKey"`
+	Hits      int       `json:"hits"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CategorySuggestion is the category the bot gave a transaction, kept to learn
+// from the user's corrections and to measure how often it was right.
+type CategorySuggestion struct {
+	TransactionID     string    `json:"transaction_id" gorm:"primaryKey"`
+	UserID            string    `json:"user_id"`
+	Text              string    `json:"text"` // the message keywords come from
+	Source            string    `json:"source"`
+	Category          string    `json:"category"`
+	CorrectedCategory *string   `json:"corrected_category"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+type CategoryAccuracyQueryInput struct {
+	StartDate string `query:"start_date"`
+	EndDate   string `query:"end_date"`
+	UserID    string
+	Timezone  string
+}
+
+// CategoryAccuracy tells how many suggested categories the user kept.
+type CategoryAccuracy struct {
+	Month              string          `json:"month,omitempty"` // e.g. "2026-10"
+	Suggestions        int64           `json:"suggestions"`
+	Corrections        int64           `json:"corrections"`
+	Learned            int64           `json:"learned"` // suggestions taken from corrections
+	LearnedCorrections int64           `json:"learned_corrections"`
+	Accuracy           decimal.Decimal `json:"accuracy"` // percentage of suggestions kept
+}
+
+type CategoryAccuracyReport struct {
+	Overall CategoryAccuracy   `json:"overall"`
+	Months  []CategoryAccuracy `json:"months"`
+}
+
+// Compute fills Accuracy from the counts.
+func (a *CategoryAccuracy) Compute() {
+	a.Accuracy = decimal.Zero
+	if a.Suggestions == 0 {
+		return
+	}
+
+	kept := decimal.NewFromInt(a.Suggestions - a.Corrections)
+	a.Accuracy = kept.Mul(decimal.NewFromInt(100)).Div(decimal.NewFromInt(a.Suggestions)).Round(2)
+}
+
+type CategoryInput struct {
+	Name     string  `json:"name" validate:"required"`
+	ParentID *string `json:"parent_id"`
+	Icon     string  `json:"icon"`
+	Color    string  `json:"color"`
+}
+
+type CategoryMergeInput struct {
+	TargetID string `json:"target_id" validate:"required"`
+}
+
+type CategoryQueryInput struct {
+	Tree bool `query:"tree"`
+}
+
+// CategorySlug derives the slug stored on transactions from a display name,
+// e.g. "Kids & Education" becomes "kids-education".
+func CategorySlug(name string) string {
+	var b strings.Builder
+	dash := false
+
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteRune('-')
+			}
+
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+
+		dash = true
+	}
+
+	return b.String()
+}
+
+// CategoryKeywords splits a message into the words rules are learned for,
+// lowercased and without amounts, plus the whole message as one keyword so an
+// exact repeat wins over single words.
+func CategoryKeywords(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+
+	seen := map[string]bool{}
+	var keywords, kept []string
+
+	for _, word := range words {
+		if len([]rune(word)) < CategoryKeywordMinLength {
+			continue
+		}
+
+		kept = append(kept, word)
+		if !seen[word] {
+			seen[word] = true
+			keywords = append(keywords, word)
+		}
+	}
+
+	if len(kept) > 1 {
+		keywords = append(keywords, strings.Join(kept, " "))
+	}
+
+	return keywords
+}
+
+// SuggestCategory picks a category from the rules matching a message. A rule
+// for the whole message wins; otherwise words that were always corrected to
+// the same category vote with their hits. Ties suggest nothing.
+func SuggestCategory(text string, rules []CategoryRule) string {
+	keywords := CategoryKeywords(text)
+	if len(keywords) == 0 {
+		return ""
+	}
+
+	phrase := ""
+	if len(keywords) > 1 {
+		phrase = keywords[len(keywords)-1]
+	}
+
+	byKeyword := map[string][]CategoryRule{}
+	for _, rule := range rules {
+		byKeyword[rule.Keyword] = append(byKeyword[rule.Keyword], rule)
+	}
+
+	if phrase != "" {
+		if category := topCategory(byKeyword[phrase]); category != "" {
+			return category
+		}
+	}
+
+	votes := map[string]int{}
+	for _, keyword := range keywords {
+		matched := byKeyword[keyword]
+		if keyword == phrase || len(matched) != 1 {
+			continue
+		}
+
+		votes[matched[0].Category] += matched[0].Hits
+	}
+
+	var votesFor []CategoryRule
+	for category, hits := range votes {
+		votesFor = append(votesFor, CategoryRule{Category: category, Hits: hits})
+	}
+
+	return topCategory(votesFor)
+}
+
+// topCategory is the category with the most hits, or "" on a tie.
+func topCategory(rules []CategoryRule) string {
+	best, tie := CategoryRule{}, false
+
+	for _, rule := range rules {
+		switch {
+		case rule.Hits > best.Hits:
+			best, tie = rule, false
+		case rule.Hits == best.Hits:
+			tie = true
+		}
+	}
+
+	if tie {
+		return ""
+	}
+
+	return best.Category
+}
+
+// CategoryBySlug finds a category in a list, e.g. to show its name.
+func CategoryBySlug(categories []Category, slug string) (Category, bool) {
+	for _, category := range categories {
+		if category.Slug == slug {
+			return category, true
+		}
+	}
+
+	return Category{}, false
+}
+
+// CategoryTree nests a flat list under its top-level categories.
+func CategoryTree(categories []Category) []Category {
+	children := map[string][]Category{}
+	for _, category := range categories {
+		if category.ParentID != nil {
+			children[*category.ParentID] = append(children[*category.ParentID], category)
+		}
+	}
+
+	var tree []Category
+	for _, category := range categories {
+		if category.ParentID == nil {
+			category.Children = children[category.ID]
+			tree = append(tree, category)
+		}
+	}
+
+	return tree
+}
@@ -0,0 +1,143 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	WalletTypeCash       = "cash"
+	WalletTypeCreditCard = "credit_card"
+
+	// CategoryCardPayment marks both sides of a card payment. It moves money
+	// between wallets, so summaries do not count it as spending or income.
+	CategoryCardPayment = "card_payment"
+
+	// CreditCardMinimumPaymentPercent is the share of the amount due a
+	// minimum payment covers.
+	CreditCardMinimumPaymentPercent = 10
+	// CreditCardReminderDays is how long before the due date the bot reminds.
+	CreditCardReminderDays = 3
+)
+
+type CreditCardRepository interface {
+	// Statement works out the card's last closed statement as of now.
+	Statement(ctx context.Context, wallet Wallet, now time.Time) (CreditCardStatement, error)
+	// Pay saves both sides of a card payment and their audit entries in one
+	// transaction.
+	Pay(ctx context.Context, from, to *Transaction, actor AuditActor) error
+	// Remind notifies users about card payments due within CreditCardReminderDays.
+	Remind(ctx context.Context, now time.Time) (int, error)
+}
+
+// CreditCardStatement describes a card as of its last statement closing.
+// Amounts owed are positive.
+type CreditCardStatement struct {
+	WalletID         string          `json:"wallet_id"`
+	PeriodStart      time.Time       `json:"period_start"`
+	ClosingDate      time.Time       `json:"closing_date"`
+	DueDate          time.Time       `json:"due_date"`
+	StatementBalance decimal.Decimal `json:"statement_balance"`  // owed when the statement closed
+	PaidSinceClosing decimal.Decimal `json:"paid_since_closing"` // payments and refunds after closing
+	AmountDue        decimal.Decimal `json:"amount_due"`
+	MinimumPayment   decimal.Decimal `json:"minimum_payment"`
+	CurrentBalance   decimal.Decimal `json:"current_balance"` // owed right now
+	CreditLimit      decimal.Decimal `json:"credit_limit"`
+	AvailableCredit  decimal.Decimal `json:"available_credit"`
+}
+
+type CreditCardPaymentInput struct {
+	FromWalletID string          `json:"from_wallet_id" validate:"required"`
+	Amount       decimal.Decimal `json:"amount"`
+	PaidAt       time.Time       `json:"paid_at"` // now when empty
+	Description  string          `json:"description"`
+}
+
+// Transactions turns a payment into its two sides: money leaving the bank
+// wallet and arriving on the card, linked by a transfer id.
+func (in *CreditCardPaymentInput) Transactions(userID string, card Wallet) (Transaction, Transaction) {
+	transferID := ulid.Make().String()
+
+	if in.PaidAt.IsZero() {
+		in.PaidAt = time.Now()
+	}
+
+	if in.Description == "" {
+		in.Description = "Payment for " + card.Name
+	}
+
+	side := func(walletID, transactionType string) Transaction {
+		return Transaction{
+			ID:              ulid.Make().String(),
+			UserID:          userID,
+			WalletID:        walletID,
+			Category:        CategoryCardPayment,
+			TransactionType: transactionType,
+			Description:     in.Description,
+			SpentAt:         in.PaidAt,
+			Amount:          in.Amount,
+			TransferID:      &transferID,
+		}
+	}
+
+	return side(in.FromWalletID, TransactionTypeExpense), side(card.ID, TransactionTypeIncome)
+}
+
+// StatementDates gives the period of the last statement that closed on or
+// before now, and when it is due. Closing and due days past the end of a
+// short month fall on its last day.
+func (w *Wallet) StatementDates(now time.Time) (start, closing, due time.Time) {
+	loc := now.Location()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+	closing = dayOfMonth(today.Year(), today.Month(), w.StatementClosingDay, loc)
+	if closing.After(today) {
+		closing = dayOfMonth(today.Year(), today.Month()-1, w.StatementClosingDay, loc)
+	}
+
+	previous := dayOfMonth(closing.Year(), closing.Month()-1, w.StatementClosingDay, loc)
+	start = previous.AddDate(0, 0, 1)
+
+	due = dayOfMonth(closing.Year(), closing.Month(), w.PaymentDueDay, loc)
+	if !due.After(closing) {
+		due = dayOfMonth(closing.Year(), closing.Month()+1, w.PaymentDueDay, loc)
+	}
+
+	return start, closing, due
+}
+
+// NewCreditCardStatement fills a statement from the card's balances, which
+// are negative while money is owed: the balance at the end of the closing
+// day, the credits booked after it, and the balance now.
+func NewCreditCardStatement(wallet Wallet, now time.Time, closingBalance, paidSinceClosing, balance decimal.Decimal) CreditCardStatement {
+	start, closing, due := wallet.StatementDates(now)
+
+	statement := CreditCardStatement{
+		WalletID:         wallet.ID,
+		PeriodStart:      start,
+		ClosingDate:      closing,
+		DueDate:          due,
+		StatementBalance: decimal.Max(decimal.Zero, closingBalance.Neg()),
+		PaidSinceClosing: paidSinceClosing,
+		CurrentBalance:   balance.Neg(),
+		CreditLimit:      wallet.CreditLimit,
+	}
+
+	statement.AmountDue = decimal.Max(decimal.Zero, statement.StatementBalance.Sub(paidSinceClosing))
+	statement.MinimumPayment = statement.AmountDue.Mul(decimal.NewFromInt(CreditCardMinimumPaymentPercent)).Div(decimal.NewFromInt(100)).RoundCeil(2)
+	statement.AvailableCredit = decimal.Max(decimal.Zero, wallet.CreditLimit.Sub(statement.CurrentBalance))
+
+	return statement
+}
+
+// dayOfMonth is the given day of a month, or the month's last day when it
+// is shorter. Months out of range roll over into the next or previous year.
+func dayOfMonth(year int, month time.Month, day int, loc *time.Location) time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, loc)
+	last := first.AddDate(0, 1, -1).Day()
+
+	return first.AddDate(0, 0, min(day, last)-1)
+}
@@ -4,7 +4,74 @@ import "errors"
 
 var (
 	ErrGoogleNoIdToken  = errors.New("no id_token field in oauth2 token")
+	ErrOIDCNoIdToken    = errors.New("no id_token in the provider's token response")
 	ErrInvalidAuthClaim = errors.New("invalid auth claim")
 	ErrRegisterRequired = errors.New("register required")
 	ErrForbidden        = errors.New("forbidden request")
+
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reused")
+	ErrSessionRevoked      = errors.New("session revoked")
+
+	ErrEmailTaken         = errors.New("email already registered")
+	ErrEmailNotAllowed    = errors.New("email is not in the list of valid emails")
+	ErrEmailNotVerified   = errors.New("email not verified")
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	ErrInvalidToken       = errors.New("invalid or expired token")
+	ErrIdentityLinked     = errors.New("identity already linked to another account")
+
+	ErrUnknownProvider   = errors.New("unknown identity provider")
+	ErrEmailNotConfirmed = errors.New("identity provider did not confirm the email address")
+
+	ErrMFANotEnrolled   = errors.New("two-factor authentication is not enrolled")
+	ErrMFAAlreadyActive = errors.New("two-factor authentication is already enabled")
+	ErrInvalidMFACode   = errors.New("invalid two-factor code")
+	ErrMFALocked        = errors.New("too many invalid two-factor codes, try again later")
+
+	ErrRateNotFound      = errors.New("exchange rate not found")
+	ErrBaseCurrencyInUse = errors.New("base currency cannot change once transactions are recorded in it")
+
+	ErrInvalidSchedule = errors.New("invalid recurrence schedule")
+
+	ErrImportNotPending   = errors.New("import is not awaiting commit")
+	ErrImportNotCommitted = errors.New("import was not committed")
+	ErrUnsupportedFormat  = errors.New("unsupported file format")
+
+	ErrUnsupportedArchive = errors.New("unsupported archive version")
+
+	ErrInvalidTag = errors.New("tag name must contain a letter or digit")
+	ErrTagExists  = errors.New("tag already exists")
+
+	ErrUnknownCategory     = errors.New("unknown category")
+	ErrInvalidCategoryName = errors.New("category name must contain a letter or digit")
+	ErrCategoryExists      = errors.New("a category with this name already exists, merge them instead")
+	ErrCategoryInUse       = errors.New("category is still used, merge it into another one instead")
+	ErrCategoryProtected   = errors.New("the other category cannot be merged away or deleted")
+	ErrInvalidCategoryTree = errors.New("categories can only be nested one level deep")
+
+	ErrInvalidPayeeName = errors.New("payee name must contain a letter or digit")
+	ErrPayeeExists      = errors.New("payee already exists")
+	ErrPayeeAliasTaken  = errors.New("alias already belongs to another payee")
+
+	ErrFileNotFound          = errors.New("file not found")
+	ErrUnsupportedAttachment = errors.New("only JPEG, PNG, GIF, WebP images and PDF documents can be attached")
+	ErrTooManyAttachments    = errors.New("transaction has too many attachments")
+
+	ErrUnknownTrashType   = errors.New("unknown trash item type")
+	ErrTrashParentDeleted = errors.New("the wallet of this transaction is in the trash, restore it first")
+
+	ErrInvalidWalletDeleteMode = errors.New("mode must be move, archive or delete")
+	ErrInvalidTargetWallet     = errors.New("moving transactions needs another wallet to move them to")
+
+	ErrInvalidWalletType  = errors.New("wallet type must be cash or credit_card")
+	ErrInvalidCreditCard  = errors.New("credit cards need a positive limit and closing and due days between 1 and 31")
+	ErrNotCreditCard      = errors.New("wallet is not a credit card")
+	ErrInvalidCardPayment = errors.New("card payments need a positive amount from a wallet that is not a credit card")
+
+	ErrInvalidGoal             = errors.New("goals need a positive target amount and a YYYY-MM-DD deadline, if any")
+	ErrInvalidGoalWallet       = errors.New("goals cannot track a credit card")
+	ErrGoalTracksWallet        = errors.New("this goal follows its wallet balance, add money to the wallet instead")
+	ErrInvalidGoalContribution = errors.New("contributions need a non-zero amount")
+
+	ErrInvalidAssetValue = errors.New("asset value cannot be negative, record debts as a liability")
 )
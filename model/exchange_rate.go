@@ -0,0 +1,94 @@
+package model
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	RateSourceManual = "manual"
+	RateSourceRemote = "remote"
+)
+
+// RateProvider returns how many units of quote one unit of base buys on date.
+type RateProvider interface {
+	Rate(ctx context.Context, base, quote string, date time.Time) (ExchangeRate, error)
+}
+
+type ExchangeRateRepository interface {
+	RateProvider
+	FindAll(ctx context.Context, query ExchangeRateQueryInput) ([]ExchangeRate, int64, error)
+	Save(ctx context.Context, rate *ExchangeRate) error
+	Delete(ctx context.Context, id int64) error
+}
+
+type ExchangeRate struct {
+	ID        int64           `json:"id"`
+	Base      string          `json:"base"`
+	Quote     string          `json:"quote"`
+	Rate      decimal.Decimal `json:"rate" gorm:"type:numeric(20,10)"`
+	RateDate  time.Time       `json:"rate_date" gorm:"type:date"`
+	Source    string          `json:"source"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+type ExchangeRateInput struct {
+	Base     string          `json:"base" validate:"required,len=3,alpha"`
+	Quote    string          `json:"quote" validate:"required,len=3,alpha"`
+	Rate     decimal.Decimal `json:"rate"`
+	RateDate string          `json:"rate_date" validate:"required"` // format: "2006-01-02"
+}
+
+type ExchangeRateQueryInput struct {
+	Base  string `query:"base"`
+	Quote string `query:"quote"`
+	PaginatedRequest
+}
+
+// ConvertTransaction fills the base-currency Amount of t from its original
+// currency and amount, using the rate on the day the money was spent. Shares
+// are taken to be in the original currency and are converted at the same rate.
+func ConvertTransaction(ctx context.Context, provider RateProvider, t *Transaction, baseCurrency string) error {
+	baseCurrency = strings.ToUpper(baseCurrency)
+	t.Currency = strings.ToUpper(t.Currency)
+
+	spentAt := t.SpentAt
+	if spentAt.IsZero() {
+		spentAt = time.Now()
+	}
+
+	if t.Amount.IsZero() {
+		t.Amount = t.OriginalAmount
+	}
+
+	if t.Currency == "" || t.Currency == baseCurrency {
+		t.Currency = baseCurrency
+		t.OriginalAmount = t.Amount
+		t.ExchangeRate = decimal.NewFromInt(1)
+		t.RateDate = &spentAt
+
+		return nil
+	}
+
+	if t.OriginalAmount.IsZero() {
+		t.OriginalAmount = t.Amount
+	}
+
+	exchangeRate, err := provider.Rate(ctx, t.Currency, baseCurrency, spentAt)
+	if err != nil {
+		return err
+	}
+
+	for i := range t.TransactionShares {
+		t.TransactionShares[i].Amount = t.TransactionShares[i].Amount.Mul(exchangeRate.Rate).Round(2)
+	}
+
+	t.ExchangeRate = exchangeRate.Rate
+	t.RateDate = &exchangeRate.RateDate
+	t.Amount = t.OriginalAmount.Mul(exchangeRate.Rate).Round(2)
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package model
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+const (
+	ExportFormatCSV   = "csv"
+	ExportFormatXLSX  = "xlsx"
+	ExportFormatJSONL = "jsonl"
+	ExportFormatPDF   = "pdf"
+
+	ExportKindTransactions = "transactions"
+	ExportKindStatement    = "statement"
+
+	ExportStatusPending = "PENDING"
+	ExportStatusRunning = "RUNNING"
+	ExportStatusDone    = "DONE"
+	ExportStatusFailed  = "FAILED"
+
+	// ExportSyncLimit is the largest export streamed in the request itself;
+	// anything bigger becomes a background job.
+	ExportSyncLimit = 5000
+	// ExportTTL is how long a finished export stays downloadable.
+	ExportTTL = 24 * time.Hour
+)
+
+var ExportContentTypes = map[string]string{
+	ExportFormatCSV:   "text/csv",
+	ExportFormatXLSX:  "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	ExportFormatJSONL: "application/x-ndjson",
+	ExportFormatPDF:   "application/pdf",
+}
+
+type ExportRepository interface {
+	CountTransactions(ctx context.Context, query TransactionQueryInput) (int64, error)
+	// WriteTransactions streams the filtered transactions to w in the given format.
+	WriteTransactions(ctx context.Context, w io.Writer, format string, query TransactionQueryInput) error
+	// WriteStatement renders a monthly PDF statement for one wallet.
+	WriteStatement(ctx context.Context, w io.Writer, query StatementQueryInput) error
+
+	CreateJob(ctx context.Context, job *ExportJob) error
+	FindJobs(ctx context.Context, userID string) ([]ExportJob, error)
+	FindJobByID(ctx context.Context, id string) (ExportJob, error)
+	// RunPendingJobs renders queued jobs into dir and removes expired files.
+	RunPendingJobs(ctx context.Context, dir string) error
+}
+
+type ExportJob struct {
+	ID         string       `json:"id" gorm:"primaryKey"`
+	UserID     string       `json:"user_id"`
+	Kind       string       `json:"kind"`
+	Format     string       `json:"format"`
+	Params     ExportParams `json:"params" gorm:"serializer:json"`
+	Status     string       `json:"status"`
+	FileName   string       `json:"file_name"`
+	FilePath   string       `json:"-"`
+	Size       int64        `json:"size"`
+	Error      string       `json:"error,omitempty"`
+	ExpiresAt  *time.Time   `json:"expires_at"`
+	FinishedAt *time.Time   `json:"finished_at"`
+	CreatedAt  time.Time    `json:"created_at"`
+	UpdatedAt  time.Time    `json:"updated_at"`
+}
+
+// ExportParams keeps the request of a background job so the worker can
+// replay it; exactly one of the fields is set, matching Kind.
+type ExportParams struct {
+	Transactions *TransactionQueryInput `json:"transactions,omitempty"`
+	Statement    *StatementQueryInput   `json:"statement,omitempty"`
+}
+
+type ExportQueryInput struct {
+	Format string `query:"format"`
+	Async  bool   `query:"async"`
+	TransactionQueryInput
+}
+
+type StatementQueryInput struct {
+	WalletID string `query:"wallet_id" json:"wallet_id"`
+	Month    string `query:"month" json:"month"` // format: "2006-01"
+	Async    bool   `query:"async" json:"-"`
+}
@@ -0,0 +1,153 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// GoalHistoryMonths is how far back contributions are averaged to project
+// when a goal will be reached.
+const GoalHistoryMonths = 6
+
+// GoalMilestones are the percentages of a target the bot celebrates.
+var GoalMilestones = []int{25, 50, 75, 100}
+
+type GoalRepository interface {
+	Create(ctx context.Context, goal *Goal) error
+	FindAll(ctx context.Context, userID string) ([]Goal, error)
+	FindByID(ctx context.Context, id string) (Goal, error)
+	Update(ctx context.Context, goal Goal) error
+	// Delete removes the goal with its contributions; a linked wallet stays.
+	Delete(ctx context.Context, id string) error
+
+	AddContribution(ctx context.Context, contribution *GoalContribution) error
+	FindContributions(ctx context.Context, goalID string) ([]GoalContribution, error)
+	FindContributionByID(ctx context.Context, id string) (GoalContribution, error)
+	DeleteContribution(ctx context.Context, id string) error
+
+	// Progress works out how far along the goal is as of now.
+	Progress(ctx context.Context, goal Goal, now time.Time) (GoalProgress, error)
+	// Celebrate tells users through the bot about milestones their goals passed.
+	Celebrate(ctx context.Context, now time.Time) (int, error)
+}
+
+// Goal is an amount the user saves towards. Money saved is either the
+// balance of a linked wallet or the sum of contributions logged by hand.
+type Goal struct {
+	ID            string             `json:"id" gorm:"primaryKey"`
+	UserID        string             `json:"user_id"`
+	Name          string             `json:"name"`
+	TargetAmount  decimal.Decimal    `json:"target_amount" gorm:"type:numeric(20,2)"` // in the user's base currency
+	Deadline      *time.Time         `json:"deadline" gorm:"type:date"`
+	WalletID      *string            `json:"wallet_id"`
+	Milestone     int                `json:"milestone"` // highest percentage celebrated so far
+	Contributions []GoalContribution `json:"contributions,omitempty" gorm:"foreignKey:GoalID"`
+	Progress      *GoalProgress      `json:"progress,omitempty" gorm:"-"`
+	CreatedAt     time.Time          `json:"created_at"`
+	UpdatedAt     time.Time          `json:"updated_at"`
+}
+
+type GoalContribution struct {
+	ID            string          `json:"id" gorm:"primaryKey"`
+	GoalID        string          `json:"goal_id"`
+	UserID        string          `json:"-"`
+	Amount        decimal.Decimal `json:"amount" gorm:"type:numeric(20,2)"` // negative for a withdrawal
+	ContributedAt time.Time       `json:"contributed_at"`
+	Note          string          `json:"note"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+type GoalInput struct {
+	Name         string          `json:"name" validate:"required"`
+	TargetAmount decimal.Decimal `json:"target_amount"`
+	Deadline     string          `json:"deadline"`  // YYYY-MM-DD, empty for none
+	WalletID     string          `json:"wallet_id"` // empty to track contributions instead
+}
+
+type GoalContributionInput struct {
+	Amount        decimal.Decimal `json:"amount"`
+	ContributedAt time.Time       `json:"contributed_at"` // now when empty
+	Note          string          `json:"note"`
+}
+
+// GoalProgress is where a goal stands. Monthly figures use 30-day months.
+type GoalProgress struct {
+	Saved          decimal.Decimal `json:"saved"`
+	Remaining      decimal.Decimal `json:"remaining"`
+	Percent        decimal.Decimal `json:"percent"`
+	Reached        bool            `json:"reached"`
+	AverageMonthly decimal.Decimal `json:"average_monthly"` // over the last GoalHistoryMonths months
+	// RequiredMonthly is what is left per month to make the deadline, or
+	// nil without one.
+	RequiredMonthly *decimal.Decimal `json:"required_monthly"`
+	// ProjectedDate is when the goal is reached at the average pace, or nil
+	// when nothing is being put aside.
+	ProjectedDate *time.Time `json:"projected_date"`
+	OnTrack       bool       `json:"on_track"` // reached, or projected to be by the deadline
+}
+
+// HistoryStart is where the averaging window begins: GoalHistoryMonths ago,
+// or when the goal was set if that is more recent.
+func (g *Goal) HistoryStart(now time.Time) time.Time {
+	start := now.AddDate(0, -GoalHistoryMonths, 0)
+	if g.CreatedAt.After(start) {
+		return g.CreatedAt
+	}
+
+	return start
+}
+
+// NewGoalProgress works out a goal's progress from what is saved in total
+// and what was put aside since HistoryStart.
+func NewGoalProgress(goal Goal, now time.Time, saved, recent decimal.Decimal) GoalProgress {
+	progress := GoalProgress{
+		Saved:     saved,
+		Remaining: decimal.Max(decimal.Zero, goal.TargetAmount.Sub(saved)),
+		Reached:   saved.GreaterThanOrEqual(goal.TargetAmount),
+	}
+
+	if goal.TargetAmount.IsPositive() {
+		progress.Percent = saved.Div(goal.TargetAmount).Mul(decimal.NewFromInt(100)).Round(2)
+	}
+
+	progress.AverageMonthly = recent.Div(monthsBetween(goal.HistoryStart(now), now)).Round(2)
+
+	if goal.Deadline != nil {
+		required := progress.Remaining.Div(monthsBetween(now, *goal.Deadline)).RoundCeil(2)
+		progress.RequiredMonthly = &required
+	}
+
+	switch {
+	case progress.Reached:
+		progress.OnTrack = true
+	case progress.AverageMonthly.IsPositive():
+		days := progress.Remaining.Div(progress.AverageMonthly).Mul(decimal.NewFromInt(30)).Ceil().IntPart()
+		projected := now.AddDate(0, 0, int(days))
+		progress.ProjectedDate = &projected
+		progress.OnTrack = goal.Deadline == nil || !projected.After(goal.Deadline.AddDate(0, 0, 1))
+	}
+
+	return progress
+}
+
+// Milestone is the highest of GoalMilestones the progress has passed, or 0.
+func (p *GoalProgress) Milestone() int {
+	reached := 0
+	for _, milestone := range GoalMilestones {
+		if p.Percent.GreaterThanOrEqual(decimal.NewFromInt(int64(milestone))) {
+			reached = milestone
+		}
+	}
+
+	return reached
+}
+
+// monthsBetween counts 30-day months, at least one so that a goal set
+// yesterday or due tomorrow still divides sensibly.
+func monthsBetween(from, to time.Time) decimal.Decimal {
+	months := decimal.NewFromFloat(to.Sub(from).Hours() / 24 / 30)
+
+	return decimal.Max(decimal.NewFromInt(1), months)
+}
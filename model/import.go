@@ -0,0 +1,153 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/notblessy/anggar-service/utils"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	ImportFormatCSV = "csv"
+	ImportFormatOFX = "ofx"
+	ImportFormatQFX = "qfx"
+	ImportFormatQIF = "qif"
+
+	ImportStatusPreview   = "PREVIEW"
+	ImportStatusCommitted = "COMMITTED"
+	ImportStatusUndone    = "UNDONE"
+
+	// ImportMaxFileSize and ImportMaxRows keep a single upload reasonable.
+	ImportMaxFileSize = 5 << 20
+	ImportMaxRows     = 5000
+
+	// ImportDuplicateThreshold is the description similarity above which a row
+	// on the same day with the same amount is treated as already recorded.
+	ImportDuplicateThreshold = 0.6
+)
+
+type ImportRepository interface {
+	FindMappings(ctx context.Context, userID string) ([]ImportMapping, error)
+	FindMappingByID(ctx context.Context, id string) (ImportMapping, error)
+	SaveMapping(ctx context.Context, mapping *ImportMapping) error
+	DeleteMapping(ctx context.Context, id string) error
+
+	FindAll(ctx context.Context, query ImportQueryInput) ([]ImportBatch, int64, error)
+	FindByID(ctx context.Context, id string) (ImportBatch, error)
+	CreateBatch(ctx context.Context, batch *ImportBatch) error
+	// FindCandidates lists the user's transactions around the given days, for duplicate detection.
+	FindCandidates(ctx context.Context, userID string, start, end time.Time) ([]Transaction, error)
+	// Commit stores the transactions and marks the batch committed atomically.
+	Commit(ctx context.Context, batch *ImportBatch, transactions []Transaction) error
+	// Undo removes every transaction created by the batch.
+	Undo(ctx context.Context, batch *ImportBatch) (int64, error)
+}
+
+// ImportMapping is a saved CSV column layout, usually one per bank.
+type ImportMapping struct {
+	ID                string    `json:"id" gorm:"primaryKey"`
+	UserID            string    `json:"user_id"`
+	Name              string    `json:"name"`
+	Delimiter         string    `json:"delimiter"`
+	HasHeader         bool      `json:"has_header"`
+	SkipRows          int       `json:"skip_rows"`
+	DateColumn        string    `json:"date_column"`
+	DateFormat        string    `json:"date_format"` // Go layout, e.g. "02/01/2006"
+	DescriptionColumn string    `json:"description_column"`
+	AmountColumn      string    `json:"amount_column"`
+	DebitColumn       string    `json:"debit_column"`
+	CreditColumn      string    `json:"credit_column"`
+	DecimalSeparator  string    `json:"decimal_separator"`
+	InvertSign        bool      `json:"invert_sign"`
+	Currency          string    `json:"currency"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// CSVOptions converts the saved mapping into parser options.
+func (m *ImportMapping) CSVOptions() utils.CSVStatementOptions {
+	delimiter := ','
+	if m.Delimiter == `\t` || m.Delimiter == "tab" {
+		delimiter = '\t'
+	} else if runes := []rune(m.Delimiter); len(runes) > 0 {
+		delimiter = runes[0]
+	}
+
+	return utils.CSVStatementOptions{
+		Delimiter:         delimiter,
+		HasHeader:         m.HasHeader,
+		SkipRows:          m.SkipRows,
+		DateColumn:        m.DateColumn,
+		DateFormat:        m.DateFormat,
+		DescriptionColumn: m.DescriptionColumn,
+		AmountColumn:      m.AmountColumn,
+		DebitColumn:       m.DebitColumn,
+		CreditColumn:      m.CreditColumn,
+		DecimalSeparator:  m.DecimalSeparator,
+		InvertSign:        m.InvertSign,
+	}
+}
+
+type ImportMappingInput struct {
+	Name              string `json:"name" validate:"required,max=100"`
+	Delimiter         string `json:"delimiter" validate:"max=3"`
+	HasHeader         bool   `json:"has_header"`
+	SkipRows          int    `json:"skip_rows" validate:"min=0,max=100"`
+	DateColumn        string `json:"date_column" validate:"required"`
+	DateFormat        string `json:"date_format" validate:"required"`
+	DescriptionColumn string `json:"description_column" validate:"required"`
+	AmountColumn      string `json:"amount_column" validate:"required_without_all=DebitColumn CreditColumn"`
+	DebitColumn       string `json:"debit_column" validate:"required_with=CreditColumn"`
+	CreditColumn      string `json:"credit_column" validate:"required_with=DebitColumn"`
+	DecimalSeparator  string `json:"decimal_separator" validate:"omitempty,oneof=. ,"`
+	InvertSign        bool   `json:"invert_sign"`
+	Currency          string `json:"currency" validate:"omitempty,len=3,alpha"`
+}
+
+// ImportBatch is one uploaded statement. Its rows are kept from preview until
+// commit, and every transaction it creates carries its id so it can be undone.
+type ImportBatch struct {
+	ID               string      `json:"id" gorm:"primaryKey"`
+	UserID           string      `json:"user_id"`
+	WalletID         string      `json:"wallet_id"`
+	MappingID        *string     `json:"mapping_id"`
+	Format           string      `json:"format"`
+	FileName         string      `json:"file_name"`
+	Status           string      `json:"status"`
+	Rows             []ImportRow `json:"rows,omitempty" gorm:"serializer:json"`
+	TransactionCount int         `json:"transaction_count"`
+	CommittedAt      *time.Time  `json:"committed_at"`
+	UndoneAt         *time.Time  `json:"undone_at"`
+	CreatedAt        time.Time   `json:"created_at"`
+	UpdatedAt        time.Time   `json:"updated_at"`
+}
+
+type ImportRow struct {
+	utils.StatementEntry
+	TransactionType string          `json:"transaction_type"`
+	AbsoluteAmount  decimal.Decimal `json:"absolute_amount"`
+	Duplicate       bool            `json:"duplicate"`
+	DuplicateOf     string          `json:"duplicate_of,omitempty"`
+	Similarity      float64         `json:"similarity,omitempty"`
+}
+
+type ImportPreviewInput struct {
+	WalletID   string `form:"wallet_id"`
+	Format     string `form:"format"` // csv, ofx, qfx or qif; guessed from the file name when empty
+	MappingID  string `form:"mapping_id"`
+	DateFormat string `form:"date_format"` // QIF only
+	Currency   string `form:"currency"`
+}
+
+type ImportCommitInput struct {
+	// SkipLines lists rows to leave out; duplicates are left out unless IncludeDuplicates is set.
+	SkipLines         []int  `json:"skip_lines"`
+	IncludeDuplicates bool   `json:"include_duplicates"`
+	Category          string `json:"category"`
+}
+
+type ImportQueryInput struct {
+	UserID string
+	PaginatedRequest
+}
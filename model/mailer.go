@@ -0,0 +1,14 @@
+package model
+
+import "context"
+
+// Mailer delivers transactional email such as verification and password reset links.
+type Mailer interface {
+	Send(ctx context.Context, mail Mail) error
+}
+
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+}
@@ -0,0 +1,90 @@
+package model
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	RecoveryCodeCount = 10
+	MFAChallengeTTL   = 5 * time.Minute
+
+	// MFAMaxFailures wrong codes in a row lock the second factor for
+	// MFALockoutDuration. Six digits are otherwise guessable one challenge
+	// token after another.
+	MFAMaxFailures     = 5
+	MFALockoutDuration = 15 * time.Minute
+)
+
+type MFARepository interface {
+	FindTOTP(ctx context.Context, userID string) (UserTOTP, error)
+	// Enroll stores a new unconfirmed secret, replacing any pending enrolment.
+	Enroll(ctx context.Context, userID, secret string) error
+	Confirm(ctx context.Context, userID string, step int64) error
+	// MarkStepUsed records the last accepted time step and fails when it was already used.
+	MarkStepUsed(ctx context.Context, userID string, step int64) error
+	Disable(ctx context.Context, userID string) error
+	// RecordFailure counts a wrong code and locks the second factor once
+	// MFAMaxFailures were counted in a row.
+	RecordFailure(ctx context.Context, userID string, now time.Time) error
+	ResetFailures(ctx context.Context, userID string) error
+
+	// ReplaceRecoveryCodes drops every previous code and stores the hashes of codes.
+	ReplaceRecoveryCodes(ctx context.Context, userID string, codes []string) error
+	UseRecoveryCode(ctx context.Context, userID, code string) error
+	CountRecoveryCodes(ctx context.Context, userID string) (int64, error)
+}
+
+type UserTOTP struct {
+	UserID         string     `json:"user_id" gorm:"primaryKey"`
+	Secret         string     `json:"-"`
+	ConfirmedAt    *time.Time `json:"confirmed_at"`
+	LastUsedStep   int64      `json:"-"`
+	FailedAttempts int        `json:"-"`
+	LockedUntil    *time.Time `json:"locked_until"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+func (UserTOTP) TableName() string {
+	return "user_totps"
+}
+
+// Enabled reports whether the secret was confirmed and is enforced at login.
+func (t *UserTOTP) Enabled() bool {
+	return t.ConfirmedAt != nil
+}
+
+// Locked reports whether too many wrong codes were entered recently.
+func (t *UserTOTP) Locked(now time.Time) bool {
+	return t.LockedUntil != nil && now.Before(*t.LockedUntil)
+}
+
+type RecoveryCode struct {
+	ID        int64      `json:"id"`
+	UserID    string     `json:"user_id"`
+	CodeHash  string     `json:"-"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type MFAStatus struct {
+	Enabled           bool       `json:"enabled"`
+	Pending           bool       `json:"pending"`
+	ConfirmedAt       *time.Time `json:"confirmed_at"`
+	RecoveryCodesLeft int64      `json:"recovery_codes_left"`
+}
+
+type TOTPEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+type MFACodeRequest struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type MFAChallengeRequest struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	MFACodeRequest
+}
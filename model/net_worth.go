@@ -0,0 +1,104 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	AssetKindAsset     = "asset"
+	AssetKindLiability = "liability"
+
+	SnapshotSourceWallet = "wallet"
+	SnapshotSourceAsset  = "asset"
+
+	// NetWorthDefaultDays is how much history the series covers when no
+	// dates are given.
+	NetWorthDefaultDays = 90
+)
+
+type NetWorthRepository interface {
+	CreateAsset(ctx context.Context, asset *Asset) error
+	FindAllAssets(ctx context.Context, userID string) ([]Asset, error)
+	FindAssetByID(ctx context.Context, id string) (Asset, error)
+	UpdateAsset(ctx context.Context, asset Asset) error
+	DeleteAsset(ctx context.Context, id string) error
+
+	// Snapshot records the user's wallet balances and asset values for the
+	// local date of now, replacing what was recorded for it earlier.
+	Snapshot(ctx context.Context, userID string, now time.Time) error
+	// SnapshotAll takes today's snapshot for every user with a wallet or asset.
+	SnapshotAll(ctx context.Context, now time.Time) (int, error)
+	// Series reads the recorded snapshots back as daily net-worth points.
+	Series(ctx context.Context, query NetWorthQueryInput) ([]NetWorthPoint, error)
+}
+
+// Asset is something the user owns or owes outside of their wallets, like a
+// vehicle or a loan, valued by hand.
+type Asset struct {
+	ID        string          `json:"id" gorm:"primaryKey"`
+	UserID    string          `json:"user_id"`
+	Name      string          `json:"name"`
+	Kind      string          `json:"kind"`                            // asset or liability
+	Value     decimal.Decimal `json:"value" gorm:"type:numeric(20,2)"` // in the user's base currency, positive for both kinds
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+type AssetInput struct {
+	Name  string          `json:"name" validate:"required"`
+	Kind  string          `json:"kind" validate:"required,oneof=asset liability"`
+	Value decimal.Decimal `json:"value"`
+}
+
+// BalanceSnapshot is the balance of one wallet or asset at the end of a day.
+// Liabilities are stored negative so a day's rows add up to its net worth.
+type BalanceSnapshot struct {
+	UserID     string          `json:"-"`
+	Date       time.Time       `json:"date" gorm:"type:date"`
+	SourceType string          `json:"source_type"`
+	SourceID   string          `json:"source_id"`
+	Name       string          `json:"name"` // kept so deleted wallets still read well
+	Balance    decimal.Decimal `json:"balance" gorm:"type:numeric(20,2)"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+type NetWorthQueryInput struct {
+	StartDate string `query:"start_date"`
+	EndDate   string `query:"end_date"`
+	UserID    string
+}
+
+// NetWorthPoint is the user's net worth on one day with what made it up.
+type NetWorthPoint struct {
+	Date        time.Time         `json:"date"`
+	Assets      decimal.Decimal   `json:"assets"`      // everything with a positive balance
+	Liabilities decimal.Decimal   `json:"liabilities"` // everything owed, as a positive amount
+	NetWorth    decimal.Decimal   `json:"net_worth"`
+	Breakdown   []BalanceSnapshot `json:"breakdown"`
+}
+
+// NewNetWorthSeries groups snapshots ordered by date into one point per day.
+func NewNetWorthSeries(snapshots []BalanceSnapshot) []NetWorthPoint {
+	points := []NetWorthPoint{}
+
+	for _, snapshot := range snapshots {
+		if len(points) == 0 || !points[len(points)-1].Date.Equal(snapshot.Date) {
+			points = append(points, NetWorthPoint{Date: snapshot.Date, Breakdown: []BalanceSnapshot{}})
+		}
+
+		point := &points[len(points)-1]
+		point.Breakdown = append(point.Breakdown, snapshot)
+		point.NetWorth = point.NetWorth.Add(snapshot.Balance)
+
+		if snapshot.Balance.IsNegative() {
+			point.Liabilities = point.Liabilities.Sub(snapshot.Balance)
+		} else {
+			point.Assets = point.Assets.Add(snapshot.Balance)
+		}
+	}
+
+	return points
+}
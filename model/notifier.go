@@ -0,0 +1,9 @@
+package model
+
+import "context"
+
+// Notifier pushes a short message to a user outside of a request, e.g. a
+// reminder from the bot. Users without a linked chat are skipped silently.
+type Notifier interface {
+	Notify(ctx context.Context, userID, text string) error
+}
@@ -0,0 +1,49 @@
+package model
+
+import "context"
+
+// OIDCProvider signs users in through any OpenID Connect issuer.
+type OIDCProvider interface {
+	Name() string
+	DisplayName() string
+	AuthCodeURL(ctx context.Context, state, redirectURL string) (string, error)
+	// Exchange trades an authorization code for a validated ID token and maps its claims.
+	Exchange(ctx context.Context, code, redirectURL string) (ExternalAuthInfo, error)
+}
+
+type OIDCProviderConfig struct {
+	Name         string
+	DisplayName  string
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	EmailClaim   string
+	NameClaim    string
+	PictureClaim string
+}
+
+// ExternalAuthInfo is the identity asserted by an external provider.
+type ExternalAuthInfo struct {
+	Provider      string `json:"provider"`
+	Subject       string `json:"sub"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	Picture       string `json:"picture"`
+}
+
+type OIDCAuthRequest struct {
+	Code        string `json:"code" validate:"required"`
+	RedirectURI string `json:"redirect_uri"`
+}
+
+type OIDCProviderOption struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+}
+
+// OIDCIdentity is the user_identities provider value for a configured OIDC provider.
+func OIDCIdentity(name string) string {
+	return "oidc:" + name
+}
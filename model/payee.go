@@ -0,0 +1,144 @@
+package model
+
+import (
+	"context"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	PayeeReportDefaultLimit = 10
+	PayeeReportMaxLimit     = 100
+)
+
+type PayeeRepository interface {
+	// Create saves the payee with its aliases.
+	Create(ctx context.Context, payee *Payee) error
+	FindAll(ctx context.Context, query PayeeQueryInput) ([]Payee, error)
+	FindByID(ctx context.Context, id string) (Payee, error)
+	// Update saves the payee and replaces its aliases.
+	Update(ctx context.Context, payee Payee) error
+	// Delete removes the payee; its transactions keep their description.
+	Delete(ctx context.Context, id string) error
+	Report(ctx context.Context, query PayeeReportQueryInput) ([]PayeeReport, error)
+}
+
+// Payee is a merchant or person money goes to or comes from. Transactions
+// whose description matches one of its aliases are linked to it, so "Grab",
+// "grabfood" and "GRAB*FOOD JKT" add up to the same payee.
+type Payee struct {
+	ID              string       `json:"id" gorm:"primaryKey"`
+	UserID          string       `json:"user_id"`
+	Name            string       `json:"name"`
+	DefaultCategory string       `json:"default_category"` // slug, used when a transaction comes without one
+	Aliases         []PayeeAlias `json:"aliases" gorm:"foreignKey:PayeeID"`
+	CreatedAt       time.Time    `json:"created_at"`
+	UpdatedAt       time.Time    `json:"updated_at"`
+}
+
+// PayeeAlias is a fragment of a description, kept normalized by PayeeKey.
+type PayeeAlias struct {
+	ID        string    `json:"id" gorm:"primaryKey"`
+	PayeeID   string    `json:"payee_id"`
+	UserID    string    `json:"-"`
+	Pattern   string    `json:"pattern"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type PayeeInput struct {
+	Name            string   `json:"name" validate:"required"`
+	DefaultCategory string   `json:"default_category"`
+	Aliases         []string `json:"aliases"` // the name is always an alias too
+}
+
+type PayeeQueryInput struct {
+	Keyword string `query:"keyword"`
+	UserID  string
+}
+
+type PayeeReportQueryInput struct {
+	StartDate string `query:"start_date"`
+	EndDate   string `query:"end_date"`
+	Period    string `query:"period"` // "week" or "month", used when no dates are given
+	Limit     int    `query:"limit"`
+	UserID    string
+	Timezone  string
+}
+
+// LimitOrDefault keeps the number of payees reported within bounds.
+func (q *PayeeReportQueryInput) LimitOrDefault() int {
+	if q.Limit <= 0 {
+		return PayeeReportDefaultLimit
+	}
+
+	if q.Limit > PayeeReportMaxLimit {
+		return PayeeReportMaxLimit
+	}
+
+	return q.Limit
+}
+
+type PayeeReport struct {
+	PayeeID      string          `json:"payee_id"`
+	Name         string          `json:"name"`
+	Transactions int64           `json:"transactions"`
+	Expense      decimal.Decimal `json:"expense"`
+	Income       decimal.Decimal `json:"income"`
+}
+
+// PayeeKey reduces a description to lowercase letters and digits, so
+// "GRAB*FOOD JKT" becomes "grabfoodjkt" and matches the alias "grabfood".
+func PayeeKey(text string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
+
+// PayeeAliases normalizes and de-duplicates the patterns of a payee,
+// starting with its name.
+func PayeeAliases(name string, aliases []string) []string {
+	seen := map[string]bool{}
+	var patterns []string
+
+	for _, alias := range append([]string{name}, aliases...) {
+		pattern := PayeeKey(alias)
+		if pattern == "" || seen[pattern] {
+			continue
+		}
+
+		seen[pattern] = true
+		patterns = append(patterns, pattern)
+	}
+
+	return patterns
+}
+
+// MatchPayee finds the payee a description belongs to. The longest matching
+// alias wins, so "grabfood" goes to GrabFood even when Grab has "grab".
+func MatchPayee(payees []Payee, description string) (Payee, bool) {
+	key := PayeeKey(description)
+	if key == "" {
+		return Payee{}, false
+	}
+
+	var match Payee
+	longest := 0
+
+	for _, payee := range payees {
+		for _, alias := range payee.Aliases {
+			if len(alias.Pattern) > longest && strings.Contains(key, alias.Pattern) {
+				match, longest = payee, len(alias.Pattern)
+			}
+		}
+	}
+
+	return match, longest > 0
+}
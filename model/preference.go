@@ -0,0 +1,90 @@
+package model
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	DefaultCurrency  = "IDR"
+	DefaultTimezone  = "Asia/Jakarta"
+	DefaultLocale    = "id-ID"
+	DefaultWeekStart = time.Monday
+
+	PeriodWeek  = "week"
+	PeriodMonth = "month"
+)
+
+type PreferenceRepository interface {
+	// Find returns the stored preferences, or the defaults when the user has none.
+	Find(ctx context.Context, userID string) (UserPreference, error)
+	// Upsert refuses to change the base currency with ErrBaseCurrencyInUse
+	// once the user has transactions converted to it.
+	Upsert(ctx context.Context, preference *UserPreference) error
+}
+
+type UserPreference struct {
+	UserID          string       `json:"user_id" gorm:"primaryKey"`
+	BaseCurrency    string       `json:"base_currency"`
+	Timezone        string       `json:"timezone"`
+	Locale          string       `json:"locale"`
+	WeekStart       time.Weekday `json:"week_start"`
+	DefaultWalletID string       `json:"default_wallet_id"`
+	CreatedAt       time.Time    `json:"created_at"`
+	UpdatedAt       time.Time    `json:"updated_at"`
+}
+
+// DefaultPreference is used for users who never saved their settings.
+func DefaultPreference(userID string) UserPreference {
+	return UserPreference{
+		UserID:       userID,
+		BaseCurrency: DefaultCurrency,
+		Timezone:     DefaultTimezone,
+		Locale:       DefaultLocale,
+		WeekStart:    DefaultWeekStart,
+	}
+}
+
+// Location loads the user's timezone, falling back to UTC when it is unknown.
+func (p *UserPreference) Location() *time.Location {
+	loc, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+
+	return loc
+}
+
+// Now is the current time in the user's timezone.
+func (p *UserPreference) Now() time.Time {
+	return time.Now().In(p.Location())
+}
+
+// PeriodRange returns the first and last local date of the week or month
+// containing t, formatted as "2006-01-02". Weeks begin on WeekStart.
+func (p *UserPreference) PeriodRange(period string, t time.Time) (string, string) {
+	t = t.In(p.Location())
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+
+	var start, end time.Time
+
+	switch period {
+	case PeriodWeek:
+		offset := (int(day.Weekday()) - int(p.WeekStart) + 7) % 7
+		start = day.AddDate(0, 0, -offset)
+		end = start.AddDate(0, 0, 6)
+	default:
+		start = time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, day.Location())
+		end = start.AddDate(0, 1, -1)
+	}
+
+	return start.Format("2006-01-02"), end.Format("2006-01-02")
+}
+
+type PreferenceInput struct {
+	BaseCurrency    string        `json:"base_currency" validate:"omitempty,len=3,alpha"`
+	Timezone        string        `json:"timezone"`
+	Locale          string        `json:"locale" validate:"omitempty,max=16"`
+	WeekStart       *time.Weekday `json:"week_start" validate:"omitempty,min=0,max=6"`
+	DefaultWalletID *string       `json:"default_wallet_id"`
+}
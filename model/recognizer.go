@@ -3,38 +3,36 @@ package model
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
 )
 
 type RecognizerRepository interface {
 	RecognizeTransaction(ctx context.Context, prompt, text string) (Transaction, error)
 }
 
-var CategoryMapper = map[string]string{
-	"utilities":      "Utilities",
-	"transportation": "Transportation",
-	"home":           "Home",
-	"shopping":       "Shopping",
-	"groceries":      "Groceries",
-	"entertainment":  "Entertainment",
-	"food":           "Food & Beverage",
-	"other":          "Other",
-}
-
-func SystemPrompt(meID, sharedID string) string {
+// SystemPrompt builds the recognizer instructions for a user, using their
+// currency, their own categories and the current time in their timezone so
+// relative dates resolve correctly.
+func SystemPrompt(meID, sharedID string, preference UserPreference, categories []Category) string {
 	return fmt.Sprintf(`
 		You are a finance message parser. Given a short, natural language message like "makan ayam 50000" or "uang freelance 200000", respond with a structured JSON that fits this format:
 		{
 			"id": "string",                       // generate as ulid
 			"description": "string",               // short description of the transaction
-			"amount": number,                      // numeric amount in IDR
+			"amount": number,                      // numeric amount in the currency below
+			"currency": "string",                  // ISO 4217 code if the message names one (e.g. "$5" is USD, "SGD 12" is SGD), otherwise %s
 			"transaction_type": "INCOME" | "EXPENSE", // determine based on message
 			"wallet_name": "string",               // if not mentioned, return "default"
 			"user_id": "string"                      // if not mentioned, return "self"
 			"is_shared": true | false,
-			"category": "string"                  // detect category from description but only in this value: utilities, transportation, home, shopping, groceries, entertainment, food, other. if unable to detect, return "other"
-			"spent_at": "2023-10-01T00:00:00Z" // if not mentioned, return current time
+			"category": "string"                  // detect category from description, answering with the slug of one of the categories listed below. if unable to detect, return "%s"
+			"spent_at": "2023-10-01T00:00:00Z" // if not mentioned, return current time. resolve words like "yesterday" or "kemarin" against current time
 		}
 
+		Categories, as slug: name (prefer the most specific one that fits):
+%s
+
 		if message contains (name <amount>, name <amount>), return is_shared: true,
 		you can assume the first name is me, and the second name is shared.
 		also assume the me name with %s ID and shared name with %s ID
@@ -48,12 +46,43 @@ func SystemPrompt(meID, sharedID string) string {
 			"transaction_id": "string", // id of the transaction
 			"user_id": "",
 			"percentage": number, // percentage of the share
-			"amount": number // amount of the share
+			"amount": number // amount of the share, in the same currency as the transaction
 		}
 		Assume:
 		- It's always an expense transaction
 		- Detect which description and which is amount
 
+		Current time is %s (%s).
+
 		Only respond with the JSON object. No explanation, no extra text.
-	`, meID, sharedID)
+	`, preference.BaseCurrency, FallbackCategory(categories), promptCategories(categories), meID, sharedID, preference.Now().Format(time.RFC3339), preference.Timezone)
+}
+
+// FallbackCategory is what unrecognised transactions are filed under: "other"
+// when the user still has it, otherwise their first category.
+func FallbackCategory(categories []Category) string {
+	if _, ok := CategoryBySlug(categories, CategoryOther); ok || len(categories) == 0 {
+		return CategoryOther
+	}
+
+	return categories[0].Slug
+}
+
+func promptCategories(categories []Category) string {
+	names := map[string]string{}
+	for _, category := range categories {
+		names[category.ID] = category.Name
+	}
+
+	var b strings.Builder
+	for _, category := range categories {
+		fmt.Fprintf(&b, "\t\t- %s: %s", category.Slug, category.Name)
+		if category.ParentID != nil {
+			fmt.Fprintf(&b, " (under %s)", names[*category.ParentID])
+		}
+
+		b.WriteString("\n")
+	}
+
+	return b.String()
 }
@@ -0,0 +1,165 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/notblessy/anggar-service/utils"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+const (
+	FrequencyDaily   = "DAILY"
+	FrequencyWeekly  = "WEEKLY"
+	FrequencyMonthly = "MONTHLY"
+	FrequencyYearly  = "YEARLY"
+	FrequencyRRule   = "RRULE"
+
+	// RecurringCatchUpLimit caps how many missed occurrences one run
+	// generates for a single rule after a long downtime.
+	RecurringCatchUpLimit = 400
+)
+
+type RecurringTransactionRepository interface {
+	Create(ctx context.Context, recurring *RecurringTransaction) error
+	FindAll(ctx context.Context, query RecurringQueryInput) ([]RecurringTransaction, int64, error)
+	FindByID(ctx context.Context, id string) (RecurringTransaction, error)
+	Update(ctx context.Context, recurring *RecurringTransaction) error
+	Delete(ctx context.Context, id string) error
+	FindOccurrences(ctx context.Context, recurringID string) ([]RecurringOccurrence, error)
+
+	// Upcoming lists the occurrences of the user's active rules due until the given time.
+	Upcoming(ctx context.Context, userID string, until time.Time) ([]UpcomingBill, error)
+	// Generate creates the transactions for every occurrence due by now,
+	// catching up on the ones missed while the service was down.
+	Generate(ctx context.Context, now time.Time) (int, error)
+	// Remind notifies users about charges coming up within their reminder window.
+	Remind(ctx context.Context, now time.Time) (int, error)
+}
+
+type RecurringTransaction struct {
+	ID               string          `json:"id" gorm:"primaryKey"`
+	UserID           string          `json:"user_id"`
+	WalletID         string          `json:"wallet_id"`
+	Category         string          `json:"category"`
+	TransactionType  string          `json:"transaction_type"`
+	Description      string          `json:"description"`
+	Amount           decimal.Decimal `json:"amount" gorm:"type:numeric(20,2)"` // in Currency
+	Currency         string          `json:"currency"`
+	Frequency        string          `json:"frequency"`
+	Interval         int             `json:"interval"`
+	DayOfMonth       int             `json:"day_of_month"`
+	RRule            string          `json:"rrule" gorm:"column:rrule"` // normalized rule the schedule runs on
+	StartAt          time.Time       `json:"start_at"`
+	EndAt            *time.Time      `json:"end_at"`
+	NextRunAt        *time.Time      `json:"next_run_at"` // nil once the series is over
+	RemindDaysBefore int             `json:"remind_days_before"`
+	RemindedFor      *time.Time      `json:"-"`
+	IsActive         bool            `json:"is_active"`
+
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+}
+
+// NextAfter returns the first occurrence after the given time, evaluating the
+// rule in loc so days roll over at the owner's midnight.
+func (r *RecurringTransaction) NextAfter(after time.Time, loc *time.Location) (time.Time, bool) {
+	rule, err := utils.ParseRRule(r.RRule)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return rule.Next(r.StartAt.In(loc), after)
+}
+
+// Transaction builds the transaction for one occurrence. The amount is still
+// in the rule's currency; callers convert it to the owner's base currency.
+func (r *RecurringTransaction) Transaction(occurrenceAt time.Time) Transaction {
+	return Transaction{
+		UserID:          r.UserID,
+		WalletID:        r.WalletID,
+		Category:        r.Category,
+		TransactionType: r.TransactionType,
+		Description:     r.Description,
+		SpentAt:         occurrenceAt,
+		Amount:          r.Amount,
+		Currency:        r.Currency,
+	}
+}
+
+// RecurringOccurrence records that an occurrence was generated, which keeps
+// generation idempotent across restarts and overlapping runs.
+type RecurringOccurrence struct {
+	ID            int64     `json:"id"`
+	RecurringID   string    `json:"recurring_id"`
+	OccurrenceAt  time.Time `json:"occurrence_at"`
+	TransactionID string    `json:"transaction_id"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type RecurringTransactionInput struct {
+	WalletID         string          `json:"wallet_id"`
+	Category         string          `json:"category" validate:"required"`
+	TransactionType  string          `json:"transaction_type" validate:"required,oneof=INCOME EXPENSE"`
+	Description      string          `json:"description" validate:"required,max=255"`
+	Amount           decimal.Decimal `json:"amount"`
+	Currency         string          `json:"currency" validate:"omitempty,len=3,alpha"`
+	Frequency        string          `json:"frequency" validate:"required,oneof=DAILY WEEKLY MONTHLY YEARLY RRULE"`
+	Interval         int             `json:"interval" validate:"min=0,max=366"`
+	DayOfMonth       int             `json:"day_of_month" validate:"min=-31,max=31"` // MONTHLY only; negative counts from month end
+	RRule            string          `json:"rrule"`                                  // RRULE only
+	StartAt          time.Time       `json:"start_at" validate:"required"`
+	EndAt            *time.Time      `json:"end_at"`
+	RemindDaysBefore int             `json:"remind_days_before" validate:"min=0,max=30"`
+	IsActive         *bool           `json:"is_active"`
+}
+
+// Schedule turns the simple frequency fields, or the raw RRULE, into one rule.
+func (i *RecurringTransactionInput) Schedule() (utils.RRule, error) {
+	var rule utils.RRule
+
+	switch i.Frequency {
+	case FrequencyRRule:
+		parsed, err := utils.ParseRRule(i.RRule)
+		if err != nil {
+			return utils.RRule{}, fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+		}
+
+		rule = parsed
+	default:
+		rule = utils.RRule{Freq: i.Frequency, Interval: i.Interval}
+		if rule.Interval < 1 {
+			rule.Interval = 1
+		}
+
+		if i.Frequency == FrequencyMonthly && i.DayOfMonth != 0 {
+			rule.ByMonthDay = []int{i.DayOfMonth}
+		}
+	}
+
+	if i.EndAt != nil && (rule.Until.IsZero() || i.EndAt.Before(rule.Until)) {
+		rule.Until = i.EndAt.UTC()
+	}
+
+	return rule, nil
+}
+
+type RecurringQueryInput struct {
+	Keyword string `query:"keyword"`
+	UserID  string
+	PaginatedRequest
+}
+
+type UpcomingBill struct {
+	RecurringID     string          `json:"recurring_id"`
+	Description     string          `json:"description"`
+	Category        string          `json:"category"`
+	TransactionType string          `json:"transaction_type"`
+	WalletID        string          `json:"wallet_id"`
+	Amount          decimal.Decimal `json:"amount"`
+	Currency        string          `json:"currency"`
+	DueAt           time.Time       `json:"due_at"`
+}
@@ -35,7 +35,7 @@ type ScopeInput struct {
 	EndDate       string          `json:"end_date"`
 	AutoRenew     bool            `json:"auto_renew"`
 	RenewalPeriod string          `json:"renewal_period"`
-	CategoryIDs   []string        `json:"scope_categories"`
+	CategoryIDs   []string        `json:"scope_categories"` // ids of the user's categories
 }
 
 func (bi *ScopeInput) ToScope() Scope {
@@ -59,9 +59,11 @@ type ScopeOverview struct {
 }
 
 type ScopeCategory struct {
-	ID        int64     `json:"id"`
-	ScopeID   int64     `json:"scope_id"`
-	Category  string    `json:"category"`
+	ID         int64  `json:"id"`
+	ScopeID    int64  `json:"scope_id"`
+	CategoryID string `json:"category_id"` // a parent category covers its children too
+	// Category is the slug archives before version 2 used instead of CategoryID.
+	Category  string    `json:"category,omitempty" gorm:"-"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 	DeletedAt gorm.DeletedAt
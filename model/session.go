@@ -0,0 +1,53 @@
+package model
+
+import (
+	"context"
+	"time"
+)
+
+const RefreshTokenTTL = 30 * 24 * time.Hour
+
+type SessionRepository interface {
+	// Start opens a new session and returns it with its first raw refresh token.
+	Start(ctx context.Context, userID string, meta SessionMeta) (Session, string, error)
+	// Rotate exchanges a raw refresh token for a new one. Presenting a token
+	// that was already rotated revokes the whole session and returns ErrRefreshTokenReused.
+	Rotate(ctx context.Context, rawToken string) (Session, string, error)
+	FindActive(ctx context.Context, userID string) ([]Session, error)
+	IsActive(ctx context.Context, id string) (bool, error)
+	Revoke(ctx context.Context, userID, id string) error
+	RevokeAll(ctx context.Context, userID string) error
+}
+
+// Session is a refresh token family. Every rotation issues a new
+// RefreshToken under the same session.
+type Session struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt time.Time  `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Current    bool       `json:"current" gorm:"-"`
+	User       User       `json:"-" gorm:"foreignKey:UserID;->"`
+}
+
+type RefreshToken struct {
+	ID        string     `json:"id"`
+	SessionID string     `json:"session_id"`
+	TokenHash string     `json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type SessionMeta struct {
+	UserAgent string
+	IPAddress string
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
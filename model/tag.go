@@ -0,0 +1,130 @@
+package model
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/shopspring/decimal"
+)
+
+const TagMaxLength = 50
+
+type TagRepository interface {
+	Create(ctx context.Context, tag *Tag) error
+	FindAll(ctx context.Context, query TagQueryInput) ([]Tag, error)
+	FindByID(ctx context.Context, id string) (Tag, error)
+	Update(ctx context.Context, id string, tag Tag) error
+	Delete(ctx context.Context, id string) error
+	// SetTransactionTags replaces the user's tags on a transaction, creating
+	// missing tags by name. Tags other participants put on it are left alone.
+	SetTransactionTags(ctx context.Context, userID, transactionID string, names []string) ([]Tag, error)
+	Report(ctx context.Context, query TagReportQueryInput) ([]TagReport, error)
+}
+
+// Tag is a free-form label owned by one user. A transaction can carry many.
+type Tag struct {
+	ID        string    `json:"id" gorm:"primaryKey"`
+	UserID    string    `json:"user_id"`
+	Name      string    `json:"name"` // normalized, see NormalizeTag
+	Color     string    `json:"color"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type TagInput struct {
+	Name  string `json:"name" validate:"required"`
+	Color string `json:"color"`
+}
+
+type TagQueryInput struct {
+	Keyword string `query:"keyword"`
+	UserID  string
+}
+
+type TagReportQueryInput struct {
+	StartDate string `query:"start_date"`
+	EndDate   string `query:"end_date"`
+	Period    string `query:"period"` // "week" or "month", used when no dates are given
+	UserID    string
+	Timezone  string
+}
+
+type TagReport struct {
+	TagID        string          `json:"tag_id"`
+	Name         string          `json:"name"`
+	Color        string          `json:"color"`
+	Transactions int64           `json:"transactions"`
+	Income       decimal.Decimal `json:"income"`
+	Expense      decimal.Decimal `json:"expense"`
+}
+
+// NormalizeTag lowercases a tag and turns spaces into dashes, dropping a
+// leading "#" and anything but letters, digits, dashes and underscores, so
+// "#Bali Trip 2026" and "bali-trip-2026" are the same tag.
+func NormalizeTag(name string) string {
+	name = strings.TrimPrefix(strings.TrimSpace(name), "#")
+
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_':
+			b.WriteRune(r)
+		case unicode.IsSpace(r):
+			b.WriteRune('-')
+		}
+	}
+
+	normalized := []rune(b.String())
+	if len(normalized) > TagMaxLength {
+		normalized = normalized[:TagMaxLength]
+	}
+
+	return string(normalized)
+}
+
+// NormalizeTags normalizes and de-duplicates names, dropping empty ones.
+func NormalizeTags(names []string) []string {
+	seen := map[string]bool{}
+	var tags []string
+
+	for _, name := range names {
+		tag := NormalizeTag(name)
+		if tag == "" || seen[tag] {
+			continue
+		}
+
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+
+	return tags
+}
+
+// TagNames lists the names of tags, e.g. to pass a transaction's tags on.
+func TagNames(tags []Tag) []string {
+	names := make([]string, len(tags))
+	for i, tag := range tags {
+		names[i] = tag.Name
+	}
+
+	return names
+}
+
+var hashtagPattern = regexp.MustCompile(`(^|\s)#([\p{L}\p{N}_-]+)`)
+
+// ExtractHashtags pulls "#tag" words out of a chat message and returns the
+// message without them, so they do not end up in the description.
+func ExtractHashtags(text string) (string, []string) {
+	var names []string
+
+	for _, match := range hashtagPattern.FindAllStringSubmatch(text, -1) {
+		names = append(names, match[2])
+	}
+
+	clean := hashtagPattern.ReplaceAllString(text, "$1")
+
+	return strings.Join(strings.Fields(clean), " "), NormalizeTags(names)
+}
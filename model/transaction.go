@@ -2,6 +2,7 @@ package model
 
 import (
 	"context"
+	"strings"
 	"time"
 
 	"github.com/shopspring/decimal"
@@ -13,17 +14,21 @@ const (
 	TransactionTypeExpense = "EXPENSE"
 
 	CategoryOpname = "opname"
+	CategoryOther  = "other"
 )
 
 type TransactionRepository interface {
-	Create(c context.Context, transaction *Transaction) error
+	// Create, Update, Delete, UpdateShare and DeleteShare record the change
+	// for actor in the audit log along with it.
+	Create(c context.Context, transaction *Transaction, actor AuditActor) error
 	FindAll(c context.Context, query TransactionQueryInput) ([]Transaction, int64, error)
-	FindByID(c context.Context, id int64) (Transaction, error)
-	Update(c context.Context, id int64, transaction Transaction) error
-	Delete(c context.Context, id int64) error
+	FindByID(c context.Context, id string) (Transaction, error)
+	Update(c context.Context, id string, transaction Transaction, actor AuditActor) error
+	Delete(c context.Context, id string, actor AuditActor) error
 
-	UpdateShare(c context.Context, id string, share TransactionShare) error
-	DeleteShare(c context.Context, id string) error
+	FindShareByID(c context.Context, id string) (TransactionShare, error)
+	UpdateShare(c context.Context, id string, share TransactionShare, actor AuditActor) error
+	DeleteShare(c context.Context, id string, actor AuditActor) error
 
 	CurrentMonthSummary(c context.Context, query SummaryQueryInput) (Summary, error)
 }
@@ -31,13 +36,24 @@ type TransactionRepository interface {
 type Transaction struct {
 	ID                string             `json:"id" gorm:"primaryKey"`
 	UserID            string             `json:"user_id"` // creator
+	WalletID          string             `json:"wallet_id"`
+	WalletName        string             `json:"wallet_name,omitempty" gorm:"-"` // filled by the recognizer only
 	Category          string             `json:"category"`
 	TransactionType   string             `json:"transaction_type"` // e.g. "INCOME", "EXPENSE"
 	Description       string             `json:"description"`
 	SpentAt           time.Time          `json:"spent_at"`
-	Amount            decimal.Decimal    `json:"amount" gorm:"type:numeric(20,2)"`
+	Amount            decimal.Decimal    `json:"amount" gorm:"type:numeric(20,2)"` // in the creator's base currency
+	Currency          string             `json:"currency"`
+	OriginalAmount    decimal.Decimal    `json:"original_amount" gorm:"type:numeric(20,2)"`
+	ExchangeRate      decimal.Decimal    `json:"exchange_rate" gorm:"type:numeric(20,10)"`
+	RateDate          *time.Time         `json:"rate_date" gorm:"type:date"`
 	IsShared          bool               `json:"is_shared"`
+	ImportBatchID     *string            `json:"import_batch_id,omitempty"`
+	PayeeID           *string            `json:"payee_id"`
+	TransferID        *string            `json:"transfer_id,omitempty"` // shared by both sides of a card payment
+	Payee             *Payee             `json:"payee,omitempty" gorm:"foreignKey:PayeeID"`
 	TransactionShares []TransactionShare `json:"transaction_shares" gorm:"foreignKey:TransactionID"`
+	Tags              []Tag              `json:"tags" gorm:"many2many:transaction_tags"`
 	User              User               `json:"user" gorm:"foreignKey:UserID"`
 
 	CreatedAt time.Time      `json:"created_at"`
@@ -45,6 +61,21 @@ type Transaction struct {
 	DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"index"`
 }
 
+// IsParticipant reports whether the user created the transaction or holds a share of it.
+func (t *Transaction) IsParticipant(userID string) bool {
+	if t.UserID == userID {
+		return true
+	}
+
+	for _, share := range t.TransactionShares {
+		if share.UserID == userID {
+			return true
+		}
+	}
+
+	return false
+}
+
 type TransactionShare struct {
 	ID            string          `json:"id" gorm:"primaryKey"`
 	TransactionID string          `json:"transaction_id"`
@@ -60,14 +91,32 @@ type TransactionQueryInput struct {
 	UserID    string `query:"user_id"`
 	StartDate string `query:"start_date"`
 	EndDate   string `query:"end_date"`
+	WalletID  string `query:"wallet_id"`
 	Filter    string `query:"filter"` // "shared", "personal", or empty for all
+	Tags      string `query:"tags"`   // comma separated; a transaction must carry every tag listed
+	PayeeID   string `query:"payee_id"`
+	// ParticipantID limits results to transactions the user created or shares in.
+	ParticipantID string
+	// Timezone is the IANA zone used to turn spent_at into calendar dates.
+	Timezone string
 	PaginatedRequest
 }
 
+// TagNames lists the normalized tags the query filters on.
+func (q *TransactionQueryInput) TagNames() []string {
+	if q.Tags == "" {
+		return nil
+	}
+
+	return NormalizeTags(strings.Split(q.Tags, ","))
+}
+
 type SummaryQueryInput struct {
 	UserID    string `query:"user_id"`
 	StartDate string `query:"start_date"` // format: "2006-01-02"
 	EndDate   string `query:"end_date"`   // format: "2006-01-02"
+	Period    string `query:"period"`     // "week" or "month", used when no dates are given
+	Timezone  string
 }
 
 type Summary struct {
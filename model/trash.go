@@ -0,0 +1,56 @@
+package model
+
+import (
+	"context"
+	"time"
+)
+
+// TrashRetention is how long deleted items can be restored before they are
+// purged for good.
+const TrashRetention = 30 * 24 * time.Hour
+
+const (
+	TrashTypeTransaction = "transaction"
+	TrashTypeWallet      = "wallet"
+	TrashTypeScope       = "scope"
+)
+
+// TrashTypes are the soft-deleted entities the trash lists.
+var TrashTypes = map[string]bool{
+	TrashTypeTransaction: true,
+	TrashTypeWallet:      true,
+	TrashTypeScope:       true,
+}
+
+// TrashRepository works on soft-deleted rows. Rows deleted together with an
+// item carry its exact deleted_at, which is how they are restored or purged
+// along with it.
+type TrashRepository interface {
+	FindAll(ctx context.Context, query TrashQueryInput) ([]TrashItem, int64, error)
+	FindByID(ctx context.Context, itemType, id string) (TrashItem, error)
+	// Restore brings an item back with everything deleted along with it. A
+	// restored transaction is recorded in the audit log for actor.
+	Restore(ctx context.Context, item TrashItem, actor AuditActor) error
+	// Purge removes an item and everything deleted along with it for good.
+	Purge(ctx context.Context, item TrashItem) error
+	// PurgeExpired purges every item deleted before the given time.
+	PurgeExpired(ctx context.Context, before time.Time) (int, error)
+}
+
+// TrashItem is a deleted transaction, wallet or scope. Transactions deleted
+// with their wallet are listed under the wallet only.
+type TrashItem struct {
+	Type      string    `json:"type"`
+	ID        string    `json:"id"`
+	UserID    string    `json:"-"`
+	Name      string    `json:"name"`
+	Cascaded  int64     `json:"cascaded"` // shares, transactions or scope categories that come back with it
+	DeletedAt time.Time `json:"deleted_at"`
+	PurgeAt   time.Time `json:"purge_at" gorm:"-"`
+}
+
+type TrashQueryInput struct {
+	Type   string `query:"type"`
+	UserID string
+	PaginatedRequest
+}
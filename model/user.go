@@ -7,23 +7,50 @@ import (
 	"gorm.io/gorm"
 )
 
+const (
+	IdentityGoogle   = "google"
+	IdentityPassword = "password"
+
+	UserTokenVerifyEmail   = "verify_email"
+	UserTokenResetPassword = "reset_password"
+
+	VerifyEmailTTL   = 24 * time.Hour
+	ResetPasswordTTL = time.Hour
+)
+
 type UserRepository interface {
 	Authenticate(ctx context.Context, code, requestOrigin string) (User, error)
 	FindByID(ctx context.Context, id string) (User, error)
+	FindByEmail(ctx context.Context, email string) (User, error)
 	FindOptions(ctx context.Context) ([]UserOption, error)
+
+	Register(ctx context.Context, input RegisterRequest) (User, error)
+	Login(ctx context.Context, email, password string) (User, error)
+	CheckPassword(ctx context.Context, userID, password string) error
+	SetPassword(ctx context.Context, userID, password string) error
+	MarkEmailVerified(ctx context.Context, userID string) error
+
+	IssueToken(ctx context.Context, userID, purpose string, ttl time.Duration) (string, error)
+	ConsumeToken(ctx context.Context, purpose, rawToken string) (User, error)
+
+	LinkGoogle(ctx context.Context, userID, code, requestOrigin string) error
+	AuthenticateExternal(ctx context.Context, info ExternalAuthInfo) (User, error)
+	LinkExternal(ctx context.Context, userID string, info ExternalAuthInfo) error
+	FindIdentities(ctx context.Context, userID string) ([]UserIdentity, error)
 }
 
 type User struct {
-	ID         string         `json:"id"`
-	Email      string         `json:"email"`
-	Name       string         `json:"name"`
-	TelegramID int64          `json:"telegram_id"`
-	Password   string         `json:"password,omitempty"`
-	Picture    string         `json:"picture"`
-	Role       string         `json:"role"`
-	CreatedAt  time.Time      `json:"created_at"`
-	UpdatedAt  time.Time      `json:"updated_at"`
-	DeletedAt  gorm.DeletedAt `json:"deleted_at"`
+	ID              string         `json:"id"`
+	Email           string         `json:"email"`
+	Name            string         `json:"name"`
+	TelegramID      int64          `json:"telegram_id"`
+	Password        string         `json:"-"` // bcrypt hash, never sent
+	Picture         string         `json:"picture"`
+	Role            string         `json:"role"`
+	EmailVerifiedAt *time.Time     `json:"email_verified_at"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `json:"deleted_at"`
 }
 
 func (u *User) OmitPassword() {
@@ -45,9 +72,23 @@ type ChangeUsernameRequest struct {
 }
 
 type GoogleAuthInfo struct {
-	Email   string `json:"email"`
-	Name    string `json:"name"`
-	Picture string `json:"picture"`
+	Subject       string `json:"sub"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	Picture       string `json:"picture"`
+}
+
+// External converts Google's claims into the provider-neutral form.
+func (g GoogleAuthInfo) External() ExternalAuthInfo {
+	return ExternalAuthInfo{
+		Provider:      IdentityGoogle,
+		Subject:       g.Subject,
+		Email:         g.Email,
+		EmailVerified: g.EmailVerified,
+		Name:          g.Name,
+		Picture:       g.Picture,
+	}
 }
 
 type UserOption struct {
@@ -56,3 +97,53 @@ type UserOption struct {
 	Name    string `json:"name"`
 	Picture string `json:"picture"`
 }
+
+// UserIdentity links a user to a way of signing in. A user may hold
+// several identities, e.g. both Google and a password.
+type UserIdentity struct {
+	ID        int64     `json:"id"`
+	UserID    string    `json:"user_id"`
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"subject"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UserToken is a single-use token sent by email. Only its hash is stored.
+type UserToken struct {
+	ID        string     `json:"id"`
+	UserID    string     `json:"user_id"`
+	Purpose   string     `json:"purpose"`
+	TokenHash string     `json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type RegisterRequest struct {
+	Name     string `json:"name" validate:"required"`
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required,min=8,max=72"`
+}
+
+type LoginRequest struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
+}
+
+type EmailRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type TokenRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,min=8,max=72"`
+}
+
+type SetPasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	Password        string `json:"password" validate:"required,min=8,max=72"`
+}
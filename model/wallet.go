@@ -14,19 +14,41 @@ type WalletRepository interface {
 	FindAll(c context.Context, query WalletQueryInput) ([]Wallet, int64, error)
 	FindByID(c context.Context, id string) (Wallet, error)
 	Update(c context.Context, id string, wallet Wallet) error
-	Delete(c context.Context, id string) error
+	// Delete removes a wallet in one transaction, handling its transactions as
+	// the input asks.
+	Delete(c context.Context, id string, input WalletDeleteInput) (WalletDeleteResult, error)
+	Unarchive(c context.Context, id string) error
 	Option(c context.Context, userID string) ([]Wallet, error)
 }
 
+// What happens to a wallet's transactions when it is deleted.
+const (
+	// WalletDeleteMove hands the transactions to another wallet.
+	WalletDeleteMove = "move"
+	// WalletDeleteArchive keeps the wallet and its transactions for history
+	// but hides it and stops new transactions from using it.
+	WalletDeleteArchive = "archive"
+	// WalletDeleteCascade sends the transactions and their shares to the trash
+	// together with the wallet.
+	WalletDeleteCascade = "delete"
+)
+
 type Wallet struct {
-	ID        string          `json:"id"`
-	UserID    string          `json:"user_id"`
-	Name      string          `json:"name"`
-	Balance   decimal.Decimal `json:"balance"`
-	CreatedAt time.Time       `json:"created_at"`
-	UpdatedAt time.Time       `json:"updated_at"`
-	DeletedAt gorm.DeletedAt  `json:"deleted_at"`
-	Owner     User            `json:"owner" gorm:"foreignKey:UserID;->"`
+	ID      string          `json:"id"`
+	UserID  string          `json:"user_id"`
+	Name    string          `json:"name"`
+	Balance decimal.Decimal `json:"balance"` // opening balance; what is owed on a credit card
+	Type    string          `json:"type"`    // WalletTypeCash when empty
+	// Credit cards only.
+	CreditLimit         decimal.Decimal `json:"credit_limit" gorm:"type:numeric(20,2)"`
+	StatementClosingDay int             `json:"statement_closing_day"`
+	PaymentDueDay       int             `json:"payment_due_day"`
+	DueRemindedFor      *time.Time      `json:"-" gorm:"type:date"`
+	CreatedAt           time.Time       `json:"created_at"`
+	UpdatedAt           time.Time       `json:"updated_at"`
+	ArchivedAt          *time.Time      `json:"archived_at"`
+	DeletedAt           gorm.DeletedAt  `json:"deleted_at"`
+	Owner               User            `json:"owner" gorm:"foreignKey:UserID;->"`
 }
 
 type WalletInput struct {
@@ -36,19 +58,65 @@ type WalletInput struct {
 }
 
 type WalletQueryInput struct {
-	Keyword string `query:"keyword"`
-	UserID  string `query:"user_id"`
+	Keyword         string `query:"keyword"`
+	UserID          string `query:"user_id"`
+	IncludeArchived bool   `query:"include_archived"`
 	PaginatedRequest
 }
 
+type WalletDeleteInput struct {
+	Mode           string `query:"mode"` // WalletDeleteCascade when empty
+	TargetWalletID string `query:"target_wallet_id"`
+}
+
+// WalletDeleteResult counts what a wallet deletion touched.
+type WalletDeleteResult struct {
+	Mode         string `json:"mode"`
+	Transactions int64  `json:"transactions"` // moved or deleted
+	Shares       int64  `json:"shares"`       // deleted with their transactions
+	Recurring    int64  `json:"recurring"`    // moved or paused
+	Goals        int64  `json:"goals"`        // moved, or unlinked to take contributions
+}
+
 func (w *Wallet) InitiateTransactionBalance() Transaction {
+	transactionType := TransactionTypeIncome
+	if w.IsCreditCard() {
+		transactionType = TransactionTypeExpense
+	}
+
 	return Transaction{
 		ID:              ulid.Make().String(),
 		UserID:          w.UserID,
+		WalletID:        w.ID,
 		Amount:          w.Balance,
+		OriginalAmount:  w.Balance,
+		ExchangeRate:    decimal.NewFromInt(1),
 		Category:        CategoryOpname,
-		TransactionType: TransactionTypeIncome,
+		TransactionType: transactionType,
 		Description:     "Initial balance",
 		SpentAt:         time.Now(),
 	}
 }
+
+func (w *Wallet) IsCreditCard() bool {
+	return w.Type == WalletTypeCreditCard
+}
+
+// Validate checks the wallet type and, for credit cards, the card terms.
+func (w *Wallet) Validate() error {
+	switch w.Type {
+	case "", WalletTypeCash:
+		return nil
+	case WalletTypeCreditCard:
+	default:
+		return ErrInvalidWalletType
+	}
+
+	if !w.CreditLimit.IsPositive() ||
+		w.StatementClosingDay < 1 || w.StatementClosingDay > 31 ||
+		w.PaymentDueDay < 1 || w.PaymentDueDay > 31 {
+		return ErrInvalidCreditCard
+	}
+
+	return nil
+}
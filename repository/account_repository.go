@@ -0,0 +1,1120 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/notblessy/anggar-service/model"
+	"github.com/oklog/ulid/v2"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type accountRepository struct {
+	db             *gorm.DB
+	preferenceRepo model.PreferenceRepository
+	storage        model.FileStorage
+}
+
+// NewAccountRepository :nodoc:
+func NewAccountRepository(db *gorm.DB, preferenceRepo model.PreferenceRepository, storage model.FileStorage) model.AccountRepository {
+	return &accountRepository{db: db, preferenceRepo: preferenceRepo, storage: storage}
+}
+
+func (r *accountRepository) Archive(ctx context.Context, userID string) (model.AccountArchive, error) {
+	logger := logrus.WithField("user_id", userID)
+
+	archive := model.AccountArchive{
+		Version:    model.AccountArchiveVersion,
+		ExportedAt: time.Now(),
+	}
+
+	db := r.db.WithContext(ctx)
+
+	if err := db.Where("id = ?", userID).First(&archive.Profile).Error; err != nil {
+		logger.Error(err)
+		return model.AccountArchive{}, err
+	}
+
+	archive.Profile.OmitPassword()
+
+	if archive.Profile.TelegramID != 0 {
+		archive.BotLink = &model.BotLink{
+			UserID:     archive.Profile.ID,
+			Email:      archive.Profile.Email,
+			Name:       archive.Profile.Name,
+			TelegramID: archive.Profile.TelegramID,
+		}
+	}
+
+	preference, err := r.preferenceRepo.Find(ctx, userID)
+	if err != nil {
+		logger.Error(err)
+		return model.AccountArchive{}, err
+	}
+
+	archive.Preference = preference
+
+	sharesHeld := db.Where("user_id = ?", userID).
+		Where("transaction_id IN (?)", db.Model(&model.Transaction{}).Select("id").Where("user_id <> ?", userID))
+
+	queries := []struct {
+		name string
+		run  func() error
+	}{
+		{"identities", func() error {
+			return db.Where("user_id = ?", userID).Order("id").Find(&archive.Identities).Error
+		}},
+		{"wallets", func() error {
+			return db.Where("user_id = ?", userID).Order("created_at").Find(&archive.Wallets).Error
+		}},
+		{"categories", func() error {
+			return db.Where("user_id = ?", userID).Order("name").Find(&archive.Categories).Error
+		}},
+		{"category rules", func() error {
+			return db.Where("user_id = ?", userID).Order("keyword, category").Find(&archive.CategoryRules).Error
+		}},
+		{"scopes", func() error {
+			return db.Preload("ScopeCategories").Where("user_id = ?", userID).Order("id").Find(&archive.Scopes).Error
+		}},
+		{"tags", func() error {
+			return db.Where("user_id = ?", userID).Order("name").Find(&archive.Tags).Error
+		}},
+		{"payees", func() error {
+			return db.Preload("Aliases").Where("user_id = ?", userID).Order("name").Find(&archive.Payees).Error
+		}},
+		{"transactions", func() error {
+			return db.Preload("TransactionShares").Preload("Tags", "tags.user_id = ?", userID).Where("user_id = ?", userID).Order("spent_at, id").Find(&archive.Transactions).Error
+		}},
+		{"shares", func() error {
+			return sharesHeld.Order("transaction_id").Find(&archive.Shares).Error
+		}},
+		{"recurring transactions", func() error {
+			return db.Where("user_id = ?", userID).Order("created_at").Find(&archive.RecurringTransactions).Error
+		}},
+		{"import mappings", func() error {
+			return db.Where("user_id = ?", userID).Order("name").Find(&archive.ImportMappings).Error
+		}},
+		{"goals", func() error {
+			return db.Preload("Contributions", func(db *gorm.DB) *gorm.DB {
+				return db.Order("contributed_at")
+			}).Where("user_id = ?", userID).Order("created_at").Find(&archive.Goals).Error
+		}},
+		{"assets", func() error {
+			return db.Where("user_id = ?", userID).Order("kind, name").Find(&archive.Assets).Error
+		}},
+	}
+
+	for _, query := range queries {
+		if err := query.run(); err != nil {
+			logger.Errorf("Error archiving %s: %v", query.name, err)
+			return model.AccountArchive{}, err
+		}
+	}
+
+	return archive, nil
+}
+
+func (r *accountRepository) FindDeletion(ctx context.Context, userID string) (model.AccountDeletion, error) {
+	var deletion model.AccountDeletion
+
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).First(&deletion).Error
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			logrus.WithField("user_id", userID).Error(err)
+		}
+
+		return model.AccountDeletion{}, err
+	}
+
+	return deletion, nil
+}
+
+func (r *accountRepository) RequestDeletion(ctx context.Context, userID string, purgeAt time.Time) (model.AccountDeletion, error) {
+	deletion := model.AccountDeletion{
+		UserID:      userID,
+		RequestedAt: time.Now(),
+		PurgeAt:     purgeAt,
+	}
+
+	// Asking again keeps the original schedule instead of pushing it back.
+	err := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&deletion).Error
+	if err != nil {
+		logrus.WithField("user_id", userID).Error(err)
+		return model.AccountDeletion{}, err
+	}
+
+	return r.FindDeletion(ctx, userID)
+}
+
+func (r *accountRepository) CancelDeletion(ctx context.Context, userID string) error {
+	res := r.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&model.AccountDeletion{})
+	if res.Error != nil {
+		logrus.WithField("user_id", userID).Error(res.Error)
+		return res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (r *accountRepository) PurgeDue(ctx context.Context, now time.Time) (int, error) {
+	var due []model.AccountDeletion
+
+	if err := r.db.WithContext(ctx).Where("purge_at <= ?", now).Order("purge_at").Find(&due).Error; err != nil {
+		logrus.Error(err)
+		return 0, err
+	}
+
+	purged := 0
+	for _, deletion := range due {
+		if err := r.purge(ctx, deletion.UserID); err != nil {
+			logrus.WithField("user_id", deletion.UserID).Errorf("Error purging account: %v", err)
+			continue
+		}
+
+		purged++
+	}
+
+	return purged, nil
+}
+
+// purge erases an account in one transaction. Transactions that other users
+// hold a share of stay, because their balances depend on them; the user row is
+// kept as an anonymous tombstone so those rows still point somewhere.
+func (r *accountRepository) purge(ctx context.Context, userID string) error {
+	var files []string
+	var attachments []model.Attachment
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Re-check inside the transaction in case the owner cancelled meanwhile.
+		res := tx.Where("user_id = ?", userID).Delete(&model.AccountDeletion{})
+		if res.Error != nil {
+			return res.Error
+		}
+
+		if res.RowsAffected == 0 {
+			return nil
+		}
+
+		if err := tx.Model(&model.ExportJob{}).Where("user_id = ? AND file_path <> ''", userID).Pluck("file_path", &files).Error; err != nil {
+			return err
+		}
+
+		const private = `transactions.user_id = @user AND NOT EXISTS (
+			SELECT 1 FROM transaction_shares s
+			WHERE s.transaction_id = transactions.id AND s.user_id <> @user AND s.deleted_at IS NULL)`
+
+		user := map[string]interface{}{"user": userID}
+
+		// the user's uploads go, and so does anything on the transactions about to be deleted
+		const attached = `attachments.user_id = @user OR attachments.transaction_id IN (SELECT id FROM transactions WHERE ` + private + `)`
+
+		if err := tx.Where(attached, user).Find(&attachments).Error; err != nil {
+			return err
+		}
+
+		statements := []struct {
+			sql  string
+			args []interface{}
+		}{
+			{`DELETE FROM attachments WHERE ` + attached, []interface{}{user}},
+			{`DELETE FROM audit_logs WHERE transaction_id IN (SELECT id FROM transactions WHERE ` + private + `)`, []interface{}{user}},
+			{`DELETE FROM transaction_shares WHERE transaction_id IN (SELECT id FROM transactions WHERE ` + private + `)`, []interface{}{user}},
+			{`DELETE FROM transactions WHERE ` + private, []interface{}{user}},
+			// what is left is shared; detach it from the wallets and imports about to go
+			{`UPDATE transactions SET wallet_id = '', import_batch_id = NULL, payee_id = NULL WHERE user_id = ?`, []interface{}{userID}},
+			{`DELETE FROM recurring_transactions WHERE user_id = ?`, []interface{}{userID}},
+			{`DELETE FROM import_batches WHERE user_id = ?`, []interface{}{userID}},
+			{`DELETE FROM import_mappings WHERE user_id = ?`, []interface{}{userID}},
+			{`DELETE FROM export_jobs WHERE user_id = ?`, []interface{}{userID}},
+			{`DELETE FROM scope_categories WHERE scope_id IN (SELECT id FROM scopes WHERE user_id = ?)`, []interface{}{userID}},
+			{`DELETE FROM scopes WHERE user_id = ?`, []interface{}{userID}},
+			{`DELETE FROM category_suggestions WHERE user_id = ?`, []interface{}{userID}},
+			{`DELETE FROM category_rules WHERE user_id = ?`, []interface{}{userID}},
+			{`UPDATE categories SET parent_id = NULL WHERE user_id = ?`, []interface{}{userID}},
+			{`DELETE FROM categories WHERE user_id = ?`, []interface{}{userID}},
+			{`DELETE FROM tags WHERE user_id = ?`, []interface{}{userID}},
+			{`DELETE FROM payee_aliases WHERE user_id = ?`, []interface{}{userID}},
+			{`DELETE FROM payees WHERE user_id = ?`, []interface{}{userID}},
+			{`DELETE FROM goal_contributions WHERE goal_id IN (SELECT id FROM goals WHERE user_id = ?)`, []interface{}{userID}},
+			{`DELETE FROM goals WHERE user_id = ?`, []interface{}{userID}},
+			{`DELETE FROM balance_snapshots WHERE user_id = ?`, []interface{}{userID}},
+			{`DELETE FROM assets WHERE user_id = ?`, []interface{}{userID}},
+			{`DELETE FROM wallets WHERE user_id = ?`, []interface{}{userID}},
+			{`DELETE FROM refresh_tokens WHERE session_id IN (SELECT id FROM sessions WHERE user_id = ?)`, []interface{}{userID}},
+			{`DELETE FROM sessions WHERE user_id = ?`, []interface{}{userID}},
+			{`DELETE FROM api_tokens WHERE user_id = ?`, []interface{}{userID}},
+			{`DELETE FROM user_tokens WHERE user_id = ?`, []interface{}{userID}},
+			{`DELETE FROM user_identities WHERE user_id = ?`, []interface{}{userID}},
+			{`DELETE FROM recovery_codes WHERE user_id = ?`, []interface{}{userID}},
+			{`DELETE FROM user_totps WHERE user_id = ?`, []interface{}{userID}},
+			{`DELETE FROM user_preferences WHERE user_id = ?`, []interface{}{userID}},
+			{`UPDATE users SET email = ?, name = ?, password = NULL, picture = NULL, telegram_id = NULL,
+				email_verified_at = NULL, updated_at = NOW(), deleted_at = COALESCE(deleted_at, NOW())
+				WHERE id = ?`, []interface{}{"deleted-" + userID + "@deleted.invalid", model.DeletedUserName, userID}},
+		}
+
+		for _, statement := range statements {
+			if err := tx.Exec(statement.sql, statement.args...).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			logrus.WithField("user_id", userID).Error(err)
+		}
+	}
+
+	for _, attachment := range attachments {
+		keys := []string{attachment.StorageKey}
+		if attachment.ThumbnailKey != nil {
+			keys = append(keys, *attachment.ThumbnailKey)
+		}
+
+		for _, key := range keys {
+			if err := r.storage.Delete(ctx, key); err != nil {
+				logrus.WithField("user_id", userID).Error(err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// RunAccountPurger erases accounts whose deletion grace period is over.
+func RunAccountPurger(ctx context.Context, repo model.AccountRepository, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := repo.PurgeDue(ctx, time.Now()); err != nil {
+			logrus.Errorf("account purge failed: %v", err)
+		} else if n > 0 {
+			logrus.Infof("purged %d accounts", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *accountRepository) Restore(ctx context.Context, userID string, archive model.AccountArchive, onConflict string) (model.RestoreResult, error) {
+	logger := logrus.WithField("user_id", userID)
+
+	if archive.Version < 1 || archive.Version > model.AccountArchiveVersion {
+		return model.RestoreResult{}, model.ErrUnsupportedArchive
+	}
+
+	result := model.RestoreResult{Warnings: []string{}}
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		restore := &archiveRestore{
+			tx:           tx,
+			userID:       userID,
+			archive:      archive,
+			skip:         onConflict != model.RestoreDuplicate,
+			result:       &result,
+			wallets:      map[string]string{},
+			categories:   map[string]string{},
+			payees:       map[string]string{},
+			transactions: map[string]string{},
+			users:        map[string]bool{},
+		}
+
+		steps := []func() error{
+			restore.restoreWallets,
+			restore.restoreCategories,
+			restore.restoreCategoryRules,
+			restore.restoreScopes,
+			restore.restoreTags,
+			restore.restorePayees,
+			restore.restoreTransactions,
+			restore.restoreHeldShares,
+			restore.restoreRecurring,
+			restore.restoreImportMappings,
+			restore.restoreGoals,
+			restore.restoreAssets,
+			restore.restorePreference,
+			restore.restoreBotLink,
+		}
+
+		for _, step := range steps {
+			if err := step(); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		logger.Errorf("Error restoring archive: %v", err)
+		return model.RestoreResult{}, err
+	}
+
+	return result, nil
+}
+
+// archiveRestore carries the ID remapping while an archive is written into an
+// account. Archived IDs are never reused, so restoring into the same instance
+// cannot collide with the rows the archive was taken from.
+type archiveRestore struct {
+	tx      *gorm.DB
+	userID  string
+	archive model.AccountArchive
+	skip    bool
+	result  *model.RestoreResult
+
+	wallets      map[string]string // archived id to id in this account
+	categories   map[string]string
+	payees       map[string]string
+	transactions map[string]string
+	users        map[string]bool // other users known to exist here
+}
+
+func (a *archiveRestore) warn(format string, args ...interface{}) {
+	a.result.Warnings = append(a.result.Warnings, fmt.Sprintf(format, args...))
+}
+
+// owner maps a user referenced by the archive to this instance: the archive
+// owner becomes the restoring user, anyone else must exist under the same ID.
+func (a *archiveRestore) owner(id string) (string, error) {
+	if id == a.archive.Profile.ID {
+		return a.userID, nil
+	}
+
+	exists, ok := a.users[id]
+	if !ok {
+		var count int64
+		if err := a.tx.Model(&model.User{}).Where("id = ?", id).Count(&count).Error; err != nil {
+			return "", err
+		}
+
+		exists = count > 0
+		a.users[id] = exists
+	}
+
+	if !exists {
+		return "", nil
+	}
+
+	return id, nil
+}
+
+func (a *archiveRestore) restoreWallets() error {
+	for _, wallet := range a.archive.Wallets {
+		if a.skip {
+			var existing model.Wallet
+
+			err := a.tx.Where("user_id = ? AND LOWER(name) = LOWER(?)", a.userID, wallet.Name).Limit(1).Find(&existing).Error
+			if err != nil {
+				return err
+			}
+
+			if existing.ID != "" {
+				a.wallets[wallet.ID] = existing.ID
+				a.result.Wallets.Skipped++
+				continue
+			}
+		}
+
+		restored := model.Wallet{
+			ID:                  ulid.Make().String(),
+			UserID:              a.userID,
+			Name:                wallet.Name,
+			Balance:             wallet.Balance,
+			Type:                wallet.Type,
+			CreditLimit:         wallet.CreditLimit,
+			StatementClosingDay: wallet.StatementClosingDay,
+			PaymentDueDay:       wallet.PaymentDueDay,
+			ArchivedAt:          wallet.ArchivedAt,
+			CreatedAt:           wallet.CreatedAt,
+			UpdatedAt:           time.Now(),
+		}
+
+		// archives made before wallet types existed hold cash wallets only
+		if restored.Type == "" {
+			restored.Type = model.WalletTypeCash
+		}
+
+		if err := a.tx.Create(&restored).Error; err != nil {
+			return err
+		}
+
+		a.wallets[wallet.ID] = restored.ID
+		a.result.Wallets.Created++
+	}
+
+	return nil
+}
+
+func (a *archiveRestore) restoreScopes() error {
+	for _, scope := range a.archive.Scopes {
+		if a.skip {
+			var count int64
+
+			err := a.tx.Model(&model.Scope{}).Where("user_id = ? AND LOWER(name) = LOWER(?)", a.userID, scope.Name).Count(&count).Error
+			if err != nil {
+				return err
+			}
+
+			if count > 0 {
+				a.result.Scopes.Skipped++
+				continue
+			}
+		}
+
+		restored := model.Scope{
+			UserID:    a.userID,
+			Name:      scope.Name,
+			CreatedAt: scope.CreatedAt,
+			UpdatedAt: time.Now(),
+		}
+
+		for _, category := range scope.ScopeCategories {
+			id, err := a.categoryFor(category)
+			if err != nil {
+				return err
+			}
+
+			if id == "" {
+				a.warn("scope %q: category %s%s is not in the account", scope.Name, category.CategoryID, category.Category)
+				continue
+			}
+
+			restored.ScopeCategories = append(restored.ScopeCategories, model.ScopeCategory{CategoryID: id})
+		}
+
+		if err := a.tx.Create(&restored).Error; err != nil {
+			return err
+		}
+
+		a.result.Scopes.Created++
+	}
+
+	return nil
+}
+
+// restoreCategories adds the archived categories; a slug the account already
+// uses is the same category, whatever the conflict mode. The defaults are
+// seeded first so version 1 archives still find the categories they name.
+func (a *archiveRestore) restoreCategories() error {
+	if err := seedCategories(a.tx, a.userID); err != nil {
+		return err
+	}
+
+	created := map[string]bool{}
+
+	for _, category := range a.archive.Categories {
+		var existing model.Category
+
+		err := a.tx.Where("user_id = ? AND slug = ?", a.userID, category.Slug).Limit(1).Find(&existing).Error
+		if err != nil {
+			return err
+		}
+
+		if existing.ID != "" {
+			a.categories[category.ID] = existing.ID
+			a.result.Categories.Skipped++
+			continue
+		}
+
+		restored := model.Category{
+			ID:        ulid.Make().String(),
+			UserID:    a.userID,
+			Slug:      category.Slug,
+			Name:      category.Name,
+			Icon:      category.Icon,
+			Color:     category.Color,
+			CreatedAt: category.CreatedAt,
+			UpdatedAt: time.Now(),
+		}
+
+		if err := a.tx.Create(&restored).Error; err != nil {
+			return err
+		}
+
+		a.categories[category.ID] = restored.ID
+		created[category.ID] = true
+		a.result.Categories.Created++
+	}
+
+	// Parents are linked once every category exists, and only for the ones
+	// created here so existing categories keep their place in the tree.
+	for _, category := range a.archive.Categories {
+		if !created[category.ID] || category.ParentID == nil {
+			continue
+		}
+
+		var parent model.Category
+
+		err := a.tx.Where("id = ?", a.categories[*category.ParentID]).Limit(1).Find(&parent).Error
+		if err != nil {
+			return err
+		}
+
+		if parent.ID == "" || parent.ParentID != nil {
+			a.warn("category %q: parent is missing or nested, kept at the top level", category.Name)
+			continue
+		}
+
+		err = a.tx.Model(&model.Category{}).Where("id = ?", a.categories[category.ID]).Update("parent_id", parent.ID).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// restoreCategoryRules brings back what was learned from corrections, for the
+// categories the account has. Rules it already knows keep their own count.
+func (a *archiveRestore) restoreCategoryRules() error {
+	for _, rule := range a.archive.CategoryRules {
+		var count int64
+
+		err := a.tx.Model(&model.Category{}).Where("user_id = ? AND slug = ?", a.userID, rule.Category).Count(&count).Error
+		if err != nil {
+			return err
+		}
+
+		if count == 0 {
+			a.result.CategoryRules.Skipped++
+			continue
+		}
+
+		restored := model.CategoryRule{
+			UserID:    a.userID,
+			Keyword:   rule.Keyword,
+			Category:  rule.Category,
+			Hits:      rule.Hits,
+			CreatedAt: rule.CreatedAt,
+			UpdatedAt: time.Now(),
+		}
+
+		res := a.tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&restored)
+		if res.Error != nil {
+			return res.Error
+		}
+
+		if res.RowsAffected == 0 {
+			a.result.CategoryRules.Skipped++
+			continue
+		}
+
+		a.result.CategoryRules.Created++
+	}
+
+	return nil
+}
+
+// categoryFor maps a scope category to one of the account's categories. Version
+// 1 archives only name the slug.
+func (a *archiveRestore) categoryFor(category model.ScopeCategory) (string, error) {
+	if category.CategoryID != "" {
+		return a.categories[category.CategoryID], nil
+	}
+
+	var existing model.Category
+
+	err := a.tx.Where("user_id = ? AND slug = ?", a.userID, category.Category).Limit(1).Find(&existing).Error
+	if err != nil {
+		return "", err
+	}
+
+	return existing.ID, nil
+}
+
+// restoreTags adds the archived tags; a tag whose name the account already
+// uses is the same tag, whatever the conflict mode.
+func (a *archiveRestore) restoreTags() error {
+	for _, tag := range a.archive.Tags {
+		restored := model.Tag{
+			ID:        ulid.Make().String(),
+			UserID:    a.userID,
+			Name:      model.NormalizeTag(tag.Name),
+			Color:     tag.Color,
+			CreatedAt: tag.CreatedAt,
+			UpdatedAt: time.Now(),
+		}
+
+		if restored.Name == "" {
+			a.result.Tags.Skipped++
+			continue
+		}
+
+		res := a.tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "name"}},
+			DoNothing: true,
+		}).Create(&restored)
+		if res.Error != nil {
+			return res.Error
+		}
+
+		if res.RowsAffected == 0 {
+			a.result.Tags.Skipped++
+			continue
+		}
+
+		a.result.Tags.Created++
+	}
+
+	return nil
+}
+
+// restorePayees adds the archived payees with the aliases no payee in the
+// account claims yet. A payee whose name is taken is the same payee.
+func (a *archiveRestore) restorePayees() error {
+	for _, payee := range a.archive.Payees {
+		var existing model.Payee
+
+		err := a.tx.Where("user_id = ? AND LOWER(name) = LOWER(?)", a.userID, payee.Name).Limit(1).Find(&existing).Error
+		if err != nil {
+			return err
+		}
+
+		if existing.ID != "" {
+			a.payees[payee.ID] = existing.ID
+			a.result.Payees.Skipped++
+			continue
+		}
+
+		restored := model.Payee{
+			ID:              ulid.Make().String(),
+			UserID:          a.userID,
+			Name:            payee.Name,
+			DefaultCategory: payee.DefaultCategory,
+			CreatedAt:       payee.CreatedAt,
+			UpdatedAt:       time.Now(),
+		}
+
+		// The default only survives when the account has that category.
+		if restored.DefaultCategory != "" {
+			var count int64
+
+			err := a.tx.Model(&model.Category{}).Where("user_id = ? AND slug = ?", a.userID, restored.DefaultCategory).Count(&count).Error
+			if err != nil {
+				return err
+			}
+
+			if count == 0 {
+				restored.DefaultCategory = ""
+			}
+		}
+
+		if err := a.tx.Omit(clause.Associations).Create(&restored).Error; err != nil {
+			return err
+		}
+
+		for _, alias := range payee.Aliases {
+			row := model.PayeeAlias{
+				ID:      ulid.Make().String(),
+				PayeeID: restored.ID,
+				UserID:  a.userID,
+				Pattern: alias.Pattern,
+			}
+
+			var taken int64
+			if err := a.tx.Model(&model.PayeeAlias{}).Where("user_id = ? AND pattern = ?", a.userID, row.Pattern).Count(&taken).Error; err != nil {
+				return err
+			}
+
+			if taken > 0 {
+				a.warn("payee %q: alias %q already belongs to another payee", payee.Name, alias.Pattern)
+				continue
+			}
+
+			if err := a.tx.Create(&row).Error; err != nil {
+				return err
+			}
+		}
+
+		a.payees[payee.ID] = restored.ID
+		a.result.Payees.Created++
+	}
+
+	return nil
+}
+
+// walletFor maps an archived wallet, leaving the transaction unassigned when
+// the wallet was not part of the archive.
+func (a *archiveRestore) walletFor(id string) string {
+	if id == "" {
+		return ""
+	}
+
+	mapped, ok := a.wallets[id]
+	if !ok {
+		a.warn("wallet %s is not in the archive, records using it were left without a wallet", id)
+		a.wallets[id] = ""
+	}
+
+	return mapped
+}
+
+func (a *archiveRestore) restoreTransactions() error {
+	for _, transaction := range a.archive.Transactions {
+		if a.skip {
+			var existing model.Transaction
+
+			err := a.tx.
+				Where("user_id = ? AND spent_at = ? AND amount = ?", a.userID, transaction.SpentAt, transaction.Amount).
+				Where("transaction_type = ? AND description = ?", transaction.TransactionType, transaction.Description).
+				Limit(1).Find(&existing).Error
+			if err != nil {
+				return err
+			}
+
+			if existing.ID != "" {
+				a.transactions[transaction.ID] = existing.ID
+				a.result.Transactions.Skipped++
+				continue
+			}
+		}
+
+		restored := transaction
+		restored.ID = ulid.Make().String()
+		restored.UserID = a.userID
+		restored.WalletID = a.walletFor(transaction.WalletID)
+		restored.ImportBatchID = nil
+		restored.PayeeID = nil
+		restored.Payee = nil
+		restored.TransactionShares = nil
+		restored.Tags = nil
+		restored.User = model.User{}
+		restored.UpdatedAt = time.Now()
+
+		var shares []model.TransactionShare
+		dropped, shared := false, false
+
+		for _, share := range transaction.TransactionShares {
+			owner, err := a.owner(share.UserID)
+			if err != nil {
+				return err
+			}
+
+			if owner == "" {
+				dropped = true
+				a.result.Shares.Skipped++
+				continue
+			}
+
+			shared = shared || owner != a.userID
+			shares = append(shares, model.TransactionShare{
+				ID:            ulid.Make().String(),
+				TransactionID: restored.ID,
+				UserID:        owner,
+				Percentage:    share.Percentage,
+				Amount:        share.Amount,
+			})
+		}
+
+		// A split with people who do not exist here becomes a personal expense.
+		if dropped && !shared {
+			restored.IsShared = false
+		}
+
+		if transaction.PayeeID != nil {
+			if id, ok := a.payees[*transaction.PayeeID]; ok {
+				restored.PayeeID = &id
+			}
+		}
+
+		if err := a.tx.Omit(clause.Associations).Create(&restored).Error; err != nil {
+			return err
+		}
+
+		if len(shares) > 0 {
+			if err := a.tx.Omit(clause.Associations).Create(&shares).Error; err != nil {
+				return err
+			}
+		}
+
+		if len(transaction.Tags) > 0 {
+			if _, err := setTransactionTags(a.tx, a.userID, restored.ID, model.TagNames(transaction.Tags)); err != nil {
+				return err
+			}
+		}
+
+		a.transactions[transaction.ID] = restored.ID
+		a.result.Transactions.Created++
+		a.result.Shares.Created += len(shares)
+	}
+
+	if a.result.Shares.Skipped > 0 {
+		a.warn("%d shares belong to users who do not exist on this instance and were left out", a.result.Shares.Skipped)
+	}
+
+	return nil
+}
+
+// restoreHeldShares puts back the user's part of other people's transactions,
+// which is only possible when those transactions exist on this instance.
+func (a *archiveRestore) restoreHeldShares() error {
+	for _, share := range a.archive.Shares {
+		var exists, held int64
+
+		err := a.tx.Model(&model.Transaction{}).Where("id = ?", share.TransactionID).Count(&exists).Error
+		if err != nil {
+			return err
+		}
+
+		if exists > 0 {
+			err = a.tx.Model(&model.TransactionShare{}).
+				Where("transaction_id = ? AND user_id = ?", share.TransactionID, a.userID).
+				Count(&held).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		if exists == 0 || held > 0 {
+			a.result.Shares.Skipped++
+			continue
+		}
+
+		restored := model.TransactionShare{
+			ID:            ulid.Make().String(),
+			TransactionID: share.TransactionID,
+			UserID:        a.userID,
+			Percentage:    share.Percentage,
+			Amount:        share.Amount,
+		}
+
+		if err := a.tx.Omit(clause.Associations).Create(&restored).Error; err != nil {
+			return err
+		}
+
+		a.result.Shares.Created++
+	}
+
+	return nil
+}
+
+func (a *archiveRestore) restoreRecurring() error {
+	for _, recurring := range a.archive.RecurringTransactions {
+		if a.skip {
+			var count int64
+
+			err := a.tx.Model(&model.RecurringTransaction{}).
+				Where("user_id = ? AND description = ? AND rrule = ?", a.userID, recurring.Description, recurring.RRule).
+				Where("amount = ? AND currency = ?", recurring.Amount, recurring.Currency).
+				Count(&count).Error
+			if err != nil {
+				return err
+			}
+
+			if count > 0 {
+				a.result.RecurringTransactions.Skipped++
+				continue
+			}
+		}
+
+		restored := recurring
+		restored.ID = ulid.Make().String()
+		restored.UserID = a.userID
+		restored.WalletID = a.walletFor(recurring.WalletID)
+		restored.RemindedFor = nil
+		restored.UpdatedAt = time.Now()
+
+		if err := a.tx.Create(&restored).Error; err != nil {
+			return err
+		}
+
+		a.result.RecurringTransactions.Created++
+	}
+
+	return nil
+}
+
+func (a *archiveRestore) restoreImportMappings() error {
+	for _, mapping := range a.archive.ImportMappings {
+		if a.skip {
+			var count int64
+
+			err := a.tx.Model(&model.ImportMapping{}).Where("user_id = ? AND LOWER(name) = LOWER(?)", a.userID, mapping.Name).Count(&count).Error
+			if err != nil {
+				return err
+			}
+
+			if count > 0 {
+				a.result.ImportMappings.Skipped++
+				continue
+			}
+		}
+
+		restored := mapping
+		restored.ID = ulid.Make().String()
+		restored.UserID = a.userID
+		restored.UpdatedAt = time.Now()
+
+		if err := a.tx.Create(&restored).Error; err != nil {
+			return err
+		}
+
+		a.result.ImportMappings.Created++
+	}
+
+	return nil
+}
+
+// restoreGoals adds the archived goals with their contributions. A goal
+// whose wallet did not come along goes back to tracking contributions.
+func (a *archiveRestore) restoreGoals() error {
+	for _, goal := range a.archive.Goals {
+		if a.skip {
+			var count int64
+
+			err := a.tx.Model(&model.Goal{}).Where("user_id = ? AND LOWER(name) = LOWER(?)", a.userID, goal.Name).Count(&count).Error
+			if err != nil {
+				return err
+			}
+
+			if count > 0 {
+				a.result.Goals.Skipped++
+				continue
+			}
+		}
+
+		restored := goal
+		restored.ID = ulid.Make().String()
+		restored.UserID = a.userID
+		restored.WalletID = nil
+		restored.Contributions = nil
+		restored.UpdatedAt = time.Now()
+
+		if goal.WalletID != nil {
+			if id := a.walletFor(*goal.WalletID); id != "" {
+				restored.WalletID = &id
+			}
+		}
+
+		if err := a.tx.Create(&restored).Error; err != nil {
+			return err
+		}
+
+		for _, contribution := range goal.Contributions {
+			contribution.ID = ulid.Make().String()
+			contribution.GoalID = restored.ID
+			contribution.UserID = a.userID
+
+			if err := a.tx.Create(&contribution).Error; err != nil {
+				return err
+			}
+		}
+
+		a.result.Goals.Created++
+	}
+
+	return nil
+}
+
+// restoreAssets adds the archived assets at their last value. Their history
+// starts again with the next snapshot.
+func (a *archiveRestore) restoreAssets() error {
+	for _, asset := range a.archive.Assets {
+		if a.skip {
+			var count int64
+
+			err := a.tx.Model(&model.Asset{}).Where("user_id = ? AND LOWER(name) = LOWER(?)", a.userID, asset.Name).Count(&count).Error
+			if err != nil {
+				return err
+			}
+
+			if count > 0 {
+				a.result.Assets.Skipped++
+				continue
+			}
+		}
+
+		restored := asset
+		restored.ID = ulid.Make().String()
+		restored.UserID = a.userID
+		restored.UpdatedAt = time.Now()
+
+		if err := a.tx.Create(&restored).Error; err != nil {
+			return err
+		}
+
+		a.result.Assets.Created++
+	}
+
+	return nil
+}
+
+// restorePreference applies the archived settings only to an account that
+// never saved its own.
+func (a *archiveRestore) restorePreference() error {
+	archived := a.archive.Preference
+	if archived.BaseCurrency == "" {
+		return nil
+	}
+
+	var current []model.UserPreference
+	if err := a.tx.Where("user_id = ?", a.userID).Limit(1).Find(&current).Error; err != nil {
+		return err
+	}
+
+	if len(current) > 0 {
+		if current[0].BaseCurrency != archived.BaseCurrency {
+			a.warn("archived amounts are in %s but this account uses %s", archived.BaseCurrency, current[0].BaseCurrency)
+		}
+
+		return nil
+	}
+
+	restored := archived
+	restored.UserID = a.userID
+	restored.DefaultWalletID = a.wallets[archived.DefaultWalletID]
+	restored.CreatedAt = time.Now()
+	restored.UpdatedAt = time.Now()
+
+	if err := a.tx.Create(&restored).Error; err != nil {
+		return err
+	}
+
+	a.result.Preference = true
+
+	return nil
+}
+
+// restoreBotLink moves the Telegram link over unless either side is taken.
+func (a *archiveRestore) restoreBotLink() error {
+	if a.archive.BotLink == nil || a.archive.BotLink.TelegramID == 0 {
+		return nil
+	}
+
+	res := a.tx.Model(&model.User{}).
+		Where("id = ? AND COALESCE(telegram_id, 0) = 0", a.userID).
+		Where("NOT EXISTS (SELECT 1 FROM users other WHERE other.telegram_id = ? AND other.id <> ?)", a.archive.BotLink.TelegramID, a.userID).
+		Update("telegram_id", a.archive.BotLink.TelegramID)
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		a.warn("the Telegram link was not restored because this account or that chat is already linked")
+		return nil
+	}
+
+	a.result.BotLink = true
+
+	return nil
+}
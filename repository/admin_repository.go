@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/notblessy/anggar-service/model"
+	"github.com/notblessy/anggar-service/utils"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+type adminRepository struct {
+	db *gorm.DB
+}
+
+// NewAdminRepository :nodoc:
+func NewAdminRepository(db *gorm.DB) model.AdminRepository {
+	return &adminRepository{db}
+}
+
+func (r *adminRepository) FindAllUsers(ctx context.Context, query model.UserQueryInput) ([]model.User, int64, error) {
+	logger := logrus.WithField("query", utils.Dump(query))
+
+	var users []model.User
+
+	qb := r.db.WithContext(ctx).Model(&model.User{})
+
+	if query.Keyword != "" {
+		qb = qb.Where("name ILIKE ? OR email ILIKE ?", "%"+query.Keyword+"%", "%"+query.Keyword+"%")
+	}
+
+	if query.Role != "" {
+		qb = qb.Where("role = ?", query.Role)
+	}
+
+	var total int64
+	if err := qb.Count(&total).Error; err != nil {
+		logger.Error(err)
+		return nil, 0, err
+	}
+
+	if err := qb.Scopes(query.Paginated()).Order(query.Sorted()).Find(&users).Error; err != nil {
+		logger.Error(err)
+		return nil, 0, err
+	}
+
+	for i := range users {
+		users[i].OmitPassword()
+	}
+
+	return users, total, nil
+}
+
+func (r *adminRepository) UpdateRole(ctx context.Context, userID, role string) error {
+	logger := logrus.WithField("user_id", userID).WithField("role", role)
+
+	res := r.db.WithContext(ctx).Model(&model.User{}).Where("id = ?", userID).Update("role", role)
+	if res.Error != nil {
+		logger.Error(res.Error)
+		return res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (r *adminRepository) DeleteUser(ctx context.Context, userID string) error {
+	logger := logrus.WithField("user_id", userID)
+
+	if err := r.db.WithContext(ctx).Where("id = ?", userID).Delete(&model.User{}).Error; err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *adminRepository) FindAllowedEmails(ctx context.Context) ([]model.AllowedEmail, error) {
+	var emails []model.AllowedEmail
+
+	if err := r.db.WithContext(ctx).Order("email ASC").Find(&emails).Error; err != nil {
+		logrus.Error(err)
+		return nil, err
+	}
+
+	return emails, nil
+}
+
+func (r *adminRepository) CreateAllowedEmail(ctx context.Context, allowed *model.AllowedEmail) error {
+	logger := logrus.WithField("allowed_email", utils.Dump(allowed))
+
+	if err := r.db.WithContext(ctx).Create(allowed).Error; err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *adminRepository) DeleteAllowedEmail(ctx context.Context, id int64) error {
+	logger := logrus.WithField("id", id)
+
+	if err := r.db.WithContext(ctx).Delete(&model.AllowedEmail{}, id).Error; err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *adminRepository) FindBotLinks(ctx context.Context) ([]model.BotLink, error) {
+	var links []model.BotLink
+
+	err := r.db.WithContext(ctx).
+		Model(&model.User{}).
+		Select("id AS user_id, email, name, telegram_id").
+		Where("telegram_id IS NOT NULL AND telegram_id <> 0").
+		Order("name ASC").
+		Scan(&links).Error
+	if err != nil {
+		logrus.Error(err)
+		return nil, err
+	}
+
+	return links, nil
+}
+
+func (r *adminRepository) Unlink(ctx context.Context, userID string) error {
+	logger := logrus.WithField("user_id", userID)
+
+	if err := r.db.WithContext(ctx).Model(&model.User{}).Where("id = ?", userID).Update("telegram_id", nil).Error; err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *adminRepository) Stats(ctx context.Context) (model.SystemStats, error) {
+	var stats model.SystemStats
+
+	counts := []struct {
+		query *gorm.DB
+		dest  *int64
+	}{
+		{r.db.WithContext(ctx).Model(&model.User{}), &stats.Users},
+		{r.db.WithContext(ctx).Model(&model.User{}).Where("role = ?", model.RoleAdmin), &stats.Admins},
+		{r.db.WithContext(ctx).Model(&model.User{}).Where("telegram_id IS NOT NULL AND telegram_id <> 0"), &stats.LinkedBots},
+		{r.db.WithContext(ctx).Model(&model.Wallet{}), &stats.Wallets},
+		{r.db.WithContext(ctx).Model(&model.Scope{}), &stats.Scopes},
+		{r.db.WithContext(ctx).Model(&model.Transaction{}), &stats.Transactions},
+	}
+
+	for _, count := range counts {
+		if err := count.query.Count(count.dest).Error; err != nil {
+			logrus.Error(err)
+			return model.SystemStats{}, err
+		}
+	}
+
+	return stats, nil
+}
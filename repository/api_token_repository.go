@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/notblessy/anggar-service/model"
+	"github.com/notblessy/anggar-service/utils"
+	"github.com/oklog/ulid/v2"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// lastUsedResolution throttles last_used_at writes to one per token per minute.
+const lastUsedResolution = time.Minute
+
+type apiTokenRepository struct {
+	db *gorm.DB
+}
+
+// NewAPITokenRepository :nodoc:
+func NewAPITokenRepository(db *gorm.DB) model.APITokenRepository {
+	return &apiTokenRepository{db}
+}
+
+func (r *apiTokenRepository) Create(ctx context.Context, token *model.APIToken) (string, error) {
+	logger := logrus.WithField("user_id", token.UserID).WithField("name", token.Name)
+
+	secret, err := utils.RandomToken(32)
+	if err != nil {
+		logger.Error(err)
+		return "", err
+	}
+
+	raw := model.APITokenPrefix + secret
+
+	token.ID = ulid.Make().String()
+	token.TokenHash = utils.HashToken(raw)
+	token.Prefix = raw[:len(model.APITokenPrefix)+6]
+
+	if err := r.db.WithContext(ctx).Create(token).Error; err != nil {
+		logger.Error(err)
+		return "", err
+	}
+
+	return raw, nil
+}
+
+func (r *apiTokenRepository) FindAll(ctx context.Context, userID string) ([]model.APIToken, error) {
+	logger := logrus.WithField("user_id", userID)
+
+	var tokens []model.APIToken
+
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at DESC").Find(&tokens).Error; err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+func (r *apiTokenRepository) Authenticate(ctx context.Context, rawToken string) (model.APIToken, error) {
+	var token model.APIToken
+
+	err := r.db.WithContext(ctx).Preload("User").Where("token_hash = ?", utils.HashToken(rawToken)).First(&token).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return model.APIToken{}, model.ErrInvalidToken
+	}
+
+	if err != nil {
+		logrus.Error(err)
+		return model.APIToken{}, err
+	}
+
+	now := time.Now()
+	if !token.IsActive(now) || token.User.ID == "" {
+		return model.APIToken{}, model.ErrInvalidToken
+	}
+
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) > lastUsedResolution {
+		if err := r.db.WithContext(ctx).Model(&model.APIToken{}).Where("id = ?", token.ID).Update("last_used_at", now).Error; err != nil {
+			logrus.WithField("id", token.ID).Error(err)
+		}
+	}
+
+	return token, nil
+}
+
+func (r *apiTokenRepository) Revoke(ctx context.Context, userID, id string) error {
+	logger := logrus.WithField("user_id", userID).WithField("id", id)
+
+	res := r.db.WithContext(ctx).
+		Model(&model.APIToken{}).
+		Where("id = ? AND user_id = ?", id, userID).
+		Where("revoked_at IS NULL").
+		Update("revoked_at", time.Now())
+	if res.Error != nil {
+		logger.Error(res.Error)
+		return res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/notblessy/anggar-service/model"
+	"github.com/notblessy/anggar-service/utils"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+type attachmentRepository struct {
+	db *gorm.DB
+}
+
+// NewAttachmentRepository :nodoc:
+func NewAttachmentRepository(db *gorm.DB) model.AttachmentRepository {
+	return &attachmentRepository{db}
+}
+
+func (r *attachmentRepository) Create(ctx context.Context, attachment *model.Attachment) error {
+	logger := logrus.WithField("attachment", utils.Dump(attachment))
+
+	if err := r.db.WithContext(ctx).Create(attachment).Error; err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	attachment.HasThumbnail = attachment.ThumbnailKey != nil
+
+	return nil
+}
+
+func (r *attachmentRepository) FindByID(ctx context.Context, id string) (model.Attachment, error) {
+	var attachment model.Attachment
+
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&attachment).Error; err != nil {
+		logrus.WithField("id", id).Error(err)
+		return model.Attachment{}, err
+	}
+
+	attachment.HasThumbnail = attachment.ThumbnailKey != nil
+
+	return attachment, nil
+}
+
+func (r *attachmentRepository) FindByTransaction(ctx context.Context, transactionID string) ([]model.Attachment, error) {
+	attachments := []model.Attachment{}
+
+	if err := r.db.WithContext(ctx).Where("transaction_id = ?", transactionID).Order("created_at").Find(&attachments).Error; err != nil {
+		logrus.WithField("transaction_id", transactionID).Error(err)
+		return nil, err
+	}
+
+	for i := range attachments {
+		attachments[i].HasThumbnail = attachments[i].ThumbnailKey != nil
+	}
+
+	return attachments, nil
+}
+
+func (r *attachmentRepository) CountByTransaction(ctx context.Context, transactionID string) (int64, error) {
+	var count int64
+
+	if err := r.db.WithContext(ctx).Model(&model.Attachment{}).Where("transaction_id = ?", transactionID).Count(&count).Error; err != nil {
+		logrus.WithField("transaction_id", transactionID).Error(err)
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (r *attachmentRepository) Delete(ctx context.Context, id string) error {
+	if err := r.db.WithContext(ctx).Where("id = ?", id).Delete(&model.Attachment{}).Error; err != nil {
+		logrus.WithField("id", id).Error(err)
+		return err
+	}
+
+	return nil
+}
+
+// NewStorageFromEnv returns the S3-compatible storage configured by S3_*
+// variables when STORAGE_DRIVER is "s3", otherwise files go to ATTACHMENT_DIR.
+func NewStorageFromEnv() model.FileStorage {
+	if os.Getenv("STORAGE_DRIVER") == "s3" {
+		return NewS3Storage(S3Config{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Region:          os.Getenv("S3_REGION"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+			PathStyle:       os.Getenv("S3_PATH_STYLE") != "false",
+		}, nil)
+	}
+
+	dir := os.Getenv("ATTACHMENT_DIR")
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "anggar-attachments")
+	}
+
+	return NewLocalStorage(dir)
+}
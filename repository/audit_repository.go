@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/notblessy/anggar-service/model"
+	"github.com/notblessy/anggar-service/utils"
+	"github.com/oklog/ulid/v2"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+type auditRepository struct {
+	db *gorm.DB
+}
+
+// NewAuditRepository :nodoc:
+func NewAuditRepository(db *gorm.DB) model.AuditRepository {
+	return &auditRepository{db}
+}
+
+func (r *auditRepository) Record(ctx context.Context, log *model.AuditLog) error {
+	if err := recordAudit(r.db.WithContext(ctx), log); err != nil {
+		logrus.WithField("audit", utils.Dump(log)).Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *auditRepository) FindByTransaction(ctx context.Context, transactionID string) ([]model.AuditLog, error) {
+	logs := []model.AuditLog{}
+
+	err := r.db.WithContext(ctx).
+		Scopes(withActorName).
+		Where("audit_logs.transaction_id = ?", transactionID).
+		Order("audit_logs.created_at, audit_logs.id").
+		Find(&logs).Error
+	if err != nil {
+		logrus.WithField("transaction_id", transactionID).Error(err)
+		return nil, err
+	}
+
+	return logs, nil
+}
+
+func (r *auditRepository) Feed(ctx context.Context, query model.AuditFeedQueryInput) ([]model.AuditLog, int64, error) {
+	logger := logrus.WithField("query", utils.Dump(query))
+
+	logs := []model.AuditLog{}
+	var total int64
+
+	// Deleted transactions stay in the feed, so look them up unscoped.
+	db := r.db.WithContext(ctx)
+	shared := db.Model(&model.TransactionShare{}).Select("transaction_id").Where("user_id = ?", query.ParticipantID)
+	involved := db.Model(&model.Transaction{}).Unscoped().Select("id").
+		Where("user_id = ? OR id IN (?)", query.ParticipantID, shared)
+
+	qb := db.Model(&model.AuditLog{}).Where("audit_logs.transaction_id IN (?)", involved)
+
+	if query.Channel != "" {
+		qb = qb.Where("audit_logs.channel = ?", query.Channel)
+	}
+
+	if query.ActorID != "" {
+		qb = qb.Where("audit_logs.actor_id = ?", query.ActorID)
+	}
+
+	if err := qb.Count(&total).Error; err != nil {
+		logger.Error(err)
+		return nil, 0, err
+	}
+
+	err := qb.Scopes(withActorName, query.Paginated()).
+		Order("audit_logs.created_at DESC, audit_logs.id DESC").
+		Find(&logs).Error
+	if err != nil {
+		logger.Error(err)
+		return nil, 0, err
+	}
+
+	return logs, total, nil
+}
+
+func withActorName(db *gorm.DB) *gorm.DB {
+	return db.Select("audit_logs.*, users.name AS actor_name").
+		Joins("LEFT JOIN users ON users.id = audit_logs.actor_id")
+}
+
+// auditTransactions logs an import creating or undoing its transactions.
+func auditTransactions(tx *gorm.DB, userID, action string, transactions []model.Transaction) error {
+	if len(transactions) == 0 {
+		return nil
+	}
+
+	logs := make([]model.AuditLog, len(transactions))
+	for i, transaction := range transactions {
+		state := model.TransactionAuditState(transaction)
+
+		if action == model.AuditActionDeleted {
+			logs[i] = model.NewAuditLog(userID, model.AuditChannelImport, action, transaction, state, nil)
+		} else {
+			logs[i] = model.NewAuditLog(userID, model.AuditChannelImport, action, transaction, nil, state)
+		}
+
+		logs[i].ID = ulid.Make().String()
+	}
+
+	return tx.CreateInBatches(logs, 500).Error
+}
+
+// recordAudit appends an entry on the given connection, so changes made in a
+// transaction are logged in the same one.
+func recordAudit(tx *gorm.DB, log *model.AuditLog) error {
+	if log.ID == "" {
+		log.ID = ulid.Make().String()
+	}
+
+	return tx.Create(log).Error
+}
@@ -2,13 +2,14 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"strconv"
 	"strings"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/notblessy/anggar-service/model"
+	"github.com/notblessy/anggar-service/utils"
 	"github.com/shopspring/decimal"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/text/cases"
@@ -19,16 +20,24 @@ import (
 var waitingRooms = make(map[int64]bool)
 
 type capitalBotRepository struct {
-	db     *gorm.DB
-	openAi model.RecognizerRepository
-	bot    *tgbotapi.BotAPI
+	db             *gorm.DB
+	openAi         model.RecognizerRepository
+	bot            *tgbotapi.BotAPI
+	preferenceRepo model.PreferenceRepository
+	rateProvider   model.RateProvider
+	categoryRepo   model.CategoryRepository
+	payeeRepo      model.PayeeRepository
 }
 
-func NewCapitalBotRepository(db *gorm.DB, bot *tgbotapi.BotAPI, openAi model.RecognizerRepository) *capitalBotRepository {
+func NewCapitalBotRepository(db *gorm.DB, bot *tgbotapi.BotAPI, openAi model.RecognizerRepository, preferenceRepo model.PreferenceRepository, rateProvider model.RateProvider, categoryRepo model.CategoryRepository, payeeRepo model.PayeeRepository) *capitalBotRepository {
 	return &capitalBotRepository{
-		db:     db,
-		bot:    bot,
-		openAi: openAi,
+		db:             db,
+		bot:            bot,
+		openAi:         openAi,
+		preferenceRepo: preferenceRepo,
+		rateProvider:   rateProvider,
+		categoryRepo:   categoryRepo,
+		payeeRepo:      payeeRepo,
 	}
 }
 
@@ -165,9 +174,34 @@ func (c *capitalBotRepository) handleMessage(ctx context.Context, message *tgbot
 			return
 		}
 
-		withPrompt := model.SystemPrompt(loggedUser.ID, potentialShareUser.ID)
+		preference, err := c.preferenceRepo.Find(ctx, loggedUser.ID)
+		if err != nil {
+			logger.Error("failed to find preference: ", err)
+			msg := tgbotapi.NewMessage(message.Chat.ID, "An error occurred while processing your request.")
+			c.bot.Send(msg)
+			return
+		}
+
+		categories, err := c.categoryRepo.FindAll(ctx, loggedUser.ID)
+		if err != nil {
+			logger.Error("failed to find categories: ", err)
+			msg := tgbotapi.NewMessage(message.Chat.ID, "An error occurred while processing your request.")
+			c.bot.Send(msg)
+			return
+		}
+
+		withPrompt := model.SystemPrompt(loggedUser.ID, potentialShareUser.ID, preference, categories)
+
+		// "#tags" are ours to handle, the recognizer only sees the rest of the message.
+		text, tagNames := model.ExtractHashtags(message.Text)
+
+		// What the user taught us beats the recognizer's guess.
+		learned, err := c.categoryRepo.Suggest(ctx, loggedUser.ID, text)
+		if err != nil {
+			logger.Error("failed to suggest category: ", err)
+		}
 
-		transaction, err := c.openAi.RecognizeTransaction(ctx, withPrompt, message.Text)
+		transaction, err := c.openAi.RecognizeTransaction(ctx, withPrompt, text)
 		if err != nil {
 			logger.Error("failed to recognize transaction: ", err)
 			reply := tgbotapi.NewMessage(message.Chat.ID, "Sorry, I couldn't understand that.")
@@ -176,9 +210,81 @@ func (c *capitalBotRepository) handleMessage(ctx context.Context, message *tgbot
 		}
 
 		transaction.UserID = loggedUser.ID
-		transaction.SpentAt = time.Now()
 
-		err = c.db.WithContext(ctx).Create(&transaction).Error
+		payees, err := c.payeeRepo.FindAll(ctx, model.PayeeQueryInput{UserID: loggedUser.ID})
+		if err != nil {
+			logger.Error("failed to find payees: ", err)
+		}
+
+		transaction.PayeeID, transaction.Payee = nil, nil
+
+		payee, known := model.MatchPayee(payees, text)
+		if !known {
+			payee, known = model.MatchPayee(payees, transaction.Description)
+		}
+
+		if known {
+			transaction.PayeeID = &payee.ID
+		}
+
+		source := model.CategorySourceRecognizer
+		if _, ok := model.CategoryBySlug(categories, learned); ok {
+			transaction.Category = learned
+			source = model.CategorySourceLearned
+		}
+
+		// A default the user set on the payee is the strongest hint of all.
+		if _, ok := model.CategoryBySlug(categories, payee.DefaultCategory); known && ok {
+			transaction.Category = payee.DefaultCategory
+			source = model.CategorySourcePayee
+		}
+
+		if _, ok := model.CategoryBySlug(categories, transaction.Category); !ok {
+			transaction.Category = model.FallbackCategory(categories)
+		}
+
+		// Trust the recognized date only when it is plausible; the model falls back to its own idea of "now" otherwise.
+		now := time.Now()
+		if transaction.SpentAt.IsZero() || transaction.SpentAt.After(now) || transaction.SpentAt.Before(now.AddDate(-1, 0, 0)) {
+			transaction.SpentAt = now
+		}
+
+		transaction.WalletID = c.resolveWallet(ctx, loggedUser.ID, transaction.WalletName, preference)
+
+		err = model.ConvertTransaction(ctx, c.rateProvider, &transaction, preference.BaseCurrency)
+		if errors.Is(err, model.ErrRateNotFound) {
+			reply := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("I don't know the %s to %s rate yet, please add it first.", transaction.Currency, preference.BaseCurrency))
+			c.bot.Send(reply)
+			return
+		}
+
+		if err != nil {
+			logger.Error("failed to convert transaction: ", err)
+			reply := tgbotapi.NewMessage(message.Chat.ID, "An error occurred while converting your transaction.")
+			c.bot.Send(reply)
+			return
+		}
+
+		transaction.Tags = nil
+
+		err = c.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&transaction).Error; err != nil {
+				return err
+			}
+
+			if err := recordSuggestion(tx, transaction, text, source); err != nil {
+				return err
+			}
+
+			audit := model.NewAuditLog(loggedUser.ID, model.AuditChannelBot, model.AuditActionCreated, transaction,
+				nil, model.TransactionAuditState(transaction))
+			if err := recordAudit(tx, &audit); err != nil {
+				return err
+			}
+
+			_, err := setTransactionTags(tx, loggedUser.ID, transaction.ID, tagNames)
+			return err
+		})
 		if err != nil {
 			logger.Error("failed to save transaction: ", err)
 			reply := tgbotapi.NewMessage(message.Chat.ID, "An error occurred while saving your transaction.")
@@ -188,7 +294,7 @@ func (c *capitalBotRepository) handleMessage(ctx context.Context, message *tgbot
 
 		transactionIndex := model.Transaction{}
 
-		err = c.db.WithContext(ctx).Where("id = ?", transaction.ID).Preload("TransactionShares.User").First(&transactionIndex).Error
+		err = c.db.WithContext(ctx).Where("id = ?", transaction.ID).Preload("TransactionShares.User").Preload("Tags").Preload("Payee").First(&transactionIndex).Error
 		if err != nil {
 			logger.Error("failed to find transaction: ", err)
 			reply := tgbotapi.NewMessage(message.Chat.ID, "An error occurred while retrieving your transaction.")
@@ -196,29 +302,54 @@ func (c *capitalBotRepository) handleMessage(ctx context.Context, message *tgbot
 			return
 		}
 
-		reply := tgbotapi.NewMessage(message.Chat.ID, replyMessage(transactionIndex))
+		reply := tgbotapi.NewMessage(message.Chat.ID, replyMessage(transactionIndex, preference, categories))
 		reply.ParseMode = tgbotapi.ModeMarkdownV2
 		c.bot.Send(reply)
 	}
 }
 
-func replyMessage(transaction model.Transaction) string {
+// resolveWallet maps the wallet name the recognizer picked to one of the
+// user's wallets, falling back to the default wallet from their preferences.
+func (c *capitalBotRepository) resolveWallet(ctx context.Context, userID, walletName string, preference model.UserPreference) string {
+	if walletName != "" && !strings.EqualFold(walletName, "default") {
+		var wallet model.Wallet
+
+		err := c.db.WithContext(ctx).Where("user_id = ? AND name ILIKE ?", userID, walletName).First(&wallet).Error
+		if err == nil {
+			return wallet.ID
+		}
+	}
+
+	return preference.DefaultWalletID
+}
+
+func replyMessage(transaction model.Transaction, preference model.UserPreference, categories []model.Category) string {
 	var b strings.Builder
 	titleCaser := cases.Title(language.English)
 
-	if value, ok := model.CategoryMapper[transaction.Category]; !ok {
-		transaction.Category = "Other"
-	} else {
-		transaction.Category = value
+	if category, ok := model.CategoryBySlug(categories, transaction.Category); ok {
+		transaction.Category = category.Name
 	}
 
 	b.WriteString("✅ *Transaction Recognized*\n\n")
 	b.WriteString(fmt.Sprintf("*Category:* %s\n", escapeMarkdownV2(titleCaser.String(transaction.Category))))
 	b.WriteString(fmt.Sprintf("*Type:* %s\n", escapeMarkdownV2(titleCaser.String(strings.ToLower(transaction.TransactionType)))))
 	b.WriteString(fmt.Sprintf("*Description:* %s\n", escapeMarkdownV2(transaction.Description)))
-	b.WriteString(fmt.Sprintf("*Amount:* %s\n", escapeMarkdownV2(formatRupiah(transaction.Amount))))
-	b.WriteString(fmt.Sprintf("*Date:* %s\n", escapeMarkdownV2(transaction.SpentAt.Format("2 Jan 2006"))))
+	if transaction.Payee != nil {
+		b.WriteString(fmt.Sprintf("*Payee:* %s\n", escapeMarkdownV2(transaction.Payee.Name)))
+	}
+	b.WriteString(fmt.Sprintf("*Amount:* %s\n", escapeMarkdownV2(utils.FormatMoney(transaction.Amount, preference.BaseCurrency, preference.Locale))))
+	if transaction.Currency != "" && transaction.Currency != preference.BaseCurrency {
+		b.WriteString(fmt.Sprintf("*Original:* %s \\(rate %s\\)\n",
+			escapeMarkdownV2(utils.FormatMoney(transaction.OriginalAmount, transaction.Currency, preference.Locale)),
+			escapeMarkdownV2(transaction.ExchangeRate.String()),
+		))
+	}
+	b.WriteString(fmt.Sprintf("*Date:* %s\n", escapeMarkdownV2(transaction.SpentAt.In(preference.Location()).Format("2 Jan 2006"))))
 	b.WriteString(fmt.Sprintf("*Shared:* %s\n", map[bool]string{true: "Yes", false: "No"}[transaction.IsShared]))
+	if len(transaction.Tags) > 0 {
+		b.WriteString(fmt.Sprintf("*Tags:* %s\n", escapeMarkdownV2("#"+strings.Join(model.TagNames(transaction.Tags), " #"))))
+	}
 
 	if transaction.IsShared && len(transaction.TransactionShares) > 0 {
 		b.WriteString("\n*Breakdown:*\n")
@@ -227,12 +358,12 @@ func replyMessage(transaction model.Transaction) string {
 				b.WriteString(fmt.Sprintf("• %s: %s%% — %s\n",
 					escapeMarkdownV2(share.User.Name),
 					escapeMarkdownV2(share.Percentage.StringFixed(2)),
-					escapeMarkdownV2(formatRupiah(share.Amount)),
+					escapeMarkdownV2(utils.FormatMoney(share.Amount, preference.BaseCurrency, preference.Locale)),
 				))
 			} else {
 				b.WriteString(fmt.Sprintf("• %s: %s\n",
 					escapeMarkdownV2(share.User.Name),
-					escapeMarkdownV2(formatRupiah(share.Amount)),
+					escapeMarkdownV2(utils.FormatMoney(share.Amount, preference.BaseCurrency, preference.Locale)),
 				))
 			}
 		}
@@ -265,24 +396,24 @@ func escapeMarkdownV2(text string) string {
 	return replacer.Replace(text)
 }
 
-func formatRupiah(amount decimal.Decimal) string {
-	// Get integer part
-	intPart := amount.IntPart()
+// Notify sends text to the user's linked Telegram chat, if there is one.
+func (c *capitalBotRepository) Notify(ctx context.Context, userID, text string) error {
+	if c.bot == nil {
+		return nil
+	}
+
+	var user model.User
 
-	// Format with dot as thousand separator
-	formatted := strconv.FormatInt(intPart, 10)
-	n := len(formatted)
-	if n <= 3 {
-		return "Rp" + formatted
+	err := c.db.WithContext(ctx).Where("id = ? AND telegram_id IS NOT NULL AND telegram_id <> 0", userID).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
 	}
 
-	var result []string
-	for i := n; i > 0; i -= 3 {
-		start := i - 3
-		if start < 0 {
-			start = 0
-		}
-		result = append([]string{formatted[start:i]}, result...)
+	if err != nil {
+		return err
 	}
-	return "Rp" + strings.Join(result, ".")
+
+	_, err = c.bot.Send(tgbotapi.NewMessage(user.TelegramID, text))
+
+	return err
 }
@@ -0,0 +1,424 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/notblessy/anggar-service/model"
+	"github.com/notblessy/anggar-service/utils"
+	"github.com/oklog/ulid/v2"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type categoryRepository struct {
+	db *gorm.DB
+}
+
+// NewCategoryRepository :nodoc:
+func NewCategoryRepository(db *gorm.DB) model.CategoryRepository {
+	return &categoryRepository{db}
+}
+
+func (r *categoryRepository) FindAll(ctx context.Context, userID string) ([]model.Category, error) {
+	logger := logrus.WithField("user_id", userID)
+
+	var categories []model.Category
+
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("name ASC").Find(&categories).Error; err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	if len(categories) > 0 {
+		return categories, nil
+	}
+
+	if err := seedCategories(r.db.WithContext(ctx), userID); err != nil {
+		logger.Errorf("Error seeding categories: %v", err)
+		return nil, err
+	}
+
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("name ASC").Find(&categories).Error; err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	return categories, nil
+}
+
+// seedCategories gives a user without categories the defaults. Concurrent
+// callers may race here, the unique slug per user keeps the result the same.
+func seedCategories(db *gorm.DB, userID string) error {
+	var count int64
+	if err := db.Model(&model.Category{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return nil
+	}
+
+	categories := make([]model.Category, len(model.DefaultCategories))
+
+	for i, category := range model.DefaultCategories {
+		category.ID = ulid.Make().String()
+		category.UserID = userID
+		categories[i] = category
+	}
+
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "slug"}},
+		DoNothing: true,
+	}).Create(&categories).Error
+}
+
+func (r *categoryRepository) FindTree(ctx context.Context, userID string) ([]model.Category, error) {
+	categories, err := r.FindAll(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return model.CategoryTree(categories), nil
+}
+
+func (r *categoryRepository) FindByID(ctx context.Context, id string) (model.Category, error) {
+	var category model.Category
+
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&category).Error; err != nil {
+		logrus.WithField("id", id).Error(err)
+		return model.Category{}, err
+	}
+
+	return category, nil
+}
+
+func (r *categoryRepository) Create(ctx context.Context, category *model.Category) error {
+	logger := logrus.WithField("category", utils.Dump(category))
+
+	res := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "slug"}},
+		DoNothing: true,
+	}).Create(category)
+	if res.Error != nil {
+		logger.Error(res.Error)
+		return res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		return model.ErrCategoryExists
+	}
+
+	return nil
+}
+
+func (r *categoryRepository) Update(ctx context.Context, category model.Category) (int64, error) {
+	logger := logrus.WithField("category", utils.Dump(category))
+
+	var rewritten int64
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing model.Category
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", category.ID).First(&existing).Error; err != nil {
+			return err
+		}
+
+		if existing.Slug != category.Slug {
+			var taken int64
+
+			err := tx.Model(&model.Category{}).
+				Where("user_id = ? AND slug = ? AND id <> ?", existing.UserID, category.Slug, existing.ID).
+				Count(&taken).Error
+			if err != nil {
+				return err
+			}
+
+			if taken > 0 {
+				return model.ErrCategoryExists
+			}
+
+			rewritten, err = recategorize(tx, existing.UserID, existing.Slug, category.Slug)
+			if err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(&model.Category{}).Where("id = ?", existing.ID).Updates(map[string]interface{}{
+			"parent_id":  category.ParentID,
+			"slug":       category.Slug,
+			"name":       category.Name,
+			"icon":       category.Icon,
+			"color":      category.Color,
+			"updated_at": time.Now(),
+		}).Error
+	})
+	if err != nil {
+		logger.Error(err)
+		return 0, err
+	}
+
+	return rewritten, nil
+}
+
+func (r *categoryRepository) Merge(ctx context.Context, source, target model.Category) (int64, error) {
+	logger := logrus.WithField("source_id", source.ID).WithField("target_id", target.ID)
+
+	var rewritten int64
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+
+		rewritten, err = recategorize(tx, source.UserID, source.Slug, target.Slug)
+		if err != nil {
+			return err
+		}
+
+		// Scopes already covering the target would otherwise list it twice.
+		err = tx.Exec(`DELETE FROM scope_categories WHERE category_id = ?
+			AND scope_id IN (SELECT scope_id FROM scope_categories WHERE category_id = ?)`, source.ID, target.ID).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Model(&model.ScopeCategory{}).Where("category_id = ?", source.ID).Update("category_id", target.ID).Error
+		if err != nil {
+			return err
+		}
+
+		// Children follow the source, but never below a child, to keep the tree two levels deep.
+		parentID := &target.ID
+		if target.ParentID != nil {
+			parentID = target.ParentID
+		}
+
+		err = tx.Model(&model.Category{}).Where("parent_id = ?", source.ID).Update("parent_id", parentID).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Where("id = ?", source.ID).Delete(&model.Category{}).Error
+	})
+	if err != nil {
+		logger.Error(err)
+		return 0, err
+	}
+
+	return rewritten, nil
+}
+
+func (r *categoryRepository) Delete(ctx context.Context, id string) error {
+	logger := logrus.WithField("id", id)
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var category model.Category
+		if err := tx.Where("id = ?", id).First(&category).Error; err != nil {
+			return err
+		}
+
+		var used int64
+
+		err := tx.Model(&model.Transaction{}).Where("user_id = ? AND category = ?", category.UserID, category.Slug).Count(&used).Error
+		if err != nil {
+			return err
+		}
+
+		if used == 0 {
+			err = tx.Model(&model.RecurringTransaction{}).Where("user_id = ? AND category = ?", category.UserID, category.Slug).Count(&used).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		if used > 0 {
+			return model.ErrCategoryInUse
+		}
+
+		if err := tx.Model(&model.Category{}).Where("parent_id = ?", id).Update("parent_id", nil).Error; err != nil {
+			return err
+		}
+
+		err = tx.Where("user_id = ? AND category = ?", category.UserID, category.Slug).Delete(&model.CategoryRule{}).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Model(&model.Payee{}).Where("user_id = ? AND default_category = ?", category.UserID, category.Slug).Update("default_category", "").Error
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Where("category_id = ?", id).Delete(&model.ScopeCategory{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Where("id = ?", id).Delete(&model.Category{}).Error
+	})
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *categoryRepository) Suggest(ctx context.Context, userID, text string) (string, error) {
+	keywords := model.CategoryKeywords(text)
+	if len(keywords) == 0 {
+		return "", nil
+	}
+
+	var rules []model.CategoryRule
+
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND keyword IN ?", userID, keywords).
+		Where("category IN (?)", r.db.Model(&model.Category{}).Select("slug").Where("user_id = ?", userID)).
+		Find(&rules).Error
+	if err != nil {
+		logrus.WithField("user_id", userID).Error(err)
+		return "", err
+	}
+
+	return model.SuggestCategory(text, rules), nil
+}
+
+func (r *categoryRepository) Learn(ctx context.Context, userID, transactionID, category string) error {
+	logger := logrus.WithField("transaction_id", transactionID).WithField("category", category)
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var suggestion model.CategorySuggestion
+
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("transaction_id = ? AND user_id = ?", transactionID, userID).
+			Limit(1).Find(&suggestion).Error
+		if err != nil || suggestion.TransactionID == "" {
+			return err
+		}
+
+		// Going back to the suggestion takes the correction back, but what
+		// was learned from it stays.
+		var corrected *string
+		if category != suggestion.Category {
+			corrected = &category
+		}
+
+		err = tx.Model(&model.CategorySuggestion{}).Where("transaction_id = ?", transactionID).
+			Updates(map[string]interface{}{"corrected_category": corrected, "updated_at": time.Now()}).Error
+		if err != nil || corrected == nil {
+			return err
+		}
+
+		for _, keyword := range model.CategoryKeywords(suggestion.Text) {
+			rule := model.CategoryRule{UserID: userID, Keyword: keyword, Category: category, Hits: 1}
+
+			err := tx.Clauses(clause.OnConflict{
+				Columns: []clause.Column{{Name: "user_id"}, {Name: "keyword"}, {Name: "category"}},
+				DoUpdates: clause.Assignments(map[string]interface{}{
+					"hits":       gorm.Expr("category_rules.hits + 1"),
+					"updated_at": time.Now(),
+				}),
+			}).Create(&rule).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *categoryRepository) Accuracy(ctx context.Context, query model.CategoryAccuracyQueryInput) (model.CategoryAccuracyReport, error) {
+	logger := logrus.WithField("query", utils.Dump(query))
+
+	report := model.CategoryAccuracyReport{Months: []model.CategoryAccuracy{}}
+
+	err := r.db.WithContext(ctx).
+		Model(&model.CategorySuggestion{}).
+		Select(`TO_CHAR(created_at AT TIME ZONE ?, 'YYYY-MM') AS month,
+			COUNT(*) AS suggestions,
+			COUNT(*) FILTER (WHERE corrected_category IS NOT NULL) AS corrections,
+			COUNT(*) FILTER (WHERE source = ?) AS learned,
+			COUNT(*) FILTER (WHERE source = ? AND corrected_category IS NOT NULL) AS learned_corrections`,
+			query.Timezone, model.CategorySourceLearned, model.CategorySourceLearned).
+		Where("user_id = ?", query.UserID).
+		Where("DATE(created_at AT TIME ZONE ?) BETWEEN ? AND ?", query.Timezone, query.StartDate, query.EndDate).
+		Group("month").
+		Order("month").
+		Scan(&report.Months).Error
+	if err != nil {
+		logger.Error(err)
+		return model.CategoryAccuracyReport{}, err
+	}
+
+	for i := range report.Months {
+		month := &report.Months[i]
+		month.Compute()
+
+		report.Overall.Suggestions += month.Suggestions
+		report.Overall.Corrections += month.Corrections
+		report.Overall.Learned += month.Learned
+		report.Overall.LearnedCorrections += month.LearnedCorrections
+	}
+
+	report.Overall.Compute()
+
+	return report, nil
+}
+
+// recordSuggestion keeps the category the bot gave a new transaction. It runs
+// on the caller's transaction, next to the one creating it.
+func recordSuggestion(tx *gorm.DB, transaction model.Transaction, text, source string) error {
+	return tx.Create(&model.CategorySuggestion{
+		TransactionID: transaction.ID,
+		UserID:        transaction.UserID,
+		Text:          text,
+		Source:        source,
+		Category:      transaction.Category,
+	}).Error
+}
+
+// recategorize moves a user's transactions, schedules, learned rules and payee
+// defaults from one slug to another and reports how many transactions changed.
+func recategorize(tx *gorm.DB, userID, from, to string) (int64, error) {
+	res := tx.Model(&model.Transaction{}).Unscoped().
+		Where("user_id = ? AND category = ?", userID, from).
+		Updates(map[string]interface{}{"category": to, "updated_at": time.Now()})
+	if res.Error != nil {
+		return 0, res.Error
+	}
+
+	err := tx.Model(&model.RecurringTransaction{}).Unscoped().
+		Where("user_id = ? AND category = ?", userID, from).
+		Updates(map[string]interface{}{"category": to, "updated_at": time.Now()}).Error
+	if err != nil {
+		return 0, err
+	}
+
+	statements := []struct {
+		sql  string
+		args []interface{}
+	}{
+		{`INSERT INTO category_rules (user_id, keyword, category, hits, created_at, updated_at)
+			SELECT user_id, keyword, ?, hits, created_at, NOW() FROM category_rules WHERE user_id = ? AND category = ?
+			ON CONFLICT (user_id, keyword, category) DO UPDATE SET hits = category_rules.hits + EXCLUDED.hits, updated_at = NOW()`,
+			[]interface{}{to, userID, from}},
+		{`DELETE FROM category_rules WHERE user_id = ? AND category = ?`, []interface{}{userID, from}},
+		{`UPDATE payees SET default_category = ? WHERE user_id = ? AND default_category = ?`, []interface{}{to, userID, from}},
+		{`UPDATE category_suggestions SET category = ? WHERE user_id = ? AND category = ?`, []interface{}{to, userID, from}},
+		{`UPDATE category_suggestions SET corrected_category = ? WHERE user_id = ? AND corrected_category = ?`, []interface{}{to, userID, from}},
+	}
+
+	for _, statement := range statements {
+		if err := tx.Exec(statement.sql, statement.args...).Error; err != nil {
+			return 0, err
+		}
+	}
+
+	return res.RowsAffected, nil
+}
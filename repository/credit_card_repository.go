@@ -0,0 +1,187 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/notblessy/anggar-service/model"
+	"github.com/notblessy/anggar-service/utils"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+type creditCardRepository struct {
+	db             *gorm.DB
+	preferenceRepo model.PreferenceRepository
+	notifier       model.Notifier
+}
+
+// NewCreditCardRepository :nodoc:
+func NewCreditCardRepository(db *gorm.DB, preferenceRepo model.PreferenceRepository, notifier model.Notifier) model.CreditCardRepository {
+	return &creditCardRepository{db, preferenceRepo, notifier}
+}
+
+func (r *creditCardRepository) Statement(ctx context.Context, wallet model.Wallet, now time.Time) (model.CreditCardStatement, error) {
+	logger := logrus.WithField("wallet_id", wallet.ID)
+
+	preference, err := r.preferenceRepo.Find(ctx, wallet.UserID)
+	if err != nil {
+		logger.Error(err)
+		return model.CreditCardStatement{}, err
+	}
+
+	now = now.In(preference.Location())
+	_, closing, _ := wallet.StatementDates(now)
+	closed := closing.AddDate(0, 0, 1) // the closing day belongs to the statement
+
+	balance := func(where string, args ...interface{}) (decimal.Decimal, error) {
+		var sum decimal.Decimal
+
+		err := r.db.WithContext(ctx).Model(&model.Transaction{}).
+			Select("COALESCE(SUM(CASE WHEN transaction_type = ? THEN amount ELSE -amount END), 0)", model.TransactionTypeIncome).
+			Where("wallet_id = ?", wallet.ID).
+			Where(where, args...).
+			Scan(&sum).Error
+
+		return sum, err
+	}
+
+	closingBalance, err := balance("spent_at < ?", closed)
+	if err != nil {
+		logger.Error(err)
+		return model.CreditCardStatement{}, err
+	}
+
+	paid, err := balance("spent_at >= ? AND transaction_type = ?", closed, model.TransactionTypeIncome)
+	if err != nil {
+		logger.Error(err)
+		return model.CreditCardStatement{}, err
+	}
+
+	current, err := balance("TRUE")
+	if err != nil {
+		logger.Error(err)
+		return model.CreditCardStatement{}, err
+	}
+
+	return model.NewCreditCardStatement(wallet, now, closingBalance, paid, current), nil
+}
+
+func (r *creditCardRepository) Pay(ctx context.Context, from, to *model.Transaction, actor model.AuditActor) error {
+	logger := logrus.WithField("from", utils.Dump(from)).WithField("to", utils.Dump(to))
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, side := range []*model.Transaction{from, to} {
+			if err := tx.Create(side).Error; err != nil {
+				return err
+			}
+
+			audit := model.NewAuditLog(actor.ID, actor.Channel, model.AuditActionCreated, *side,
+				nil, model.TransactionAuditState(*side))
+			if err := recordAudit(tx, &audit); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *creditCardRepository) Remind(ctx context.Context, now time.Time) (int, error) {
+	if r.notifier == nil {
+		return 0, nil
+	}
+
+	var cards []model.Wallet
+
+	err := r.db.WithContext(ctx).
+		Where("type = ? AND archived_at IS NULL", model.WalletTypeCreditCard).
+		Find(&cards).Error
+	if err != nil {
+		logrus.Error(err)
+		return 0, err
+	}
+
+	sent := 0
+
+	for _, card := range cards {
+		logger := logrus.WithField("wallet_id", card.ID)
+
+		statement, err := r.Statement(ctx, card, now)
+		if err != nil {
+			continue
+		}
+
+		if !statement.AmountDue.IsPositive() || statement.DueDate.After(now.AddDate(0, 0, model.CreditCardReminderDays)) {
+			continue
+		}
+
+		if card.DueRemindedFor != nil && card.DueRemindedFor.Format(time.DateOnly) == statement.DueDate.Format(time.DateOnly) {
+			continue
+		}
+
+		preference, err := r.preferenceRepo.Find(ctx, card.UserID)
+		if err != nil {
+			continue
+		}
+
+		if err := r.notifier.Notify(ctx, card.UserID, cardDueMessage(card, statement, preference)); err != nil {
+			logger.Errorf("failed to send card reminder: %v", err)
+			continue
+		}
+
+		err = r.db.WithContext(ctx).Model(&model.Wallet{}).
+			Where("id = ?", card.ID).
+			Update("due_reminded_for", statement.DueDate).Error
+		if err != nil {
+			logger.Error(err)
+			continue
+		}
+
+		sent++
+	}
+
+	return sent, nil
+}
+
+func cardDueMessage(card model.Wallet, statement model.CreditCardStatement, preference model.UserPreference) string {
+	money := func(amount decimal.Decimal) string {
+		return utils.FormatMoney(amount, preference.BaseCurrency, preference.Locale)
+	}
+
+	return fmt.Sprintf("Reminder: %s of your %s statement is due on %s. The minimum payment is %s.",
+		money(statement.AmountDue),
+		card.Name,
+		statement.DueDate.Format("Mon, 2 Jan 2006"),
+		money(statement.MinimumPayment),
+	)
+}
+
+// RunCreditCardReminder sends card due-date reminders every interval until
+// ctx is cancelled.
+func RunCreditCardReminder(ctx context.Context, repo model.CreditCardRepository, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := repo.Remind(ctx, time.Now()); err != nil {
+			logrus.Errorf("card reminders failed: %v", err)
+		} else if n > 0 {
+			logrus.Infof("sent %d card reminders", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/notblessy/anggar-service/model"
+	"github.com/notblessy/anggar-service/utils"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type exchangeRateRepository struct {
+	db     *gorm.DB
+	remote model.RateProvider
+}
+
+// NewExchangeRateRepository :nodoc:
+// remote is optional; without it only the rates stored in the table are used.
+func NewExchangeRateRepository(db *gorm.DB, remote model.RateProvider) model.ExchangeRateRepository {
+	return &exchangeRateRepository{db: db, remote: remote}
+}
+
+// Rate looks for a stored rate on the date first, then asks the remote
+// provider and keeps its answer, and finally falls back to the latest stored
+// rate before the date so conversion keeps working offline.
+func (r *exchangeRateRepository) Rate(ctx context.Context, base, quote string, date time.Time) (model.ExchangeRate, error) {
+	base = strings.ToUpper(base)
+	quote = strings.ToUpper(quote)
+	day := date.Format("2006-01-02")
+
+	if base == quote {
+		return model.ExchangeRate{Base: base, Quote: quote, Rate: decimal.NewFromInt(1), RateDate: date}, nil
+	}
+
+	rate, err := r.stored(ctx, base, quote, "rate_date = ?", day)
+	if err == nil {
+		return rate, nil
+	}
+
+	if !errors.Is(err, model.ErrRateNotFound) {
+		return model.ExchangeRate{}, err
+	}
+
+	if r.remote != nil {
+		rate, err := r.remote.Rate(ctx, base, quote, date)
+		if err == nil {
+			if err := r.Save(ctx, &rate); err != nil {
+				logrus.Warnf("failed to cache exchange rate: %v", err)
+			}
+
+			return rate, nil
+		}
+
+		logrus.WithField("base", base).WithField("quote", quote).Warnf("remote exchange rate unavailable: %v", err)
+	}
+
+	return r.stored(ctx, base, quote, "rate_date <= ?", day)
+}
+
+// stored finds the latest matching rate for the pair, inverting the opposite
+// pair when only that one was entered.
+func (r *exchangeRateRepository) stored(ctx context.Context, base, quote, dateCondition, day string) (model.ExchangeRate, error) {
+	logger := logrus.WithField("base", base).WithField("quote", quote).WithField("date", day)
+
+	var rates []model.ExchangeRate
+
+	err := r.db.WithContext(ctx).
+		Where("(base = ? AND quote = ?) OR (base = ? AND quote = ?)", base, quote, quote, base).
+		Where(dateCondition, day).
+		Order("rate_date DESC").
+		Limit(2).
+		Find(&rates).Error
+	if err != nil {
+		logger.Error(err)
+		return model.ExchangeRate{}, err
+	}
+
+	if len(rates) == 0 {
+		return model.ExchangeRate{}, model.ErrRateNotFound
+	}
+
+	// Prefer the direct pair when both directions exist for the same date.
+	rate := rates[0]
+	if len(rates) > 1 && rate.Base != base && rates[1].Base == base && rates[1].RateDate.Equal(rate.RateDate) {
+		rate = rates[1]
+	}
+
+	if rate.Base != base {
+		rate.Rate = decimal.NewFromInt(1).DivRound(rate.Rate, 10)
+		rate.Base, rate.Quote = base, quote
+	}
+
+	return rate, nil
+}
+
+func (r *exchangeRateRepository) FindAll(ctx context.Context, query model.ExchangeRateQueryInput) ([]model.ExchangeRate, int64, error) {
+	logger := logrus.WithField("query", utils.Dump(query))
+
+	var rates []model.ExchangeRate
+
+	qb := r.db.WithContext(ctx).Model(&model.ExchangeRate{})
+
+	if query.Base != "" {
+		qb = qb.Where("base = ?", strings.ToUpper(query.Base))
+	}
+
+	if query.Quote != "" {
+		qb = qb.Where("quote = ?", strings.ToUpper(query.Quote))
+	}
+
+	var total int64
+	if err := qb.Count(&total).Error; err != nil {
+		logger.Error(err)
+		return nil, 0, err
+	}
+
+	if err := qb.Scopes(query.Paginated()).Order("rate_date DESC").Find(&rates).Error; err != nil {
+		logger.Error(err)
+		return nil, 0, err
+	}
+
+	return rates, total, nil
+}
+
+func (r *exchangeRateRepository) Save(ctx context.Context, rate *model.ExchangeRate) error {
+	logger := logrus.WithField("rate", utils.Dump(rate))
+
+	rate.Base = strings.ToUpper(rate.Base)
+	rate.Quote = strings.ToUpper(rate.Quote)
+
+	if rate.Source == "" {
+		rate.Source = model.RateSourceManual
+	}
+
+	err := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "base"}, {Name: "quote"}, {Name: "rate_date"}},
+		DoUpdates: clause.AssignmentColumns([]string{"rate", "source"}),
+	}).Create(rate).Error
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *exchangeRateRepository) Delete(ctx context.Context, id int64) error {
+	logger := logrus.WithField("id", id)
+
+	res := r.db.WithContext(ctx).Where("id = ?", id).Delete(&model.ExchangeRate{})
+	if res.Error != nil {
+		logger.Error(res.Error)
+		return res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
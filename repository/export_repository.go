@@ -0,0 +1,504 @@
+package repository
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/notblessy/anggar-service/model"
+	"github.com/notblessy/anggar-service/utils"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const exportBatchSize = 500
+
+var exportColumns = []string{
+	"id", "spent_at", "transaction_type", "category", "description",
+	"amount", "currency", "original_amount", "exchange_rate",
+	"wallet_id", "is_shared", "created_by", "tags",
+}
+
+type exportRepository struct {
+	db             *gorm.DB
+	preferenceRepo model.PreferenceRepository
+}
+
+// NewExportRepository :nodoc:
+func NewExportRepository(db *gorm.DB, preferenceRepo model.PreferenceRepository) model.ExportRepository {
+	return &exportRepository{db: db, preferenceRepo: preferenceRepo}
+}
+
+func (r *exportRepository) CountTransactions(ctx context.Context, query model.TransactionQueryInput) (int64, error) {
+	logger := logrus.WithField("query", utils.Dump(query))
+
+	var total int64
+	if err := r.db.WithContext(ctx).Model(&model.Transaction{}).Scopes(transactionFilter(query)).Count(&total).Error; err != nil {
+		logger.Error(err)
+		return 0, err
+	}
+
+	return total, nil
+}
+
+func (r *exportRepository) WriteTransactions(ctx context.Context, w io.Writer, format string, query model.TransactionQueryInput) error {
+	logger := logrus.WithField("query", utils.Dump(query)).WithField("format", format)
+
+	loc := time.UTC
+	if l, err := time.LoadLocation(query.Timezone); err == nil {
+		loc = l
+	}
+
+	var write func(transaction model.Transaction) error
+	var finish func() error
+
+	switch format {
+	case model.ExportFormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(exportColumns); err != nil {
+			return err
+		}
+
+		write = func(transaction model.Transaction) error {
+			return cw.Write(exportRecord(transaction, loc))
+		}
+		finish = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	case model.ExportFormatXLSX:
+		xw, err := utils.NewXLSXWriter(w)
+		if err != nil {
+			return err
+		}
+
+		header := make([]interface{}, len(exportColumns))
+		for i, column := range exportColumns {
+			header[i] = column
+		}
+
+		if err := xw.WriteRow(header...); err != nil {
+			return err
+		}
+
+		write = func(transaction model.Transaction) error {
+			record := exportRecord(transaction, loc)
+			cells := make([]interface{}, len(record))
+			for i, value := range record {
+				cells[i] = value
+			}
+
+			// inline strings are never evaluated, and money stays numeric so it can be summed
+			cells[3], cells[4], cells[12] = transaction.Category, transaction.Description, strings.Join(model.TagNames(transaction.Tags), ",")
+			cells[5], cells[7], cells[8] = transaction.Amount, transaction.OriginalAmount, transaction.ExchangeRate
+
+			return xw.WriteRow(cells...)
+		}
+		finish = xw.Close
+	case model.ExportFormatJSONL:
+		encoder := json.NewEncoder(w)
+
+		write = func(transaction model.Transaction) error {
+			return encoder.Encode(transaction)
+		}
+		finish = func() error { return nil }
+	default:
+		return model.ErrUnsupportedFormat
+	}
+
+	var batch []model.Transaction
+
+	err := r.db.WithContext(ctx).
+		Preload("User").
+		Preload("TransactionShares").
+		Preload("Tags", "tags.user_id = ?", query.ParticipantID).
+		Scopes(transactionFilter(query)).
+		FindInBatches(&batch, exportBatchSize, func(tx *gorm.DB, _ int) error {
+			for _, transaction := range batch {
+				if err := write(transaction); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}).Error
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	return finish()
+}
+
+func exportRecord(transaction model.Transaction, loc *time.Location) []string {
+	return []string{
+		transaction.ID,
+		transaction.SpentAt.In(loc).Format("2006-01-02 15:04"),
+		transaction.TransactionType,
+		spreadsheetSafe(transaction.Category),
+		spreadsheetSafe(transaction.Description),
+		transaction.Amount.String(),
+		transaction.Currency,
+		transaction.OriginalAmount.String(),
+		transaction.ExchangeRate.String(),
+		transaction.WalletID,
+		fmt.Sprint(transaction.IsShared),
+		transaction.User.Name,
+		spreadsheetSafe(strings.Join(model.TagNames(transaction.Tags), ",")),
+	}
+}
+
+// spreadsheetSafe stops free text such as "=HYPERLINK(...)" from being run
+// as a formula when the export is opened in a spreadsheet.
+func spreadsheetSafe(s string) string {
+	if s != "" && strings.ContainsRune("=+-@", rune(s[0])) {
+		return "'" + s
+	}
+
+	return s
+}
+
+func (r *exportRepository) WriteStatement(ctx context.Context, w io.Writer, query model.StatementQueryInput) error {
+	logger := logrus.WithField("query", utils.Dump(query))
+
+	var wallet model.Wallet
+	if err := r.db.WithContext(ctx).Where("id = ?", query.WalletID).First(&wallet).Error; err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	preference, err := r.preferenceRepo.Find(ctx, wallet.UserID)
+	if err != nil {
+		return err
+	}
+
+	loc := preference.Location()
+
+	month, err := time.ParseInLocation("2006-01", query.Month, loc)
+	if err != nil {
+		return fmt.Errorf("invalid month %q", query.Month)
+	}
+
+	startDate := month.Format("2006-01-02")
+	endDate := month.AddDate(0, 1, -1).Format("2006-01-02")
+	timezone := preference.Timezone
+
+	var opening decimal.Decimal
+
+	err = r.db.WithContext(ctx).Model(&model.Transaction{}).
+		Select("COALESCE(SUM(CASE WHEN transaction_type = ? THEN amount ELSE -amount END), 0)", model.TransactionTypeIncome).
+		Where("wallet_id = ?", wallet.ID).
+		Where("DATE(spent_at AT TIME ZONE ?) < ?", timezone, startDate).
+		Scan(&opening).Error
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	var entries []model.Transaction
+
+	err = r.db.WithContext(ctx).
+		Where("wallet_id = ?", wallet.ID).
+		Scopes(spentBetween(timezone, startDate, endDate)).
+		Order("spent_at ASC").
+		Find(&entries).Error
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	money := func(amount decimal.Decimal) string {
+		return utils.FormatMoney(amount, preference.BaseCurrency, preference.Locale)
+	}
+
+	const (
+		left       = 40.0
+		right      = utils.PDFPageWidth - 40
+		top        = utils.PDFPageHeight - 50
+		bottom     = 60.0
+		lineHeight = 14.0
+	)
+
+	pdf := utils.NewPDF()
+	y := 0.0
+
+	tableHeader := func() {
+		pdf.Text(left, y, 9, true, "Date")
+		pdf.Text(left+70, y, 9, true, "Description")
+		pdf.Text(left+290, y, 9, true, "Category")
+		pdf.TextRight(right-90, y, 9, true, "Amount")
+		pdf.TextRight(right, y, 9, true, "Balance")
+		pdf.Line(left, y-4, right, y-4)
+		y -= lineHeight + 2
+	}
+
+	newPage := func() {
+		pdf.AddPage()
+		y = top
+		tableHeader()
+	}
+
+	pdf.AddPage()
+	y = top
+	pdf.Text(left, y, 16, true, "Statement: "+wallet.Name)
+	y -= 20
+	pdf.Text(left, y, 10, false, fmt.Sprintf("Period %s to %s (%s)", month.Format("2 Jan 2006"), month.AddDate(0, 1, -1).Format("2 Jan 2006"), timezone))
+	y -= 24
+	pdf.Text(left, y, 10, true, "Opening balance")
+	pdf.TextRight(right, y, 10, true, money(opening))
+	y -= 24
+	tableHeader()
+
+	balance := opening
+	var income, expense decimal.Decimal
+
+	for _, entry := range entries {
+		if y < bottom {
+			newPage()
+		}
+
+		amount := entry.Amount
+		if entry.TransactionType == model.TransactionTypeIncome {
+			income = income.Add(amount)
+		} else {
+			expense = expense.Add(amount)
+			amount = amount.Neg()
+		}
+
+		balance = balance.Add(amount)
+
+		description := entry.Description
+		if runes := []rune(description); len(runes) > 42 {
+			description = string(runes[:39]) + "..."
+		}
+
+		pdf.Text(left, y, 9, false, entry.SpentAt.In(loc).Format("02 Jan"))
+		pdf.Text(left+70, y, 9, false, description)
+		pdf.Text(left+290, y, 9, false, entry.Category)
+		pdf.TextRight(right-90, y, 9, false, money(amount))
+		pdf.TextRight(right, y, 9, false, money(balance))
+		y -= lineHeight
+	}
+
+	if y < bottom+60 {
+		pdf.AddPage()
+		y = top
+	}
+
+	pdf.Line(left, y+4, right, y+4)
+	y -= 10
+
+	for _, total := range []struct {
+		label  string
+		amount decimal.Decimal
+	}{
+		{"Money in", income},
+		{"Money out", expense.Neg()},
+		{"Closing balance", balance},
+	} {
+		pdf.Text(left, y, 10, true, total.label)
+		pdf.TextRight(right, y, 10, true, money(total.amount))
+		y -= lineHeight + 2
+	}
+
+	if _, err := pdf.WriteTo(w); err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *exportRepository) CreateJob(ctx context.Context, job *model.ExportJob) error {
+	logger := logrus.WithField("job", utils.Dump(job))
+
+	if err := r.db.WithContext(ctx).Create(job).Error; err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *exportRepository) FindJobs(ctx context.Context, userID string) ([]model.ExportJob, error) {
+	logger := logrus.WithField("user_id", userID)
+
+	var jobs []model.ExportJob
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at DESC").Limit(50).Find(&jobs).Error; err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+func (r *exportRepository) FindJobByID(ctx context.Context, id string) (model.ExportJob, error) {
+	logger := logrus.WithField("id", id)
+
+	var job model.ExportJob
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&job).Error; err != nil {
+		logger.Error(err)
+		return model.ExportJob{}, err
+	}
+
+	return job, nil
+}
+
+func (r *exportRepository) RunPendingJobs(ctx context.Context, dir string) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	r.removeExpired(ctx)
+
+	for {
+		job, ok, err := r.claimJob(ctx)
+		if err != nil || !ok {
+			return err
+		}
+
+		r.runJob(ctx, dir, job)
+	}
+}
+
+// claimJob takes the oldest pending job, skipping rows another worker holds.
+func (r *exportRepository) claimJob(ctx context.Context) (model.ExportJob, bool, error) {
+	var jobs []model.ExportJob
+
+	err := r.db.WithContext(ctx).Raw(`
+		UPDATE export_jobs SET status = ?, updated_at = NOW()
+		WHERE id = (
+			SELECT id FROM export_jobs WHERE status = ? ORDER BY created_at LIMIT 1 FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`, model.ExportStatusRunning, model.ExportStatusPending).Scan(&jobs).Error
+	if err != nil {
+		logrus.Error(err)
+		return model.ExportJob{}, false, err
+	}
+
+	if len(jobs) == 0 {
+		return model.ExportJob{}, false, nil
+	}
+
+	return jobs[0], true, nil
+}
+
+func (r *exportRepository) runJob(ctx context.Context, dir string, job model.ExportJob) {
+	logger := logrus.WithField("job_id", job.ID)
+
+	path := filepath.Join(dir, job.ID+"."+job.Format)
+
+	size, err := r.renderJob(ctx, path, job)
+
+	now := time.Now()
+	updates := map[string]interface{}{"finished_at": now}
+
+	if err != nil {
+		logger.Errorf("export failed: %v", err)
+		os.Remove(path)
+
+		updates["status"] = model.ExportStatusFailed
+		updates["error"] = err.Error()
+	} else {
+		updates["status"] = model.ExportStatusDone
+		updates["file_path"] = path
+		updates["size"] = size
+		updates["expires_at"] = now.Add(model.ExportTTL)
+	}
+
+	if err := r.db.WithContext(ctx).Model(&model.ExportJob{}).Where("id = ?", job.ID).Updates(updates).Error; err != nil {
+		logger.Error(err)
+	}
+}
+
+func (r *exportRepository) renderJob(ctx context.Context, path string, job model.ExportJob) (int64, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	switch {
+	case job.Kind == model.ExportKindTransactions && job.Params.Transactions != nil:
+		err = r.WriteTransactions(ctx, file, job.Format, *job.Params.Transactions)
+	case job.Kind == model.ExportKindStatement && job.Params.Statement != nil:
+		err = r.WriteStatement(ctx, file, *job.Params.Statement)
+	default:
+		err = fmt.Errorf("export job %s has no parameters", job.ID)
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	return info.Size(), nil
+}
+
+// removeExpired deletes files past their download window and requeues jobs
+// left running by a worker that died.
+func (r *exportRepository) removeExpired(ctx context.Context) {
+	var expired []model.ExportJob
+
+	err := r.db.WithContext(ctx).
+		Where("status = ? AND expires_at < ? AND file_path <> ''", model.ExportStatusDone, time.Now()).
+		Find(&expired).Error
+	if err != nil {
+		logrus.Error(err)
+		return
+	}
+
+	for _, job := range expired {
+		if err := os.Remove(job.FilePath); err != nil && !os.IsNotExist(err) {
+			logrus.WithField("job_id", job.ID).Error(err)
+			continue
+		}
+
+		r.db.WithContext(ctx).Model(&model.ExportJob{}).Where("id = ?", job.ID).Update("file_path", "")
+	}
+
+	err = r.db.WithContext(ctx).Model(&model.ExportJob{}).
+		Where("status = ? AND updated_at < ?", model.ExportStatusRunning, time.Now().Add(-time.Hour)).
+		Update("status", model.ExportStatusPending).Error
+	if err != nil {
+		logrus.Error(err)
+	}
+}
+
+// ExportDir is where background exports are written, from EXPORT_DIR.
+func ExportDir() string {
+	if dir := os.Getenv("EXPORT_DIR"); dir != "" {
+		return dir
+	}
+
+	return filepath.Join(os.TempDir(), "anggar-exports")
+}
+
+// RunExportWorker processes queued export jobs every interval until ctx is cancelled.
+func RunExportWorker(ctx context.Context, repo model.ExportRepository, dir string, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := repo.RunPendingJobs(ctx, dir); err != nil {
+			logrus.Errorf("export worker failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/notblessy/anggar-service/model"
+	"github.com/notblessy/anggar-service/utils"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+type goalRepository struct {
+	db             *gorm.DB
+	preferenceRepo model.PreferenceRepository
+	notifier       model.Notifier
+}
+
+// NewGoalRepository :nodoc:
+func NewGoalRepository(db *gorm.DB, preferenceRepo model.PreferenceRepository, notifier model.Notifier) model.GoalRepository {
+	return &goalRepository{db, preferenceRepo, notifier}
+}
+
+func (r *goalRepository) Create(ctx context.Context, goal *model.Goal) error {
+	if err := r.db.WithContext(ctx).Create(goal).Error; err != nil {
+		logrus.WithField("goal", utils.Dump(goal)).Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *goalRepository) FindAll(ctx context.Context, userID string) ([]model.Goal, error) {
+	goals := []model.Goal{}
+
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at ASC").Find(&goals).Error; err != nil {
+		logrus.WithField("user_id", userID).Error(err)
+		return nil, err
+	}
+
+	return goals, nil
+}
+
+func (r *goalRepository) FindByID(ctx context.Context, id string) (model.Goal, error) {
+	var goal model.Goal
+
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&goal).Error; err != nil {
+		logrus.WithField("id", id).Error(err)
+		return model.Goal{}, err
+	}
+
+	return goal, nil
+}
+
+func (r *goalRepository) Update(ctx context.Context, goal model.Goal) error {
+	err := r.db.WithContext(ctx).Model(&model.Goal{}).Where("id = ?", goal.ID).Updates(map[string]interface{}{
+		"name":          goal.Name,
+		"target_amount": goal.TargetAmount,
+		"deadline":      goal.Deadline,
+		"wallet_id":     goal.WalletID,
+		"milestone":     goal.Milestone,
+		"updated_at":    time.Now(),
+	}).Error
+	if err != nil {
+		logrus.WithField("goal", utils.Dump(goal)).Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *goalRepository) Delete(ctx context.Context, id string) error {
+	logger := logrus.WithField("id", id)
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("goal_id = ?", id).Delete(&model.GoalContribution{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Where("id = ?", id).Delete(&model.Goal{}).Error
+	})
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *goalRepository) AddContribution(ctx context.Context, contribution *model.GoalContribution) error {
+	if err := r.db.WithContext(ctx).Create(contribution).Error; err != nil {
+		logrus.WithField("contribution", utils.Dump(contribution)).Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *goalRepository) FindContributions(ctx context.Context, goalID string) ([]model.GoalContribution, error) {
+	contributions := []model.GoalContribution{}
+
+	err := r.db.WithContext(ctx).Where("goal_id = ?", goalID).
+		Order("contributed_at DESC, id DESC").
+		Find(&contributions).Error
+	if err != nil {
+		logrus.WithField("goal_id", goalID).Error(err)
+		return nil, err
+	}
+
+	return contributions, nil
+}
+
+func (r *goalRepository) FindContributionByID(ctx context.Context, id string) (model.GoalContribution, error) {
+	var contribution model.GoalContribution
+
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&contribution).Error; err != nil {
+		logrus.WithField("id", id).Error(err)
+		return model.GoalContribution{}, err
+	}
+
+	return contribution, nil
+}
+
+func (r *goalRepository) DeleteContribution(ctx context.Context, id string) error {
+	if err := r.db.WithContext(ctx).Where("id = ?", id).Delete(&model.GoalContribution{}).Error; err != nil {
+		logrus.WithField("id", id).Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *goalRepository) Progress(ctx context.Context, goal model.Goal, now time.Time) (model.GoalProgress, error) {
+	logger := logrus.WithField("goal_id", goal.ID)
+
+	saved, err := r.saved(ctx, goal, time.Time{})
+	if err != nil {
+		logger.Error(err)
+		return model.GoalProgress{}, err
+	}
+
+	recent, err := r.saved(ctx, goal, goal.HistoryStart(now))
+	if err != nil {
+		logger.Error(err)
+		return model.GoalProgress{}, err
+	}
+
+	return model.NewGoalProgress(goal, now, saved, recent), nil
+}
+
+// saved sums what went towards the goal since the given time, or ever when
+// it is zero: the net flow of its wallet, or its contributions.
+func (r *goalRepository) saved(ctx context.Context, goal model.Goal, since time.Time) (decimal.Decimal, error) {
+	var sum decimal.Decimal
+
+	var qb *gorm.DB
+	if goal.WalletID != nil {
+		qb = r.db.WithContext(ctx).Model(&model.Transaction{}).
+			Select("COALESCE(SUM(CASE WHEN transaction_type = ? THEN amount ELSE -amount END), 0)", model.TransactionTypeIncome).
+			Where("wallet_id = ?", *goal.WalletID)
+
+		if !since.IsZero() {
+			qb = qb.Where("spent_at >= ?", since)
+		}
+	} else {
+		qb = r.db.WithContext(ctx).Model(&model.GoalContribution{}).
+			Select("COALESCE(SUM(amount), 0)").
+			Where("goal_id = ?", goal.ID)
+
+		if !since.IsZero() {
+			qb = qb.Where("contributed_at >= ?", since)
+		}
+	}
+
+	err := qb.Scan(&sum).Error
+
+	return sum, err
+}
+
+func (r *goalRepository) Celebrate(ctx context.Context, now time.Time) (int, error) {
+	if r.notifier == nil {
+		return 0, nil
+	}
+
+	var goals []model.Goal
+
+	if err := r.db.WithContext(ctx).Where("milestone < ?", 100).Find(&goals).Error; err != nil {
+		logrus.Error(err)
+		return 0, err
+	}
+
+	sent := 0
+
+	for _, goal := range goals {
+		logger := logrus.WithField("goal_id", goal.ID)
+
+		progress, err := r.Progress(ctx, goal, now)
+		if err != nil {
+			continue
+		}
+
+		milestone := progress.Milestone()
+		if milestone <= goal.Milestone {
+			continue
+		}
+
+		preference, err := r.preferenceRepo.Find(ctx, goal.UserID)
+		if err != nil {
+			continue
+		}
+
+		if err := r.notifier.Notify(ctx, goal.UserID, goalMilestoneMessage(goal, progress, milestone, preference)); err != nil {
+			logger.Errorf("failed to send goal milestone: %v", err)
+			continue
+		}
+
+		err = r.db.WithContext(ctx).Model(&model.Goal{}).
+			Where("id = ?", goal.ID).
+			UpdateColumn("milestone", milestone).Error
+		if err != nil {
+			logger.Error(err)
+			continue
+		}
+
+		sent++
+	}
+
+	return sent, nil
+}
+
+func goalMilestoneMessage(goal model.Goal, progress model.GoalProgress, milestone int, preference model.UserPreference) string {
+	money := func(amount decimal.Decimal) string {
+		return utils.FormatMoney(amount, preference.BaseCurrency, preference.Locale)
+	}
+
+	if milestone == 100 {
+		return fmt.Sprintf("🎉 You reached your goal %s: %s saved. Well done!", goal.Name, money(progress.Saved))
+	}
+
+	return fmt.Sprintf("🎉 You are %d%% of the way to %s: %s of %s saved.",
+		milestone,
+		goal.Name,
+		money(progress.Saved),
+		money(goal.TargetAmount),
+	)
+}
+
+// RunGoalMilestones celebrates goal milestones every interval until ctx is
+// cancelled.
+func RunGoalMilestones(ctx context.Context, repo model.GoalRepository, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := repo.Celebrate(ctx, time.Now()); err != nil {
+			logrus.Errorf("goal milestones failed: %v", err)
+		} else if n > 0 {
+			logrus.Infof("celebrated %d goal milestones", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
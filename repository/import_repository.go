@@ -0,0 +1,222 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/notblessy/anggar-service/model"
+	"github.com/notblessy/anggar-service/utils"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+type importRepository struct {
+	db *gorm.DB
+}
+
+// NewImportRepository :nodoc:
+func NewImportRepository(db *gorm.DB) model.ImportRepository {
+	return &importRepository{db}
+}
+
+func (r *importRepository) FindMappings(ctx context.Context, userID string) ([]model.ImportMapping, error) {
+	logger := logrus.WithField("user_id", userID)
+
+	var mappings []model.ImportMapping
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("name ASC").Find(&mappings).Error; err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	return mappings, nil
+}
+
+func (r *importRepository) FindMappingByID(ctx context.Context, id string) (model.ImportMapping, error) {
+	logger := logrus.WithField("id", id)
+
+	var mapping model.ImportMapping
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&mapping).Error; err != nil {
+		logger.Error(err)
+		return model.ImportMapping{}, err
+	}
+
+	return mapping, nil
+}
+
+func (r *importRepository) SaveMapping(ctx context.Context, mapping *model.ImportMapping) error {
+	logger := logrus.WithField("mapping", utils.Dump(mapping))
+
+	if err := r.db.WithContext(ctx).Save(mapping).Error; err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *importRepository) DeleteMapping(ctx context.Context, id string) error {
+	logger := logrus.WithField("id", id)
+
+	if err := r.db.WithContext(ctx).Where("id = ?", id).Delete(&model.ImportMapping{}).Error; err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *importRepository) FindAll(ctx context.Context, query model.ImportQueryInput) ([]model.ImportBatch, int64, error) {
+	logger := logrus.WithField("query", utils.Dump(query))
+
+	var batches []model.ImportBatch
+
+	qb := r.db.WithContext(ctx).Model(&model.ImportBatch{}).Where("user_id = ?", query.UserID)
+
+	var total int64
+	if err := qb.Count(&total).Error; err != nil {
+		logger.Error(err)
+		return nil, 0, err
+	}
+
+	// rows can be large, the list only needs the summary
+	if err := qb.Omit("rows").Scopes(query.Paginated()).Order(query.Sorted()).Find(&batches).Error; err != nil {
+		logger.Error(err)
+		return nil, 0, err
+	}
+
+	return batches, total, nil
+}
+
+func (r *importRepository) FindByID(ctx context.Context, id string) (model.ImportBatch, error) {
+	logger := logrus.WithField("id", id)
+
+	var batch model.ImportBatch
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&batch).Error; err != nil {
+		logger.Error(err)
+		return model.ImportBatch{}, err
+	}
+
+	return batch, nil
+}
+
+func (r *importRepository) CreateBatch(ctx context.Context, batch *model.ImportBatch) error {
+	logger := logrus.WithField("batch_id", batch.ID)
+
+	if err := r.db.WithContext(ctx).Create(batch).Error; err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *importRepository) FindCandidates(ctx context.Context, userID string, start, end time.Time) ([]model.Transaction, error) {
+	logger := logrus.WithField("user_id", userID)
+
+	var transactions []model.Transaction
+
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND spent_at >= ? AND spent_at < ?", userID, start, end).
+		Find(&transactions).Error
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	return transactions, nil
+}
+
+func (r *importRepository) Commit(ctx context.Context, batch *model.ImportBatch, transactions []model.Transaction) error {
+	logger := logrus.WithField("batch_id", batch.ID)
+
+	now := time.Now()
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Claim the batch first so a double submit cannot import it twice.
+		res := tx.Model(&model.ImportBatch{}).
+			Where("id = ? AND status = ?", batch.ID, model.ImportStatusPreview).
+			Updates(map[string]interface{}{
+				"status":            model.ImportStatusCommitted,
+				"transaction_count": len(transactions),
+				"committed_at":      now,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+
+		if res.RowsAffected == 0 {
+			return model.ErrImportNotPending
+		}
+
+		if len(transactions) == 0 {
+			return nil
+		}
+
+		if err := tx.CreateInBatches(transactions, 500).Error; err != nil {
+			return err
+		}
+
+		return auditTransactions(tx, batch.UserID, model.AuditActionCreated, transactions)
+	})
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	batch.Status = model.ImportStatusCommitted
+	batch.TransactionCount = len(transactions)
+	batch.CommittedAt = &now
+
+	return nil
+}
+
+func (r *importRepository) Undo(ctx context.Context, batch *model.ImportBatch) (int64, error) {
+	logger := logrus.WithField("batch_id", batch.ID)
+
+	now := time.Now()
+
+	var removed int64
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&model.ImportBatch{}).
+			Where("id = ? AND status = ?", batch.ID, model.ImportStatusCommitted).
+			Updates(map[string]interface{}{
+				"status":    model.ImportStatusUndone,
+				"undone_at": now,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+
+		if res.RowsAffected == 0 {
+			return model.ErrImportNotCommitted
+		}
+
+		imported := []model.Transaction{}
+		if err := tx.Preload("TransactionShares").Where("import_batch_id = ?", batch.ID).Find(&imported).Error; err != nil {
+			return err
+		}
+
+		if err := auditTransactions(tx, batch.UserID, model.AuditActionDeleted, imported); err != nil {
+			return err
+		}
+
+		res = tx.Where("import_batch_id = ?", batch.ID).Delete(&model.Transaction{})
+		if res.Error != nil {
+			return res.Error
+		}
+
+		removed = res.RowsAffected
+
+		return nil
+	})
+	if err != nil {
+		logger.Error(err)
+		return 0, err
+	}
+
+	batch.Status = model.ImportStatusUndone
+	batch.UndoneAt = &now
+
+	return removed, nil
+}
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/notblessy/anggar-service/model"
+	"github.com/sirupsen/logrus"
+)
+
+type localStorage struct {
+	dir string
+}
+
+// NewLocalStorage keeps files under dir, one file per key.
+func NewLocalStorage(dir string) model.FileStorage {
+	return &localStorage{dir: dir}
+}
+
+// path maps a key into the storage directory, refusing keys that would
+// escape it.
+func (s *localStorage) path(key string) (string, error) {
+	path := filepath.Join(s.dir, filepath.FromSlash(key))
+	if !strings.HasPrefix(path, filepath.Clean(s.dir)+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid storage key %q", key)
+	}
+
+	return path, nil
+}
+
+func (s *localStorage) Put(ctx context.Context, key, contentType string, data []byte) error {
+	logger := logrus.WithField("key", key)
+
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	// Write aside and rename so a reader never sees half a file.
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o640); err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		logger.Error(err)
+		os.Remove(tmp)
+		return err
+	}
+
+	return nil
+}
+
+func (s *localStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, model.ErrFileNotFound
+	}
+
+	if err != nil {
+		logrus.WithField("key", key).Error(err)
+		return nil, err
+	}
+
+	return file, nil
+}
+
+func (s *localStorage) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		logrus.WithField("key", key).Error(err)
+		return err
+	}
+
+	return nil
+}
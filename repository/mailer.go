@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+
+	"github.com/notblessy/anggar-service/model"
+	"github.com/sirupsen/logrus"
+)
+
+type smtpMailer struct {
+	addr     string
+	host     string
+	username string
+	password string
+	from     string
+}
+
+// NewMailer returns an SMTP mailer configured from SMTP_* variables, or a
+// mailer that only logs messages when SMTP_HOST is not set. The log leaves out
+// message bodies, which carry live tokens, unless MAIL_LOG_BODY is "true" for
+// local development.
+func NewMailer() model.Mailer {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		return &logMailer{body: os.Getenv("MAIL_LOG_BODY") == "true"}
+	}
+
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "25"
+	}
+
+	return NewSMTPMailer(net.JoinHostPort(host, port), os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"), os.Getenv("SMTP_FROM"))
+}
+
+// NewSMTPMailer sends mail through the SMTP server at addr (host:port).
+// Authentication is skipped when username is empty, which suits local stand-in servers.
+func NewSMTPMailer(addr, username, password, from string) model.Mailer {
+	host, _, _ := net.SplitHostPort(addr)
+
+	return &smtpMailer{
+		addr:     addr,
+		host:     host,
+		username: username,
+		password: password,
+		from:     from,
+	}
+}
+
+func (m *smtpMailer) Send(ctx context.Context, mail model.Mail) error {
+	logger := logrus.WithField("to", mail.To).WithField("subject", mail.Subject)
+
+	var auth smtp.Auth
+	if m.username != "" {
+		auth = smtp.PlainAuth("", m.username, m.password, m.host)
+	}
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("From: %s\r\n", m.from))
+	b.WriteString(fmt.Sprintf("To: %s\r\n", mail.To))
+	b.WriteString(fmt.Sprintf("Subject: %s\r\n", mail.Subject))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	b.WriteString(mail.Body)
+
+	if err := smtp.SendMail(m.addr, auth, m.from, []string{mail.To}, []byte(b.String())); err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+type logMailer struct {
+	body bool
+}
+
+func (m *logMailer) Send(ctx context.Context, mail model.Mail) error {
+	logger := logrus.WithField("to", mail.To).WithField("subject", mail.Subject)
+
+	if m.body {
+		logger.Info(mail.Body)
+		return nil
+	}
+
+	logger.Info("mail not sent, SMTP_HOST is not set")
+
+	return nil
+}
@@ -0,0 +1,224 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/notblessy/anggar-service/model"
+	"github.com/notblessy/anggar-service/utils"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type mfaRepository struct {
+	db *gorm.DB
+}
+
+// NewMFARepository :nodoc:
+func NewMFARepository(db *gorm.DB) model.MFARepository {
+	return &mfaRepository{db}
+}
+
+func (r *mfaRepository) FindTOTP(ctx context.Context, userID string) (model.UserTOTP, error) {
+	var totp model.UserTOTP
+
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).First(&totp).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return model.UserTOTP{}, model.ErrMFANotEnrolled
+	}
+
+	if err != nil {
+		logrus.WithField("user_id", userID).Error(err)
+		return model.UserTOTP{}, err
+	}
+
+	return totp, nil
+}
+
+func (r *mfaRepository) Enroll(ctx context.Context, userID, secret string) error {
+	logger := logrus.WithField("user_id", userID)
+
+	totp := model.UserTOTP{
+		UserID:    userID,
+		Secret:    secret,
+		CreatedAt: time.Now(),
+	}
+
+	// Only a pending enrolment may be replaced, an active one must be disabled first.
+	res := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"secret", "created_at", "last_used_step", "failed_attempts", "locked_until"}),
+		Where:     clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "user_totps.confirmed_at IS NULL"}}},
+	}).Create(&totp)
+	if res.Error != nil {
+		logger.Error(res.Error)
+		return res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		return model.ErrMFAAlreadyActive
+	}
+
+	return nil
+}
+
+func (r *mfaRepository) Confirm(ctx context.Context, userID string, step int64) error {
+	logger := logrus.WithField("user_id", userID)
+
+	err := r.db.WithContext(ctx).
+		Model(&model.UserTOTP{}).
+		Where("user_id = ?", userID).
+		Updates(map[string]interface{}{
+			"confirmed_at":   time.Now(),
+			"last_used_step": step,
+		}).Error
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *mfaRepository) MarkStepUsed(ctx context.Context, userID string, step int64) error {
+	logger := logrus.WithField("user_id", userID)
+
+	res := r.db.WithContext(ctx).
+		Model(&model.UserTOTP{}).
+		Where("user_id = ? AND last_used_step < ?", userID, step).
+		Update("last_used_step", step)
+	if res.Error != nil {
+		logger.Error(res.Error)
+		return res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		return model.ErrInvalidMFACode
+	}
+
+	return nil
+}
+
+func (r *mfaRepository) RecordFailure(ctx context.Context, userID string, now time.Time) error {
+	logger := logrus.WithField("user_id", userID)
+
+	// Counted in one statement so concurrent guesses cannot slip past the limit.
+	limit := gorm.Expr("failed_attempts + 1 >= ?", model.MFAMaxFailures)
+
+	err := r.db.WithContext(ctx).
+		Model(&model.UserTOTP{}).
+		Where("user_id = ?", userID).
+		Updates(map[string]interface{}{
+			"failed_attempts": gorm.Expr("CASE WHEN ? THEN 0 ELSE failed_attempts + 1 END", limit),
+			"locked_until":    gorm.Expr("CASE WHEN ? THEN ? ELSE locked_until END", limit, now.Add(model.MFALockoutDuration)),
+		}).Error
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *mfaRepository) ResetFailures(ctx context.Context, userID string) error {
+	logger := logrus.WithField("user_id", userID)
+
+	err := r.db.WithContext(ctx).
+		Model(&model.UserTOTP{}).
+		Where("user_id = ?", userID).
+		Updates(map[string]interface{}{
+			"failed_attempts": 0,
+			"locked_until":    nil,
+		}).Error
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *mfaRepository) Disable(ctx context.Context, userID string) error {
+	logger := logrus.WithField("user_id", userID)
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&model.UserTOTP{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Where("user_id = ?", userID).Delete(&model.RecoveryCode{}).Error
+	})
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *mfaRepository) ReplaceRecoveryCodes(ctx context.Context, userID string, codes []string) error {
+	logger := logrus.WithField("user_id", userID)
+
+	records := make([]model.RecoveryCode, 0, len(codes))
+	for _, code := range codes {
+		records = append(records, model.RecoveryCode{
+			UserID:   userID,
+			CodeHash: utils.HashToken(normalizeRecoveryCode(code)),
+		})
+	}
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&model.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&records).Error
+	})
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *mfaRepository) UseRecoveryCode(ctx context.Context, userID, code string) error {
+	logger := logrus.WithField("user_id", userID)
+
+	res := r.db.WithContext(ctx).
+		Model(&model.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, utils.HashToken(normalizeRecoveryCode(code))).
+		Update("used_at", time.Now())
+	if res.Error != nil {
+		logger.Error(res.Error)
+		return res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		return model.ErrInvalidMFACode
+	}
+
+	return nil
+}
+
+func (r *mfaRepository) CountRecoveryCodes(ctx context.Context, userID string) (int64, error) {
+	var count int64
+
+	err := r.db.WithContext(ctx).
+		Model(&model.RecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&count).Error
+	if err != nil {
+		logrus.WithField("user_id", userID).Error(err)
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), " ", ""))
+}
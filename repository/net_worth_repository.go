@@ -0,0 +1,195 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/notblessy/anggar-service/model"
+	"github.com/notblessy/anggar-service/utils"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+type netWorthRepository struct {
+	db             *gorm.DB
+	preferenceRepo model.PreferenceRepository
+}
+
+// NewNetWorthRepository :nodoc:
+func NewNetWorthRepository(db *gorm.DB, preferenceRepo model.PreferenceRepository) model.NetWorthRepository {
+	return &netWorthRepository{db, preferenceRepo}
+}
+
+func (r *netWorthRepository) CreateAsset(ctx context.Context, asset *model.Asset) error {
+	if err := r.db.WithContext(ctx).Create(asset).Error; err != nil {
+		logrus.WithField("asset", utils.Dump(asset)).Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *netWorthRepository) FindAllAssets(ctx context.Context, userID string) ([]model.Asset, error) {
+	assets := []model.Asset{}
+
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("kind, name").Find(&assets).Error; err != nil {
+		logrus.WithField("user_id", userID).Error(err)
+		return nil, err
+	}
+
+	return assets, nil
+}
+
+func (r *netWorthRepository) FindAssetByID(ctx context.Context, id string) (model.Asset, error) {
+	var asset model.Asset
+
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&asset).Error; err != nil {
+		logrus.WithField("id", id).Error(err)
+		return model.Asset{}, err
+	}
+
+	return asset, nil
+}
+
+func (r *netWorthRepository) UpdateAsset(ctx context.Context, asset model.Asset) error {
+	err := r.db.WithContext(ctx).Model(&model.Asset{}).Where("id = ?", asset.ID).Updates(map[string]interface{}{
+		"name":       asset.Name,
+		"kind":       asset.Kind,
+		"value":      asset.Value,
+		"updated_at": time.Now(),
+	}).Error
+	if err != nil {
+		logrus.WithField("asset", utils.Dump(asset)).Error(err)
+		return err
+	}
+
+	return nil
+}
+
+// DeleteAsset removes the asset. Its past snapshots stay in the series.
+func (r *netWorthRepository) DeleteAsset(ctx context.Context, id string) error {
+	if err := r.db.WithContext(ctx).Where("id = ?", id).Delete(&model.Asset{}).Error; err != nil {
+		logrus.WithField("id", id).Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *netWorthRepository) Snapshot(ctx context.Context, userID string, now time.Time) error {
+	logger := logrus.WithField("user_id", userID)
+
+	preference, err := r.preferenceRepo.Find(ctx, userID)
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	loc := preference.Location()
+
+	args := map[string]interface{}{
+		"user":      userID,
+		"date":      now.In(loc).Format(time.DateOnly),
+		"timezone":  loc.String(),
+		"now":       now,
+		"income":    model.TransactionTypeIncome,
+		"liability": model.AssetKindLiability,
+		"wallet":    model.SnapshotSourceWallet,
+		"asset":     model.SnapshotSourceAsset,
+	}
+
+	statements := []string{
+		`DELETE FROM balance_snapshots WHERE user_id = @user AND date = CAST(@date AS DATE)`,
+		// archived wallets still hold their money
+		`INSERT INTO balance_snapshots (user_id, date, source_type, source_id, name, balance, created_at)
+			SELECT w.user_id, CAST(@date AS DATE), @wallet, w.id, w.name,
+				COALESCE(SUM(CASE WHEN t.transaction_type = @income THEN t.amount ELSE -t.amount END), 0), @now
+			FROM wallets w
+			LEFT JOIN transactions t ON t.wallet_id = w.id AND t.deleted_at IS NULL
+				AND DATE(t.spent_at AT TIME ZONE @timezone) <= CAST(@date AS DATE)
+			WHERE w.user_id = @user AND w.deleted_at IS NULL
+			GROUP BY w.id, w.user_id, w.name`,
+		`INSERT INTO balance_snapshots (user_id, date, source_type, source_id, name, balance, created_at)
+			SELECT a.user_id, CAST(@date AS DATE), @asset, a.id, a.name,
+				CASE WHEN a.kind = @liability THEN -a.value ELSE a.value END, @now
+			FROM assets a
+			WHERE a.user_id = @user`,
+	}
+
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, statement := range statements {
+			if err := tx.Exec(statement, args).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *netWorthRepository) SnapshotAll(ctx context.Context, now time.Time) (int, error) {
+	var users []string
+
+	err := r.db.WithContext(ctx).
+		Raw(`SELECT user_id FROM wallets WHERE deleted_at IS NULL UNION SELECT user_id FROM assets`).
+		Scan(&users).Error
+	if err != nil {
+		logrus.Error(err)
+		return 0, err
+	}
+
+	taken := 0
+	for _, userID := range users {
+		if err := r.Snapshot(ctx, userID, now); err != nil {
+			continue
+		}
+
+		taken++
+	}
+
+	return taken, nil
+}
+
+func (r *netWorthRepository) Series(ctx context.Context, query model.NetWorthQueryInput) ([]model.NetWorthPoint, error) {
+	logger := logrus.WithField("query", utils.Dump(query))
+
+	var snapshots []model.BalanceSnapshot
+
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND date BETWEEN ? AND ?", query.UserID, query.StartDate, query.EndDate).
+		Order("date, source_type DESC, name, source_id").
+		Find(&snapshots).Error
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	return model.NewNetWorthSeries(snapshots), nil
+}
+
+// RunNetWorthSnapshots refreshes today's balance snapshots every interval
+// until ctx is cancelled. The last run of a day is the one that stays.
+func RunNetWorthSnapshots(ctx context.Context, repo model.NetWorthRepository, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := repo.SnapshotAll(ctx, time.Now()); err != nil {
+			logrus.Errorf("net worth snapshots failed: %v", err)
+		} else if n > 0 {
+			logrus.Infof("took balance snapshots for %d users", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,437 @@
+package repository
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/notblessy/anggar-service/model"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+)
+
+// jwksRefreshInterval bounds how often an unknown key id can trigger a JWKS refetch.
+const jwksRefreshInterval = time.Minute
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type oidcProvider struct {
+	config model.OIDCProviderConfig
+	client *http.Client
+
+	mu          sync.Mutex
+	discovery   *oidcDiscovery
+	keys        map[string]interface{}
+	keysFetched time.Time
+}
+
+// NewOIDCProvider builds a provider for the issuer in config. Discovery and
+// JWKS are fetched lazily through client, so a local mock issuer works as well.
+func NewOIDCProvider(config model.OIDCProviderConfig, client *http.Client) model.OIDCProvider {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "email", "profile"}
+	}
+
+	if config.EmailClaim == "" {
+		config.EmailClaim = "email"
+	}
+
+	if config.NameClaim == "" {
+		config.NameClaim = "name"
+	}
+
+	if config.PictureClaim == "" {
+		config.PictureClaim = "picture"
+	}
+
+	if config.DisplayName == "" {
+		config.DisplayName = config.Name
+	}
+
+	return &oidcProvider{config: config, client: client}
+}
+
+// NewOIDCProvidersFromEnv reads OIDC_PROVIDERS, a comma separated list of
+// names, and for each name the OIDC_<NAME>_ISSUER, _CLIENT_ID, _CLIENT_SECRET,
+// _DISPLAY_NAME, _SCOPES, _EMAIL_CLAIM, _NAME_CLAIM and _PICTURE_CLAIM variables.
+func NewOIDCProvidersFromEnv() []model.OIDCProvider {
+	var providers []model.OIDCProvider
+
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+
+		config := model.OIDCProviderConfig{
+			Name:         name,
+			DisplayName:  os.Getenv(prefix + "DISPLAY_NAME"),
+			IssuerURL:    os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			EmailClaim:   os.Getenv(prefix + "EMAIL_CLAIM"),
+			NameClaim:    os.Getenv(prefix + "NAME_CLAIM"),
+			PictureClaim: os.Getenv(prefix + "PICTURE_CLAIM"),
+		}
+
+		if scopes := os.Getenv(prefix + "SCOPES"); scopes != "" {
+			config.Scopes = strings.Fields(strings.ReplaceAll(scopes, ",", " "))
+		}
+
+		if config.IssuerURL == "" || config.ClientID == "" {
+			logrus.Warnf("oidc provider %s is missing issuer or client id, skipped", name)
+			continue
+		}
+
+		providers = append(providers, NewOIDCProvider(config, nil))
+	}
+
+	return providers
+}
+
+func (p *oidcProvider) Name() string {
+	return p.config.Name
+}
+
+func (p *oidcProvider) DisplayName() string {
+	return p.config.DisplayName
+}
+
+func (p *oidcProvider) AuthCodeURL(ctx context.Context, state, redirectURL string) (string, error) {
+	oauthConfig, err := p.oauthConfig(ctx, redirectURL)
+	if err != nil {
+		return "", err
+	}
+
+	return oauthConfig.AuthCodeURL(state), nil
+}
+
+func (p *oidcProvider) Exchange(ctx context.Context, code, redirectURL string) (model.ExternalAuthInfo, error) {
+	logger := logrus.WithField("provider", p.config.Name)
+
+	oauthConfig, err := p.oauthConfig(ctx, redirectURL)
+	if err != nil {
+		logger.Error(err)
+		return model.ExternalAuthInfo{}, err
+	}
+
+	token, err := oauthConfig.Exchange(context.WithValue(ctx, oauth2.HTTPClient, p.client), code)
+	if err != nil {
+		return model.ExternalAuthInfo{}, fmt.Errorf("code exchange wrong: %s", err.Error())
+	}
+
+	idToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		logger.Error(model.ErrOIDCNoIdToken)
+		return model.ExternalAuthInfo{}, model.ErrOIDCNoIdToken
+	}
+
+	claims, err := p.verifyIDToken(ctx, idToken)
+	if err != nil {
+		logger.Errorf("Error validating token: %v", err)
+		return model.ExternalAuthInfo{}, fmt.Errorf("id token validation failed: %w", model.ErrInvalidAuthClaim)
+	}
+
+	return p.mapClaims(claims)
+}
+
+func (p *oidcProvider) oauthConfig(ctx context.Context, redirectURL string) (*oauth2.Config, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &oauth2.Config{
+		ClientID:     p.config.ClientID,
+		ClientSecret: p.config.ClientSecret,
+		RedirectURL:  redirectURL,
+		Scopes:       p.config.Scopes,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  discovery.AuthorizationEndpoint,
+			TokenURL: discovery.TokenEndpoint,
+		},
+	}, nil
+}
+
+// discover fetches the issuer's configuration once. The fetch runs without mu
+// held, so a slow issuer does not hold up logins that only need the cache.
+func (p *oidcProvider) discover(ctx context.Context) (*oidcDiscovery, error) {
+	p.mu.Lock()
+	cached := p.discovery
+	p.mu.Unlock()
+
+	if cached != nil {
+		return cached, nil
+	}
+
+	url := strings.TrimRight(p.config.IssuerURL, "/") + "/.well-known/openid-configuration"
+
+	var discovery oidcDiscovery
+	if err := p.getJSON(ctx, url, &discovery); err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+
+	// The issuer must match exactly, otherwise tokens could be replayed from another tenant.
+	if discovery.Issuer != p.config.IssuerURL {
+		return nil, fmt.Errorf("oidc discovery: issuer %q does not match %q", discovery.Issuer, p.config.IssuerURL)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// a concurrent login may have stored it first
+	if p.discovery == nil {
+		p.discovery = &discovery
+	}
+
+	return p.discovery, nil
+}
+
+func (p *oidcProvider) verifyIDToken(ctx context.Context, idToken string) (jwt.MapClaims, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := jwt.MapClaims{}
+
+	_, err = jwt.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		switch token.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS, *jwt.SigningMethodECDSA:
+		default:
+			return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+		}
+
+		kid, _ := token.Header["kid"].(string)
+
+		return p.key(ctx, discovery.JWKSURI, kid)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !claims.VerifyIssuer(discovery.Issuer, true) {
+		return nil, errors.New("issuer mismatch")
+	}
+
+	if !claims.VerifyAudience(p.config.ClientID, true) {
+		return nil, errors.New("audience mismatch")
+	}
+
+	return claims, nil
+}
+
+// key returns the verification key for kid, refetching the JWKS when the key
+// is unknown so that provider key rotation is picked up. Like discover, it
+// holds mu only to read and store the cache, never during the fetch.
+func (p *oidcProvider) key(ctx context.Context, jwksURI, kid string) (interface{}, error) {
+	p.mu.Lock()
+	key, ok := p.lookupKey(kid)
+	fresh := p.keys != nil && time.Since(p.keysFetched) < jwksRefreshInterval
+	p.mu.Unlock()
+
+	if ok {
+		return key, nil
+	}
+
+	if fresh {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+
+	if err := p.getJSON(ctx, jwksURI, &set); err != nil {
+		return nil, fmt.Errorf("jwks: %w", err)
+	}
+
+	keys := make(map[string]interface{})
+
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		key, err := jwk.publicKey()
+		if err != nil {
+			logrus.WithField("kid", jwk.Kid).Warn(err)
+			continue
+		}
+
+		keys[jwk.Kid] = key
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.keys = keys
+	p.keysFetched = time.Now()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("unknown key id %q", kid)
+}
+
+// lookupKey must be called with mu held. A token without kid is accepted only
+// when the issuer publishes a single key.
+func (p *oidcProvider) lookupKey(kid string) (interface{}, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+func (p *oidcProvider) mapClaims(claims jwt.MapClaims) (model.ExternalAuthInfo, error) {
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return model.ExternalAuthInfo{}, fmt.Errorf("sub: %w", model.ErrInvalidAuthClaim)
+	}
+
+	email, _ := claims[p.config.EmailClaim].(string)
+	if email == "" {
+		return model.ExternalAuthInfo{}, fmt.Errorf("%s: %w", p.config.EmailClaim, model.ErrInvalidAuthClaim)
+	}
+
+	name, _ := claims[p.config.NameClaim].(string)
+	if name == "" {
+		name, _ = claims["preferred_username"].(string)
+	}
+
+	if name == "" {
+		name = email
+	}
+
+	picture, _ := claims[p.config.PictureClaim].(string)
+
+	return model.ExternalAuthInfo{
+		Provider:      model.OIDCIdentity(p.config.Name),
+		Subject:       subject,
+		Email:         email,
+		EmailVerified: emailVerified(claims),
+		Name:          name,
+		Picture:       picture,
+	}, nil
+}
+
+// emailVerified reads the email_verified claim. Some issuers send it as the
+// string "true"; a missing claim means unverified.
+func emailVerified(claims map[string]interface{}) bool {
+	switch v := claims["email_verified"].(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	}
+
+	return false
+}
+
+func (p *oidcProvider) getJSON(ctx context.Context, url string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: unexpected status %d", url, resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("rsa modulus: %w", err)
+		}
+
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("rsa exponent: %w", err)
+		}
+
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("ec x: %w", err)
+		}
+
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("ec y: %w", err)
+		}
+
+		return &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
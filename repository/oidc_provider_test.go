@@ -0,0 +1,490 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/notblessy/anggar-service/model"
+)
+
+const testClientID = "anggar"
+
+// mockIssuer is an OpenID provider serving discovery, JWKS and a token
+// endpoint. Each authorization code is exchanged for an id_token carrying
+// the claims registered for it.
+type mockIssuer struct {
+	server *httptest.Server
+	issuer string // what discovery claims, the server URL unless overridden
+
+	mu      sync.Mutex
+	keys    map[string]*rsa.PrivateKey
+	kid     string // the key new tokens are signed with
+	codes   map[string]jwt.MapClaims
+	fetches int // how many times the JWKS was served
+
+	noIDToken bool          // answer the token request without an id_token
+	stalled   chan struct{} // when set, the JWKS handler reports here and waits for release
+	release   chan struct{}
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	t.Helper()
+
+	m := &mockIssuer{keys: map[string]*rsa.PrivateKey{}, codes: map[string]jwt.MapClaims{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", m.discovery)
+	mux.HandleFunc("/jwks", m.jwks)
+	mux.HandleFunc("/token", m.token)
+
+	m.server = httptest.NewServer(mux)
+	m.issuer = m.server.URL
+	t.Cleanup(m.server.Close)
+
+	m.rotate(t, "key-1")
+
+	return m
+}
+
+// rotate publishes a new signing key in place of the current ones.
+func (m *mockIssuer) rotate(t *testing.T, kid string) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.keys = map[string]*rsa.PrivateKey{kid: key}
+	m.kid = kid
+}
+
+// code registers claims to be returned for a new authorization code. Issuer,
+// audience and lifetime are filled in unless given.
+func (m *mockIssuer) code(claims jwt.MapClaims) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	defaults := jwt.MapClaims{
+		"iss": m.server.URL,
+		"aud": testClientID,
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+
+	for k, v := range defaults {
+		if _, ok := claims[k]; !ok {
+			claims[k] = v
+		}
+	}
+
+	code := fmt.Sprintf("code-%d", len(m.codes)+1)
+	m.codes[code] = claims
+
+	return code
+}
+
+func (m *mockIssuer) jwksFetches() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.fetches
+}
+
+func (m *mockIssuer) discovery(w http.ResponseWriter, _ *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	json.NewEncoder(w).Encode(oidcDiscovery{
+		Issuer:                m.issuer,
+		AuthorizationEndpoint: m.server.URL + "/authorize",
+		TokenEndpoint:         m.server.URL + "/token",
+		JWKSURI:               m.server.URL + "/jwks",
+	})
+}
+
+func (m *mockIssuer) jwks(w http.ResponseWriter, _ *http.Request) {
+	m.mu.Lock()
+	stalled, release := m.stalled, m.release
+	m.mu.Unlock()
+
+	if stalled != nil {
+		stalled <- struct{}{}
+		<-release
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.fetches++
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+
+	for kid, key := range m.keys {
+		set.Keys = append(set.Keys, jsonWebKey{
+			Kid: kid,
+			Kty: "RSA",
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		})
+	}
+
+	json.NewEncoder(w).Encode(set)
+}
+
+func (m *mockIssuer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	m.mu.Lock()
+	claims, ok := m.codes[r.PostForm.Get("code")]
+	key, kid := m.keys[m.kid], m.kid
+	noIDToken := m.noIDToken
+	m.mu.Unlock()
+
+	if !ok {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if noIDToken {
+		json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "access", "token_type": "Bearer", "expires_in": 3600})
+		return
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+
+	idToken, err := token.SignedString(key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+func (m *mockIssuer) provider(config model.OIDCProviderConfig) *oidcProvider {
+	config.Name = "mock"
+	config.IssuerURL = m.server.URL
+	config.ClientID = testClientID
+
+	return NewOIDCProvider(config, m.server.Client()).(*oidcProvider)
+}
+
+func TestOIDCDiscovery(t *testing.T) {
+	issuer := newMockIssuer(t)
+	ctx := context.Background()
+
+	url, err := issuer.provider(model.OIDCProviderConfig{}).AuthCodeURL(ctx, "state-1", "https://app.test/callback")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{issuer.server.URL + "/authorize?", "client_id=" + testClientID, "state=state-1", "scope=openid+email+profile"} {
+		if !strings.Contains(url, want) {
+			t.Errorf("auth url %s does not contain %s", url, want)
+		}
+	}
+
+	// another tenant answering for our issuer URL
+	issuer.mu.Lock()
+	issuer.issuer = "https://evil.test"
+	issuer.mu.Unlock()
+
+	_, err = issuer.provider(model.OIDCProviderConfig{}).AuthCodeURL(ctx, "state-1", "https://app.test/callback")
+	if err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Fatalf("expected an issuer mismatch, got %v", err)
+	}
+}
+
+func TestOIDCClaimMapping(t *testing.T) {
+	issuer := newMockIssuer(t)
+
+	tests := []struct {
+		name   string
+		config model.OIDCProviderConfig
+		claims jwt.MapClaims
+		want   model.ExternalAuthInfo
+		err    error
+	}{
+		{
+			name:   "standard claims",
+			claims: jwt.MapClaims{"sub": "u1", "email": "ana@example.com", "email_verified": true, "name": "Ana", "picture": "https://img.test/ana"},
+			want:   model.ExternalAuthInfo{Subject: "u1", Email: "ana@example.com", EmailVerified: true, Name: "Ana", Picture: "https://img.test/ana"},
+		},
+		{
+			name:   "configured claims",
+			config: model.OIDCProviderConfig{EmailClaim: "upn", NameClaim: "display_name", PictureClaim: "avatar"},
+			claims: jwt.MapClaims{"sub": "u2", "upn": "budi@corp.test", "email_verified": true, "display_name": "Budi", "avatar": "https://img.test/budi"},
+			want:   model.ExternalAuthInfo{Subject: "u2", Email: "budi@corp.test", EmailVerified: true, Name: "Budi", Picture: "https://img.test/budi"},
+		},
+		{
+			name:   "preferred_username fallback and string email_verified",
+			claims: jwt.MapClaims{"sub": "u3", "email": "citra@example.com", "email_verified": "true", "preferred_username": "citra"},
+			want:   model.ExternalAuthInfo{Subject: "u3", Email: "citra@example.com", EmailVerified: true, Name: "citra"},
+		},
+		{
+			name:   "email as name",
+			claims: jwt.MapClaims{"sub": "u4", "email": "dewi@example.com"},
+			want:   model.ExternalAuthInfo{Subject: "u4", Email: "dewi@example.com", Name: "dewi@example.com"},
+		},
+		{
+			name:   "missing email",
+			claims: jwt.MapClaims{"sub": "u5", "name": "Eka"},
+			err:    model.ErrInvalidAuthClaim,
+		},
+		{
+			name:   "missing subject",
+			claims: jwt.MapClaims{"email": "fajar@example.com"},
+			err:    model.ErrInvalidAuthClaim,
+		},
+		{
+			name:   "other audience",
+			claims: jwt.MapClaims{"sub": "u6", "email": "gita@example.com", "aud": "someone-else"},
+			err:    model.ErrInvalidAuthClaim,
+		},
+		{
+			name:   "other issuer",
+			claims: jwt.MapClaims{"sub": "u7", "email": "hadi@example.com", "iss": "https://evil.test"},
+			err:    model.ErrInvalidAuthClaim,
+		},
+		{
+			name:   "expired",
+			claims: jwt.MapClaims{"sub": "u8", "email": "indah@example.com", "exp": time.Now().Add(-time.Minute).Unix()},
+			err:    model.ErrInvalidAuthClaim,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := issuer.provider(tt.config).Exchange(context.Background(), issuer.code(tt.claims), "https://app.test/callback")
+
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("expected %v, got %v", tt.err, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tt.want.Provider = model.OIDCIdentity("mock")
+			if info != tt.want {
+				t.Fatalf("got %+v, want %+v", info, tt.want)
+			}
+		})
+	}
+}
+
+func TestOIDCKeyRotation(t *testing.T) {
+	issuer := newMockIssuer(t)
+	provider := issuer.provider(model.OIDCProviderConfig{})
+	ctx := context.Background()
+
+	exchange := func() error {
+		_, err := provider.Exchange(ctx, issuer.code(jwt.MapClaims{"sub": "u1", "email": "ana@example.com"}), "https://app.test/callback")
+		return err
+	}
+
+	if err := exchange(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := exchange(); err != nil {
+		t.Fatal(err)
+	}
+
+	if issuer.jwksFetches() != 1 {
+		t.Fatalf("expected the JWKS to be fetched once, got %d", issuer.jwksFetches())
+	}
+
+	issuer.rotate(t, "key-2")
+
+	// an unknown key id does not refetch more than once per interval
+	if err := exchange(); !errors.Is(err, model.ErrInvalidAuthClaim) {
+		t.Fatalf("expected the new key to be rejected right after a fetch, got %v", err)
+	}
+
+	if issuer.jwksFetches() != 1 {
+		t.Fatalf("expected no refetch within the interval, got %d fetches", issuer.jwksFetches())
+	}
+
+	provider.mu.Lock()
+	provider.keysFetched = time.Now().Add(-jwksRefreshInterval)
+	provider.mu.Unlock()
+
+	if err := exchange(); err != nil {
+		t.Fatalf("expected the rotated key to be picked up, got %v", err)
+	}
+
+	if issuer.jwksFetches() != 2 {
+		t.Fatalf("expected the JWKS to be refetched, got %d fetches", issuer.jwksFetches())
+	}
+}
+
+func TestOIDCMissingIDToken(t *testing.T) {
+	issuer := newMockIssuer(t)
+
+	issuer.mu.Lock()
+	issuer.noIDToken = true
+	issuer.mu.Unlock()
+
+	_, err := issuer.provider(model.OIDCProviderConfig{}).Exchange(context.Background(), issuer.code(jwt.MapClaims{"sub": "u1"}), "https://app.test/callback")
+	if !errors.Is(err, model.ErrOIDCNoIdToken) {
+		t.Fatalf("expected ErrOIDCNoIdToken, got %v", err)
+	}
+}
+
+// A JWKS refetch for a rotated key must not hold up logins the cache can serve.
+func TestOIDCSlowJWKSDoesNotBlock(t *testing.T) {
+	issuer := newMockIssuer(t)
+	provider := issuer.provider(model.OIDCProviderConfig{})
+	ctx := context.Background()
+
+	if _, err := provider.Exchange(ctx, issuer.code(jwt.MapClaims{"sub": "u1", "email": "ana@example.com"}), "https://app.test/callback"); err != nil {
+		t.Fatal(err)
+	}
+
+	provider.mu.Lock()
+	provider.keysFetched = time.Now().Add(-jwksRefreshInterval)
+	provider.mu.Unlock()
+
+	issuer.mu.Lock()
+	issuer.stalled, issuer.release = make(chan struct{}), make(chan struct{})
+	issuer.mu.Unlock()
+
+	issuer.rotate(t, "key-2")
+
+	refetched := make(chan error, 1)
+	go func() {
+		_, err := provider.Exchange(ctx, issuer.code(jwt.MapClaims{"sub": "u2", "email": "budi@example.com"}), "https://app.test/callback")
+		refetched <- err
+	}()
+
+	<-issuer.stalled
+
+	served := make(chan error, 1)
+	go func() {
+		if _, err := provider.AuthCodeURL(ctx, "state-1", "https://app.test/callback"); err != nil {
+			served <- err
+			return
+		}
+
+		_, err := provider.key(ctx, issuer.server.URL+"/jwks", "key-1")
+		served <- err
+	}()
+
+	var err error
+	blocked := false
+
+	select {
+	case err = <-served:
+	case <-time.After(2 * time.Second):
+		blocked = true
+	}
+
+	// let the fetch finish either way, the server cannot shut down before it
+	close(issuer.release)
+
+	if blocked {
+		t.Fatal("the cache was blocked by the JWKS fetch")
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := <-refetched; err != nil {
+		t.Fatalf("expected the rotated key to be picked up, got %v", err)
+	}
+}
+
+func TestOIDCUnverifiedEmail(t *testing.T) {
+	issuer := newMockIssuer(t)
+	provider := issuer.provider(model.OIDCProviderConfig{})
+	ctx := context.Background()
+
+	db := newTestDB(t, &model.User{}, &model.UserIdentity{}, &model.UserToken{}, &model.AllowedEmail{})
+	repo := NewUserRepository(db)
+
+	registered, err := repo.Register(ctx, model.RegisterRequest{Name: "Ana", Email: "ana@example.com", Password: "ana-password"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.MarkEmailVerified(ctx, registered.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := provider.Exchange(ctx, issuer.code(jwt.MapClaims{"sub": "u1", "email": "ana@example.com", "email_verified": false}), "https://app.test/callback")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.EmailVerified {
+		t.Fatal("expected the email to be unverified")
+	}
+
+	// an existing account is not linked on the provider's unconfirmed word
+	if _, err := repo.AuthenticateExternal(ctx, info); !errors.Is(err, model.ErrEmailNotConfirmed) {
+		t.Fatalf("expected ErrEmailNotConfirmed, got %v", err)
+	}
+
+	identities, err := repo.FindIdentities(ctx, registered.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, identity := range identities {
+		if identity.Provider == info.Provider {
+			t.Fatal("the provider identity was linked")
+		}
+	}
+
+	// a new address gets an account, left unverified
+	info, err = provider.Exchange(ctx, issuer.code(jwt.MapClaims{"sub": "u2", "email": "budi@example.com"}), "https://app.test/callback")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := repo.AuthenticateExternal(ctx, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if user.EmailVerifiedAt != nil {
+		t.Fatal("expected the new account to be unverified")
+	}
+}
@@ -0,0 +1,193 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/notblessy/anggar-service/model"
+	"github.com/notblessy/anggar-service/utils"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+type payeeRepository struct {
+	db *gorm.DB
+}
+
+// NewPayeeRepository :nodoc:
+func NewPayeeRepository(db *gorm.DB) model.PayeeRepository {
+	return &payeeRepository{db}
+}
+
+func (r *payeeRepository) Create(ctx context.Context, payee *model.Payee) error {
+	logger := logrus.WithField("payee", utils.Dump(payee))
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := checkPayee(tx, *payee); err != nil {
+			return err
+		}
+
+		return tx.Create(payee).Error
+	})
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *payeeRepository) FindAll(ctx context.Context, query model.PayeeQueryInput) ([]model.Payee, error) {
+	logger := logrus.WithField("query", utils.Dump(query))
+
+	var payees []model.Payee
+
+	qb := r.db.WithContext(ctx).Preload("Aliases", func(db *gorm.DB) *gorm.DB {
+		return db.Order("pattern")
+	}).Where("user_id = ?", query.UserID)
+
+	if query.Keyword != "" {
+		qb = qb.Where("name ILIKE ?", "%"+query.Keyword+"%")
+	}
+
+	if err := qb.Order("name ASC").Find(&payees).Error; err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	return payees, nil
+}
+
+func (r *payeeRepository) FindByID(ctx context.Context, id string) (model.Payee, error) {
+	var payee model.Payee
+
+	if err := r.db.WithContext(ctx).Preload("Aliases").Where("id = ?", id).First(&payee).Error; err != nil {
+		logrus.WithField("id", id).Error(err)
+		return model.Payee{}, err
+	}
+
+	return payee, nil
+}
+
+func (r *payeeRepository) Update(ctx context.Context, payee model.Payee) error {
+	logger := logrus.WithField("payee", utils.Dump(payee))
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := checkPayee(tx, payee); err != nil {
+			return err
+		}
+
+		err := tx.Model(&model.Payee{}).Where("id = ?", payee.ID).Updates(map[string]interface{}{
+			"name":             payee.Name,
+			"default_category": payee.DefaultCategory,
+			"updated_at":       time.Now(),
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Where("payee_id = ?", payee.ID).Delete(&model.PayeeAlias{}).Error; err != nil {
+			return err
+		}
+
+		if len(payee.Aliases) == 0 {
+			return nil
+		}
+
+		return tx.Create(&payee.Aliases).Error
+	})
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *payeeRepository) Delete(ctx context.Context, id string) error {
+	logger := logrus.WithField("id", id)
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.Transaction{}).Unscoped().Where("payee_id = ?", id).Update("payee_id", nil).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("payee_id = ?", id).Delete(&model.PayeeAlias{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Where("id = ?", id).Delete(&model.Payee{}).Error
+	})
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *payeeRepository) Report(ctx context.Context, query model.PayeeReportQueryInput) ([]model.PayeeReport, error) {
+	logger := logrus.WithField("query", utils.Dump(query))
+
+	reports := []model.PayeeReport{}
+
+	err := r.db.WithContext(ctx).
+		Table("payees").
+		Select(`payees.id AS payee_id, payees.name,
+			COUNT(transactions.id) AS transactions,
+			COALESCE(SUM(CASE WHEN transactions.transaction_type = ? THEN transactions.amount END), 0) AS expense,
+			COALESCE(SUM(CASE WHEN transactions.transaction_type = ? THEN transactions.amount END), 0) AS income`,
+			model.TransactionTypeExpense, model.TransactionTypeIncome).
+		Joins("JOIN transactions ON transactions.payee_id = payees.id AND transactions.deleted_at IS NULL").
+		Where("payees.user_id = ?", query.UserID).
+		Scopes(spentBetween(query.Timezone, query.StartDate, query.EndDate)).
+		Group("payees.id, payees.name").
+		Order("expense DESC, payees.name ASC").
+		Limit(query.LimitOrDefault()).
+		Scan(&reports).Error
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	return reports, nil
+}
+
+// checkPayee keeps payee names and aliases unique per user, so a description
+// never matches two payees equally well.
+func checkPayee(tx *gorm.DB, payee model.Payee) error {
+	var count int64
+
+	err := tx.Model(&model.Payee{}).
+		Where("user_id = ? AND LOWER(name) = LOWER(?) AND id <> ?", payee.UserID, payee.Name, payee.ID).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return model.ErrPayeeExists
+	}
+
+	patterns := make([]string, len(payee.Aliases))
+	for i, alias := range payee.Aliases {
+		patterns[i] = alias.Pattern
+	}
+
+	if len(patterns) == 0 {
+		return nil
+	}
+
+	err = tx.Model(&model.PayeeAlias{}).
+		Where("user_id = ? AND pattern IN ? AND payee_id <> ?", payee.UserID, patterns, payee.ID).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return model.ErrPayeeAliasTaken
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/notblessy/anggar-service/model"
+	"github.com/notblessy/anggar-service/utils"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type preferenceRepository struct {
+	db *gorm.DB
+}
+
+// NewPreferenceRepository :nodoc:
+func NewPreferenceRepository(db *gorm.DB) model.PreferenceRepository {
+	return &preferenceRepository{db}
+}
+
+func (r *preferenceRepository) Find(ctx context.Context, userID string) (model.UserPreference, error) {
+	var preference model.UserPreference
+
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).First(&preference).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return model.DefaultPreference(userID), nil
+	}
+
+	if err != nil {
+		logrus.WithField("user_id", userID).Error(err)
+		return model.UserPreference{}, err
+	}
+
+	return preference, nil
+}
+
+func (r *preferenceRepository) Upsert(ctx context.Context, preference *model.UserPreference) error {
+	logger := logrus.WithField("preference", utils.Dump(preference))
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		current := model.DefaultPreference(preference.UserID)
+
+		err := tx.Where("user_id = ?", preference.UserID).First(&current).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		// Stored amounts are in the old base currency, trashed ones included.
+		if preference.BaseCurrency != current.BaseCurrency {
+			var converted int64
+
+			err := tx.Unscoped().Model(&model.Transaction{}).Where("user_id = ?", preference.UserID).Count(&converted).Error
+			if err != nil {
+				return err
+			}
+
+			if converted > 0 {
+				return model.ErrBaseCurrencyInUse
+			}
+		}
+
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"base_currency", "timezone", "locale", "week_start", "default_wallet_id", "updated_at"}),
+		}).Create(preference).Error
+	})
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/notblessy/anggar-service/model"
+	"github.com/shopspring/decimal"
+)
+
+func TestBaseCurrencyLocksOnceUsed(t *testing.T) {
+	db := newTestDB(t, &model.UserPreference{}, &model.Transaction{})
+	repo := NewPreferenceRepository(db)
+	ctx := context.Background()
+
+	preference := model.DefaultPreference("u1")
+	preference.BaseCurrency = "USD"
+
+	// nothing is converted yet, so the currency is free to change
+	if err := repo.Upsert(ctx, &preference); err != nil {
+		t.Fatal(err)
+	}
+
+	transaction := model.Transaction{ID: "t1", UserID: "u1", Amount: decimal.NewFromInt(10), Currency: "USD"}
+	if err := db.Create(&transaction).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// a trashed transaction can come back, so it counts too
+	if err := db.Delete(&transaction).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	preference.BaseCurrency = "EUR"
+	if err := repo.Upsert(ctx, &preference); !errors.Is(err, model.ErrBaseCurrencyInUse) {
+		t.Fatalf("expected ErrBaseCurrencyInUse, got %v", err)
+	}
+
+	preference.BaseCurrency = "USD"
+	preference.Timezone = "Europe/Berlin"
+	if err := repo.Upsert(ctx, &preference); err != nil {
+		t.Fatalf("other preferences still change: %v", err)
+	}
+
+	stored, err := repo.Find(ctx, "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stored.BaseCurrency != "USD" || stored.Timezone != "Europe/Berlin" {
+		t.Fatalf("got %s in %s", stored.BaseCurrency, stored.Timezone)
+	}
+}
@@ -0,0 +1,372 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/notblessy/anggar-service/model"
+	"github.com/notblessy/anggar-service/utils"
+	"github.com/oklog/ulid/v2"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type recurringRepository struct {
+	db             *gorm.DB
+	preferenceRepo model.PreferenceRepository
+	rateProvider   model.RateProvider
+	notifier       model.Notifier
+}
+
+// NewRecurringRepository :nodoc:
+// notifier may be nil, in which case reminders are not sent.
+func NewRecurringRepository(db *gorm.DB, preferenceRepo model.PreferenceRepository, rateProvider model.RateProvider, notifier model.Notifier) model.RecurringTransactionRepository {
+	return &recurringRepository{
+		db:             db,
+		preferenceRepo: preferenceRepo,
+		rateProvider:   rateProvider,
+		notifier:       notifier,
+	}
+}
+
+func (r *recurringRepository) Create(ctx context.Context, recurring *model.RecurringTransaction) error {
+	logger := logrus.WithField("recurring", utils.Dump(recurring))
+
+	if err := r.db.WithContext(ctx).Create(recurring).Error; err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *recurringRepository) FindAll(ctx context.Context, query model.RecurringQueryInput) ([]model.RecurringTransaction, int64, error) {
+	logger := logrus.WithField("query", utils.Dump(query))
+
+	var recurrings []model.RecurringTransaction
+
+	qb := r.db.WithContext(ctx).Model(&model.RecurringTransaction{}).Where("user_id = ?", query.UserID)
+
+	if query.Keyword != "" {
+		qb = qb.Where("description ILIKE ?", "%"+query.Keyword+"%")
+	}
+
+	var total int64
+	if err := qb.Count(&total).Error; err != nil {
+		logger.Error(err)
+		return nil, 0, err
+	}
+
+	if err := qb.Scopes(query.Paginated()).Order(query.Sorted()).Find(&recurrings).Error; err != nil {
+		logger.Error(err)
+		return nil, 0, err
+	}
+
+	return recurrings, total, nil
+}
+
+func (r *recurringRepository) FindByID(ctx context.Context, id string) (model.RecurringTransaction, error) {
+	logger := logrus.WithField("id", id)
+
+	var recurring model.RecurringTransaction
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&recurring).Error; err != nil {
+		logger.Error(err)
+		return model.RecurringTransaction{}, err
+	}
+
+	return recurring, nil
+}
+
+func (r *recurringRepository) Update(ctx context.Context, recurring *model.RecurringTransaction) error {
+	logger := logrus.WithField("recurring", utils.Dump(recurring))
+
+	if err := r.db.WithContext(ctx).Save(recurring).Error; err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *recurringRepository) Delete(ctx context.Context, id string) error {
+	logger := logrus.WithField("id", id)
+
+	if err := r.db.WithContext(ctx).Where("id = ?", id).Delete(&model.RecurringTransaction{}).Error; err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *recurringRepository) FindOccurrences(ctx context.Context, recurringID string) ([]model.RecurringOccurrence, error) {
+	logger := logrus.WithField("recurring_id", recurringID)
+
+	var occurrences []model.RecurringOccurrence
+	if err := r.db.WithContext(ctx).Where("recurring_id = ?", recurringID).Order("occurrence_at DESC").Find(&occurrences).Error; err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	return occurrences, nil
+}
+
+func (r *recurringRepository) Upcoming(ctx context.Context, userID string, until time.Time) ([]model.UpcomingBill, error) {
+	logger := logrus.WithField("user_id", userID)
+
+	var recurrings []model.RecurringTransaction
+
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND is_active = ? AND next_run_at IS NOT NULL AND next_run_at <= ?", userID, true, until).
+		Find(&recurrings).Error
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	preference, err := r.preferenceRepo.Find(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	loc := preference.Location()
+	bills := []model.UpcomingBill{}
+
+	for _, recurring := range recurrings {
+		for due, ok := *recurring.NextRunAt, true; ok && !due.After(until); due, ok = recurring.NextAfter(due, loc) {
+			bills = append(bills, model.UpcomingBill{
+				RecurringID:     recurring.ID,
+				Description:     recurring.Description,
+				Category:        recurring.Category,
+				TransactionType: recurring.TransactionType,
+				WalletID:        recurring.WalletID,
+				Amount:          recurring.Amount,
+				Currency:        recurring.Currency,
+				DueAt:           due.In(loc),
+			})
+		}
+	}
+
+	sort.Slice(bills, func(i, j int) bool {
+		return bills[i].DueAt.Before(bills[j].DueAt)
+	})
+
+	return bills, nil
+}
+
+func (r *recurringRepository) Generate(ctx context.Context, now time.Time) (int, error) {
+	var recurrings []model.RecurringTransaction
+
+	err := r.db.WithContext(ctx).
+		Where("is_active = ? AND next_run_at IS NOT NULL AND next_run_at <= ?", true, now).
+		Find(&recurrings).Error
+	if err != nil {
+		logrus.Error(err)
+		return 0, err
+	}
+
+	generated := 0
+
+	for i := range recurrings {
+		n, err := r.generateDue(ctx, &recurrings[i], now)
+		generated += n
+
+		if err != nil {
+			// One broken rule (e.g. a missing exchange rate) must not hold up the rest.
+			logrus.WithField("recurring_id", recurrings[i].ID).Errorf("failed to generate recurring transaction: %v", err)
+		}
+	}
+
+	return generated, nil
+}
+
+// generateDue walks a rule from its next run up to now and then moves
+// next_run_at past what was generated. It stops at the first failure so the
+// occurrence is retried on the next run.
+func (r *recurringRepository) generateDue(ctx context.Context, recurring *model.RecurringTransaction, now time.Time) (int, error) {
+	preference, err := r.preferenceRepo.Find(ctx, recurring.UserID)
+	if err != nil {
+		return 0, err
+	}
+
+	loc := preference.Location()
+	next := recurring.NextRunAt
+	generated := 0
+
+	for next != nil && !next.After(now) && generated < model.RecurringCatchUpLimit {
+		created, err := r.generateOccurrence(ctx, recurring, *next, preference)
+		if err != nil {
+			r.saveNextRun(ctx, recurring, next)
+			return generated, err
+		}
+
+		if created {
+			generated++
+		}
+
+		occurrence, ok := recurring.NextAfter(*next, loc)
+		if ok {
+			next = &occurrence
+		} else {
+			next = nil
+		}
+	}
+
+	return generated, r.saveNextRun(ctx, recurring, next)
+}
+
+func (r *recurringRepository) saveNextRun(ctx context.Context, recurring *model.RecurringTransaction, next *time.Time) error {
+	err := r.db.WithContext(ctx).Model(&model.RecurringTransaction{}).
+		Where("id = ?", recurring.ID).
+		Update("next_run_at", next).Error
+	if err != nil {
+		logrus.WithField("recurring_id", recurring.ID).Error(err)
+		return err
+	}
+
+	recurring.NextRunAt = next
+
+	return nil
+}
+
+// generateOccurrence creates the transaction for one occurrence unless it was
+// already generated; the unique (recurring_id, occurrence_at) key decides.
+func (r *recurringRepository) generateOccurrence(ctx context.Context, recurring *model.RecurringTransaction, occurrenceAt time.Time, preference model.UserPreference) (bool, error) {
+	transaction := recurring.Transaction(occurrenceAt)
+	transaction.ID = ulid.Make().String()
+
+	if err := model.ConvertTransaction(ctx, r.rateProvider, &transaction, preference.BaseCurrency); err != nil {
+		return false, err
+	}
+
+	created := false
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		occurrence := model.RecurringOccurrence{
+			RecurringID:   recurring.ID,
+			OccurrenceAt:  occurrenceAt,
+			TransactionID: transaction.ID,
+		}
+
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&occurrence)
+		if res.Error != nil {
+			return res.Error
+		}
+
+		if res.RowsAffected == 0 {
+			return nil
+		}
+
+		if err := tx.Create(&transaction).Error; err != nil {
+			return err
+		}
+
+		audit := model.NewAuditLog(recurring.UserID, model.AuditChannelRecurring, model.AuditActionCreated, transaction,
+			nil, model.TransactionAuditState(transaction))
+		if err := recordAudit(tx, &audit); err != nil {
+			return err
+		}
+
+		created = true
+
+		return nil
+	})
+	if err != nil {
+		logrus.WithField("recurring_id", recurring.ID).WithField("occurrence_at", occurrenceAt).Error(err)
+		return false, err
+	}
+
+	return created, nil
+}
+
+func (r *recurringRepository) Remind(ctx context.Context, now time.Time) (int, error) {
+	if r.notifier == nil {
+		return 0, nil
+	}
+
+	var recurrings []model.RecurringTransaction
+
+	err := r.db.WithContext(ctx).
+		Where("is_active = ? AND remind_days_before > 0 AND next_run_at IS NOT NULL AND next_run_at > ?", true, now).
+		Where("reminded_for IS NULL OR reminded_for <> next_run_at").
+		Find(&recurrings).Error
+	if err != nil {
+		logrus.Error(err)
+		return 0, err
+	}
+
+	sent := 0
+
+	for _, recurring := range recurrings {
+		if recurring.NextRunAt.After(now.AddDate(0, 0, recurring.RemindDaysBefore)) {
+			continue
+		}
+
+		preference, err := r.preferenceRepo.Find(ctx, recurring.UserID)
+		if err != nil {
+			continue
+		}
+
+		if err := r.notifier.Notify(ctx, recurring.UserID, reminderMessage(recurring, preference)); err != nil {
+			logrus.WithField("recurring_id", recurring.ID).Errorf("failed to send reminder: %v", err)
+			continue
+		}
+
+		err = r.db.WithContext(ctx).Model(&model.RecurringTransaction{}).
+			Where("id = ?", recurring.ID).
+			Update("reminded_for", recurring.NextRunAt).Error
+		if err != nil {
+			logrus.WithField("recurring_id", recurring.ID).Error(err)
+			continue
+		}
+
+		sent++
+	}
+
+	return sent, nil
+}
+
+func reminderMessage(recurring model.RecurringTransaction, preference model.UserPreference) string {
+	verb := "will be charged"
+	if recurring.TransactionType == model.TransactionTypeIncome {
+		verb = "is expected"
+	}
+
+	return fmt.Sprintf("Reminder: %s of %s %s on %s.",
+		recurring.Description,
+		utils.FormatMoney(recurring.Amount, recurring.Currency, preference.Locale),
+		verb,
+		recurring.NextRunAt.In(preference.Location()).Format("Mon, 2 Jan 2006"),
+	)
+}
+
+// RunRecurringScheduler generates due recurring transactions and sends
+// reminders every interval until ctx is cancelled. It runs once right away so
+// occurrences missed during downtime are caught up on start.
+func RunRecurringScheduler(ctx context.Context, repo model.RecurringTransactionRepository, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		now := time.Now()
+
+		if n, err := repo.Generate(ctx, now); err != nil {
+			logrus.Errorf("recurring generation failed: %v", err)
+		} else if n > 0 {
+			logrus.Infof("generated %d recurring transactions", n)
+		}
+
+		if _, err := repo.Remind(ctx, now); err != nil {
+			logrus.Errorf("recurring reminders failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/notblessy/anggar-service/model"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+)
+
+type remoteRateProvider struct {
+	baseURL string
+	client  *http.Client
+}
+
+type remoteRateResponse struct {
+	Base  string                     `json:"base"`
+	Date  string                     `json:"date"`
+	Rates map[string]decimal.Decimal `json:"rates"`
+}
+
+// NewRemoteRateProvider reads historical rates from a Frankfurter-compatible
+// API, i.e. GET {baseURL}/{date}?from=USD&to=IDR.
+func NewRemoteRateProvider(baseURL string, client *http.Client) model.RateProvider {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return &remoteRateProvider{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  client,
+	}
+}
+
+// NewRateProviderFromEnv returns the remote provider configured by
+// EXCHANGE_RATE_URL, or nil when rates are maintained by hand only.
+func NewRateProviderFromEnv() model.RateProvider {
+	baseURL := os.Getenv("EXCHANGE_RATE_URL")
+	if baseURL == "" {
+		return nil
+	}
+
+	return NewRemoteRateProvider(baseURL, nil)
+}
+
+func (p *remoteRateProvider) Rate(ctx context.Context, base, quote string, date time.Time) (model.ExchangeRate, error) {
+	logger := logrus.WithField("base", base).WithField("quote", quote).WithField("date", date.Format("2006-01-02"))
+
+	params := url.Values{}
+	params.Set("from", base)
+	params.Set("to", quote)
+
+	endpoint := fmt.Sprintf("%s/%s?%s", p.baseURL, date.Format("2006-01-02"), params.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		logger.Error(err)
+		return model.ExchangeRate{}, err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		logger.Error(err)
+		return model.ExchangeRate{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusUnprocessableEntity {
+		return model.ExchangeRate{}, model.ErrRateNotFound
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("exchange rate request failed with status %d", resp.StatusCode)
+		logger.Error(err)
+		return model.ExchangeRate{}, err
+	}
+
+	var body remoteRateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		logger.Error(err)
+		return model.ExchangeRate{}, err
+	}
+
+	rate, ok := body.Rates[quote]
+	if !ok || !rate.IsPositive() {
+		return model.ExchangeRate{}, model.ErrRateNotFound
+	}
+
+	// The API answers with the closest earlier business day, keep that date.
+	rateDate, err := time.Parse("2006-01-02", body.Date)
+	if err != nil {
+		rateDate = date
+	}
+
+	return model.ExchangeRate{
+		Base:     base,
+		Quote:    quote,
+		Rate:     rate,
+		RateDate: rateDate,
+		Source:   model.RateSourceRemote,
+	}, nil
+}
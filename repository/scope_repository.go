@@ -34,19 +34,28 @@ func (r *scopeRepository) Create(c context.Context, scope model.ScopeInput) (mod
 
 	var scopeCategories []model.ScopeCategory
 
-	for _, category := range scope.CategoryIDs {
+	for _, categoryID := range scope.CategoryIDs {
 		scopeCategories = append(scopeCategories, model.ScopeCategory{
-			ScopeID:  scopeCreate.ID,
-			Category: category,
+			ScopeID:    scopeCreate.ID,
+			CategoryID: categoryID,
 		})
 	}
 
-	if err := tx.Create(&scopeCategories).Error; err != nil {
+	if len(scopeCategories) > 0 {
+		if err := tx.Create(&scopeCategories).Error; err != nil {
+			logger.Error(err)
+			tx.Rollback()
+			return model.Scope{}, err
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
 		logger.Error(err)
-		tx.Rollback()
 		return model.Scope{}, err
 	}
 
+	scopeCreate.ScopeCategories = scopeCategories
+
 	return scopeCreate, nil
 }
 
@@ -131,13 +140,29 @@ func (r *scopeRepository) Update(c context.Context, id int64, scope model.Scope)
 		}
 	}
 
+	if err := trx.Commit().Error; err != nil {
+		logger.Error(err)
+		return err
+	}
+
 	return nil
 }
 
+// Delete trashes the scope with its categories, stamping them with the same
+// time so a restore brings back only those.
 func (r *scopeRepository) Delete(c context.Context, id int64) error {
 	logger := logrus.WithField("id", id)
 
-	if err := r.db.Delete(&model.Scope{}, id).Error; err != nil {
+	now := time.Now()
+
+	err := r.db.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.ScopeCategory{}).Where("scope_id = ?", id).Update("deleted_at", now).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&model.Scope{}).Where("id = ?", id).Update("deleted_at", now).Error
+	})
+	if err != nil {
 		logger.Error(err)
 		return err
 	}
@@ -150,21 +175,30 @@ func (r *scopeRepository) FindOverviews(c context.Context, userID string) ([]mod
 
 	var overviews []model.ScopeOverview
 
-	if err := r.db.
+	// A category counts its children, and each transaction is summed once even
+	// when the scope lists both a parent and one of its children.
+	if err := r.db.WithContext(c).
 		Table("scopes").
 		Select(`
-			scope.id, 
-			scope.user_id, 
-			scope.name,
-			scope.created_at, 
-			scope.updated_at, 
-			scope.deleted_at, 
-			COALESCE(SUM(transactions.amount), 0) AS total_amount_transaction
+			scopes.id,
+			scopes.user_id,
+			scopes.name,
+			scopes.created_at,
+			scopes.updated_at,
+			scopes.deleted_at,
+			COALESCE((
+				SELECT SUM(transactions.amount) FROM transactions
+				WHERE transactions.user_id = scopes.user_id
+					AND transactions.deleted_at IS NULL
+					AND transactions.category IN (
+						SELECT categories.slug FROM categories
+						JOIN scope_categories ON categories.id = scope_categories.category_id
+							OR categories.parent_id = scope_categories.category_id
+						WHERE scope_categories.scope_id = scopes.id AND scope_categories.deleted_at IS NULL
+					)
+			), 0) AS total_amount_transaction
 		`).
-		Joins("LEFT JOIN scope_categories ON scope.id = scope_categories.scope_id").
-		Joins("LEFT JOIN transactions ON transactions.category = scope_categories.category").
-		Where("scope.user_id = ?", userID).
-		Group("scope.id").
+		Where("scopes.user_id = ? AND scopes.deleted_at IS NULL", userID).
 		Scan(&overviews).Error; err != nil {
 		logger.Error(err)
 		return nil, err
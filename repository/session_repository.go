@@ -0,0 +1,215 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/notblessy/anggar-service/model"
+	"github.com/notblessy/anggar-service/utils"
+	"github.com/oklog/ulid/v2"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type sessionRepository struct {
+	db *gorm.DB
+}
+
+// NewSessionRepository :nodoc:
+func NewSessionRepository(db *gorm.DB) model.SessionRepository {
+	return &sessionRepository{db}
+}
+
+func (r *sessionRepository) Start(ctx context.Context, userID string, meta model.SessionMeta) (model.Session, string, error) {
+	logger := logrus.WithField("user_id", userID)
+
+	now := time.Now()
+	session := model.Session{
+		ID:         ulid.Make().String(),
+		UserID:     userID,
+		UserAgent:  meta.UserAgent,
+		IPAddress:  meta.IPAddress,
+		ExpiresAt:  now.Add(model.RefreshTokenTTL),
+		LastUsedAt: now,
+	}
+
+	var raw string
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&session).Error; err != nil {
+			return err
+		}
+
+		var err error
+		raw, err = r.issue(tx, session)
+		return err
+	})
+	if err != nil {
+		logger.Error(err)
+		return model.Session{}, "", err
+	}
+
+	return session, raw, nil
+}
+
+func (r *sessionRepository) Rotate(ctx context.Context, rawToken string) (model.Session, string, error) {
+	logger := logrus.WithField("method", "Rotate")
+
+	var (
+		session model.Session
+		raw     string
+	)
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var token model.RefreshToken
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", utils.HashToken(rawToken)).
+			First(&token).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.ErrInvalidRefreshToken
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Preload("User").Where("id = ?", token.SessionID).First(&session).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+
+		if session.RevokedAt != nil || session.User.ID == "" {
+			return model.ErrSessionRevoked
+		}
+
+		if token.UsedAt != nil {
+			return model.ErrRefreshTokenReused
+		}
+
+		if now.After(token.ExpiresAt) || now.After(session.ExpiresAt) {
+			return model.ErrInvalidRefreshToken
+		}
+
+		if err := tx.Model(&token).Update("used_at", now).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&session).Update("last_used_at", now).Error; err != nil {
+			return err
+		}
+
+		raw, err = r.issue(tx, session)
+		return err
+	})
+
+	// The transaction is rolled back on reuse, so the family is revoked outside of it.
+	if errors.Is(err, model.ErrRefreshTokenReused) {
+		if revokeErr := r.db.WithContext(ctx).Model(&model.Session{}).Where("id = ?", session.ID).Update("revoked_at", time.Now()).Error; revokeErr != nil {
+			logger.Error(revokeErr)
+		}
+
+		logger.WithField("session_id", session.ID).Warn("refresh token reuse detected, session revoked")
+		return model.Session{}, "", err
+	}
+
+	if err != nil {
+		logger.Error(err)
+		return model.Session{}, "", err
+	}
+
+	return session, raw, nil
+}
+
+func (r *sessionRepository) FindActive(ctx context.Context, userID string) ([]model.Session, error) {
+	logger := logrus.WithField("user_id", userID)
+
+	var sessions []model.Session
+
+	err := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Where("revoked_at IS NULL").
+		Where("expires_at > ?", time.Now()).
+		Order("last_used_at DESC").
+		Find(&sessions).Error
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+func (r *sessionRepository) IsActive(ctx context.Context, id string) (bool, error) {
+	var count int64
+
+	err := r.db.WithContext(ctx).
+		Model(&model.Session{}).
+		Where("id = ?", id).
+		Where("revoked_at IS NULL").
+		Where("expires_at > ?", time.Now()).
+		Count(&count).Error
+	if err != nil {
+		logrus.WithField("id", id).Error(err)
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+func (r *sessionRepository) Revoke(ctx context.Context, userID, id string) error {
+	logger := logrus.WithField("user_id", userID).WithField("id", id)
+
+	res := r.db.WithContext(ctx).
+		Model(&model.Session{}).
+		Where("id = ? AND user_id = ?", id, userID).
+		Where("revoked_at IS NULL").
+		Update("revoked_at", time.Now())
+	if res.Error != nil {
+		logger.Error(res.Error)
+		return res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (r *sessionRepository) RevokeAll(ctx context.Context, userID string) error {
+	logger := logrus.WithField("user_id", userID)
+
+	err := r.db.WithContext(ctx).
+		Model(&model.Session{}).
+		Where("user_id = ?", userID).
+		Where("revoked_at IS NULL").
+		Update("revoked_at", time.Now()).Error
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *sessionRepository) issue(tx *gorm.DB, session model.Session) (string, error) {
+	raw, err := utils.RandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	token := model.RefreshToken{
+		ID:        ulid.Make().String(),
+		SessionID: session.ID,
+		TokenHash: utils.HashToken(raw),
+		ExpiresAt: session.ExpiresAt,
+	}
+
+	if err := tx.Create(&token).Error; err != nil {
+		return "", err
+	}
+
+	return raw, nil
+}
@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/notblessy/anggar-service/model"
+	"github.com/notblessy/anggar-service/utils"
+	"github.com/oklog/ulid/v2"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type tagRepository struct {
+	db *gorm.DB
+}
+
+// NewTagRepository :nodoc:
+func NewTagRepository(db *gorm.DB) model.TagRepository {
+	return &tagRepository{db}
+}
+
+func (r *tagRepository) Create(ctx context.Context, tag *model.Tag) error {
+	logger := logrus.WithField("tag", utils.Dump(tag))
+
+	if err := r.db.WithContext(ctx).Create(tag).Error; err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *tagRepository) FindAll(ctx context.Context, query model.TagQueryInput) ([]model.Tag, error) {
+	logger := logrus.WithField("query", utils.Dump(query))
+
+	var tags []model.Tag
+
+	qb := r.db.WithContext(ctx).Where("user_id = ?", query.UserID)
+
+	if query.Keyword != "" {
+		qb = qb.Where("name ILIKE ?", "%"+query.Keyword+"%")
+	}
+
+	if err := qb.Order("name ASC").Find(&tags).Error; err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+func (r *tagRepository) FindByID(ctx context.Context, id string) (model.Tag, error) {
+	var tag model.Tag
+
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&tag).Error; err != nil {
+		logrus.WithField("id", id).Error(err)
+		return model.Tag{}, err
+	}
+
+	return tag, nil
+}
+
+func (r *tagRepository) Update(ctx context.Context, id string, tag model.Tag) error {
+	logger := logrus.WithField("id", id).WithField("tag", utils.Dump(tag))
+
+	err := r.db.WithContext(ctx).Model(&model.Tag{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"name": tag.Name, "color": tag.Color, "updated_at": time.Now()}).Error
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+// Delete removes the tag; its links to transactions go with it.
+func (r *tagRepository) Delete(ctx context.Context, id string) error {
+	if err := r.db.WithContext(ctx).Where("id = ?", id).Delete(&model.Tag{}).Error; err != nil {
+		logrus.WithField("id", id).Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *tagRepository) SetTransactionTags(ctx context.Context, userID, transactionID string, names []string) ([]model.Tag, error) {
+	var tags []model.Tag
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		tags, err = setTransactionTags(tx, userID, transactionID, names)
+		return err
+	})
+	if err != nil {
+		logrus.WithField("transaction_id", transactionID).Error(err)
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+func (r *tagRepository) Report(ctx context.Context, query model.TagReportQueryInput) ([]model.TagReport, error) {
+	logger := logrus.WithField("query", utils.Dump(query))
+
+	var reports []model.TagReport
+
+	err := r.db.WithContext(ctx).
+		Table("tags").
+		Select(`tags.id AS tag_id, tags.name, tags.color,
+			COUNT(DISTINCT transactions.id) AS transactions,
+			COALESCE(SUM(CASE WHEN transactions.transaction_type = ? THEN transactions.amount END), 0) AS income,
+			COALESCE(SUM(CASE WHEN transactions.transaction_type = ? THEN transactions.amount END), 0) AS expense`,
+			model.TransactionTypeIncome, model.TransactionTypeExpense).
+		Joins("JOIN transaction_tags ON transaction_tags.tag_id = tags.id").
+		Joins("JOIN transactions ON transactions.id = transaction_tags.transaction_id AND transactions.deleted_at IS NULL").
+		Where("tags.user_id = ?", query.UserID).
+		Scopes(spentBetween(query.Timezone, query.StartDate, query.EndDate)).
+		Group("tags.id, tags.name, tags.color").
+		Order("expense DESC, tags.name ASC").
+		Scan(&reports).Error
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	return reports, nil
+}
+
+// setTransactionTags finds or creates the user's tags by name and makes them
+// the user's tags on the transaction. It runs on the caller's transaction so
+// the bot can tag a transaction in the same step it saves it.
+func setTransactionTags(tx *gorm.DB, userID, transactionID string, names []string) ([]model.Tag, error) {
+	names = model.NormalizeTags(names)
+
+	for _, name := range names {
+		tag := model.Tag{ID: ulid.Make().String(), UserID: userID, Name: name}
+
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "name"}},
+			DoNothing: true,
+		}).Create(&tag).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var tags []model.Tag
+	if len(names) > 0 {
+		if err := tx.Where("user_id = ? AND name IN ?", userID, names).Order("name").Find(&tags).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	err := tx.Exec(`DELETE FROM transaction_tags WHERE transaction_id = ?
+		AND tag_id IN (SELECT id FROM tags WHERE user_id = ?)`, transactionID, userID).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tag := range tags {
+		err := tx.Exec(`INSERT INTO transaction_tags (transaction_id, tag_id) VALUES (?, ?) ON CONFLICT DO NOTHING`, transactionID, tag.ID).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return tags, nil
+}
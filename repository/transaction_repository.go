@@ -7,6 +7,7 @@ import (
 	"github.com/notblessy/anggar-service/utils"
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type transactionRepository struct {
@@ -17,10 +18,20 @@ func NewTransactionRepository(db *gorm.DB) model.TransactionRepository {
 	return &transactionRepository{db}
 }
 
-func (r *transactionRepository) Create(c context.Context, transaction *model.Transaction) error {
+func (r *transactionRepository) Create(c context.Context, transaction *model.Transaction, actor model.AuditActor) error {
 	logger := logrus.WithField("transaction", utils.Dump(transaction))
 
-	if err := r.db.Create(transaction).Error; err != nil {
+	err := r.db.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(transaction).Error; err != nil {
+			return err
+		}
+
+		audit := model.NewAuditLog(actor.ID, actor.Channel, model.AuditActionCreated, *transaction,
+			nil, model.TransactionAuditState(*transaction))
+
+		return recordAudit(tx, &audit)
+	})
+	if err != nil {
 		logger.Error(err)
 		return err
 	}
@@ -33,24 +44,12 @@ func (r *transactionRepository) FindAll(c context.Context, query model.Transacti
 
 	var transactions []model.Transaction
 
-	qb := r.db.WithContext(c).Preload("User").Preload("TransactionShares.User")
-
-	if query.UserID != "" {
-		qb = qb.Where("user_id = ?", query.UserID)
-	}
-
-	if query.Keyword != "" {
-		qb = qb.Where("description ILIKE ?", "%"+query.Keyword+"%")
-	}
-
-	if query.StartDate != "" && query.EndDate != "" {
-		qb = qb.Where("DATE(spent_at) BETWEEN ? AND ?", query.StartDate, query.EndDate)
-	}
+	qb := r.db.WithContext(c).Preload("User").Preload("Payee").Preload("TransactionShares.User").Scopes(transactionFilter(query))
 
-	if query.Filter == "shared" {
-		qb = qb.Where("is_shared = ?", true)
-	} else if query.Filter == "personal" {
-		qb = qb.Where("is_shared = ?", false)
+	if query.ParticipantID != "" {
+		qb = qb.Preload("Tags", "tags.user_id = ?", query.ParticipantID)
+	} else {
+		qb = qb.Preload("Tags")
 	}
 
 	var total int64
@@ -67,11 +66,70 @@ func (r *transactionRepository) FindAll(c context.Context, query model.Transacti
 	return transactions, total, nil
 }
 
-func (r *transactionRepository) FindByID(c context.Context, id int64) (model.Transaction, error) {
+// transactionFilter applies the list filters of TransactionQueryInput, so
+// every listing and export narrows transactions the same way.
+func transactionFilter(query model.TransactionQueryInput) func(db *gorm.DB) *gorm.DB {
+	return func(qb *gorm.DB) *gorm.DB {
+		if query.UserID != "" {
+			qb = qb.Where("transactions.user_id = ?", query.UserID)
+		}
+
+		if query.ParticipantID != "" {
+			shared := qb.Session(&gorm.Session{NewDB: true}).Model(&model.TransactionShare{}).Select("transaction_id").Where("user_id = ?", query.ParticipantID)
+			qb = qb.Where("transactions.user_id = ? OR transactions.id IN (?)", query.ParticipantID, shared)
+		}
+
+		if query.WalletID != "" {
+			qb = qb.Where("transactions.wallet_id = ?", query.WalletID)
+		}
+
+		if query.PayeeID != "" {
+			qb = qb.Where("transactions.payee_id = ?", query.PayeeID)
+		}
+
+		if query.Keyword != "" {
+			qb = qb.Where("transactions.description ILIKE ?", "%"+query.Keyword+"%")
+		}
+
+		if query.StartDate != "" && query.EndDate != "" {
+			qb = qb.Scopes(spentBetween(query.Timezone, query.StartDate, query.EndDate))
+		}
+
+		// Tags are personal, so they are matched among the viewer's own tags.
+		tagOwner := query.ParticipantID
+		if tagOwner == "" {
+			tagOwner = query.UserID
+		}
+
+		for _, name := range query.TagNames() {
+			tagged := qb.Session(&gorm.Session{NewDB: true}).
+				Table("transaction_tags").
+				Select("transaction_tags.transaction_id").
+				Joins("JOIN tags ON tags.id = transaction_tags.tag_id").
+				Where("tags.name = ?", name)
+
+			if tagOwner != "" {
+				tagged = tagged.Where("tags.user_id = ?", tagOwner)
+			}
+
+			qb = qb.Where("transactions.id IN (?)", tagged)
+		}
+
+		if query.Filter == "shared" {
+			qb = qb.Where("transactions.is_shared = ?", true)
+		} else if query.Filter == "personal" {
+			qb = qb.Where("transactions.is_shared = ?", false)
+		}
+
+		return qb
+	}
+}
+
+func (r *transactionRepository) FindByID(c context.Context, id string) (model.Transaction, error) {
 	logger := logrus.WithField("id", id)
 
 	var transaction model.Transaction
-	if err := r.db.WithContext(c).Where("id = ?", id).First(&transaction).Error; err != nil {
+	if err := r.db.WithContext(c).Preload("TransactionShares").Preload("Tags").Preload("Payee").Where("id = ?", id).First(&transaction).Error; err != nil {
 		logger.Error(err)
 		return model.Transaction{}, err
 	}
@@ -79,10 +137,31 @@ func (r *transactionRepository) FindByID(c context.Context, id int64) (model.Tra
 	return transaction, nil
 }
 
-func (r *transactionRepository) Update(c context.Context, id int64, transaction model.Transaction) error {
+func (r *transactionRepository) Update(c context.Context, id string, transaction model.Transaction, actor model.AuditActor) error {
 	logger := logrus.WithField("transaction", utils.Dump(transaction))
 
-	if err := r.db.WithContext(c).Model(&model.Transaction{}).Where("id = ?", id).Updates(&transaction).Error; err != nil {
+	err := r.db.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		existing, err := findAuditedTransaction(tx, id)
+		if err != nil {
+			return err
+		}
+
+		// shares are changed through UpdateShare and DeleteShare only
+		if err := tx.Model(&model.Transaction{}).Where("id = ?", id).Omit(clause.Associations).Updates(&transaction).Error; err != nil {
+			return err
+		}
+
+		updated, err := findAuditedTransaction(tx, id)
+		if err != nil {
+			return err
+		}
+
+		audit := model.NewAuditLog(actor.ID, actor.Channel, model.AuditActionUpdated, updated,
+			model.TransactionAuditState(existing), model.TransactionAuditState(updated))
+
+		return recordAudit(tx, &audit)
+	})
+	if err != nil {
 		logger.Error(err)
 		return err
 	}
@@ -90,10 +169,25 @@ func (r *transactionRepository) Update(c context.Context, id int64, transaction
 	return nil
 }
 
-func (r *transactionRepository) Delete(c context.Context, id int64) error {
+func (r *transactionRepository) Delete(c context.Context, id string, actor model.AuditActor) error {
 	logger := logrus.WithField("id", id)
 
-	if err := r.db.WithContext(c).Delete(&model.Transaction{}, id).Error; err != nil {
+	err := r.db.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		transaction, err := findAuditedTransaction(tx, id)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Where("id = ?", id).Delete(&model.Transaction{}).Error; err != nil {
+			return err
+		}
+
+		audit := model.NewAuditLog(actor.ID, actor.Channel, model.AuditActionDeleted, transaction,
+			model.TransactionAuditState(transaction), nil)
+
+		return recordAudit(tx, &audit)
+	})
+	if err != nil {
 		logger.Error(err)
 		return err
 	}
@@ -101,10 +195,43 @@ func (r *transactionRepository) Delete(c context.Context, id int64) error {
 	return nil
 }
 
-func (r *transactionRepository) UpdateShare(c context.Context, id string, share model.TransactionShare) error {
+func (r *transactionRepository) FindShareByID(c context.Context, id string) (model.TransactionShare, error) {
+	logger := logrus.WithField("id", id)
+
+	var share model.TransactionShare
+	if err := r.db.WithContext(c).Preload("Transaction").Where("id = ?", id).First(&share).Error; err != nil {
+		logger.Error(err)
+		return model.TransactionShare{}, err
+	}
+
+	return share, nil
+}
+
+func (r *transactionRepository) UpdateShare(c context.Context, id string, share model.TransactionShare, actor model.AuditActor) error {
 	logger := logrus.WithField("share", utils.Dump(share))
 
-	if err := r.db.WithContext(c).Model(&model.TransactionShare{}).Where("id = ?", id).Updates(&share).Error; err != nil {
+	err := r.db.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		var existing model.TransactionShare
+		if err := tx.Where("id = ?", id).First(&existing).Error; err != nil {
+			return err
+		}
+
+		// only the split changes, the share stays with its transaction and user
+		if err := tx.Model(&model.TransactionShare{}).Where("id = ?", id).Select("amount", "percentage").Updates(&share).Error; err != nil {
+			return err
+		}
+
+		var updated model.TransactionShare
+		if err := tx.Where("id = ?", id).First(&updated).Error; err != nil {
+			return err
+		}
+
+		audit := model.NewShareAuditLog(actor.ID, actor.Channel, model.AuditActionUpdated, updated,
+			model.ShareAuditState(existing), model.ShareAuditState(updated))
+
+		return recordAudit(tx, &audit)
+	})
+	if err != nil {
 		logger.Error(err)
 		return err
 	}
@@ -112,10 +239,25 @@ func (r *transactionRepository) UpdateShare(c context.Context, id string, share
 	return nil
 }
 
-func (r *transactionRepository) DeleteShare(c context.Context, id string) error {
+func (r *transactionRepository) DeleteShare(c context.Context, id string, actor model.AuditActor) error {
 	logger := logrus.WithField("id", id)
 
-	if err := r.db.WithContext(c).Where("id = ?", id).Delete(&model.TransactionShare{}).Error; err != nil {
+	err := r.db.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		var share model.TransactionShare
+		if err := tx.Where("id = ?", id).First(&share).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("id = ?", id).Delete(&model.TransactionShare{}).Error; err != nil {
+			return err
+		}
+
+		audit := model.NewShareAuditLog(actor.ID, actor.Channel, model.AuditActionDeleted, share,
+			model.ShareAuditState(share), nil)
+
+		return recordAudit(tx, &audit)
+	})
+	if err != nil {
 		logger.Error(err)
 		return err
 	}
@@ -123,17 +265,41 @@ func (r *transactionRepository) DeleteShare(c context.Context, id string) error
 	return nil
 }
 
+// findAuditedTransaction loads what TransactionAuditState reads of a
+// transaction inside tx.
+func findAuditedTransaction(tx *gorm.DB, id string) (model.Transaction, error) {
+	var transaction model.Transaction
+	err := tx.Preload("TransactionShares").Where("id = ?", id).First(&transaction).Error
+
+	return transaction, err
+}
+
+// sharingPartners selects the users who take part in a shared transaction with
+// @user, either holding a share of theirs or owning one they hold a share of.
+const sharingPartners = `
+	SELECT s.user_id FROM transaction_shares s
+	JOIN transactions t ON t.id = s.transaction_id
+	WHERE t.user_id = @user AND s.user_id <> @user AND s.deleted_at IS NULL AND t.deleted_at IS NULL
+	UNION
+	SELECT t.user_id FROM transactions t
+	JOIN transaction_shares s ON s.transaction_id = t.id
+	WHERE s.user_id = @user AND t.user_id <> @user AND s.deleted_at IS NULL AND t.deleted_at IS NULL`
+
+// CurrentMonthSummary compares the user's spending with that of the people
+// they share expenses with. Nobody else's transactions are counted.
 func (r *transactionRepository) CurrentMonthSummary(c context.Context, query model.SummaryQueryInput) (model.Summary, error) {
 	logger := logrus.WithField("query", utils.Dump(query))
 
 	var summary model.Summary
 
+	partners := map[string]interface{}{"user": query.UserID}
+
 	if err := r.db.WithContext(c).
 		Model(&model.Transaction{}).
 		Select("COALESCE(SUM(amount), 0) AS total_expense").
 		Where("user_id = ?", query.UserID).
 		Where("transaction_type = ?", model.TransactionTypeExpense).
-		Where("DATE(spent_at) BETWEEN ? AND ?", query.StartDate, query.EndDate).
+		Scopes(spending, spentBetween(query.Timezone, query.StartDate, query.EndDate)).
 		Scan(&summary.MeExpense).Error; err != nil {
 		logger.Error(err)
 		return model.Summary{}, err
@@ -142,9 +308,9 @@ func (r *transactionRepository) CurrentMonthSummary(c context.Context, query mod
 	if err := r.db.WithContext(c).
 		Model(&model.Transaction{}).
 		Select("COALESCE(SUM(amount), 0) AS total_expense").
-		Where("user_id <> ?", query.UserID).
+		Where("user_id IN ("+sharingPartners+")", partners).
 		Where("transaction_type = ?", model.TransactionTypeExpense).
-		Where("DATE(spent_at) BETWEEN ? AND ?", query.StartDate, query.EndDate).
+		Scopes(spending, spentBetween(query.Timezone, query.StartDate, query.EndDate)).
 		Scan(&summary.OtherExpense).Error; err != nil {
 		logger.Error(err)
 		return model.Summary{}, err
@@ -158,7 +324,7 @@ func (r *transactionRepository) CurrentMonthSummary(c context.Context, query mod
 		Where("transaction_type = ?", model.TransactionTypeExpense).
 		Where("user_id = ?", query.UserID).
 		Where("is_shared = ?", true).
-		Where("DATE(spent_at) BETWEEN ? AND ?", query.StartDate, query.EndDate).
+		Scopes(spending, spentBetween(query.Timezone, query.StartDate, query.EndDate)).
 		Find(&transactionIds).Error; err != nil {
 		logger.Error(err)
 		return model.Summary{}, err
@@ -190,9 +356,9 @@ func (r *transactionRepository) CurrentMonthSummary(c context.Context, query mod
 		Model(&model.Transaction{}).
 		Select("id").
 		Where("transaction_type = ?", model.TransactionTypeExpense).
-		Where("user_id <> ?", query.UserID).
+		Where("user_id IN ("+sharingPartners+")", partners).
 		Where("is_shared = ?", true).
-		Where("DATE(spent_at) BETWEEN ? AND ?", query.StartDate, query.EndDate).
+		Scopes(spending, spentBetween(query.Timezone, query.StartDate, query.EndDate)).
 		Find(&otherTransactionIds).Error; err != nil {
 		logger.Error(err)
 		return model.Summary{}, err
@@ -226,13 +392,34 @@ func (r *transactionRepository) CurrentMonthSummary(c context.Context, query mod
 		return model.Summary{}, err
 	}
 
+	// Without anyone to share with, Other stays empty.
 	if err := r.db.WithContext(c).
 		Model(&model.User{}).
-		Where("id <> ?", query.UserID).
-		First(&summary.Other).Error; err != nil {
+		Where("id IN ("+sharingPartners+")", partners).
+		Order("id").
+		Limit(1).
+		Find(&summary.Other).Error; err != nil {
 		logger.Error(err)
 		return model.Summary{}, err
 	}
 
 	return summary, nil
 }
+
+// spending leaves out opening balances and card payments, which move money
+// rather than spend it.
+func spending(db *gorm.DB) *gorm.DB {
+	return db.Where("category NOT IN ?", []string{model.CategoryOpname, model.CategoryCardPayment})
+}
+
+// spentBetween filters by the calendar date of spent_at in the given timezone,
+// so a late-night expense lands on the user's local day rather than the server's.
+func spentBetween(timezone, startDate, endDate string) func(db *gorm.DB) *gorm.DB {
+	if timezone == "" {
+		timezone = model.DefaultTimezone
+	}
+
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("DATE(spent_at AT TIME ZONE ?) BETWEEN ? AND ?", timezone, startDate, endDate)
+	}
+}
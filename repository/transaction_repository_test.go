@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/notblessy/anggar-service/model"
+	"github.com/shopspring/decimal"
+)
+
+func TestTransactionChangesAreAudited(t *testing.T) {
+	db := newTestDB(t, &model.User{}, &model.Payee{}, &model.Tag{}, &model.Transaction{}, &model.TransactionShare{}, &model.AuditLog{})
+	repo := NewTransactionRepository(db)
+	ctx := context.Background()
+	actor := model.AuditActor{ID: "owner", Channel: model.AuditChannelWeb}
+
+	transaction := model.Transaction{
+		ID:       "t1",
+		UserID:   "owner",
+		Category: "FOOD",
+		Amount:   decimal.NewFromInt(100),
+		IsShared: true,
+		TransactionShares: []model.TransactionShare{
+			{ID: "s1", TransactionID: "t1", UserID: "owner", Amount: decimal.NewFromInt(50)},
+			{ID: "s2", TransactionID: "t1", UserID: "friend", Amount: decimal.NewFromInt(50)},
+		},
+	}
+
+	if err := repo.Create(ctx, &transaction, actor); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.Update(ctx, "t1", model.Transaction{Category: "DRINKS"}, actor); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.UpdateShare(ctx, "s2", model.TransactionShare{Amount: decimal.NewFromInt(40)}, actor); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.DeleteShare(ctx, "s1", actor); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.Delete(ctx, "t1", actor); err != nil {
+		t.Fatal(err)
+	}
+
+	var logs []model.AuditLog
+	if err := db.Order("id").Find(&logs).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		action, entity, entityID, change string
+	}{
+		{model.AuditActionCreated, model.AuditEntityTransaction, "t1", ""},
+		{model.AuditActionUpdated, model.AuditEntityTransaction, "t1", "category"},
+		{model.AuditActionUpdated, model.AuditEntityShare, "s2", "amount"},
+		{model.AuditActionDeleted, model.AuditEntityShare, "s1", ""},
+		{model.AuditActionDeleted, model.AuditEntityTransaction, "t1", ""},
+	}
+
+	if len(logs) != len(want) {
+		t.Fatalf("got %d audit entries, want %d", len(logs), len(want))
+	}
+
+	for i, w := range want {
+		log := logs[i]
+
+		if log.Action != w.action || log.EntityType != w.entity || log.EntityID != w.entityID || log.TransactionID != "t1" {
+			t.Errorf("entry %d: got %s %s %s, want %s %s %s", i, log.Action, log.EntityType, log.EntityID, w.action, w.entity, w.entityID)
+		}
+
+		if log.ActorID != actor.ID || log.Channel != actor.Channel {
+			t.Errorf("entry %d: got actor %s via %s", i, log.ActorID, log.Channel)
+		}
+
+		if w.change != "" && (len(log.Changes) != 1 || log.Changes[0] != w.change) {
+			t.Errorf("entry %d: got changes %v, want [%s]", i, log.Changes, w.change)
+		}
+	}
+}
+
+// A change whose audit entry cannot be written is not saved either.
+func TestTransactionChangeRollsBackWithoutAudit(t *testing.T) {
+	db := newTestDB(t, &model.User{}, &model.Payee{}, &model.Tag{}, &model.Transaction{}, &model.TransactionShare{}, &model.AuditLog{})
+	repo := NewTransactionRepository(db)
+	ctx := context.Background()
+	actor := model.AuditActor{ID: "owner", Channel: model.AuditChannelWeb}
+
+	if err := repo.Create(ctx, &model.Transaction{ID: "t1", UserID: "owner", Category: "FOOD"}, actor); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Migrator().DropTable(&model.AuditLog{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.Update(ctx, "t1", model.Transaction{Category: "DRINKS"}, actor); err == nil {
+		t.Fatal("expected the update to fail")
+	}
+
+	if err := repo.Delete(ctx, "t1", actor); err == nil {
+		t.Fatal("expected the delete to fail")
+	}
+
+	transaction, err := repo.FindByID(ctx, "t1")
+	if err != nil {
+		t.Fatalf("the transaction was deleted: %v", err)
+	}
+
+	if transaction.Category != "FOOD" {
+		t.Fatalf("the update was saved: category is %s", transaction.Category)
+	}
+}
+
+// Updates change the values of a share, never who holds it or where it belongs.
+func TestUpdateKeepsSharesInPlace(t *testing.T) {
+	db := newTestDB(t, &model.User{}, &model.Payee{}, &model.Tag{}, &model.Transaction{}, &model.TransactionShare{}, &model.AuditLog{})
+	repo := NewTransactionRepository(db)
+	ctx := context.Background()
+	actor := model.AuditActor{ID: "owner", Channel: model.AuditChannelWeb}
+
+	transactions := []model.Transaction{
+		{
+			ID:       "t1",
+			UserID:   "owner",
+			Category: "FOOD",
+			IsShared: true,
+			TransactionShares: []model.TransactionShare{
+				{ID: "s1", TransactionID: "t1", UserID: "owner", Amount: decimal.NewFromInt(50)},
+			},
+		},
+		{ID: "t2", UserID: "victim", Category: "FOOD"},
+	}
+
+	for i := range transactions {
+		if err := repo.Create(ctx, &transactions[i], actor); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	moved := model.TransactionShare{TransactionID: "t2", UserID: "stranger", Amount: decimal.NewFromInt(40)}
+	if err := repo.UpdateShare(ctx, "s1", moved, actor); err != nil {
+		t.Fatal(err)
+	}
+
+	share, err := repo.FindShareByID(ctx, "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if share.TransactionID != "t1" || share.UserID != "owner" || !share.Amount.Equal(decimal.NewFromInt(40)) {
+		t.Fatalf("got share of %s on %s for %s", share.UserID, share.TransactionID, share.Amount)
+	}
+
+	added := model.Transaction{
+		Category: "DRINKS",
+		TransactionShares: []model.TransactionShare{
+			{ID: "s9", TransactionID: "t2", UserID: "owner"},
+		},
+	}
+	if err := repo.Update(ctx, "t1", added, actor); err != nil {
+		t.Fatal(err)
+	}
+
+	var count int64
+	if err := db.Model(&model.TransactionShare{}).Where("id = ?", "s9").Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 0 {
+		t.Fatal("the update created a share")
+	}
+}
@@ -0,0 +1,300 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/notblessy/anggar-service/model"
+	"github.com/notblessy/anggar-service/utils"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// trashedItems lists every soft-deleted item. Transactions deleted with their
+// wallet share its deleted_at and are left to the wallet.
+const trashedItems = `
+	SELECT 'transaction' AS type, t.id, t.user_id, COALESCE(t.description, '') AS name, t.deleted_at,
+		(SELECT COUNT(*) FROM transaction_shares s WHERE s.transaction_id = t.id AND s.deleted_at IS NULL) AS cascaded
+	FROM transactions t
+	WHERE t.deleted_at IS NOT NULL
+		AND NOT EXISTS (SELECT 1 FROM wallets w WHERE w.id = t.wallet_id AND w.deleted_at = t.deleted_at)
+	UNION ALL
+	SELECT 'wallet', w.id, w.user_id, w.name, w.deleted_at,
+		(SELECT COUNT(*) FROM transactions t WHERE t.wallet_id = w.id AND t.deleted_at = w.deleted_at)
+	FROM wallets w
+	WHERE w.deleted_at IS NOT NULL
+	UNION ALL
+	SELECT 'scope', CAST(sc.id AS VARCHAR), sc.user_id, sc.name, sc.deleted_at,
+		(SELECT COUNT(*) FROM scope_categories c WHERE c.scope_id = sc.id AND c.deleted_at = sc.deleted_at)
+	FROM scopes sc
+	WHERE sc.deleted_at IS NOT NULL`
+
+type trashRepository struct {
+	db      *gorm.DB
+	storage model.FileStorage
+}
+
+// NewTrashRepository :nodoc:
+func NewTrashRepository(db *gorm.DB, storage model.FileStorage) model.TrashRepository {
+	return &trashRepository{db, storage}
+}
+
+func (r *trashRepository) trash(ctx context.Context) *gorm.DB {
+	return r.db.WithContext(ctx).Table("(?) AS trash", gorm.Expr(trashedItems))
+}
+
+func (r *trashRepository) FindAll(ctx context.Context, query model.TrashQueryInput) ([]model.TrashItem, int64, error) {
+	logger := logrus.WithField("query", utils.Dump(query))
+
+	items := []model.TrashItem{}
+	var total int64
+
+	qb := r.trash(ctx).Where("trash.user_id = ?", query.UserID)
+
+	if query.Type != "" {
+		qb = qb.Where("trash.type = ?", query.Type)
+	}
+
+	if err := qb.Count(&total).Error; err != nil {
+		logger.Error(err)
+		return nil, 0, err
+	}
+
+	if err := qb.Scopes(query.Paginated()).Order("trash.deleted_at DESC, trash.id").Find(&items).Error; err != nil {
+		logger.Error(err)
+		return nil, 0, err
+	}
+
+	for i := range items {
+		items[i].PurgeAt = items[i].DeletedAt.Add(model.TrashRetention)
+	}
+
+	return items, total, nil
+}
+
+func (r *trashRepository) FindByID(ctx context.Context, itemType, id string) (model.TrashItem, error) {
+	logger := logrus.WithField("type", itemType).WithField("id", id)
+
+	var item model.TrashItem
+	if err := r.trash(ctx).Where("trash.type = ? AND trash.id = ?", itemType, id).Take(&item).Error; err != nil {
+		logger.Error(err)
+		return model.TrashItem{}, err
+	}
+
+	item.PurgeAt = item.DeletedAt.Add(model.TrashRetention)
+
+	return item, nil
+}
+
+func (r *trashRepository) Restore(ctx context.Context, item model.TrashItem, actor model.AuditActor) error {
+	logger := logrus.WithField("item", utils.Dump(item))
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var statements []string
+
+		switch item.Type {
+		case model.TrashTypeTransaction:
+			var deletedWallets int64
+			err := tx.Unscoped().Model(&model.Wallet{}).
+				Where("id = (SELECT wallet_id FROM transactions WHERE id = ?) AND deleted_at IS NOT NULL", item.ID).
+				Count(&deletedWallets).Error
+			if err != nil {
+				return err
+			}
+
+			if deletedWallets > 0 {
+				return model.ErrTrashParentDeleted
+			}
+
+			statements = []string{`UPDATE transactions SET deleted_at = NULL WHERE id = @id`}
+		case model.TrashTypeWallet:
+			statements = []string{
+				`UPDATE transaction_shares SET deleted_at = NULL WHERE deleted_at = @deleted_at
+					AND transaction_id IN (SELECT id FROM transactions WHERE wallet_id = @id AND deleted_at = @deleted_at)`,
+				`UPDATE transactions SET deleted_at = NULL WHERE wallet_id = @id AND deleted_at = @deleted_at`,
+				`UPDATE wallets SET deleted_at = NULL WHERE id = @id`,
+			}
+		case model.TrashTypeScope:
+			statements = []string{
+				`UPDATE scope_categories SET deleted_at = NULL WHERE scope_id = @id AND deleted_at = @deleted_at`,
+				`UPDATE scopes SET deleted_at = NULL WHERE id = @id`,
+			}
+		default:
+			return model.ErrUnknownTrashType
+		}
+
+		args := map[string]interface{}{"id": trashKey(item), "deleted_at": item.DeletedAt}
+
+		for _, statement := range statements {
+			if err := tx.Exec(statement, args).Error; err != nil {
+				return err
+			}
+		}
+
+		if item.Type != model.TrashTypeTransaction {
+			return nil
+		}
+
+		var transaction model.Transaction
+		if err := tx.Preload("TransactionShares").Where("id = ?", item.ID).First(&transaction).Error; err != nil {
+			return err
+		}
+
+		audit := model.NewAuditLog(actor.ID, actor.Channel, model.AuditActionRestored, transaction,
+			nil, model.TransactionAuditState(transaction))
+
+		return recordAudit(tx, &audit)
+	})
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *trashRepository) Purge(ctx context.Context, item model.TrashItem) error {
+	logger := logrus.WithField("item", utils.Dump(item))
+
+	var attachments []model.Attachment
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		args := map[string]interface{}{"id": trashKey(item), "deleted_at": item.DeletedAt}
+
+		var statements []string
+		var err error
+
+		switch item.Type {
+		case model.TrashTypeTransaction:
+			attachments, err = purgeTransactions(tx, `id = @id AND deleted_at IS NOT NULL`, args)
+			if err != nil {
+				return err
+			}
+		case model.TrashTypeWallet:
+			attachments, err = purgeTransactions(tx, `wallet_id = @id AND deleted_at = @deleted_at`, args)
+			if err != nil {
+				return err
+			}
+
+			statements = []string{
+				// transactions trashed on their own earlier outlive the wallet
+				`UPDATE transactions SET wallet_id = '' WHERE wallet_id = @id`,
+				`UPDATE goals SET wallet_id = NULL WHERE wallet_id = @id`,
+				`DELETE FROM wallets WHERE id = @id AND deleted_at IS NOT NULL`,
+			}
+		case model.TrashTypeScope:
+			statements = []string{
+				`DELETE FROM scope_categories WHERE scope_id = @id`,
+				`DELETE FROM scopes WHERE id = @id AND deleted_at IS NOT NULL`,
+			}
+		default:
+			return model.ErrUnknownTrashType
+		}
+
+		for _, statement := range statements {
+			if err := tx.Exec(statement, args).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	for _, attachment := range attachments {
+		keys := []string{attachment.StorageKey}
+		if attachment.ThumbnailKey != nil {
+			keys = append(keys, *attachment.ThumbnailKey)
+		}
+
+		for _, key := range keys {
+			if err := r.storage.Delete(ctx, key); err != nil {
+				logger.Error(err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r *trashRepository) PurgeExpired(ctx context.Context, before time.Time) (int, error) {
+	var expired []model.TrashItem
+
+	if err := r.trash(ctx).Where("trash.deleted_at <= ?", before).Order("trash.deleted_at").Find(&expired).Error; err != nil {
+		logrus.Error(err)
+		return 0, err
+	}
+
+	purged := 0
+	for _, item := range expired {
+		if err := r.Purge(ctx, item); err != nil {
+			logrus.WithField("type", item.Type).WithField("id", item.ID).Errorf("Error purging trash: %v", err)
+			continue
+		}
+
+		purged++
+	}
+
+	return purged, nil
+}
+
+// purgeTransactions hard-deletes the transactions matching where, with their
+// shares and attachment rows, and returns the attachments whose files should go.
+// Tags and category suggestions follow through their foreign keys; the audit
+// log keeps its entries.
+func purgeTransactions(tx *gorm.DB, where string, args map[string]interface{}) ([]model.Attachment, error) {
+	doomed := `SELECT id FROM transactions WHERE ` + where
+
+	var attachments []model.Attachment
+	if err := tx.Where(`transaction_id IN (`+doomed+`)`, args).Find(&attachments).Error; err != nil {
+		return nil, err
+	}
+
+	statements := []string{
+		`DELETE FROM attachments WHERE transaction_id IN (` + doomed + `)`,
+		`DELETE FROM transaction_shares WHERE transaction_id IN (` + doomed + `)`,
+		`DELETE FROM transactions WHERE ` + where,
+	}
+
+	for _, statement := range statements {
+		if err := tx.Exec(statement, args).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return attachments, nil
+}
+
+// trashKey is the id to match rows on; scopes have numeric ids.
+func trashKey(item model.TrashItem) interface{} {
+	if item.Type == model.TrashTypeScope {
+		id, _ := strconv.ParseInt(item.ID, 10, 64)
+		return id
+	}
+
+	return item.ID
+}
+
+// RunTrashPurger empties the trash of items older than the retention window.
+func RunTrashPurger(ctx context.Context, repo model.TrashRepository, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := repo.PurgeExpired(ctx, time.Now().Add(-model.TrashRetention)); err != nil {
+			logrus.Errorf("trash purge failed: %v", err)
+		} else if n > 0 {
+			logrus.Infof("purged %d trashed items", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -48,6 +48,11 @@ func (u *userRepository) verifyToken(ctx context.Context, code, requestOrigin st
 
 func (u *userRepository) claimAuth(payload *idtoken.Payload) (model.GoogleAuthInfo, error) {
 
+	subject := payload.Subject
+	if subject == "" {
+		return model.GoogleAuthInfo{}, fmt.Errorf("sub: %w", model.ErrInvalidAuthClaim)
+	}
+
 	email := payload.Claims["email"].(string)
 	if email == "" {
 		return model.GoogleAuthInfo{}, fmt.Errorf("email: %w", model.ErrInvalidAuthClaim)
@@ -61,8 +66,10 @@ func (u *userRepository) claimAuth(payload *idtoken.Payload) (model.GoogleAuthIn
 	picture := payload.Claims["picture"].(string)
 
 	return model.GoogleAuthInfo{
-		Email:   email,
-		Name:    name,
-		Picture: picture,
+		Subject:       subject,
+		Email:         email,
+		EmailVerified: emailVerified(payload.Claims),
+		Name:          name,
+		Picture:       picture,
 	}, nil
 }
@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	gonanoid "github.com/matoous/go-nanoid/v2"
+	"github.com/notblessy/anggar-service/model"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// AuthenticateExternal signs in through an external identity. It resolves the
+// user by linked identity first, then by a verified email, and otherwise
+// creates a new account.
+func (a *userRepository) AuthenticateExternal(ctx context.Context, info model.ExternalAuthInfo) (model.User, error) {
+	logger := logrus.WithFields(logrus.Fields{
+		"provider": info.Provider,
+		"email":    info.Email,
+	})
+
+	email := strings.ToLower(strings.TrimSpace(info.Email))
+
+	allowed, err := a.isEmailAllowed(ctx, email)
+	if err != nil {
+		logger.Errorf("Error checking allowed emails: %v", err)
+		return model.User{}, err
+	}
+
+	if !allowed {
+		logger.Errorf("Email %s is not in the list of valid emails", email)
+		return model.User{}, fmt.Errorf("%s: %w", email, model.ErrEmailNotAllowed)
+	}
+
+	var authUser model.User
+
+	var identity model.UserIdentity
+	err = a.db.WithContext(ctx).Where("provider = ? AND subject = ?", info.Provider, info.Subject).First(&identity).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		logger.Errorf("Error querying identity: %v", err)
+		return model.User{}, err
+	}
+
+	if err == nil {
+		if err := a.db.WithContext(ctx).Where("id = ?", identity.UserID).First(&authUser).Error; err != nil {
+			logger.Errorf("Error querying user: %v", err)
+			return model.User{}, err
+		}
+
+		authUser.OmitPassword()
+
+		return authUser, nil
+	}
+
+	err = a.db.WithContext(ctx).Where("email = ?", email).First(&authUser).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		logger.Errorf("Error querying user: %v", err)
+		return model.User{}, err
+	}
+
+	// Linking by email is only safe when the provider vouches for the address.
+	if authUser.ID != "" && !info.EmailVerified {
+		return model.User{}, model.ErrEmailNotConfirmed
+	}
+
+	now := time.Now()
+
+	err = a.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if authUser.ID == "" {
+			id, err := gonanoid.New()
+			if err != nil {
+				return err
+			}
+
+			authUser = model.User{
+				ID:      id,
+				Name:    info.Name,
+				Role:    model.RoleUser,
+				Email:   email,
+				Picture: info.Picture,
+			}
+
+			if info.EmailVerified {
+				authUser.EmailVerifiedAt = &now
+			}
+
+			if err := tx.Create(&authUser).Error; err != nil {
+				return err
+			}
+		} else if authUser.EmailVerifiedAt == nil {
+			// The provider proves the address is theirs, not that they set the
+			// pending password: whoever registered it may have been someone
+			// else. The password goes, and can be set again through a reset.
+			authUser.EmailVerifiedAt = &now
+
+			err := tx.Model(&model.User{}).Where("id = ?", authUser.ID).Updates(map[string]interface{}{
+				"email_verified_at": now,
+				"password":          "",
+			}).Error
+			if err != nil {
+				return err
+			}
+
+			err = tx.Where("user_id = ? AND provider = ?", authUser.ID, model.IdentityPassword).Delete(&model.UserIdentity{}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return tx.Create(&model.UserIdentity{
+			UserID:   authUser.ID,
+			Provider: info.Provider,
+			Subject:  info.Subject,
+		}).Error
+	})
+	if err != nil {
+		logger.Errorf("Error creating user: %v", err)
+		return model.User{}, err
+	}
+
+	authUser.OmitPassword()
+
+	return authUser, nil
+}
+
+func (a *userRepository) LinkGoogle(ctx context.Context, userID, code, requestOrigin string) error {
+	logger := logrus.WithField("user_id", userID)
+
+	auth, err := a.verifyToken(ctx, code, requestOrigin)
+	if err != nil {
+		logger.Errorf("Error verifying token: %v", err)
+		return err
+	}
+
+	return a.LinkExternal(ctx, userID, auth.External())
+}
+
+func (a *userRepository) LinkExternal(ctx context.Context, userID string, info model.ExternalAuthInfo) error {
+	logger := logrus.WithField("user_id", userID).WithField("provider", info.Provider)
+
+	var identity model.UserIdentity
+	err := a.db.WithContext(ctx).Where("provider = ? AND subject = ?", info.Provider, info.Subject).First(&identity).Error
+	if err == nil {
+		if identity.UserID != userID {
+			return model.ErrIdentityLinked
+		}
+
+		return nil
+	}
+
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		logger.Errorf("Error querying identity: %v", err)
+		return err
+	}
+
+	if err := a.db.WithContext(ctx).Create(&model.UserIdentity{
+		UserID:   userID,
+		Provider: info.Provider,
+		Subject:  info.Subject,
+	}).Error; err != nil {
+		logger.Errorf("Error linking identity: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+func (a *userRepository) FindIdentities(ctx context.Context, userID string) ([]model.UserIdentity, error) {
+	var identities []model.UserIdentity
+
+	if err := a.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at ASC").Find(&identities).Error; err != nil {
+		logrus.WithField("user_id", userID).Errorf("Error querying identities: %v", err)
+		return nil, err
+	}
+
+	return identities, nil
+}
@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/notblessy/anggar-service/model"
+	"google.golang.org/api/idtoken"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newTestDB(t *testing.T, models ...interface{}) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every connection to :memory: is a database of its own
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+// An attacker registers someone else's address with a password they know.
+// When the real owner later signs in through a provider, the account they
+// take over must not keep the attacker's password.
+func TestAuthenticateExternalDropsPendingPassword(t *testing.T) {
+	db := newTestDB(t, &model.User{}, &model.UserIdentity{}, &model.UserToken{}, &model.AllowedEmail{})
+	repo := NewUserRepository(db)
+	ctx := context.Background()
+
+	registered, err := repo.Register(ctx, model.RegisterRequest{Name: "Mallory", Email: "victim@example.com", Password: "attacker-password"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := repo.AuthenticateExternal(ctx, model.ExternalAuthInfo{
+		Provider:      "oidc:keycloak",
+		Subject:       "victim-subject",
+		Email:         "victim@example.com",
+		EmailVerified: true,
+		Name:          "Victim",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if user.ID != registered.ID || user.EmailVerifiedAt == nil {
+		t.Fatalf("expected the pending account to be linked and verified, got %+v", user)
+	}
+
+	if _, err := repo.Login(ctx, "victim@example.com", "attacker-password"); !errors.Is(err, model.ErrInvalidCredentials) {
+		t.Fatalf("login with the pending password: got %v", err)
+	}
+
+	identities, err := repo.FindIdentities(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(identities) != 1 || identities[0].Provider != "oidc:keycloak" {
+		t.Fatalf("expected only the provider identity, got %+v", identities)
+	}
+}
+
+func TestAuthenticateExternalKeepsVerifiedPassword(t *testing.T) {
+	db := newTestDB(t, &model.User{}, &model.UserIdentity{}, &model.UserToken{}, &model.AllowedEmail{})
+	repo := NewUserRepository(db)
+	ctx := context.Background()
+
+	registered, err := repo.Register(ctx, model.RegisterRequest{Name: "Ana", Email: "ana@example.com", Password: "ana-password"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.MarkEmailVerified(ctx, registered.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = repo.AuthenticateExternal(ctx, model.ExternalAuthInfo{
+		Provider:      "oidc:keycloak",
+		Subject:       "ana-subject",
+		Email:         "ana@example.com",
+		EmailVerified: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repo.Login(ctx, "ana@example.com", "ana-password"); err != nil {
+		t.Fatalf("a verified owner keeps their password: %v", err)
+	}
+}
+
+// A Google address only links to an existing account when Google says it is verified.
+func TestGoogleSignInNeedsVerifiedEmail(t *testing.T) {
+	db := newTestDB(t, &model.User{}, &model.UserIdentity{}, &model.UserToken{}, &model.AllowedEmail{})
+	repo := &userRepository{db: db}
+	ctx := context.Background()
+
+	registered, err := repo.Register(ctx, model.RegisterRequest{Name: "Ana", Email: "ana@example.com", Password: "ana-password"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.MarkEmailVerified(ctx, registered.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		verified interface{}
+		linked   bool
+	}{
+		{"missing", nil, false},
+		{"false", false, false},
+		{"string false", "false", false},
+		{"true", true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := map[string]interface{}{"email": "ana@example.com", "name": "Ana", "picture": ""}
+			if tt.verified != nil {
+				claims["email_verified"] = tt.verified
+			}
+
+			info, err := repo.claimAuth(&idtoken.Payload{Subject: "google-" + tt.name, Claims: claims})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			user, err := repo.AuthenticateExternal(ctx, info.External())
+
+			if !tt.linked {
+				if !errors.Is(err, model.ErrEmailNotConfirmed) {
+					t.Fatalf("expected ErrEmailNotConfirmed, got %v", err)
+				}
+
+				return
+			}
+
+			if err != nil || user.ID != registered.ID {
+				t.Fatalf("expected the account to be linked, got %+v, %v", user, err)
+			}
+		})
+	}
+}
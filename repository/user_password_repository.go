@@ -0,0 +1,231 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	gonanoid "github.com/matoous/go-nanoid/v2"
+	"github.com/notblessy/anggar-service/model"
+	"github.com/notblessy/anggar-service/utils"
+	"github.com/oklog/ulid/v2"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func (a *userRepository) Register(ctx context.Context, input model.RegisterRequest) (model.User, error) {
+	email := strings.ToLower(strings.TrimSpace(input.Email))
+	logger := logrus.WithField("email", email)
+
+	allowed, err := a.isEmailAllowed(ctx, email)
+	if err != nil {
+		logger.Errorf("Error checking allowed emails: %v", err)
+		return model.User{}, err
+	}
+
+	if !allowed {
+		return model.User{}, model.ErrEmailNotAllowed
+	}
+
+	var count int64
+	if err := a.db.WithContext(ctx).Model(&model.User{}).Where("email = ?", email).Count(&count).Error; err != nil {
+		logger.Errorf("Error querying user: %v", err)
+		return model.User{}, err
+	}
+
+	if count > 0 {
+		return model.User{}, model.ErrEmailTaken
+	}
+
+	id, err := gonanoid.New()
+	if err != nil {
+		logger.Errorf("Error generating id: %v", err)
+		return model.User{}, err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+	if err != nil {
+		logger.Errorf("Error hashing password: %v", err)
+		return model.User{}, err
+	}
+
+	user := model.User{
+		ID:       id,
+		Email:    email,
+		Name:     input.Name,
+		Password: string(hash),
+		Role:     model.RoleUser,
+	}
+
+	err = a.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&model.UserIdentity{
+			UserID:   user.ID,
+			Provider: model.IdentityPassword,
+			Subject:  user.ID,
+		}).Error
+	})
+	if err != nil {
+		logger.Errorf("Error creating user: %v", err)
+		return model.User{}, err
+	}
+
+	user.OmitPassword()
+
+	return user, nil
+}
+
+func (a *userRepository) Login(ctx context.Context, email, password string) (model.User, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	logger := logrus.WithField("email", email)
+
+	var user model.User
+	err := a.db.WithContext(ctx).Where("email = ?", email).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return model.User{}, model.ErrInvalidCredentials
+	}
+
+	if err != nil {
+		logger.Errorf("Error querying user: %v", err)
+		return model.User{}, err
+	}
+
+	if user.Password == "" || bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) != nil {
+		return model.User{}, model.ErrInvalidCredentials
+	}
+
+	if user.EmailVerifiedAt == nil {
+		return model.User{}, model.ErrEmailNotVerified
+	}
+
+	user.OmitPassword()
+
+	return user, nil
+}
+
+func (a *userRepository) CheckPassword(ctx context.Context, userID, password string) error {
+	var user model.User
+	if err := a.db.WithContext(ctx).Where("id = ?", userID).First(&user).Error; err != nil {
+		logrus.WithField("user_id", userID).Errorf("Error querying user: %v", err)
+		return err
+	}
+
+	if user.Password == "" || bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) != nil {
+		return model.ErrInvalidCredentials
+	}
+
+	return nil
+}
+
+// SetPassword replaces the password and links the password identity when it is missing.
+func (a *userRepository) SetPassword(ctx context.Context, userID, password string) error {
+	logger := logrus.WithField("user_id", userID)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		logger.Errorf("Error hashing password: %v", err)
+		return err
+	}
+
+	err = a.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.User{}).Where("id = ?", userID).Update("password", string(hash)).Error; err != nil {
+			return err
+		}
+
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.UserIdentity{
+			UserID:   userID,
+			Provider: model.IdentityPassword,
+			Subject:  userID,
+		}).Error
+	})
+	if err != nil {
+		logger.Errorf("Error setting password: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+func (a *userRepository) MarkEmailVerified(ctx context.Context, userID string) error {
+	logger := logrus.WithField("user_id", userID)
+
+	err := a.db.WithContext(ctx).
+		Model(&model.User{}).
+		Where("id = ? AND email_verified_at IS NULL", userID).
+		Update("email_verified_at", time.Now()).Error
+	if err != nil {
+		logger.Errorf("Error verifying email: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+func (a *userRepository) IssueToken(ctx context.Context, userID, purpose string, ttl time.Duration) (string, error) {
+	logger := logrus.WithField("user_id", userID).WithField("purpose", purpose)
+
+	raw, err := utils.RandomToken(32)
+	if err != nil {
+		logger.Errorf("Error generating token: %v", err)
+		return "", err
+	}
+
+	token := model.UserToken{
+		ID:        ulid.Make().String(),
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: utils.HashToken(raw),
+		ExpiresAt: time.Now().Add(ttl),
+	}
+
+	if err := a.db.WithContext(ctx).Create(&token).Error; err != nil {
+		logger.Errorf("Error creating token: %v", err)
+		return "", err
+	}
+
+	return raw, nil
+}
+
+func (a *userRepository) ConsumeToken(ctx context.Context, purpose, rawToken string) (model.User, error) {
+	logger := logrus.WithField("purpose", purpose)
+
+	var user model.User
+
+	err := a.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var token model.UserToken
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ? AND purpose = ?", utils.HashToken(rawToken), purpose).
+			First(&token).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.ErrInvalidToken
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if token.UsedAt != nil || time.Now().After(token.ExpiresAt) {
+			return model.ErrInvalidToken
+		}
+
+		if err := tx.Model(&token).Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+
+		return tx.Where("id = ?", token.UserID).First(&user).Error
+	})
+	if err != nil {
+		logger.Errorf("Error consuming token: %v", err)
+		return model.User{}, err
+	}
+
+	user.OmitPassword()
+
+	return user, nil
+}
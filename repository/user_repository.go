@@ -2,11 +2,9 @@ package repository
 
 import (
 	"context"
-	"fmt"
 	"os"
 	"strings"
 
-	gonanoid "github.com/matoous/go-nanoid/v2"
 	"github.com/notblessy/anggar-service/model"
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
@@ -36,53 +34,29 @@ func (a *userRepository) Authenticate(ctx context.Context, code, requestOrigin s
 		return model.User{}, err
 	}
 
-	id, err := gonanoid.New()
-	if err != nil {
-		logger.Errorf("Error generating id: %v", err)
-		return model.User{}, err
-	}
-
-	validEmails := os.Getenv("VALID_EMAILS")
-	if validEmails != "" {
-		validEmailList := strings.Split(validEmails, ",")
-		if !utils.Contains(validEmailList, auth.Email) {
-			logger.Errorf("Email %s is not in the list of valid emails", auth.Email)
-			return model.User{}, fmt.Errorf("email %s is not in the list of valid emails", auth.Email)
-		}
-	}
+	return a.AuthenticateExternal(ctx, auth.External())
+}
 
-	var authUser model.User
+func (a *userRepository) FindByID(ctx context.Context, id string) (model.User, error) {
+	logger := logrus.WithField("id", id)
 
-	err = a.db.Where("email = ?", auth.Email).First(&authUser).Error
-	if err != nil && err != gorm.ErrRecordNotFound {
+	var user model.User
+	err := a.db.Where("id = ?", id).First(&user).Error
+	if err != nil {
 		logger.Errorf("Error querying user: %v", err)
 		return model.User{}, err
 	}
 
-	if err == gorm.ErrRecordNotFound {
-		authUser = model.User{
-			ID:      id,
-			Name:    auth.Name,
-			Role:    "USER",
-			Email:   auth.Email,
-			Picture: auth.Picture,
-		}
-
-		err = a.db.Create(&authUser).Error
-		if err != nil {
-			logger.Errorf("Error creating user: %v", err)
-			return model.User{}, err
-		}
-	}
+	user.OmitPassword()
 
-	return authUser, nil
+	return user, nil
 }
 
-func (a *userRepository) FindByID(ctx context.Context, id string) (model.User, error) {
-	logger := logrus.WithField("id", id)
+func (a *userRepository) FindByEmail(ctx context.Context, email string) (model.User, error) {
+	logger := logrus.WithField("email", email)
 
 	var user model.User
-	err := a.db.Where("id = ?", id).First(&user).Error
+	err := a.db.WithContext(ctx).Where("email = ?", email).First(&user).Error
 	if err != nil {
 		logger.Errorf("Error querying user: %v", err)
 		return model.User{}, err
@@ -106,3 +80,26 @@ func (a *userRepository) FindOptions(ctx context.Context) ([]model.UserOption, e
 
 	return options, nil
 }
+
+// isEmailAllowed checks the allowed_emails table and the legacy VALID_EMAILS
+// variable. When both are empty every email is allowed.
+func (a *userRepository) isEmailAllowed(ctx context.Context, email string) (bool, error) {
+	var validEmailList []string
+
+	validEmails := os.Getenv("VALID_EMAILS")
+	if validEmails != "" {
+		validEmailList = strings.Split(validEmails, ",")
+	}
+
+	var stored []string
+	if err := a.db.WithContext(ctx).Model(&model.AllowedEmail{}).Pluck("email", &stored).Error; err != nil {
+		return false, err
+	}
+
+	validEmailList = append(validEmailList, stored...)
+	if len(validEmailList) == 0 {
+		return true, nil
+	}
+
+	return utils.Contains(validEmailList, email), nil
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/notblessy/anggar-service/model"
 	"github.com/notblessy/anggar-service/utils"
@@ -20,21 +21,20 @@ func NewWalletRepository(db *gorm.DB) model.WalletRepository {
 func (r *walletRepository) Create(ctx context.Context, wallet *model.Wallet) error {
 	logger := logrus.WithField("wallet", utils.Dump(wallet))
 
-	trx := r.db.WithContext(ctx).Begin()
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(wallet).Error; err != nil {
+			return err
+		}
 
-	if err := trx.Create(wallet).Error; err != nil {
-		logger.Error(err)
-		trx.Rollback()
-		return err
-	}
+		transaction := wallet.InitiateTransactionBalance()
 
-	transaction := wallet.InitiateTransactionBalance()
-	if err := trx.Create(&transaction).Error; err != nil {
+		return tx.Create(&transaction).Error
+	})
+	if err != nil {
 		logger.Error(err)
-		trx.Rollback()
+		return err
 	}
 
-	trx.Commit()
 	return nil
 }
 
@@ -46,13 +46,17 @@ func (r *walletRepository) FindAll(ctx context.Context, query model.WalletQueryI
 	qb := r.db.WithContext(ctx).Preload("Owner")
 
 	if query.UserID != "" {
-		qb.Where("user_id = ?", query.UserID)
+		qb = qb.Where("user_id = ?", query.UserID)
 	}
 
 	if query.Keyword != "" {
 		qb = qb.Where("name ILIKE ?", "%"+query.Keyword+"%")
 	}
 
+	if !query.IncludeArchived {
+		qb = qb.Where("archived_at IS NULL")
+	}
+
 	var total int64
 	if err := qb.Model(&model.Wallet{}).Count(&total).Error; err != nil {
 		logger.Error(err)
@@ -91,19 +95,107 @@ func (r *walletRepository) Update(ctx context.Context, id string, wallet model.W
 	return nil
 }
 
-func (r *walletRepository) Delete(ctx context.Context, id string) error {
-	logger := logrus.WithField("id", id)
-
-	tx := r.db.WithContext(ctx)
-
-	err := tx.Where("wallet_id = ?", id).Delete(&model.Transaction{}).Error
+func (r *walletRepository) Delete(ctx context.Context, id string, input model.WalletDeleteInput) (model.WalletDeleteResult, error) {
+	logger := logrus.WithField("id", id).WithField("input", utils.Dump(input))
+
+	result := model.WalletDeleteResult{Mode: input.Mode}
+
+	// Whatever is deleted shares the wallet's deleted_at so the trash can
+	// restore it together.
+	now := time.Now()
+	args := map[string]interface{}{"id": id, "target": input.TargetWalletID, "now": now}
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var res *gorm.DB
+
+		switch input.Mode {
+		case model.WalletDeleteMove:
+			res = tx.Exec(`UPDATE transactions SET wallet_id = @target, updated_at = @now WHERE wallet_id = @id AND deleted_at IS NULL`, args)
+			if res.Error != nil {
+				return res.Error
+			}
+
+			result.Transactions = res.RowsAffected
+
+			res = tx.Exec(`UPDATE recurring_transactions SET wallet_id = @target, updated_at = @now WHERE wallet_id = @id AND deleted_at IS NULL`, args)
+			if res.Error != nil {
+				return res.Error
+			}
+
+			result.Recurring = res.RowsAffected
+
+			if err := tx.Exec(`UPDATE user_preferences SET default_wallet_id = @target WHERE default_wallet_id = @id`, args).Error; err != nil {
+				return err
+			}
+
+			res = tx.Exec(`UPDATE goals SET wallet_id = @target, updated_at = @now WHERE wallet_id = @id`, args)
+			if res.Error != nil {
+				return res.Error
+			}
+
+			result.Goals = res.RowsAffected
+		case model.WalletDeleteArchive, model.WalletDeleteCascade:
+			if input.Mode == model.WalletDeleteCascade {
+				res = tx.Exec(`UPDATE transaction_shares SET deleted_at = @now
+					WHERE deleted_at IS NULL
+					AND transaction_id IN (SELECT id FROM transactions WHERE wallet_id = @id AND deleted_at IS NULL)`, args)
+				if res.Error != nil {
+					return res.Error
+				}
+
+				result.Shares = res.RowsAffected
+
+				res = tx.Exec(`UPDATE transactions SET deleted_at = @now WHERE wallet_id = @id AND deleted_at IS NULL`, args)
+				if res.Error != nil {
+					return res.Error
+				}
+
+				result.Transactions = res.RowsAffected
+			}
+
+			// series would otherwise keep filling a wallet nobody can see
+			res = tx.Exec(`UPDATE recurring_transactions SET is_active = FALSE, updated_at = @now
+				WHERE wallet_id = @id AND is_active AND deleted_at IS NULL`, args)
+			if res.Error != nil {
+				return res.Error
+			}
+
+			result.Recurring = res.RowsAffected
+
+			if err := tx.Exec(`UPDATE user_preferences SET default_wallet_id = '' WHERE default_wallet_id = @id`, args).Error; err != nil {
+				return err
+			}
+
+			// a goal following a wallet that takes nothing new could never
+			// grow again, unlinked it takes contributions instead
+			res = tx.Exec(`UPDATE goals SET wallet_id = NULL, updated_at = @now WHERE wallet_id = @id`, args)
+			if res.Error != nil {
+				return res.Error
+			}
+
+			result.Goals = res.RowsAffected
+		default:
+			return model.ErrInvalidWalletDeleteMode
+		}
+
+		if input.Mode == model.WalletDeleteArchive {
+			return tx.Exec(`UPDATE wallets SET archived_at = @now, updated_at = @now WHERE id = @id`, args).Error
+		}
+
+		return tx.Exec(`UPDATE wallets SET deleted_at = @now WHERE id = @id AND deleted_at IS NULL`, args).Error
+	})
 	if err != nil {
 		logger.Error(err)
-		tx.Rollback()
-		return err
+		return model.WalletDeleteResult{}, err
 	}
 
-	if err := tx.Delete(&model.Wallet{}, "id = ?", id).Error; err != nil {
+	return result, nil
+}
+
+func (r *walletRepository) Unarchive(ctx context.Context, id string) error {
+	logger := logrus.WithField("id", id)
+
+	if err := r.db.WithContext(ctx).Model(&model.Wallet{}).Where("id = ?", id).Update("archived_at", nil).Error; err != nil {
 		logger.Error(err)
 		return err
 	}
@@ -116,7 +208,7 @@ func (r *walletRepository) Option(ctx context.Context, userID string) ([]model.W
 
 	var wallets []model.Wallet
 
-	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Find(&wallets).Error
+	err := r.db.WithContext(ctx).Where("user_id = ? AND archived_at IS NULL", userID).Find(&wallets).Error
 
 	if err != nil {
 		logger.Error(err)
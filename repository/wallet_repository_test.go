@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/notblessy/anggar-service/model"
+	"github.com/shopspring/decimal"
+)
+
+// Every delete mode leaves goals able to grow: they follow the target wallet
+// or, when no wallet takes new transactions, go back to contributions.
+func TestWalletDeleteRelinksGoals(t *testing.T) {
+	target := "w2"
+
+	tests := []struct {
+		mode string
+		want *string
+	}{
+		{model.WalletDeleteMove, &target},
+		{model.WalletDeleteArchive, nil},
+		{model.WalletDeleteCascade, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			db := newTestDB(t, &model.Wallet{}, &model.Transaction{}, &model.TransactionShare{},
+				&model.RecurringTransaction{}, &model.UserPreference{}, &model.Goal{})
+			repo := NewWalletRepository(db)
+			ctx := context.Background()
+
+			// the model leaves it out, but the migrations give shares a deleted_at
+			if err := db.Exec(`ALTER TABLE transaction_shares ADD COLUMN deleted_at DATETIME`).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			for _, wallet := range []model.Wallet{{ID: "w1", UserID: "u1", Name: "Savings"}, {ID: "w2", UserID: "u1", Name: "Bank"}} {
+				if err := repo.Create(ctx, &wallet); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			source := "w1"
+			db.Create(&model.Goal{ID: "g1", UserID: "u1", Name: "Trip", TargetAmount: decimal.NewFromInt(100), WalletID: &source})
+
+			result, err := repo.Delete(ctx, "w1", model.WalletDeleteInput{Mode: tt.mode, TargetWalletID: target})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if result.Goals != 1 {
+				t.Fatalf("got %d goals touched, want 1", result.Goals)
+			}
+
+			var goal model.Goal
+			if err := db.First(&goal, "id = ?", "g1").Error; err != nil {
+				t.Fatal(err)
+			}
+
+			switch {
+			case tt.want == nil && goal.WalletID != nil:
+				t.Fatalf("expected the goal to be unlinked, it follows %s", *goal.WalletID)
+			case tt.want != nil && (goal.WalletID == nil || *goal.WalletID != *tt.want):
+				t.Fatalf("expected the goal to follow %s, got %v", *tt.want, goal.WalletID)
+			}
+		})
+	}
+}
+
+// A wallet whose opening balance cannot be recorded is not created either.
+func TestWalletCreateRollsBackWithoutOpeningBalance(t *testing.T) {
+	db := newTestDB(t, &model.Wallet{})
+	repo := NewWalletRepository(db)
+
+	wallet := model.Wallet{ID: "w1", UserID: "u1", Name: "Cash", Balance: decimal.NewFromInt(50)}
+	if err := repo.Create(context.Background(), &wallet); err == nil {
+		t.Fatal("expected the missing transactions table to fail the create")
+	}
+
+	var count int64
+	if err := db.Model(&model.Wallet{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 0 {
+		t.Fatal("the wallet was created without its opening balance")
+	}
+}
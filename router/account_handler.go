@@ -0,0 +1,206 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/notblessy/anggar-service/model"
+	"github.com/notblessy/anggar-service/utils"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// accountArchiveHandler downloads everything tied to the account as JSON.
+func (h *httpService) accountArchiveHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	archive, err := h.accountRepo.Archive(c.Request().Context(), session.ID)
+	if err != nil {
+		logger.Errorf("Error archiving account: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	fileName := fmt.Sprintf("anggar-account-%s.json", archive.ExportedAt.Format("20060102"))
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", fileName))
+
+	return c.JSONPretty(http.StatusOK, archive, "  ")
+}
+
+// restoreAccountHandler loads an archive produced by accountArchiveHandler,
+// sent either as the request body or as a multipart "file".
+func (h *httpService) restoreAccountHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	// The body is the archive itself, so only the query string is bound here.
+	var input model.RestoreInput
+	if err := (&echo.DefaultBinder{}).BindQueryParams(c, &input); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	var src io.Reader = c.Request().Body
+
+	if strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), echo.MIMEMultipartForm) {
+		file, err := c.FormFile("file")
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, response{Message: "file is required"})
+		}
+
+		if file.Size > model.AccountArchiveMaxSize {
+			return c.JSON(http.StatusRequestEntityTooLarge, response{Message: "file is too large"})
+		}
+
+		opened, err := file.Open()
+		if err != nil {
+			logger.Errorf("Error opening upload: %v", err)
+			return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+		}
+		defer opened.Close()
+
+		src = opened
+
+		if input.OnConflict == "" {
+			input.OnConflict = c.FormValue("on_conflict")
+		}
+	}
+
+	switch input.OnConflict {
+	case "":
+		input.OnConflict = model.RestoreSkip
+	case model.RestoreSkip, model.RestoreDuplicate:
+	default:
+		return c.JSON(http.StatusBadRequest, response{Message: "on_conflict must be skip or duplicate"})
+	}
+
+	var archive model.AccountArchive
+
+	limited := &io.LimitedReader{R: src, N: model.AccountArchiveMaxSize + 1}
+	if err := json.NewDecoder(limited).Decode(&archive); err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: "invalid archive: " + err.Error()})
+	}
+
+	if limited.N <= 0 {
+		return c.JSON(http.StatusRequestEntityTooLarge, response{Message: "file is too large"})
+	}
+
+	result, err := h.accountRepo.Restore(c.Request().Context(), session.ID, archive, input.OnConflict)
+	if errors.Is(err, model.ErrUnsupportedArchive) {
+		return c.JSON(http.StatusUnprocessableEntity, response{Message: err.Error()})
+	}
+
+	if err != nil {
+		logger.Errorf("Error restoring archive: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true, Data: result})
+}
+
+func (h *httpService) findAccountDeletionHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	deletion, err := h.accountRepo.FindDeletion(c.Request().Context(), session.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.JSON(http.StatusNotFound, response{Message: "no deletion requested"})
+	}
+
+	if err != nil {
+		logger.Errorf("Error getting account deletion: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true, Data: deletion})
+}
+
+// requestAccountDeletionHandler schedules the account for erasure after the
+// grace period. The owner must prove it is them once more before it is accepted.
+func (h *httpService) requestAccountDeletionHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	var input model.AccountDeletionRequest
+	if err := c.Bind(&input); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	identities, err := h.userRepo.FindIdentities(c.Request().Context(), session.ID)
+	if err != nil {
+		logger.Errorf("Error getting identities: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: "internal server error"})
+	}
+
+	for _, identity := range identities {
+		if identity.Provider != model.IdentityPassword {
+			continue
+		}
+
+		if err := h.userRepo.CheckPassword(c.Request().Context(), session.ID, input.Password); err != nil {
+			return c.JSON(http.StatusUnauthorized, response{Message: model.ErrInvalidCredentials.Error()})
+		}
+	}
+
+	err = h.verifyMFACode(c.Request().Context(), session.ID, input.MFACodeRequest)
+	if err != nil && !errors.Is(err, model.ErrMFANotEnrolled) {
+		return mfaCodeRejected(c, err)
+	}
+
+	deletion, err := h.accountRepo.RequestDeletion(c.Request().Context(), session.ID, time.Now().Add(model.AccountDeletionGracePeriod))
+	if err != nil {
+		logger.Errorf("Error requesting account deletion: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusAccepted, response{Success: true, Data: deletion})
+}
+
+func (h *httpService) cancelAccountDeletionHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	err = h.accountRepo.CancelDeletion(c.Request().Context(), session.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.JSON(http.StatusNotFound, response{Message: "no deletion requested"})
+	}
+
+	if err != nil {
+		logger.Errorf("Error cancelling account deletion: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true})
+}
@@ -0,0 +1,195 @@
+package router
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/notblessy/anggar-service/model"
+	"github.com/notblessy/anggar-service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+func (h *httpService) findAllUserHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	var query model.UserQueryInput
+	if err := c.Bind(&query); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	users, total, err := h.adminRepo.FindAllUsers(c.Request().Context(), query)
+	if err != nil {
+		logger.Errorf("Error getting users: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{
+		Success: true,
+		Data:    withPaging(users, total, query.PageOrDefault(), query.SizeOrDefault()),
+	})
+}
+
+func (h *httpService) updateUserRoleHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	id := c.Param("id")
+
+	var input model.RoleInput
+	if err := c.Bind(&input); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	if session.ID == id {
+		return c.JSON(http.StatusBadRequest, response{Message: "cannot change your own role"})
+	}
+
+	if err := h.adminRepo.UpdateRole(c.Request().Context(), id, input.Role); err != nil {
+		logger.Errorf("Error updating role: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true})
+}
+
+func (h *httpService) deleteUserHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	id := c.Param("id")
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	if session.ID == id {
+		return c.JSON(http.StatusBadRequest, response{Message: "cannot delete your own account"})
+	}
+
+	if err := h.adminRepo.DeleteUser(c.Request().Context(), id); err != nil {
+		logger.Errorf("Error deleting user: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	if err := h.sessionRepo.RevokeAll(c.Request().Context(), id); err != nil {
+		logger.Errorf("Error revoking sessions: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true})
+}
+
+// resetUserMFAHandler removes a user's second factor when they lose their device and recovery codes.
+func (h *httpService) resetUserMFAHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	id := c.Param("id")
+
+	if err := h.mfaRepo.Disable(c.Request().Context(), id); err != nil {
+		logger.Errorf("Error resetting mfa: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	if err := h.sessionRepo.RevokeAll(c.Request().Context(), id); err != nil {
+		logger.Errorf("Error revoking sessions: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true})
+}
+
+func (h *httpService) findAllowedEmailHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	emails, err := h.adminRepo.FindAllowedEmails(c.Request().Context())
+	if err != nil {
+		logger.Errorf("Error getting allowed emails: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true, Data: emails})
+}
+
+func (h *httpService) createAllowedEmailHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	var input model.AllowedEmailInput
+	if err := c.Bind(&input); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	allowed := model.AllowedEmail{Email: strings.ToLower(strings.TrimSpace(input.Email))}
+
+	if err := h.adminRepo.CreateAllowedEmail(c.Request().Context(), &allowed); err != nil {
+		logger.Errorf("Error creating allowed email: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusCreated, response{Success: true, Data: allowed})
+}
+
+func (h *httpService) deleteAllowedEmailHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	id := utils.ParseID(c.Param("id"))
+
+	if err := h.adminRepo.DeleteAllowedEmail(c.Request().Context(), id); err != nil {
+		logger.Errorf("Error deleting allowed email: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true})
+}
+
+func (h *httpService) findBotLinkHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	links, err := h.adminRepo.FindBotLinks(c.Request().Context())
+	if err != nil {
+		logger.Errorf("Error getting bot links: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true, Data: links})
+}
+
+func (h *httpService) deleteBotLinkHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	if err := h.adminRepo.Unlink(c.Request().Context(), c.Param("user_id")); err != nil {
+		logger.Errorf("Error unlinking bot: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true})
+}
+
+func (h *httpService) systemStatsHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	stats, err := h.adminRepo.Stats(c.Request().Context())
+	if err != nil {
+		logger.Errorf("Error getting stats: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true, Data: stats})
+}
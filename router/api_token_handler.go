@@ -0,0 +1,99 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/notblessy/anggar-service/model"
+	"github.com/notblessy/anggar-service/utils"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+func (h *httpService) findAllAPITokenHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	tokens, err := h.apiTokenRepo.FindAll(c.Request().Context(), session.ID)
+	if err != nil {
+		logger.Errorf("Error getting tokens: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true, Data: tokens})
+}
+
+func (h *httpService) createAPITokenHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	var input model.APITokenInput
+	if err := c.Bind(&input); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	if input.ExpiresAt != nil && input.ExpiresAt.Before(time.Now()) {
+		return c.JSON(http.StatusBadRequest, response{Message: "expires_at must be in the future"})
+	}
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	token := model.APIToken{
+		UserID:    session.ID,
+		Name:      input.Name,
+		Scopes:    input.Scopes,
+		ExpiresAt: input.ExpiresAt,
+	}
+
+	raw, err := h.apiTokenRepo.Create(c.Request().Context(), &token)
+	if err != nil {
+		logger.Errorf("Error creating token: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusCreated, response{
+		Success: true,
+		Message: "store this token now, it will not be shown again",
+		Data: map[string]interface{}{
+			"token":     raw,
+			"api_token": token,
+		},
+	})
+}
+
+func (h *httpService) revokeAPITokenHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	err = h.apiTokenRepo.Revoke(c.Request().Context(), session.ID, c.Param("id"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.JSON(http.StatusNotFound, response{Message: "token not found"})
+	}
+
+	if err != nil {
+		logger.Errorf("Error revoking token: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true})
+}
@@ -0,0 +1,291 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/notblessy/anggar-service/model"
+	"github.com/notblessy/anggar-service/utils"
+	"github.com/oklog/ulid/v2"
+	"github.com/sirupsen/logrus"
+)
+
+// attachmentBodyLimit caps an upload request at AttachmentMaxSize plus room
+// for the multipart headers, so an oversized body is refused before it is
+// buffered.
+const attachmentBodyLimit = "11M"
+
+func (h *httpService) findAllAttachmentHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	transaction, err := h.transactionRepo.FindByID(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		logger.Errorf("Error finding transaction: %v", err)
+		return c.JSON(http.StatusNotFound, response{Message: err.Error()})
+	}
+
+	if err := canViewTransaction(session, transaction); err != nil {
+		return forbidden(c)
+	}
+
+	attachments, err := h.attachmentRepo.FindByTransaction(c.Request().Context(), transaction.ID)
+	if err != nil {
+		logger.Errorf("Error getting attachments: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true, Data: attachments})
+}
+
+// uploadAttachmentHandler stores a multipart "file" with the transaction. The
+// type is sniffed from the content, and images get a JPEG thumbnail.
+func (h *httpService) uploadAttachmentHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	transaction, err := h.transactionRepo.FindByID(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		logger.Errorf("Error finding transaction: %v", err)
+		return c.JSON(http.StatusNotFound, response{Message: err.Error()})
+	}
+
+	if err := canModifyTransaction(session, transaction); err != nil {
+		return forbidden(c)
+	}
+
+	count, err := h.attachmentRepo.CountByTransaction(c.Request().Context(), transaction.ID)
+	if err != nil {
+		logger.Errorf("Error counting attachments: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	if count >= model.AttachmentsPerTransaction {
+		return c.JSON(http.StatusConflict, response{Message: model.ErrTooManyAttachments.Error()})
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: "file is required"})
+	}
+
+	if file.Size > model.AttachmentMaxSize {
+		return c.JSON(http.StatusRequestEntityTooLarge, response{Message: "file is too large"})
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		logger.Errorf("Error opening upload: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+	defer src.Close()
+
+	data, err := io.ReadAll(io.LimitReader(src, model.AttachmentMaxSize+1))
+	if err != nil {
+		logger.Errorf("Error reading upload: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	if len(data) > model.AttachmentMaxSize {
+		return c.JSON(http.StatusRequestEntityTooLarge, response{Message: "file is too large"})
+	}
+
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+
+	ext, ok := model.AttachmentContentTypes[contentType]
+	if !ok {
+		return c.JSON(http.StatusUnsupportedMediaType, response{Message: model.ErrUnsupportedAttachment.Error()})
+	}
+
+	attachment := model.Attachment{
+		ID:            ulid.Make().String(),
+		TransactionID: transaction.ID,
+		UserID:        session.ID,
+		FileName:      attachmentFileName(file.Filename, ext),
+		ContentType:   contentType,
+		Size:          int64(len(data)),
+	}
+
+	attachment.StorageKey = model.AttachmentKey(session.ID, attachment.ID)
+
+	if err := h.fileStorage.Put(c.Request().Context(), attachment.StorageKey, contentType, data); err != nil {
+		logger.Errorf("Error storing attachment: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	// A missing thumbnail is not worth failing the upload over.
+	if strings.HasPrefix(contentType, "image/") {
+		thumbnail, err := utils.Thumbnail(data, model.AttachmentThumbnailSize)
+		if err != nil {
+			logger.Warnf("Error creating thumbnail: %v", err)
+		} else {
+			key := attachment.StorageKey + "-thumbnail"
+
+			if err := h.fileStorage.Put(c.Request().Context(), key, model.AttachmentThumbnailMimeType, thumbnail); err != nil {
+				logger.Errorf("Error storing thumbnail: %v", err)
+			} else {
+				attachment.ThumbnailKey = &key
+			}
+		}
+	}
+
+	if err := h.attachmentRepo.Create(c.Request().Context(), &attachment); err != nil {
+		logger.Errorf("Error creating attachment: %v", err)
+		h.removeAttachmentFiles(c, attachment)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusCreated, response{Success: true, Data: attachment})
+}
+
+func (h *httpService) downloadAttachmentHandler(c echo.Context) error {
+	return h.serveAttachment(c, false)
+}
+
+func (h *httpService) attachmentThumbnailHandler(c echo.Context) error {
+	return h.serveAttachment(c, true)
+}
+
+// serveAttachment streams an attachment, or its thumbnail, to anyone who can
+// see the transaction.
+func (h *httpService) serveAttachment(c echo.Context, thumbnail bool) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	attachment, transaction, err := h.findAttachment(c)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, response{Message: err.Error()})
+	}
+
+	if err := canViewTransaction(session, transaction); err != nil {
+		return forbidden(c)
+	}
+
+	key, contentType, disposition := attachment.StorageKey, attachment.ContentType, "attachment"
+	if thumbnail {
+		if attachment.ThumbnailKey == nil {
+			return c.JSON(http.StatusNotFound, response{Message: "attachment has no thumbnail"})
+		}
+
+		key, contentType, disposition = *attachment.ThumbnailKey, model.AttachmentThumbnailMimeType, "inline"
+	}
+
+	body, err := h.fileStorage.Get(c.Request().Context(), key)
+	if errors.Is(err, model.ErrFileNotFound) {
+		return c.JSON(http.StatusGone, response{Message: err.Error()})
+	}
+
+	if err != nil {
+		logger.Errorf("Error reading attachment: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+	defer body.Close()
+
+	c.Response().Header().Set(echo.HeaderContentDisposition, mime.FormatMediaType(disposition, map[string]string{"filename": attachment.FileName}))
+	c.Response().Header().Set("X-Content-Type-Options", "nosniff")
+	if !thumbnail {
+		c.Response().Header().Set(echo.HeaderContentLength, fmt.Sprint(attachment.Size))
+	}
+
+	return c.Stream(http.StatusOK, contentType, body)
+}
+
+func (h *httpService) deleteAttachmentHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	attachment, transaction, err := h.findAttachment(c)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, response{Message: err.Error()})
+	}
+
+	// Uploaders may take back their own files from a shared transaction.
+	if attachment.UserID != session.ID {
+		if err := canModifyTransaction(session, transaction); err != nil {
+			return forbidden(c)
+		}
+	}
+
+	if err := h.attachmentRepo.Delete(c.Request().Context(), attachment.ID); err != nil {
+		logger.Errorf("Error deleting attachment: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	h.removeAttachmentFiles(c, attachment)
+
+	return c.JSON(http.StatusOK, response{Success: true})
+}
+
+// findAttachment loads the attachment in the path together with its
+// transaction, making sure the two belong together.
+func (h *httpService) findAttachment(c echo.Context) (model.Attachment, model.Transaction, error) {
+	attachment, err := h.attachmentRepo.FindByID(c.Request().Context(), c.Param("attachment_id"))
+	if err != nil {
+		return model.Attachment{}, model.Transaction{}, err
+	}
+
+	if attachment.TransactionID != c.Param("id") {
+		return model.Attachment{}, model.Transaction{}, model.ErrFileNotFound
+	}
+
+	transaction, err := h.transactionRepo.FindByID(c.Request().Context(), attachment.TransactionID)
+	if err != nil {
+		return model.Attachment{}, model.Transaction{}, err
+	}
+
+	return attachment, transaction, nil
+}
+
+// removeAttachmentFiles cleans up stored bytes; a leftover file only costs space.
+func (h *httpService) removeAttachmentFiles(c echo.Context, attachment model.Attachment) {
+	logger := logrus.WithField("attachment_id", attachment.ID)
+
+	if err := h.fileStorage.Delete(c.Request().Context(), attachment.StorageKey); err != nil {
+		logger.Errorf("Error removing attachment file: %v", err)
+	}
+
+	if attachment.ThumbnailKey != nil {
+		if err := h.fileStorage.Delete(c.Request().Context(), *attachment.ThumbnailKey); err != nil {
+			logger.Errorf("Error removing thumbnail file: %v", err)
+		}
+	}
+}
+
+// attachmentFileName keeps the uploaded name without any directories, with
+// the extension matching what the file really is.
+func attachmentFileName(name, ext string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+
+	if name == "" || name == "." || name == "/" {
+		name = "attachment"
+	}
+
+	return name + ext
+}
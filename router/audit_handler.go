@@ -0,0 +1,82 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/notblessy/anggar-service/model"
+	"github.com/notblessy/anggar-service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+func (h *httpService) transactionHistoryHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	transaction, err := h.transactionRepo.FindByID(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		logger.Errorf("Error finding transaction: %v", err)
+		return c.JSON(http.StatusNotFound, response{Message: err.Error()})
+	}
+
+	if err := canViewTransaction(session, transaction); err != nil {
+		return forbidden(c)
+	}
+
+	history, err := h.auditRepo.FindByTransaction(c.Request().Context(), transaction.ID)
+	if err != nil {
+		logger.Errorf("Error getting history: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true, Data: history})
+}
+
+// activityFeedHandler lists changes to every transaction the user created or
+// shares in, including ones that have since been deleted.
+func (h *httpService) activityFeedHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	var query model.AuditFeedQueryInput
+	if err := c.Bind(&query); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	query.ParticipantID = session.ID
+
+	results, total, err := h.auditRepo.Feed(c.Request().Context(), query)
+	if err != nil {
+		logger.Errorf("Error getting activity: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{
+		Success: true,
+		Data:    withPaging(results, total, query.PageOrDefault(), query.SizeOrDefault()),
+	})
+}
+
+// auditActor is the session user as recorded in the audit log.
+func auditActor(session jwtClaims) model.AuditActor {
+	return model.AuditActor{ID: session.ID, Channel: auditChannel(session)}
+}
+
+func auditChannel(session jwtClaims) string {
+	if session.TokenID != "" {
+		return model.AuditChannelAPIToken
+	}
+
+	return model.AuditChannelWeb
+}
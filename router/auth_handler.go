@@ -33,17 +33,91 @@ func (h *httpService) loginWithGoogleHandler(c echo.Context) error {
 		})
 	}
 
-	token, err := signJwtToken(auth.ID, auth.Name, auth.Role)
+	return h.completeLogin(c, auth)
+}
+
+func (h *httpService) refreshTokenHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	var input model.RefreshRequest
+	if err := c.Bind(&input); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, &response{
+			Success: false,
+			Message: err.Error(),
+		})
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return c.JSON(http.StatusBadRequest, &response{
+			Success: false,
+			Message: err.Error(),
+		})
+	}
+
+	session, refreshToken, err := h.sessionRepo.Rotate(c.Request().Context(), input.RefreshToken)
+	if err != nil {
+		logger.Errorf("Error rotating refresh token: %v", err)
+		return c.JSON(http.StatusUnauthorized, &response{
+			Success: false,
+			Message: "unauthorized",
+		})
+	}
+
+	return h.tokenResponse(c, session.User, session.ID, refreshToken)
+}
+
+func (h *httpService) logoutHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, &response{
+			Success: false,
+			Message: "unauthorized",
+		})
+	}
+
+	if err := h.sessionRepo.Revoke(c.Request().Context(), session.ID, session.SessionID); err != nil {
+		logger.Errorf("Error revoking session: %v", err)
+		return c.JSON(http.StatusInternalServerError, &response{
+			Success: false,
+			Message: "internal server error",
+		})
+	}
+
+	return c.JSON(http.StatusOK, &response{Success: true})
+}
+
+func (h *httpService) tokenResponse(c echo.Context, user model.User, sessionID, refreshToken string) error {
+	token, err := signJwtToken(user.ID, user.Name, user.Role, sessionID)
+	if err != nil {
+		logrus.Errorf("Error signing token: %v", err)
+		return c.JSON(http.StatusInternalServerError, &response{
+			Success: false,
+			Message: "internal server error",
+		})
+	}
 
 	return c.JSON(http.StatusOK, &response{
 		Success: true,
 		Data: map[string]interface{}{
-			"token": token,
-			"type":  "Bearer",
+			"token":         token,
+			"refresh_token": refreshToken,
+			"type":          "Bearer",
+			"expires_in":    int(accessTokenTTL.Seconds()),
 		},
 	})
 }
 
+func sessionMeta(c echo.Context) model.SessionMeta {
+	return model.SessionMeta{
+		UserAgent: c.Request().UserAgent(),
+		IPAddress: c.RealIP(),
+	}
+}
+
 func (h *httpService) profileHandler(c echo.Context) error {
 	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
 
@@ -78,6 +78,10 @@ func (h *httpService) createScopeHandler(c echo.Context) error {
 
 	scope.UserID = session.ID
 
+	if err := h.ownCategories(c.Request().Context(), session.ID, scope.CategoryIDs); err != nil {
+		return h.categoryError(c, err)
+	}
+
 	result, err := h.scopeRepo.Create(c.Request().Context(), scope)
 	if err != nil {
 		logger.Errorf("Error creating scope: %v", err)
@@ -107,8 +111,8 @@ func (h *httpService) findScopeByIDHandler(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
 	}
 
-	if scope.UserID != session.ID {
-		return c.JSON(http.StatusForbidden, response{Message: "forbidden"})
+	if err := canAccessScope(session, scope); err != nil {
+		return forbidden(c)
 	}
 
 	return c.JSON(http.StatusOK, response{
@@ -135,8 +139,27 @@ func (h *httpService) updateScopeHandler(c echo.Context) error {
 		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
 	}
 
+	existing, err := h.scopeRepo.FindByID(c.Request().Context(), id)
+	if err != nil {
+		logger.Errorf("Error getting scope: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	if err := canAccessScope(session, existing); err != nil {
+		return forbidden(c)
+	}
+
 	scope.UserID = session.ID
 
+	categoryIDs := make([]string, len(scope.ScopeCategories))
+	for i, category := range scope.ScopeCategories {
+		categoryIDs[i] = category.CategoryID
+	}
+
+	if err := h.ownCategories(c.Request().Context(), session.ID, categoryIDs); err != nil {
+		return h.categoryError(c, err)
+	}
+
 	if err := h.scopeRepo.Update(c.Request().Context(), id, scope); err != nil {
 		logger.Errorf("Error updating scope: %v", err)
 		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
@@ -162,8 +185,8 @@ func (h *httpService) deleteScopeHandler(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
 	}
 
-	if scope.UserID != session.ID {
-		return c.JSON(http.StatusForbidden, response{Message: "forbidden"})
+	if err := canAccessScope(session, scope); err != nil {
+		return forbidden(c)
 	}
 
 	if err := h.scopeRepo.Delete(c.Request().Context(), id); err != nil {
@@ -0,0 +1,370 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/notblessy/anggar-service/model"
+	"github.com/notblessy/anggar-service/utils"
+	"github.com/oklog/ulid/v2"
+	"github.com/sirupsen/logrus"
+)
+
+// findAllCategoryHandler lists the user's categories, nested with ?tree=true.
+func (h *httpService) findAllCategoryHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	var query model.CategoryQueryInput
+	if err := c.Bind(&query); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	var categories []model.Category
+	if query.Tree {
+		categories, err = h.categoryRepo.FindTree(c.Request().Context(), session.ID)
+	} else {
+		categories, err = h.categoryRepo.FindAll(c.Request().Context(), session.ID)
+	}
+
+	if err != nil {
+		logger.Errorf("Error getting categories: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true, Data: categories})
+}
+
+func (h *httpService) createCategoryHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	var input model.CategoryInput
+	if err := c.Bind(&input); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	category := model.Category{
+		ID:       ulid.Make().String(),
+		UserID:   session.ID,
+		ParentID: input.ParentID,
+		Slug:     model.CategorySlug(input.Name),
+		Name:     input.Name,
+		Icon:     input.Icon,
+		Color:    input.Color,
+	}
+
+	if err := h.checkCategory(c.Request().Context(), category); err != nil {
+		return h.categoryError(c, err)
+	}
+
+	if err := h.categoryRepo.Create(c.Request().Context(), &category); err != nil {
+		return h.categoryError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true, Data: category})
+}
+
+// updateCategoryHandler renames or moves a category. A new name gives a new
+// slug, and the user's transactions are rewritten to it.
+func (h *httpService) updateCategoryHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	var input model.CategoryInput
+	if err := c.Bind(&input); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	category, err := h.categoryRepo.FindByID(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		logger.Errorf("Error finding category: %v", err)
+		return c.JSON(http.StatusNotFound, response{Message: err.Error()})
+	}
+
+	if err := canAccessCategory(session, category); err != nil {
+		return forbidden(c)
+	}
+
+	category.ParentID = input.ParentID
+	category.Name = input.Name
+	category.Icon = input.Icon
+	category.Color = input.Color
+
+	// The fallback keeps its slug so imports and the bot can always file under it.
+	if category.Slug != model.CategoryOther {
+		category.Slug = model.CategorySlug(input.Name)
+	}
+
+	if err := h.checkCategory(c.Request().Context(), category); err != nil {
+		return h.categoryError(c, err)
+	}
+
+	rewritten, err := h.categoryRepo.Update(c.Request().Context(), category)
+	if err != nil {
+		return h.categoryError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, response{
+		Success: true,
+		Data:    map[string]interface{}{"category": category, "transactions_updated": rewritten},
+	})
+}
+
+// mergeCategoryHandler files everything under the category into target_id
+// and removes the category.
+func (h *httpService) mergeCategoryHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	var input model.CategoryMergeInput
+	if err := c.Bind(&input); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	source, err := h.categoryRepo.FindByID(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		logger.Errorf("Error finding category: %v", err)
+		return c.JSON(http.StatusNotFound, response{Message: err.Error()})
+	}
+
+	target, err := h.categoryRepo.FindByID(c.Request().Context(), input.TargetID)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: "unknown target_id"})
+	}
+
+	if canAccessCategory(session, source) != nil || canAccessCategory(session, target) != nil {
+		return forbidden(c)
+	}
+
+	if source.ID == target.ID {
+		return c.JSON(http.StatusBadRequest, response{Message: "cannot merge a category into itself"})
+	}
+
+	if source.Slug == model.CategoryOther {
+		return h.categoryError(c, model.ErrCategoryProtected)
+	}
+
+	rewritten, err := h.categoryRepo.Merge(c.Request().Context(), source, target)
+	if err != nil {
+		return h.categoryError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, response{
+		Success: true,
+		Data:    map[string]interface{}{"category": target, "transactions_updated": rewritten},
+	})
+}
+
+func (h *httpService) deleteCategoryHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	category, err := h.categoryRepo.FindByID(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		logger.Errorf("Error finding category: %v", err)
+		return c.JSON(http.StatusNotFound, response{Message: err.Error()})
+	}
+
+	if err := canAccessCategory(session, category); err != nil {
+		return forbidden(c)
+	}
+
+	if category.Slug == model.CategoryOther {
+		return h.categoryError(c, model.ErrCategoryProtected)
+	}
+
+	if err := h.categoryRepo.Delete(c.Request().Context(), category.ID); err != nil {
+		return h.categoryError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true})
+}
+
+// categoryAccuracyHandler reports per month how often the user kept the
+// category the bot suggested, over the last year unless dates are given.
+func (h *httpService) categoryAccuracyHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	var query model.CategoryAccuracyQueryInput
+	if err := c.Bind(&query); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	preference, err := h.preferenceRepo.Find(c.Request().Context(), session.ID)
+	if err != nil {
+		logger.Errorf("Error getting preference: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	query.UserID = session.ID
+	query.Timezone = preference.Timezone
+
+	if query.StartDate == "" || query.EndDate == "" {
+		now := preference.Now()
+		query.StartDate = time.Date(now.Year(), now.Month()-11, 1, 0, 0, 0, 0, now.Location()).Format("2006-01-02")
+		query.EndDate = now.Format("2006-01-02")
+	}
+
+	report, err := h.categoryRepo.Accuracy(c.Request().Context(), query)
+	if err != nil {
+		logger.Errorf("Error getting category accuracy: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true, Data: report})
+}
+
+// checkCategory validates the name and keeps the tree two levels deep: a
+// parent must be one of the user's top-level categories, and a category with
+// children cannot become a child itself.
+func (h *httpService) checkCategory(ctx context.Context, category model.Category) error {
+	if category.Slug == "" {
+		return model.ErrInvalidCategoryName
+	}
+
+	if category.ParentID == nil {
+		return nil
+	}
+
+	if *category.ParentID == category.ID {
+		return model.ErrInvalidCategoryTree
+	}
+
+	categories, err := h.categoryRepo.FindAll(ctx, category.UserID)
+	if err != nil {
+		return err
+	}
+
+	var parent *model.Category
+	for i := range categories {
+		if categories[i].ID == *category.ParentID {
+			parent = &categories[i]
+		}
+
+		if categories[i].ParentID != nil && *categories[i].ParentID == category.ID {
+			return model.ErrInvalidCategoryTree
+		}
+	}
+
+	if parent == nil {
+		return model.ErrUnknownCategory
+	}
+
+	if parent.ParentID != nil {
+		return model.ErrInvalidCategoryTree
+	}
+
+	return nil
+}
+
+// validCategory checks that a transaction is filed under one of the user's
+// categories. Empty is allowed, as are the system categories.
+func (h *httpService) validCategory(ctx context.Context, userID, slug string) error {
+	if slug == "" || slug == model.CategoryOpname || slug == model.CategoryOther {
+		return nil
+	}
+
+	categories, err := h.categoryRepo.FindAll(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := model.CategoryBySlug(categories, slug); !ok {
+		return model.ErrUnknownCategory
+	}
+
+	return nil
+}
+
+// ownCategories checks that scope category ids are the user's categories.
+func (h *httpService) ownCategories(ctx context.Context, userID string, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	categories, err := h.categoryRepo.FindAll(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	owned := make(map[string]bool, len(categories))
+	for _, category := range categories {
+		owned[category.ID] = true
+	}
+
+	for _, id := range ids {
+		if !owned[id] {
+			return model.ErrUnknownCategory
+		}
+	}
+
+	return nil
+}
+
+func (h *httpService) categoryError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, model.ErrInvalidCategoryName),
+		errors.Is(err, model.ErrInvalidCategoryTree),
+		errors.Is(err, model.ErrUnknownCategory):
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	case errors.Is(err, model.ErrCategoryExists),
+		errors.Is(err, model.ErrCategoryInUse),
+		errors.Is(err, model.ErrCategoryProtected):
+		return c.JSON(http.StatusConflict, response{Message: err.Error()})
+	}
+
+	logrus.WithField("ctx", utils.Dump(c.Request().Context())).Errorf("Error saving category: %v", err)
+	return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+}
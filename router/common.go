@@ -9,6 +9,7 @@ import (
 
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/labstack/echo/v4"
+	"github.com/notblessy/anggar-service/model"
 	"github.com/sirupsen/logrus"
 )
 
@@ -51,25 +52,56 @@ func getOffset(page, size int) int {
 	return (page - 1) * size
 }
 
+const accessTokenTTL = 15 * time.Minute
+
 type jwtClaims struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
-	Role string `json:"role"`
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Role      string `json:"role"`
+	SessionID string `json:"sid"`
+	// TokenID and Scopes are only set when the request used a personal access token.
+	TokenID string   `json:"-"`
+	Scopes  []string `json:"-"`
 	jwt.RegisteredClaims
 }
 
 func (j *jwtClaims) IsSuperAdmin() bool {
-	return j.Role == "notblessy"
+	return j.Role == model.RoleAdmin
 }
 
 func (j *jwtClaims) IsUser() bool {
-	return j.Role == "user"
+	return j.Role == model.RoleUser
+}
+
+// HasRole reports whether the session carries one of the given roles.
+func (j *jwtClaims) HasRole(roles ...string) bool {
+	for _, role := range roles {
+		if j.Role == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HasScope reports whether a personal access token carries the scope.
+func (j *jwtClaims) HasScope(scope string) bool {
+	for _, s := range j.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
 }
 
-type JWTMiddleware struct{}
+type JWTMiddleware struct {
+	sessionRepo  model.SessionRepository
+	apiTokenRepo model.APITokenRepository
+}
 
-func NewJWTMiddleware() *JWTMiddleware {
-	return &JWTMiddleware{}
+func NewJWTMiddleware(sessionRepo model.SessionRepository, apiTokenRepo model.APITokenRepository) *JWTMiddleware {
+	return &JWTMiddleware{sessionRepo: sessionRepo, apiTokenRepo: apiTokenRepo}
 }
 
 func (m *JWTMiddleware) ValidateJWT(next echo.HandlerFunc) echo.HandlerFunc {
@@ -88,6 +120,10 @@ func (m *JWTMiddleware) ValidateJWT(next echo.HandlerFunc) echo.HandlerFunc {
 			})
 		}
 
+		if model.IsAPIToken(token) {
+			return m.validateAPIToken(c, next, token)
+		}
+
 		// Call gRPC to validate the token
 		user, err := validateToken(token)
 		if err != nil || user.ID == "" {
@@ -97,12 +133,110 @@ func (m *JWTMiddleware) ValidateJWT(next echo.HandlerFunc) echo.HandlerFunc {
 			})
 		}
 
+		active, err := m.sessionRepo.IsActive(c.Request().Context(), user.SessionID)
+		if err != nil {
+			logrus.Error(err)
+			return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+		}
+
+		if !active {
+			return c.JSON(http.StatusUnauthorized, response{
+				Message: "cannot validate token: " + model.ErrSessionRevoked.Error(),
+			})
+		}
+
 		c.Set("user", user)
 
 		return next(c)
 	}
 }
 
+func (m *JWTMiddleware) validateAPIToken(c echo.Context, next echo.HandlerFunc, raw string) error {
+	token, err := m.apiTokenRepo.Authenticate(c.Request().Context(), raw)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, response{
+			Message: "cannot validate token: " + err.Error(),
+		})
+	}
+
+	c.Set("user", jwtClaims{
+		ID:      token.User.ID,
+		Name:    token.User.Name,
+		Role:    token.User.Role,
+		TokenID: token.ID,
+		Scopes:  token.Scopes,
+	})
+
+	return next(c)
+}
+
+// RequireTokenScope limits personal access tokens on a route group. Reads need
+// the read scope or the resource write scope, writes need "<resource>:write".
+// An empty resource makes the group read-only for tokens. Browser sessions pass through.
+func RequireTokenScope(resource string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			session, err := authSession(c)
+			if err != nil {
+				return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+			}
+
+			if session.TokenID == "" {
+				return next(c)
+			}
+
+			writeScope := resource + ":write"
+
+			switch c.Request().Method {
+			case http.MethodGet, http.MethodHead:
+				if session.HasScope(model.TokenScopeRead) || (resource != "" && session.HasScope(writeScope)) {
+					return next(c)
+				}
+			default:
+				if resource != "" && session.HasScope(writeScope) {
+					return next(c)
+				}
+			}
+
+			return forbidden(c)
+		}
+	}
+}
+
+// DenyAPIToken keeps personal access tokens away from account and admin routes.
+func DenyAPIToken(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		session, err := authSession(c)
+		if err != nil {
+			return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+		}
+
+		if session.TokenID != "" {
+			return forbidden(c)
+		}
+
+		return next(c)
+	}
+}
+
+// RequireRole rejects sessions that carry none of the given roles. It must run after ValidateJWT.
+func RequireRole(roles ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			session, err := authSession(c)
+			if err != nil {
+				return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+			}
+
+			if !session.HasRole(roles...) {
+				return forbidden(c)
+			}
+
+			return next(c)
+		}
+	}
+}
+
 func validateToken(tokenString string) (jwtClaims, error) {
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
@@ -136,20 +270,27 @@ func validateToken(tokenString string) (jwtClaims, error) {
 		return jwtClaims{}, errors.New("roleId not found in claims")
 	}
 
+	sid, ok := claims["sid"].(string)
+	if !ok {
+		return jwtClaims{}, errors.New("session id not found in claims")
+	}
+
 	return jwtClaims{
-		ID:   uid,
-		Name: name,
-		Role: role,
+		ID:        uid,
+		Name:      name,
+		Role:      role,
+		SessionID: sid,
 	}, nil
 }
 
-func signJwtToken(id, name string, role string) (string, error) {
+func signJwtToken(id, name, role, sessionID string) (string, error) {
 	claims := &jwtClaims{
-		ID:   id,
-		Name: name,
-		Role: role,
+		ID:        id,
+		Name:      name,
+		Role:      role,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * time.Duration(24*7))),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(accessTokenTTL)),
 		},
 	}
 
@@ -162,6 +303,41 @@ func signJwtToken(id, name string, role string) (string, error) {
 	return t, nil
 }
 
+const mfaChallengeAudience = "mfa"
+
+// signMFAChallenge issues a short-lived token proving the first factor passed.
+// It has no sid claim, so it is never accepted as an access token.
+func signMFAChallenge(userID string) (string, error) {
+	claims := jwt.RegisteredClaims{
+		Subject:   userID,
+		Audience:  jwt.ClaimStrings{mfaChallengeAudience},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(model.MFAChallengeTTL)),
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(os.Getenv("JWT_SECRET")))
+}
+
+func parseMFAChallenge(tokenString string) (string, error) {
+	claims := &jwt.RegisteredClaims{}
+
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+
+		return []byte(os.Getenv("JWT_SECRET")), nil
+	})
+	if err != nil || !token.Valid {
+		return "", errors.New("invalid challenge token")
+	}
+
+	if !claims.VerifyAudience(mfaChallengeAudience, true) || claims.Subject == "" {
+		return "", errors.New("invalid challenge token")
+	}
+
+	return claims.Subject, nil
+}
+
 func authSession(c echo.Context) (jwtClaims, error) {
 	u := c.Get("user")
 	if u == nil {
@@ -0,0 +1,115 @@
+package router
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/notblessy/anggar-service/model"
+	"github.com/notblessy/anggar-service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+func (h *httpService) creditCardStatementHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	card, err := h.walletRepo.FindByID(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		logger.Errorf("Error getting wallet: %v", err)
+		return c.JSON(http.StatusNotFound, response{Message: err.Error()})
+	}
+
+	if err := canAccessWallet(session, card); err != nil {
+		return forbidden(c)
+	}
+
+	if !card.IsCreditCard() {
+		return c.JSON(http.StatusBadRequest, response{Message: model.ErrNotCreditCard.Error()})
+	}
+
+	statement, err := h.creditCardRepo.Statement(c.Request().Context(), card, time.Now())
+	if err != nil {
+		logger.Errorf("Error getting statement: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true, Data: statement})
+}
+
+// payCreditCardHandler records a card payment as a transfer: an expense on
+// the paying wallet and a matching income on the card.
+func (h *httpService) payCreditCardHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	var input model.CreditCardPaymentInput
+	if err := c.Bind(&input); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	if err := c.Validate(&input); err != nil {
+		logger.Errorf("Error validating request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	card, err := h.walletRepo.FindByID(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		logger.Errorf("Error getting wallet: %v", err)
+		return c.JSON(http.StatusNotFound, response{Message: err.Error()})
+	}
+
+	if err := canAccessWallet(session, card); err != nil {
+		return forbidden(c)
+	}
+
+	if !card.IsCreditCard() {
+		return c.JSON(http.StatusBadRequest, response{Message: model.ErrNotCreditCard.Error()})
+	}
+
+	if err := h.canUseWallet(c.Request().Context(), session, input.FromWalletID); err != nil {
+		return forbidden(c)
+	}
+
+	source, err := h.walletRepo.FindByID(c.Request().Context(), input.FromWalletID)
+	if err != nil {
+		logger.Errorf("Error getting wallet: %v", err)
+		return c.JSON(http.StatusNotFound, response{Message: err.Error()})
+	}
+
+	if !input.Amount.IsPositive() || source.IsCreditCard() {
+		return c.JSON(http.StatusBadRequest, response{Message: model.ErrInvalidCardPayment.Error()})
+	}
+
+	preference, err := h.preferenceRepo.Find(c.Request().Context(), session.ID)
+	if err != nil {
+		logger.Errorf("Error getting preference: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	from, to := input.Transactions(session.ID, card)
+
+	for _, side := range []*model.Transaction{&from, &to} {
+		if err := model.ConvertTransaction(c.Request().Context(), h.exchangeRateRepo, side, preference.BaseCurrency); err != nil {
+			return h.conversionError(c, err)
+		}
+	}
+
+	if err := h.creditCardRepo.Pay(c.Request().Context(), &from, &to, auditActor(session)); err != nil {
+		logger.Errorf("Error saving card payment: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusCreated, response{Success: true, Data: []model.Transaction{from, to}})
+}
@@ -0,0 +1,150 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/notblessy/anggar-service/model"
+	"github.com/notblessy/anggar-service/utils"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+)
+
+func (h *httpService) findAllExchangeRateHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	var query model.ExchangeRateQueryInput
+	if err := c.Bind(&query); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	rates, total, err := h.exchangeRateRepo.FindAll(c.Request().Context(), query)
+	if err != nil {
+		logger.Errorf("Error getting exchange rates: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{
+		Success: true,
+		Data:    withPaging(rates, total, query.PageOrDefault(), query.SizeOrDefault()),
+	})
+}
+
+// convertExchangeRateHandler previews the rate a transaction would use:
+// GET /exchange-rates/convert?from=USD&to=IDR&date=2006-01-02&amount=10
+func (h *httpService) convertExchangeRateHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	preference, err := h.preferenceRepo.Find(c.Request().Context(), session.ID)
+	if err != nil {
+		logger.Errorf("Error getting preference: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	from := strings.ToUpper(c.QueryParam("from"))
+	to := strings.ToUpper(c.QueryParam("to"))
+
+	if from == "" {
+		return c.JSON(http.StatusBadRequest, response{Message: "from is required"})
+	}
+
+	if to == "" {
+		to = preference.BaseCurrency
+	}
+
+	date := preference.Now()
+	if raw := c.QueryParam("date"); raw != "" {
+		date, err = time.ParseInLocation("2006-01-02", raw, preference.Location())
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, response{Message: "invalid date"})
+		}
+	}
+
+	amount := decimal.NewFromInt(1)
+	if raw := c.QueryParam("amount"); raw != "" {
+		amount, err = decimal.NewFromString(raw)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, response{Message: "invalid amount"})
+		}
+	}
+
+	rate, err := h.exchangeRateRepo.Rate(c.Request().Context(), from, to, date)
+	if errors.Is(err, model.ErrRateNotFound) {
+		return c.JSON(http.StatusNotFound, response{Message: err.Error()})
+	}
+
+	if err != nil {
+		logger.Errorf("Error getting exchange rate: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{
+		Success: true,
+		Data: map[string]interface{}{
+			"rate":      rate,
+			"amount":    amount,
+			"converted": amount.Mul(rate.Rate).Round(2),
+		},
+	})
+}
+
+func (h *httpService) createExchangeRateHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	var input model.ExchangeRateInput
+	if err := c.Bind(&input); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	if !input.Rate.IsPositive() {
+		return c.JSON(http.StatusBadRequest, response{Message: "rate must be positive"})
+	}
+
+	rateDate, err := time.Parse("2006-01-02", input.RateDate)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: "invalid rate_date"})
+	}
+
+	rate := model.ExchangeRate{
+		Base:     input.Base,
+		Quote:    input.Quote,
+		Rate:     input.Rate,
+		RateDate: rateDate,
+		Source:   model.RateSourceManual,
+	}
+
+	if err := h.exchangeRateRepo.Save(c.Request().Context(), &rate); err != nil {
+		logger.Errorf("Error saving exchange rate: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusCreated, response{Success: true, Data: rate})
+}
+
+func (h *httpService) deleteExchangeRateHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	id := utils.ParseID(c.Param("id"))
+
+	if err := h.exchangeRateRepo.Delete(c.Request().Context(), id); err != nil {
+		logger.Errorf("Error deleting exchange rate: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true})
+}
@@ -0,0 +1,235 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/notblessy/anggar-service/model"
+	"github.com/notblessy/anggar-service/utils"
+	"github.com/oklog/ulid/v2"
+	"github.com/sirupsen/logrus"
+)
+
+// exportTransactionHandler streams transactions filtered like the list
+// endpoint as csv, xlsx or jsonl. Large or ?async=true exports are queued.
+func (h *httpService) exportTransactionHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	var query model.ExportQueryInput
+	if err := c.Bind(&query); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	if query.Format == "" {
+		query.Format = model.ExportFormatCSV
+	}
+
+	if query.Format == model.ExportFormatPDF || model.ExportContentTypes[query.Format] == "" {
+		return c.JSON(http.StatusBadRequest, response{Message: model.ErrUnsupportedFormat.Error()})
+	}
+
+	preference, err := h.preferenceRepo.Find(c.Request().Context(), session.ID)
+	if err != nil {
+		logger.Errorf("Error getting preference: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	filter := query.TransactionQueryInput
+	filter.ParticipantID = session.ID
+	filter.Timezone = preference.Timezone
+
+	total, err := h.exportRepo.CountTransactions(c.Request().Context(), filter)
+	if err != nil {
+		logger.Errorf("Error counting transactions: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	fileName := fmt.Sprintf("transactions-%s.%s", preference.Now().Format("20060102-150405"), query.Format)
+
+	if query.Async || total > model.ExportSyncLimit {
+		return h.queueExport(c, model.ExportJob{
+			UserID:   session.ID,
+			Kind:     model.ExportKindTransactions,
+			Format:   query.Format,
+			FileName: fileName,
+			Params:   model.ExportParams{Transactions: &filter},
+		})
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, model.ExportContentTypes[query.Format])
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", fileName))
+	c.Response().WriteHeader(http.StatusOK)
+
+	// Headers are gone by now, so a failure can only cut the download short.
+	if err := h.exportRepo.WriteTransactions(c.Request().Context(), c.Response(), query.Format, filter); err != nil {
+		logger.Errorf("Error exporting transactions: %v", err)
+	}
+
+	return nil
+}
+
+// exportStatementHandler renders the monthly PDF statement of a wallet.
+func (h *httpService) exportStatementHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	var query model.StatementQueryInput
+	if err := c.Bind(&query); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	if query.WalletID == "" {
+		return c.JSON(http.StatusBadRequest, response{Message: "wallet_id is required"})
+	}
+
+	// archived wallets can still be exported, their history is what they are kept for
+	wallet, err := h.walletRepo.FindByID(c.Request().Context(), query.WalletID)
+	if err != nil {
+		return forbidden(c)
+	}
+
+	if err := canAccessWallet(session, wallet); err != nil {
+		return forbidden(c)
+	}
+
+	preference, err := h.preferenceRepo.Find(c.Request().Context(), session.ID)
+	if err != nil {
+		logger.Errorf("Error getting preference: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	if query.Month == "" {
+		query.Month = preference.Now().AddDate(0, -1, 0).Format("2006-01")
+	}
+
+	if _, err := time.Parse("2006-01", query.Month); err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: "month must look like 2006-01"})
+	}
+
+	fileName := fmt.Sprintf("statement-%s-%s.pdf", query.WalletID, query.Month)
+
+	if query.Async {
+		return h.queueExport(c, model.ExportJob{
+			UserID:   session.ID,
+			Kind:     model.ExportKindStatement,
+			Format:   model.ExportFormatPDF,
+			FileName: fileName,
+			Params:   model.ExportParams{Statement: &query},
+		})
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, model.ExportContentTypes[model.ExportFormatPDF])
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", fileName))
+
+	if err := h.exportRepo.WriteStatement(c.Request().Context(), c.Response(), query); err != nil {
+		logger.Errorf("Error rendering statement: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return nil
+}
+
+func (h *httpService) queueExport(c echo.Context, job model.ExportJob) error {
+	job.ID = ulid.Make().String()
+	job.Status = model.ExportStatusPending
+
+	if err := h.exportRepo.CreateJob(c.Request().Context(), &job); err != nil {
+		logrus.WithField("ctx", utils.Dump(c.Request().Context())).Errorf("Error queueing export: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusAccepted, response{
+		Success: true,
+		Data: map[string]interface{}{
+			"job":          job,
+			"status_url":   "/api/v1/exports/jobs/" + job.ID,
+			"download_url": "/api/v1/exports/jobs/" + job.ID + "/download",
+		},
+	})
+}
+
+func (h *httpService) findExportJobHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	jobs, err := h.exportRepo.FindJobs(c.Request().Context(), session.ID)
+	if err != nil {
+		logger.Errorf("Error getting export jobs: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true, Data: jobs})
+}
+
+func (h *httpService) findExportJobByIDHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	job, err := h.exportRepo.FindJobByID(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		logger.Errorf("Error finding export job: %v", err)
+		return c.JSON(http.StatusNotFound, response{Message: err.Error()})
+	}
+
+	if job.UserID != session.ID {
+		return forbidden(c)
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true, Data: job})
+}
+
+func (h *httpService) downloadExportJobHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	job, err := h.exportRepo.FindJobByID(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		logger.Errorf("Error finding export job: %v", err)
+		return c.JSON(http.StatusNotFound, response{Message: err.Error()})
+	}
+
+	if job.UserID != session.ID {
+		return forbidden(c)
+	}
+
+	switch {
+	case job.Status == model.ExportStatusFailed:
+		return c.JSON(http.StatusUnprocessableEntity, response{Message: job.Error})
+	case job.Status != model.ExportStatusDone:
+		return c.JSON(http.StatusConflict, response{Message: "export is not ready yet"})
+	case job.FilePath == "" || (job.ExpiresAt != nil && job.ExpiresAt.Before(time.Now())):
+		return c.JSON(http.StatusGone, response{Message: "export has expired"})
+	}
+
+	return c.Attachment(job.FilePath, job.FileName)
+}
@@ -0,0 +1,358 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/notblessy/anggar-service/model"
+	"github.com/notblessy/anggar-service/utils"
+	"github.com/oklog/ulid/v2"
+	"github.com/sirupsen/logrus"
+)
+
+func (h *httpService) findAllGoalHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	goals, err := h.goalRepo.FindAll(c.Request().Context(), session.ID)
+	if err != nil {
+		logger.Errorf("Error getting goals: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	for i := range goals {
+		if err := h.withGoalProgress(c.Request().Context(), &goals[i]); err != nil {
+			logger.Errorf("Error getting goal progress: %v", err)
+			return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+		}
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true, Data: goals})
+}
+
+func (h *httpService) findGoalByIDHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	goal, err := h.goalRepo.FindByID(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		logger.Errorf("Error finding goal: %v", err)
+		return c.JSON(http.StatusNotFound, response{Message: err.Error()})
+	}
+
+	if err := canAccessGoal(session, goal); err != nil {
+		return forbidden(c)
+	}
+
+	if err := h.withGoalProgress(c.Request().Context(), &goal); err != nil {
+		logger.Errorf("Error getting goal progress: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true, Data: goal})
+}
+
+func (h *httpService) createGoalHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	var input model.GoalInput
+	if err := c.Bind(&input); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	goal := model.Goal{ID: ulid.Make().String(), UserID: session.ID, CreatedAt: time.Now()}
+
+	if err := h.applyGoalInput(c.Request().Context(), session, &goal, input); err != nil {
+		return h.goalError(c, err)
+	}
+
+	if err := h.goalRepo.Create(c.Request().Context(), &goal); err != nil {
+		return h.goalError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true, Data: goal})
+}
+
+func (h *httpService) updateGoalHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	var input model.GoalInput
+	if err := c.Bind(&input); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	goal, err := h.goalRepo.FindByID(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		logger.Errorf("Error finding goal: %v", err)
+		return c.JSON(http.StatusNotFound, response{Message: err.Error()})
+	}
+
+	if err := canAccessGoal(session, goal); err != nil {
+		return forbidden(c)
+	}
+
+	if err := h.applyGoalInput(c.Request().Context(), session, &goal, input); err != nil {
+		return h.goalError(c, err)
+	}
+
+	if err := h.goalRepo.Update(c.Request().Context(), goal); err != nil {
+		return h.goalError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true, Data: goal})
+}
+
+// deleteGoalHandler removes the goal and its contributions. A linked wallet
+// and its transactions are left alone.
+func (h *httpService) deleteGoalHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	goal, err := h.goalRepo.FindByID(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		logger.Errorf("Error finding goal: %v", err)
+		return c.JSON(http.StatusNotFound, response{Message: err.Error()})
+	}
+
+	if err := canAccessGoal(session, goal); err != nil {
+		return forbidden(c)
+	}
+
+	if err := h.goalRepo.Delete(c.Request().Context(), goal.ID); err != nil {
+		logger.Errorf("Error deleting goal: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true})
+}
+
+func (h *httpService) findAllGoalContributionHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	goal, err := h.goalRepo.FindByID(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		logger.Errorf("Error finding goal: %v", err)
+		return c.JSON(http.StatusNotFound, response{Message: err.Error()})
+	}
+
+	if err := canAccessGoal(session, goal); err != nil {
+		return forbidden(c)
+	}
+
+	contributions, err := h.goalRepo.FindContributions(c.Request().Context(), goal.ID)
+	if err != nil {
+		logger.Errorf("Error getting contributions: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true, Data: contributions})
+}
+
+// createGoalContributionHandler logs money put towards a goal, or taken out
+// of it when the amount is negative. Goals linked to a wallet follow the
+// wallet's transactions instead.
+func (h *httpService) createGoalContributionHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	var input model.GoalContributionInput
+	if err := c.Bind(&input); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	goal, err := h.goalRepo.FindByID(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		logger.Errorf("Error finding goal: %v", err)
+		return c.JSON(http.StatusNotFound, response{Message: err.Error()})
+	}
+
+	if err := canAccessGoal(session, goal); err != nil {
+		return forbidden(c)
+	}
+
+	if goal.WalletID != nil {
+		return h.goalError(c, model.ErrGoalTracksWallet)
+	}
+
+	if input.Amount.IsZero() {
+		return h.goalError(c, model.ErrInvalidGoalContribution)
+	}
+
+	if input.ContributedAt.IsZero() {
+		input.ContributedAt = time.Now()
+	}
+
+	contribution := model.GoalContribution{
+		ID:            ulid.Make().String(),
+		GoalID:        goal.ID,
+		UserID:        session.ID,
+		Amount:        input.Amount,
+		ContributedAt: input.ContributedAt,
+		Note:          input.Note,
+	}
+
+	if err := h.goalRepo.AddContribution(c.Request().Context(), &contribution); err != nil {
+		return h.goalError(c, err)
+	}
+
+	return c.JSON(http.StatusCreated, response{Success: true, Data: contribution})
+}
+
+func (h *httpService) deleteGoalContributionHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	goal, err := h.goalRepo.FindByID(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		logger.Errorf("Error finding goal: %v", err)
+		return c.JSON(http.StatusNotFound, response{Message: err.Error()})
+	}
+
+	if err := canAccessGoal(session, goal); err != nil {
+		return forbidden(c)
+	}
+
+	contribution, err := h.goalRepo.FindContributionByID(c.Request().Context(), c.Param("contribution_id"))
+	if err != nil || contribution.GoalID != goal.ID {
+		return c.JSON(http.StatusNotFound, response{Message: "contribution not found"})
+	}
+
+	if err := h.goalRepo.DeleteContribution(c.Request().Context(), contribution.ID); err != nil {
+		logger.Errorf("Error deleting contribution: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true})
+}
+
+// applyGoalInput copies the input onto the goal. Milestones the goal has
+// already passed with its new target are not celebrated afterwards.
+func (h *httpService) applyGoalInput(ctx context.Context, session jwtClaims, goal *model.Goal, input model.GoalInput) error {
+	if !input.TargetAmount.IsPositive() {
+		return model.ErrInvalidGoal
+	}
+
+	goal.Deadline = nil
+	if input.Deadline != "" {
+		deadline, err := time.Parse(time.DateOnly, input.Deadline)
+		if err != nil {
+			return model.ErrInvalidGoal
+		}
+
+		goal.Deadline = &deadline
+	}
+
+	if input.WalletID == "" {
+		goal.WalletID = nil
+	} else if goal.WalletID == nil || *goal.WalletID != input.WalletID {
+		if err := h.canUseWallet(ctx, session, input.WalletID); err != nil {
+			return err
+		}
+
+		wallet, err := h.walletRepo.FindByID(ctx, input.WalletID)
+		if err != nil {
+			return err
+		}
+
+		if wallet.IsCreditCard() {
+			return model.ErrInvalidGoalWallet
+		}
+
+		goal.WalletID = &wallet.ID
+	}
+
+	goal.Name = input.Name
+	goal.TargetAmount = input.TargetAmount
+
+	if err := h.withGoalProgress(ctx, goal); err != nil {
+		return err
+	}
+
+	goal.Milestone = goal.Progress.Milestone()
+
+	return nil
+}
+
+// withGoalProgress fills in the goal's progress as of now.
+func (h *httpService) withGoalProgress(ctx context.Context, goal *model.Goal) error {
+	progress, err := h.goalRepo.Progress(ctx, *goal, time.Now())
+	if err != nil {
+		return err
+	}
+
+	goal.Progress = &progress
+
+	return nil
+}
+
+func (h *httpService) goalError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, model.ErrInvalidGoal),
+		errors.Is(err, model.ErrInvalidGoalWallet),
+		errors.Is(err, model.ErrGoalTracksWallet),
+		errors.Is(err, model.ErrInvalidGoalContribution):
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	case errors.Is(err, model.ErrForbidden):
+		return forbidden(c)
+	}
+
+	logrus.WithField("ctx", utils.Dump(c.Request().Context())).Errorf("Error saving goal: %v", err)
+	return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+}
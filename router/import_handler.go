@@ -0,0 +1,564 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/notblessy/anggar-service/model"
+	"github.com/notblessy/anggar-service/utils"
+	"github.com/oklog/ulid/v2"
+	"github.com/sirupsen/logrus"
+)
+
+// importBodyLimit caps a statement upload at ImportMaxFileSize plus room for
+// the multipart headers and form fields, so an oversized body is refused
+// before it is buffered.
+var importBodyLimit = fmt.Sprintf("%dKiB", (model.ImportMaxFileSize+64<<10)>>10)
+
+func (h *httpService) findImportMappingHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	mappings, err := h.importRepo.FindMappings(c.Request().Context(), session.ID)
+	if err != nil {
+		logger.Errorf("Error getting import mappings: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true, Data: mappings})
+}
+
+func (h *httpService) createImportMappingHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	var input model.ImportMappingInput
+	if err := c.Bind(&input); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	mapping := model.ImportMapping{ID: ulid.Make().String(), UserID: session.ID}
+	applyImportMappingInput(&mapping, input)
+
+	if err := h.importRepo.SaveMapping(c.Request().Context(), &mapping); err != nil {
+		logger.Errorf("Error saving import mapping: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusCreated, response{Success: true, Data: mapping})
+}
+
+func (h *httpService) updateImportMappingHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	var input model.ImportMappingInput
+	if err := c.Bind(&input); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	mapping, err := h.importRepo.FindMappingByID(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		logger.Errorf("Error finding import mapping: %v", err)
+		return c.JSON(http.StatusNotFound, response{Message: err.Error()})
+	}
+
+	if err := canAccessImportMapping(session, mapping); err != nil {
+		return forbidden(c)
+	}
+
+	applyImportMappingInput(&mapping, input)
+
+	if err := h.importRepo.SaveMapping(c.Request().Context(), &mapping); err != nil {
+		logger.Errorf("Error saving import mapping: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true, Data: mapping})
+}
+
+func (h *httpService) deleteImportMappingHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	mapping, err := h.importRepo.FindMappingByID(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		logger.Errorf("Error finding import mapping: %v", err)
+		return c.JSON(http.StatusNotFound, response{Message: err.Error()})
+	}
+
+	if err := canAccessImportMapping(session, mapping); err != nil {
+		return forbidden(c)
+	}
+
+	if err := h.importRepo.DeleteMapping(c.Request().Context(), mapping.ID); err != nil {
+		logger.Errorf("Error deleting import mapping: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true})
+}
+
+func applyImportMappingInput(mapping *model.ImportMapping, input model.ImportMappingInput) {
+	mapping.Name = input.Name
+	mapping.Delimiter = input.Delimiter
+	mapping.HasHeader = input.HasHeader
+	mapping.SkipRows = input.SkipRows
+	mapping.DateColumn = input.DateColumn
+	mapping.DateFormat = input.DateFormat
+	mapping.DescriptionColumn = input.DescriptionColumn
+	mapping.AmountColumn = input.AmountColumn
+	mapping.DebitColumn = input.DebitColumn
+	mapping.CreditColumn = input.CreditColumn
+	mapping.DecimalSeparator = input.DecimalSeparator
+	mapping.InvertSign = input.InvertSign
+	mapping.Currency = strings.ToUpper(input.Currency)
+
+	if mapping.Delimiter == "" {
+		mapping.Delimiter = ","
+	}
+
+	if mapping.DecimalSeparator == "" {
+		mapping.DecimalSeparator = "."
+	}
+}
+
+func (h *httpService) findAllImportHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	var query model.ImportQueryInput
+	if err := c.Bind(&query); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	query.UserID = session.ID
+
+	batches, total, err := h.importRepo.FindAll(c.Request().Context(), query)
+	if err != nil {
+		logger.Errorf("Error getting imports: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{
+		Success: true,
+		Data:    withPaging(batches, total, query.PageOrDefault(), query.SizeOrDefault()),
+	})
+}
+
+func (h *httpService) findImportByIDHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	batch, err := h.importRepo.FindByID(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		logger.Errorf("Error finding import: %v", err)
+		return c.JSON(http.StatusNotFound, response{Message: err.Error()})
+	}
+
+	if err := canAccessImport(session, batch); err != nil {
+		return forbidden(c)
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true, Data: batch})
+}
+
+// previewImportHandler parses an uploaded statement (multipart field "file"),
+// flags rows that look already recorded and keeps them as a pending batch.
+func (h *httpService) previewImportHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	var input model.ImportPreviewInput
+	if err := c.Bind(&input); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: "file is required"})
+	}
+
+	if file.Size > model.ImportMaxFileSize {
+		return c.JSON(http.StatusRequestEntityTooLarge, response{Message: "file is too large"})
+	}
+
+	preference, err := h.preferenceRepo.Find(c.Request().Context(), session.ID)
+	if err != nil {
+		logger.Errorf("Error getting preference: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	if input.WalletID == "" {
+		input.WalletID = preference.DefaultWalletID
+	}
+
+	if err := h.canUseWallet(c.Request().Context(), session, input.WalletID); err != nil {
+		return forbidden(c)
+	}
+
+	format := strings.ToLower(input.Format)
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(file.Filename)), ".")
+	}
+
+	var mapping *model.ImportMapping
+
+	if format == model.ImportFormatCSV {
+		if input.MappingID == "" {
+			return c.JSON(http.StatusBadRequest, response{Message: "mapping_id is required for csv files"})
+		}
+
+		found, err := h.importRepo.FindMappingByID(c.Request().Context(), input.MappingID)
+		if err != nil || canAccessImportMapping(session, found) != nil {
+			return c.JSON(http.StatusBadRequest, response{Message: "unknown mapping_id"})
+		}
+
+		mapping = &found
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		logger.Errorf("Error opening upload: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+	defer src.Close()
+
+	entries, err := parseStatement(io.LimitReader(src, model.ImportMaxFileSize), format, mapping, input.DateFormat)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	if len(entries) > model.ImportMaxRows {
+		return c.JSON(http.StatusBadRequest, response{Message: "statement has too many rows"})
+	}
+
+	currency := strings.ToUpper(input.Currency)
+	if currency == "" && mapping != nil {
+		currency = mapping.Currency
+	}
+
+	rows, err := h.buildImportRows(c.Request().Context(), session.ID, entries, currency, preference)
+	if err != nil {
+		logger.Errorf("Error checking duplicates: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	batch := model.ImportBatch{
+		ID:       ulid.Make().String(),
+		UserID:   session.ID,
+		WalletID: input.WalletID,
+		Format:   format,
+		FileName: file.Filename,
+		Status:   model.ImportStatusPreview,
+		Rows:     rows,
+	}
+
+	if mapping != nil {
+		batch.MappingID = &mapping.ID
+	}
+
+	if err := h.importRepo.CreateBatch(c.Request().Context(), &batch); err != nil {
+		logger.Errorf("Error saving import: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusCreated, response{Success: true, Data: batch})
+}
+
+func parseStatement(r io.Reader, format string, mapping *model.ImportMapping, dateFormat string) ([]utils.StatementEntry, error) {
+	switch format {
+	case model.ImportFormatCSV:
+		return utils.ParseCSVStatement(r, mapping.CSVOptions())
+	case model.ImportFormatOFX, model.ImportFormatQFX:
+		return utils.ParseOFXStatement(r)
+	case model.ImportFormatQIF:
+		return utils.ParseQIFStatement(r, dateFormat, "")
+	}
+
+	return nil, model.ErrUnsupportedFormat
+}
+
+// buildImportRows turns statement entries into preview rows and marks the
+// ones matching an existing transaction on the same day, with the same type
+// and amount and a similar description.
+func (h *httpService) buildImportRows(ctx context.Context, userID string, entries []utils.StatementEntry, currency string, preference model.UserPreference) ([]model.ImportRow, error) {
+	rows := make([]model.ImportRow, 0, len(entries))
+	if len(entries) == 0 {
+		return rows, nil
+	}
+
+	loc := preference.Location()
+	start, end := entries[0].Date, entries[0].Date
+
+	for _, entry := range entries {
+		if entry.Date.Before(start) {
+			start = entry.Date
+		}
+
+		if entry.Date.After(end) {
+			end = entry.Date
+		}
+	}
+
+	candidates, err := h.importRepo.FindCandidates(ctx, userID, importDay(start, loc).AddDate(0, 0, -1), importDay(end, loc).AddDate(0, 0, 2))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if entry.Amount.IsZero() {
+			continue
+		}
+
+		if entry.Currency == "" || currency != "" {
+			entry.Currency = currency
+		}
+
+		if entry.Currency == "" {
+			entry.Currency = preference.BaseCurrency
+		}
+
+		row := model.ImportRow{
+			StatementEntry:  entry,
+			TransactionType: model.TransactionTypeIncome,
+			AbsoluteAmount:  entry.Amount.Abs(),
+		}
+
+		if entry.Amount.IsNegative() {
+			row.TransactionType = model.TransactionTypeExpense
+		}
+
+		day := entry.Date.Format("2006-01-02")
+
+		for _, candidate := range candidates {
+			amount := candidate.OriginalAmount
+			if amount.IsZero() || (candidate.Currency != "" && candidate.Currency != entry.Currency) {
+				amount = candidate.Amount
+			}
+
+			if candidate.TransactionType != row.TransactionType || !amount.Equal(row.AbsoluteAmount) || candidate.SpentAt.In(loc).Format("2006-01-02") != day {
+				continue
+			}
+
+			if score := utils.Similarity(candidate.Description, entry.Description); score >= model.ImportDuplicateThreshold && score > row.Similarity {
+				row.Duplicate = true
+				row.DuplicateOf = candidate.ID
+				row.Similarity = score
+			}
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// importDay places a statement date, which carries no zone, at midday in the
+// user's timezone so it never drifts to a neighbouring day.
+func importDay(date time.Time, loc *time.Location) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 12, 0, 0, 0, loc)
+}
+
+func (h *httpService) commitImportHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	var input model.ImportCommitInput
+	if err := c.Bind(&input); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	batch, err := h.importRepo.FindByID(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		logger.Errorf("Error finding import: %v", err)
+		return c.JSON(http.StatusNotFound, response{Message: err.Error()})
+	}
+
+	if err := canAccessImport(session, batch); err != nil {
+		return forbidden(c)
+	}
+
+	if batch.Status != model.ImportStatusPreview {
+		return c.JSON(http.StatusConflict, response{Message: model.ErrImportNotPending.Error()})
+	}
+
+	// The wallet may have been archived or moved since the preview.
+	if err := h.canUseWallet(c.Request().Context(), session, batch.WalletID); err != nil {
+		return forbidden(c)
+	}
+
+	preference, err := h.preferenceRepo.Find(c.Request().Context(), session.ID)
+	if err != nil {
+		logger.Errorf("Error getting preference: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	skip := make(map[int]bool, len(input.SkipLines))
+	for _, line := range input.SkipLines {
+		skip[line] = true
+	}
+
+	if err := h.validCategory(c.Request().Context(), session.ID, input.Category); err != nil {
+		return h.categoryError(c, err)
+	}
+
+	payees, err := h.payeeRepo.FindAll(c.Request().Context(), model.PayeeQueryInput{UserID: session.ID})
+	if err != nil {
+		logger.Errorf("Error getting payees: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	transactions := make([]model.Transaction, 0, len(batch.Rows))
+
+	for _, row := range batch.Rows {
+		if skip[row.Line] || (row.Duplicate && !input.IncludeDuplicates) {
+			continue
+		}
+
+		// Rows land under the chosen category, else their payee's default.
+		category := input.Category
+		payee, known := model.MatchPayee(payees, row.Description)
+		if category == "" {
+			category = payee.DefaultCategory
+		}
+
+		if category == "" {
+			category = model.CategoryOther
+		}
+
+		transaction := model.Transaction{
+			ID:              ulid.Make().String(),
+			UserID:          session.ID,
+			WalletID:        batch.WalletID,
+			Category:        category,
+			TransactionType: row.TransactionType,
+			Description:     row.Description,
+			SpentAt:         importDay(row.Date, preference.Location()),
+			Amount:          row.AbsoluteAmount,
+			Currency:        row.Currency,
+			ImportBatchID:   &batch.ID,
+		}
+
+		if known {
+			transaction.PayeeID = &payee.ID
+		}
+
+		if err := model.ConvertTransaction(c.Request().Context(), h.exchangeRateRepo, &transaction, preference.BaseCurrency); err != nil {
+			return h.conversionError(c, err)
+		}
+
+		transactions = append(transactions, transaction)
+	}
+
+	err = h.importRepo.Commit(c.Request().Context(), &batch, transactions)
+	if errors.Is(err, model.ErrImportNotPending) {
+		return c.JSON(http.StatusConflict, response{Message: err.Error()})
+	}
+
+	if err != nil {
+		logger.Errorf("Error committing import: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true, Data: batch})
+}
+
+// undoImportHandler removes every transaction created by a committed import.
+func (h *httpService) undoImportHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	batch, err := h.importRepo.FindByID(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		logger.Errorf("Error finding import: %v", err)
+		return c.JSON(http.StatusNotFound, response{Message: err.Error()})
+	}
+
+	if err := canAccessImport(session, batch); err != nil {
+		return forbidden(c)
+	}
+
+	removed, err := h.importRepo.Undo(c.Request().Context(), &batch)
+	if errors.Is(err, model.ErrImportNotCommitted) {
+		return c.JSON(http.StatusConflict, response{Message: err.Error()})
+	}
+
+	if err != nil {
+		logger.Errorf("Error undoing import: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{
+		Success: true,
+		Data: map[string]interface{}{
+			"import_id": batch.ID,
+			"removed":   removed,
+		},
+	})
+}
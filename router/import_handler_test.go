@@ -0,0 +1,54 @@
+package router
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/notblessy/anggar-service/model"
+)
+
+// The largest statement allowed still fits the body limit, anything much
+// larger is refused before the handler buffers it.
+func TestImportBodyLimit(t *testing.T) {
+	e := echo.New()
+	e.POST("/imports", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	}, middleware.BodyLimit(importBodyLimit))
+
+	upload := func(size int) int {
+		var body bytes.Buffer
+
+		form := multipart.NewWriter(&body)
+		form.WriteField("format", "csv")
+		form.WriteField("date_format", "2006-01-02")
+
+		file, err := form.CreateFormFile("file", "statement.csv")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		file.Write(bytes.Repeat([]byte("x"), size))
+		form.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/imports", &body)
+		req.Header.Set(echo.HeaderContentType, form.FormDataContentType())
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		return rec.Code
+	}
+
+	if code := upload(model.ImportMaxFileSize); code != http.StatusOK {
+		t.Fatalf("largest statement: got %d", code)
+	}
+
+	if code := upload(2 * model.ImportMaxFileSize); code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("oversized statement: got %d", code)
+	}
+}
@@ -0,0 +1,347 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/notblessy/anggar-service/model"
+	"github.com/notblessy/anggar-service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const defaultTOTPIssuer = "Anggar"
+
+// completeLogin finishes a successful first factor. Users with TOTP enabled
+// get a challenge token instead of a session.
+func (h *httpService) completeLogin(c echo.Context, user model.User) error {
+	logger := logrus.WithField("user_id", user.ID)
+
+	totp, err := h.mfaRepo.FindTOTP(c.Request().Context(), user.ID)
+	if err != nil && !errors.Is(err, model.ErrMFANotEnrolled) {
+		logger.Errorf("Error getting totp: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: "internal server error"})
+	}
+
+	if err == nil && totp.Enabled() {
+		challenge, err := signMFAChallenge(user.ID)
+		if err != nil {
+			logger.Errorf("Error signing challenge: %v", err)
+			return c.JSON(http.StatusInternalServerError, response{Message: "internal server error"})
+		}
+
+		return c.JSON(http.StatusOK, response{
+			Success: true,
+			Data: map[string]interface{}{
+				"mfa_required":    true,
+				"challenge_token": challenge,
+				"expires_in":      int(model.MFAChallengeTTL.Seconds()),
+			},
+		})
+	}
+
+	session, refreshToken, err := h.sessionRepo.Start(c.Request().Context(), user.ID, sessionMeta(c))
+	if err != nil {
+		logger.Errorf("Error starting session: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: "internal server error"})
+	}
+
+	return h.tokenResponse(c, user, session.ID, refreshToken)
+}
+
+func (h *httpService) verifyMFAChallengeHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	var input model.MFAChallengeRequest
+	if err := c.Bind(&input); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	userID, err := parseMFAChallenge(input.ChallengeToken)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, response{Message: err.Error()})
+	}
+
+	if err := h.verifyMFACode(c.Request().Context(), userID, input.MFACodeRequest); err != nil {
+		return mfaCodeRejected(c, err)
+	}
+
+	user, err := h.userRepo.FindByID(c.Request().Context(), userID)
+	if err != nil {
+		logger.Errorf("Error querying user: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	session, refreshToken, err := h.sessionRepo.Start(c.Request().Context(), user.ID, sessionMeta(c))
+	if err != nil {
+		logger.Errorf("Error starting session: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: "internal server error"})
+	}
+
+	return h.tokenResponse(c, user, session.ID, refreshToken)
+}
+
+func (h *httpService) mfaStatusHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	var status model.MFAStatus
+
+	totp, err := h.mfaRepo.FindTOTP(c.Request().Context(), session.ID)
+	switch {
+	case errors.Is(err, model.ErrMFANotEnrolled):
+		return c.JSON(http.StatusOK, response{Success: true, Data: status})
+	case err != nil:
+		logger.Errorf("Error getting totp: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	status.Enabled = totp.Enabled()
+	status.Pending = !totp.Enabled()
+	status.ConfirmedAt = totp.ConfirmedAt
+
+	status.RecoveryCodesLeft, err = h.mfaRepo.CountRecoveryCodes(c.Request().Context(), session.ID)
+	if err != nil {
+		logger.Errorf("Error counting recovery codes: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true, Data: status})
+}
+
+func (h *httpService) enrollTOTPHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	user, err := h.userRepo.FindByID(c.Request().Context(), session.ID)
+	if err != nil {
+		logger.Errorf("Error querying user: %v", err)
+		return c.JSON(http.StatusNotFound, response{Message: "user not found"})
+	}
+
+	secret, err := utils.NewTOTPSecret()
+	if err != nil {
+		logger.Errorf("Error generating secret: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: "internal server error"})
+	}
+
+	err = h.mfaRepo.Enroll(c.Request().Context(), session.ID, secret)
+	switch {
+	case errors.Is(err, model.ErrMFAAlreadyActive):
+		return c.JSON(http.StatusConflict, response{Message: err.Error()})
+	case err != nil:
+		logger.Errorf("Error enrolling totp: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: "internal server error"})
+	}
+
+	issuer := os.Getenv("TOTP_ISSUER")
+	if issuer == "" {
+		issuer = defaultTOTPIssuer
+	}
+
+	return c.JSON(http.StatusCreated, response{
+		Success: true,
+		Data: model.TOTPEnrollment{
+			Secret:          secret,
+			ProvisioningURI: utils.TOTPProvisioningURI(issuer, user.Email, secret),
+		},
+	})
+}
+
+func (h *httpService) confirmTOTPHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	var input model.MFACodeRequest
+	if err := c.Bind(&input); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	totp, err := h.mfaRepo.FindTOTP(c.Request().Context(), session.ID)
+	switch {
+	case errors.Is(err, model.ErrMFANotEnrolled):
+		return c.JSON(http.StatusNotFound, response{Message: err.Error()})
+	case err != nil:
+		logger.Errorf("Error getting totp: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	if totp.Enabled() {
+		return c.JSON(http.StatusConflict, response{Message: model.ErrMFAAlreadyActive.Error()})
+	}
+
+	step, ok := utils.ValidateTOTP(totp.Secret, input.Code, time.Now())
+	if !ok {
+		return c.JSON(http.StatusBadRequest, response{Message: model.ErrInvalidMFACode.Error()})
+	}
+
+	if err := h.mfaRepo.Confirm(c.Request().Context(), session.ID, step); err != nil {
+		logger.Errorf("Error confirming totp: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: "internal server error"})
+	}
+
+	codes, err := h.issueRecoveryCodes(c.Request().Context(), session.ID)
+	if err != nil {
+		logger.Errorf("Error issuing recovery codes: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: "internal server error"})
+	}
+
+	return c.JSON(http.StatusOK, response{
+		Success: true,
+		Message: "store these recovery codes now, they will not be shown again",
+		Data:    map[string]interface{}{"recovery_codes": codes},
+	})
+}
+
+func (h *httpService) regenerateRecoveryCodeHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	var input model.MFACodeRequest
+	if err := c.Bind(&input); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	if err := h.verifyMFACode(c.Request().Context(), session.ID, input); err != nil {
+		return mfaCodeRejected(c, err)
+	}
+
+	codes, err := h.issueRecoveryCodes(c.Request().Context(), session.ID)
+	if err != nil {
+		logger.Errorf("Error issuing recovery codes: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: "internal server error"})
+	}
+
+	return c.JSON(http.StatusOK, response{
+		Success: true,
+		Message: "store these recovery codes now, they will not be shown again",
+		Data:    map[string]interface{}{"recovery_codes": codes},
+	})
+}
+
+func (h *httpService) disableTOTPHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	var input model.MFACodeRequest
+	if err := c.Bind(&input); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	if err := h.verifyMFACode(c.Request().Context(), session.ID, input); err != nil {
+		return mfaCodeRejected(c, err)
+	}
+
+	if err := h.mfaRepo.Disable(c.Request().Context(), session.ID); err != nil {
+		logger.Errorf("Error disabling totp: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: "internal server error"})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true})
+}
+
+// verifyMFACode accepts either a current TOTP code or an unused recovery code.
+// Wrong codes count towards the lockout, whichever kind was entered.
+func (h *httpService) verifyMFACode(ctx context.Context, userID string, input model.MFACodeRequest) error {
+	totp, err := h.mfaRepo.FindTOTP(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if !totp.Enabled() {
+		return model.ErrMFANotEnrolled
+	}
+
+	now := time.Now()
+
+	if totp.Locked(now) {
+		return model.ErrMFALocked
+	}
+
+	if input.RecoveryCode != "" {
+		err = h.mfaRepo.UseRecoveryCode(ctx, userID, input.RecoveryCode)
+	} else if step, ok := utils.ValidateTOTP(totp.Secret, input.Code, now); ok {
+		err = h.mfaRepo.MarkStepUsed(ctx, userID, step)
+	} else {
+		err = model.ErrInvalidMFACode
+	}
+
+	switch {
+	case errors.Is(err, model.ErrInvalidMFACode):
+		if err := h.mfaRepo.RecordFailure(ctx, userID, now); err != nil {
+			return err
+		}
+
+		return model.ErrInvalidMFACode
+	case err != nil:
+		return err
+	case totp.FailedAttempts > 0:
+		return h.mfaRepo.ResetFailures(ctx, userID)
+	}
+
+	return nil
+}
+
+// mfaCodeRejected answers a failed verifyMFACode.
+func mfaCodeRejected(c echo.Context, err error) error {
+	if errors.Is(err, model.ErrMFALocked) {
+		return c.JSON(http.StatusTooManyRequests, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusUnauthorized, response{Message: model.ErrInvalidMFACode.Error()})
+}
+
+func (h *httpService) issueRecoveryCodes(ctx context.Context, userID string) ([]string, error) {
+	codes := make([]string, 0, model.RecoveryCodeCount)
+
+	for i := 0; i < model.RecoveryCodeCount; i++ {
+		code, err := utils.NewRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+
+		codes = append(codes, code)
+	}
+
+	if err := h.mfaRepo.ReplaceRecoveryCodes(ctx, userID, codes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
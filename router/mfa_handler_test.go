@@ -0,0 +1,127 @@
+package router
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-playground/validator"
+	"github.com/labstack/echo/v4"
+	"github.com/notblessy/anggar-service/model"
+	"github.com/notblessy/anggar-service/repository"
+	"github.com/notblessy/anggar-service/utils"
+)
+
+// testSecret is the RFC 6238 test key, "12345678901234567890" in base32.
+const testSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// totpCode computes the code an authenticator app would show at now.
+func totpCode(t *testing.T, secret string, now time.Time) string {
+	t.Helper()
+
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(now.Unix()/30))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%06d", value%1000000)
+}
+
+func TestMFAChallengeLockout(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	db := newTestDB(t, &model.User{}, &model.UserTOTP{}, &model.RecoveryCode{}, &model.Session{}, &model.RefreshToken{})
+
+	now := time.Now()
+	db.Create(&model.User{ID: "u1", Name: "Ana", Email: "ana@example.com", Role: model.RoleUser})
+	db.Create(&model.UserTOTP{UserID: "u1", Secret: testSecret, ConfirmedAt: &now})
+
+	h := &httpService{
+		userRepo:    repository.NewUserRepository(db),
+		sessionRepo: repository.NewSessionRepository(db),
+		mfaRepo:     repository.NewMFARepository(db),
+	}
+
+	e := echo.New()
+	e.Validator = &utils.Ghost{Validator: validator.New()}
+	e.POST("/mfa/verify", h.verifyMFAChallengeHandler)
+
+	challenge, err := signMFAChallenge("u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wrong := fmt.Sprintf(`{"challenge_token":"%s","code":"000000"}`, challenge)
+	wrongRecovery := fmt.Sprintf(`{"challenge_token":"%s","recovery_code":"nope-nope"}`, challenge)
+
+	verify := func() int {
+		// each step is accepted once, so forget the last one between attempts
+		db.Model(&model.UserTOTP{}).Where("user_id = ?", "u1").Update("last_used_step", 0)
+
+		return post(t, e, "/mfa/verify", fmt.Sprintf(`{"challenge_token":"%s","code":"%s"}`, challenge, totpCode(t, testSecret, time.Now())))
+	}
+
+	locked := func() bool {
+		var totp model.UserTOTP
+		db.Where("user_id = ?", "u1").First(&totp)
+
+		return totp.Locked(time.Now())
+	}
+
+	// a success clears the failures before it
+	for i := 0; i < model.MFAMaxFailures-1; i++ {
+		if code := post(t, e, "/mfa/verify", wrong); code != http.StatusUnauthorized {
+			t.Fatalf("wrong code %d: got %d", i+1, code)
+		}
+	}
+
+	if code := verify(); code != http.StatusOK {
+		t.Fatalf("right code: got %d", code)
+	}
+
+	// recovery codes count towards the same limit
+	for i := 0; i < model.MFAMaxFailures; i++ {
+		body := wrong
+		if i%2 == 1 {
+			body = wrongRecovery
+		}
+
+		if code := post(t, e, "/mfa/verify", body); code != http.StatusUnauthorized {
+			t.Fatalf("wrong code %d: got %d", i+1, code)
+		}
+	}
+
+	if !locked() {
+		t.Fatal("expected the second factor to be locked")
+	}
+
+	if code := verify(); code != http.StatusTooManyRequests {
+		t.Fatalf("right code while locked: got %d", code)
+	}
+
+	// the lock runs out on its own
+	db.Model(&model.UserTOTP{}).Where("user_id = ?", "u1").Update("locked_until", time.Now().Add(-time.Second))
+
+	if code := verify(); code != http.StatusOK {
+		t.Fatalf("right code after the lock: got %d", code)
+	}
+
+	if code := post(t, e, "/mfa/verify", wrong); code != http.StatusUnauthorized || locked() {
+		t.Fatalf("expected the count to start over, got %d", code)
+	}
+}
@@ -0,0 +1,209 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/notblessy/anggar-service/model"
+	"github.com/notblessy/anggar-service/utils"
+	"github.com/oklog/ulid/v2"
+	"github.com/sirupsen/logrus"
+)
+
+// netWorthHandler charts net worth from the daily balance snapshots,
+// covering the last NetWorthDefaultDays days unless dates are given.
+func (h *httpService) netWorthHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	var query model.NetWorthQueryInput
+	if err := c.Bind(&query); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	preference, err := h.preferenceRepo.Find(c.Request().Context(), session.ID)
+	if err != nil {
+		logger.Errorf("Error getting preference: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	query.UserID = session.ID
+
+	if query.EndDate == "" {
+		query.EndDate = preference.Now().Format(time.DateOnly)
+	}
+
+	end, err := time.Parse(time.DateOnly, query.EndDate)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: "invalid end_date"})
+	}
+
+	if query.StartDate == "" {
+		query.StartDate = end.AddDate(0, 0, -model.NetWorthDefaultDays).Format(time.DateOnly)
+	}
+
+	if _, err := time.Parse(time.DateOnly, query.StartDate); err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: "invalid start_date"})
+	}
+
+	series, err := h.netWorthRepo.Series(c.Request().Context(), query)
+	if err != nil {
+		logger.Errorf("Error getting net worth: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true, Data: series})
+}
+
+func (h *httpService) findAllAssetHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	assets, err := h.netWorthRepo.FindAllAssets(c.Request().Context(), session.ID)
+	if err != nil {
+		logger.Errorf("Error getting assets: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true, Data: assets})
+}
+
+func (h *httpService) createAssetHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	var input model.AssetInput
+	if err := c.Bind(&input); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	if input.Value.IsNegative() {
+		return c.JSON(http.StatusBadRequest, response{Message: model.ErrInvalidAssetValue.Error()})
+	}
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	asset := model.Asset{
+		ID:     ulid.Make().String(),
+		UserID: session.ID,
+		Name:   input.Name,
+		Kind:   input.Kind,
+		Value:  input.Value,
+	}
+
+	if err := h.netWorthRepo.CreateAsset(c.Request().Context(), &asset); err != nil {
+		logger.Errorf("Error creating asset: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	h.refreshSnapshot(c.Request().Context(), session.ID)
+
+	return c.JSON(http.StatusOK, response{Success: true, Data: asset})
+}
+
+// updateAssetHandler revalues an asset. Earlier days keep the value they
+// were snapshotted with.
+func (h *httpService) updateAssetHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	var input model.AssetInput
+	if err := c.Bind(&input); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	if input.Value.IsNegative() {
+		return c.JSON(http.StatusBadRequest, response{Message: model.ErrInvalidAssetValue.Error()})
+	}
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	asset, err := h.netWorthRepo.FindAssetByID(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		logger.Errorf("Error finding asset: %v", err)
+		return c.JSON(http.StatusNotFound, response{Message: err.Error()})
+	}
+
+	if err := canAccessAsset(session, asset); err != nil {
+		return forbidden(c)
+	}
+
+	asset.Name = input.Name
+	asset.Kind = input.Kind
+	asset.Value = input.Value
+
+	if err := h.netWorthRepo.UpdateAsset(c.Request().Context(), asset); err != nil {
+		logger.Errorf("Error updating asset: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	h.refreshSnapshot(c.Request().Context(), session.ID)
+
+	return c.JSON(http.StatusOK, response{Success: true, Data: asset})
+}
+
+func (h *httpService) deleteAssetHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	asset, err := h.netWorthRepo.FindAssetByID(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		logger.Errorf("Error finding asset: %v", err)
+		return c.JSON(http.StatusNotFound, response{Message: err.Error()})
+	}
+
+	if err := canAccessAsset(session, asset); err != nil {
+		return forbidden(c)
+	}
+
+	if err := h.netWorthRepo.DeleteAsset(c.Request().Context(), asset.ID); err != nil {
+		logger.Errorf("Error deleting asset: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	h.refreshSnapshot(c.Request().Context(), session.ID)
+
+	return c.JSON(http.StatusOK, response{Success: true})
+}
+
+// refreshSnapshot retakes today's snapshot so an asset change shows up in
+// the series right away. The scheduled job catches up if it fails.
+func (h *httpService) refreshSnapshot(ctx context.Context, userID string) {
+	if err := h.netWorthRepo.Snapshot(ctx, userID, time.Now()); err != nil {
+		logrus.WithField("user_id", userID).Errorf("Error refreshing balance snapshot: %v", err)
+	}
+}
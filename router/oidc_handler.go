@@ -0,0 +1,135 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+
+	"github.com/labstack/echo/v4"
+	"github.com/notblessy/anggar-service/model"
+	"github.com/notblessy/anggar-service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+func (h *httpService) findOIDCProviderHandler(c echo.Context) error {
+	options := make([]model.OIDCProviderOption, 0, len(h.oidcProviders))
+
+	for _, provider := range h.oidcProviders {
+		options = append(options, model.OIDCProviderOption{
+			Name:        provider.Name(),
+			DisplayName: provider.DisplayName(),
+		})
+	}
+
+	sort.Slice(options, func(i, j int) bool { return options[i].Name < options[j].Name })
+
+	return c.JSON(http.StatusOK, response{Success: true, Data: options})
+}
+
+func (h *httpService) oidcAuthorizeHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	provider, ok := h.oidcProviders[c.Param("provider")]
+	if !ok {
+		return c.JSON(http.StatusNotFound, response{Message: model.ErrUnknownProvider.Error()})
+	}
+
+	redirectURI := c.QueryParam("redirect_uri")
+	if redirectURI == "" {
+		redirectURI = c.Request().Header.Get("Origin")
+	}
+
+	url, err := provider.AuthCodeURL(c.Request().Context(), c.QueryParam("state"), redirectURI)
+	if err != nil {
+		logger.Errorf("Error building authorize url: %v", err)
+		return c.JSON(http.StatusBadGateway, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true, Data: map[string]string{"url": url}})
+}
+
+func (h *httpService) loginWithOIDCHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	provider, ok := h.oidcProviders[c.Param("provider")]
+	if !ok {
+		return c.JSON(http.StatusNotFound, response{Message: model.ErrUnknownProvider.Error()})
+	}
+
+	var input model.OIDCAuthRequest
+	if err := c.Bind(&input); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	if input.RedirectURI == "" {
+		input.RedirectURI = c.Request().Header.Get("Origin")
+	}
+
+	info, err := provider.Exchange(c.Request().Context(), input.Code, input.RedirectURI)
+	if err != nil {
+		logger.Errorf("Error verifying token: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	user, err := h.userRepo.AuthenticateExternal(c.Request().Context(), info)
+	switch {
+	case errors.Is(err, model.ErrEmailNotConfirmed):
+		return c.JSON(http.StatusConflict, response{Message: err.Error()})
+	case err != nil:
+		logger.Errorf("Error authenticating: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	return h.completeLogin(c, user)
+}
+
+func (h *httpService) linkOIDCHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	provider, ok := h.oidcProviders[c.Param("provider")]
+	if !ok {
+		return c.JSON(http.StatusNotFound, response{Message: model.ErrUnknownProvider.Error()})
+	}
+
+	var input model.OIDCAuthRequest
+	if err := c.Bind(&input); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	if input.RedirectURI == "" {
+		input.RedirectURI = c.Request().Header.Get("Origin")
+	}
+
+	info, err := provider.Exchange(c.Request().Context(), input.Code, input.RedirectURI)
+	if err != nil {
+		logger.Errorf("Error verifying token: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	err = h.userRepo.LinkExternal(c.Request().Context(), session.ID, info)
+	switch {
+	case errors.Is(err, model.ErrIdentityLinked):
+		return c.JSON(http.StatusConflict, response{Message: err.Error()})
+	case err != nil:
+		logger.Errorf("Error linking identity: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: "internal server error"})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true})
+}
@@ -0,0 +1,300 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/notblessy/anggar-service/model"
+	"github.com/notblessy/anggar-service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+func (h *httpService) registerHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	var input model.RegisterRequest
+	if err := c.Bind(&input); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	user, err := h.userRepo.Register(c.Request().Context(), input)
+	switch {
+	case errors.Is(err, model.ErrEmailTaken):
+		return c.JSON(http.StatusConflict, response{Message: err.Error()})
+	case errors.Is(err, model.ErrEmailNotAllowed):
+		return c.JSON(http.StatusForbidden, response{Message: err.Error()})
+	case err != nil:
+		logger.Errorf("Error registering user: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: "internal server error"})
+	}
+
+	if err := h.sendUserTokenMail(c.Request().Context(), user, model.UserTokenVerifyEmail); err != nil {
+		logger.Errorf("Error sending verification email: %v", err)
+	}
+
+	return c.JSON(http.StatusCreated, response{Success: true, Data: user})
+}
+
+func (h *httpService) loginWithPasswordHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	var input model.LoginRequest
+	if err := c.Bind(&input); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	user, err := h.userRepo.Login(c.Request().Context(), input.Email, input.Password)
+	switch {
+	case errors.Is(err, model.ErrInvalidCredentials):
+		return c.JSON(http.StatusUnauthorized, response{Message: err.Error()})
+	case errors.Is(err, model.ErrEmailNotVerified):
+		return c.JSON(http.StatusForbidden, response{Message: err.Error()})
+	case err != nil:
+		logger.Errorf("Error logging in: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: "internal server error"})
+	}
+
+	return h.completeLogin(c, user)
+}
+
+func (h *httpService) verifyEmailHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	var input model.TokenRequest
+	if err := c.Bind(&input); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	user, err := h.userRepo.ConsumeToken(c.Request().Context(), model.UserTokenVerifyEmail, input.Token)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: model.ErrInvalidToken.Error()})
+	}
+
+	if err := h.userRepo.MarkEmailVerified(c.Request().Context(), user.ID); err != nil {
+		logger.Errorf("Error verifying email: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: "internal server error"})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true})
+}
+
+// resendVerificationHandler always succeeds so it cannot be used to probe for accounts.
+func (h *httpService) resendVerificationHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	var input model.EmailRequest
+	if err := c.Bind(&input); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	user, err := h.userRepo.FindByEmail(c.Request().Context(), strings.ToLower(input.Email))
+	if err == nil && user.EmailVerifiedAt == nil {
+		if err := h.sendUserTokenMail(c.Request().Context(), user, model.UserTokenVerifyEmail); err != nil {
+			logger.Errorf("Error sending verification email: %v", err)
+		}
+	}
+
+	return c.JSON(http.StatusAccepted, response{Success: true})
+}
+
+// forgotPasswordHandler always succeeds so it cannot be used to probe for accounts.
+func (h *httpService) forgotPasswordHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	var input model.EmailRequest
+	if err := c.Bind(&input); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	user, err := h.userRepo.FindByEmail(c.Request().Context(), strings.ToLower(input.Email))
+	if err == nil {
+		if err := h.sendUserTokenMail(c.Request().Context(), user, model.UserTokenResetPassword); err != nil {
+			logger.Errorf("Error sending reset email: %v", err)
+		}
+	}
+
+	return c.JSON(http.StatusAccepted, response{Success: true})
+}
+
+func (h *httpService) resetPasswordHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	var input model.ResetPasswordRequest
+	if err := c.Bind(&input); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	user, err := h.userRepo.ConsumeToken(c.Request().Context(), model.UserTokenResetPassword, input.Token)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: model.ErrInvalidToken.Error()})
+	}
+
+	if err := h.userRepo.SetPassword(c.Request().Context(), user.ID, input.Password); err != nil {
+		logger.Errorf("Error setting password: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: "internal server error"})
+	}
+
+	// The reset link proves ownership of the address.
+	if err := h.userRepo.MarkEmailVerified(c.Request().Context(), user.ID); err != nil {
+		logger.Errorf("Error verifying email: %v", err)
+	}
+
+	if err := h.sessionRepo.RevokeAll(c.Request().Context(), user.ID); err != nil {
+		logger.Errorf("Error revoking sessions: %v", err)
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true})
+}
+
+func (h *httpService) setPasswordHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	var input model.SetPasswordRequest
+	if err := c.Bind(&input); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	identities, err := h.userRepo.FindIdentities(c.Request().Context(), session.ID)
+	if err != nil {
+		logger.Errorf("Error getting identities: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: "internal server error"})
+	}
+
+	// Changing an existing password needs the current one; adding the first one does not.
+	for _, identity := range identities {
+		if identity.Provider != model.IdentityPassword {
+			continue
+		}
+
+		if err := h.userRepo.CheckPassword(c.Request().Context(), session.ID, input.CurrentPassword); err != nil {
+			return c.JSON(http.StatusUnauthorized, response{Message: model.ErrInvalidCredentials.Error()})
+		}
+	}
+
+	if err := h.userRepo.SetPassword(c.Request().Context(), session.ID, input.Password); err != nil {
+		logger.Errorf("Error setting password: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: "internal server error"})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true})
+}
+
+func (h *httpService) findIdentityHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	identities, err := h.userRepo.FindIdentities(c.Request().Context(), session.ID)
+	if err != nil {
+		logger.Errorf("Error getting identities: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: "internal server error"})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true, Data: identities})
+}
+
+func (h *httpService) linkGoogleHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	var authRequest model.AuthRequest
+	if err := c.Bind(&authRequest); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	err = h.userRepo.LinkGoogle(c.Request().Context(), session.ID, authRequest.Code, c.Request().Header.Get("Origin"))
+	switch {
+	case errors.Is(err, model.ErrIdentityLinked):
+		return c.JSON(http.StatusConflict, response{Message: err.Error()})
+	case err != nil:
+		logger.Errorf("Error linking google: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true})
+}
+
+func (h *httpService) sendUserTokenMail(ctx context.Context, user model.User, purpose string) error {
+	var (
+		ttl     = model.VerifyEmailTTL
+		path    = "/verify-email"
+		subject = "Verify your email"
+		intro   = "Confirm your email address by opening the link below."
+	)
+
+	if purpose == model.UserTokenResetPassword {
+		ttl = model.ResetPasswordTTL
+		path = "/reset-password"
+		subject = "Reset your password"
+		intro = "Someone asked to reset your password. Open the link below to choose a new one. Ignore this email if it wasn't you."
+	}
+
+	token, err := h.userRepo.IssueToken(ctx, user.ID, purpose, ttl)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s%s?token=%s", strings.TrimRight(os.Getenv("APP_URL"), "/"), path, token)
+
+	return h.mailer.Send(ctx, model.Mail{
+		To:      user.Email,
+		Subject: subject,
+		Body:    fmt.Sprintf("Hi %s,\n\n%s\n\n%s\n\nThe link expires in %s.\n", user.Name, intro, link, ttl),
+	})
+}
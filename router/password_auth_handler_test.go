@@ -0,0 +1,253 @@
+package router
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/go-playground/validator"
+	"github.com/labstack/echo/v4"
+	"github.com/notblessy/anggar-service/model"
+	"github.com/notblessy/anggar-service/repository"
+	"github.com/notblessy/anggar-service/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// smtpStandIn is a local SMTP server that keeps every message it receives.
+type smtpStandIn struct {
+	listener net.Listener
+
+	mu       sync.Mutex
+	messages []string
+}
+
+func newSMTPStandIn(t *testing.T) *smtpStandIn {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &smtpStandIn{listener: listener}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *smtpStandIn) serve(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 stand-in ready")
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		command := strings.ToUpper(strings.TrimSpace(line))
+
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 stand-in")
+		case command == "DATA":
+			reply("354 end with <CRLF>.<CRLF>")
+
+			var message strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+
+				if line == ".\r\n" {
+					break
+				}
+
+				message.WriteString(line)
+			}
+
+			s.mu.Lock()
+			s.messages = append(s.messages, message.String())
+			s.mu.Unlock()
+
+			reply("250 queued")
+		case command == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func (s *smtpStandIn) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.messages)
+}
+
+func (s *smtpStandIn) last(t *testing.T) string {
+	t.Helper()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.messages) == 0 {
+		t.Fatal("no mail was sent")
+	}
+
+	return s.messages[len(s.messages)-1]
+}
+
+var mailToken = regexp.MustCompile(`token=([A-Za-z0-9_-]+)`)
+
+func tokenFrom(t *testing.T, message string) string {
+	t.Helper()
+
+	match := mailToken.FindStringSubmatch(message)
+	if match == nil {
+		t.Fatalf("no token in mail:\n%s", message)
+	}
+
+	return match[1]
+}
+
+func newTestDB(t *testing.T, models ...interface{}) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every connection to :memory: is a database of its own
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func post(t *testing.T, e *echo.Echo, path, body string) int {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+
+	e.ServeHTTP(rec, req)
+
+	return rec.Code
+}
+
+func TestRegisterAndResetPasswordByMail(t *testing.T) {
+	db := newTestDB(t, &model.User{}, &model.UserIdentity{}, &model.UserToken{}, &model.AllowedEmail{}, &model.Session{})
+	mail := newSMTPStandIn(t)
+
+	h := &httpService{
+		userRepo:    repository.NewUserRepository(db),
+		sessionRepo: repository.NewSessionRepository(db),
+		mailer:      repository.NewSMTPMailer(mail.listener.Addr().String(), "", "", "noreply@anggar.test"),
+	}
+
+	e := echo.New()
+	e.Validator = &utils.Ghost{Validator: validator.New()}
+	e.POST("/register", h.registerHandler)
+	e.POST("/verify-email", h.verifyEmailHandler)
+	e.POST("/password/forgot", h.forgotPasswordHandler)
+	e.POST("/password/reset", h.resetPasswordHandler)
+
+	ctx := context.Background()
+
+	if code := post(t, e, "/register", `{"name":"Ana","email":"Ana@Example.com","password":"first-password"}`); code != http.StatusCreated {
+		t.Fatalf("register: got %d", code)
+	}
+
+	verification := mail.last(t)
+	if !strings.Contains(verification, "To: ana@example.com") || !strings.Contains(verification, "Subject: Verify your email") {
+		t.Fatalf("unexpected verification mail:\n%s", verification)
+	}
+
+	if _, err := h.userRepo.Login(ctx, "ana@example.com", "first-password"); !errors.Is(err, model.ErrEmailNotVerified) {
+		t.Fatalf("login before verifying: got %v", err)
+	}
+
+	token := tokenFrom(t, verification)
+
+	if code := post(t, e, "/verify-email", `{"token":"`+token+`"}`); code != http.StatusOK {
+		t.Fatalf("verify: got %d", code)
+	}
+
+	if code := post(t, e, "/verify-email", `{"token":"`+token+`"}`); code != http.StatusBadRequest {
+		t.Fatalf("verify with a used token: got %d", code)
+	}
+
+	if _, err := h.userRepo.Login(ctx, "ana@example.com", "first-password"); err != nil {
+		t.Fatalf("login after verifying: %v", err)
+	}
+
+	if code := post(t, e, "/password/forgot", `{"email":"ana@example.com"}`); code != http.StatusAccepted {
+		t.Fatalf("forgot: got %d", code)
+	}
+
+	reset := mail.last(t)
+	if !strings.Contains(reset, "Subject: Reset your password") {
+		t.Fatalf("unexpected reset mail:\n%s", reset)
+	}
+
+	if code := post(t, e, "/password/reset", `{"token":"`+tokenFrom(t, reset)+`","password":"second-password"}`); code != http.StatusOK {
+		t.Fatalf("reset: got %d", code)
+	}
+
+	if _, err := h.userRepo.Login(ctx, "ana@example.com", "first-password"); !errors.Is(err, model.ErrInvalidCredentials) {
+		t.Fatalf("login with the old password: got %v", err)
+	}
+
+	if _, err := h.userRepo.Login(ctx, "ana@example.com", "second-password"); err != nil {
+		t.Fatalf("login with the new password: %v", err)
+	}
+
+	// unknown addresses get the same answer and no mail
+	sent := mail.count()
+	if code := post(t, e, "/password/forgot", `{"email":"nobody@example.com"}`); code != http.StatusAccepted {
+		t.Fatalf("forgot for an unknown address: got %d", code)
+	}
+
+	if mail.count() != sent {
+		t.Fatal("mail sent to an unknown address")
+	}
+}
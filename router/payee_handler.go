@@ -0,0 +1,273 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/notblessy/anggar-service/model"
+	"github.com/notblessy/anggar-service/utils"
+	"github.com/oklog/ulid/v2"
+	"github.com/sirupsen/logrus"
+)
+
+func (h *httpService) findAllPayeeHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	var query model.PayeeQueryInput
+	if err := c.Bind(&query); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	query.UserID = session.ID
+
+	payees, err := h.payeeRepo.FindAll(c.Request().Context(), query)
+	if err != nil {
+		logger.Errorf("Error getting payees: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true, Data: payees})
+}
+
+func (h *httpService) findPayeeByIDHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	payee, err := h.payeeRepo.FindByID(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		logger.Errorf("Error finding payee: %v", err)
+		return c.JSON(http.StatusNotFound, response{Message: err.Error()})
+	}
+
+	if err := canAccessPayee(session, payee); err != nil {
+		return forbidden(c)
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true, Data: payee})
+}
+
+func (h *httpService) createPayeeHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	var input model.PayeeInput
+	if err := c.Bind(&input); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	payee := model.Payee{ID: ulid.Make().String(), UserID: session.ID}
+
+	if err := h.applyPayeeInput(c.Request().Context(), &payee, input); err != nil {
+		return h.payeeError(c, err)
+	}
+
+	if err := h.payeeRepo.Create(c.Request().Context(), &payee); err != nil {
+		return h.payeeError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true, Data: payee})
+}
+
+// updatePayeeHandler saves the payee; the aliases sent replace the old ones.
+func (h *httpService) updatePayeeHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	var input model.PayeeInput
+	if err := c.Bind(&input); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	payee, err := h.payeeRepo.FindByID(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		logger.Errorf("Error finding payee: %v", err)
+		return c.JSON(http.StatusNotFound, response{Message: err.Error()})
+	}
+
+	if err := canAccessPayee(session, payee); err != nil {
+		return forbidden(c)
+	}
+
+	if err := h.applyPayeeInput(c.Request().Context(), &payee, input); err != nil {
+		return h.payeeError(c, err)
+	}
+
+	if err := h.payeeRepo.Update(c.Request().Context(), payee); err != nil {
+		return h.payeeError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true, Data: payee})
+}
+
+func (h *httpService) deletePayeeHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	payee, err := h.payeeRepo.FindByID(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		logger.Errorf("Error finding payee: %v", err)
+		return c.JSON(http.StatusNotFound, response{Message: err.Error()})
+	}
+
+	if err := canAccessPayee(session, payee); err != nil {
+		return forbidden(c)
+	}
+
+	if err := h.payeeRepo.Delete(c.Request().Context(), payee.ID); err != nil {
+		logger.Errorf("Error deleting payee: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true})
+}
+
+// payeeReportHandler lists the payees the user spent most on over a period.
+func (h *httpService) payeeReportHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	var query model.PayeeReportQueryInput
+	if err := c.Bind(&query); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	preference, err := h.preferenceRepo.Find(c.Request().Context(), session.ID)
+	if err != nil {
+		logger.Errorf("Error getting preference: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	query.UserID = session.ID
+	query.Timezone = preference.Timezone
+
+	if query.StartDate == "" || query.EndDate == "" {
+		query.StartDate, query.EndDate = preference.PeriodRange(query.Period, preference.Now())
+	}
+
+	reports, err := h.payeeRepo.Report(c.Request().Context(), query)
+	if err != nil {
+		logger.Errorf("Error getting payee report: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true, Data: reports})
+}
+
+// applyPayeeInput copies the input onto the payee, normalizing its aliases.
+func (h *httpService) applyPayeeInput(ctx context.Context, payee *model.Payee, input model.PayeeInput) error {
+	if model.PayeeKey(input.Name) == "" {
+		return model.ErrInvalidPayeeName
+	}
+
+	if err := h.validCategory(ctx, payee.UserID, input.DefaultCategory); err != nil {
+		return err
+	}
+
+	payee.Name = input.Name
+	payee.DefaultCategory = input.DefaultCategory
+	payee.Aliases = nil
+
+	for _, pattern := range model.PayeeAliases(input.Name, input.Aliases) {
+		payee.Aliases = append(payee.Aliases, model.PayeeAlias{
+			ID:      ulid.Make().String(),
+			PayeeID: payee.ID,
+			UserID:  payee.UserID,
+			Pattern: pattern,
+		})
+	}
+
+	return nil
+}
+
+// linkPayee fills in the payee of a new or edited transaction and returns it.
+// A payee the client picked must be the user's own, otherwise it is matched
+// on the description. No match gives a zero payee.
+func (h *httpService) linkPayee(ctx context.Context, userID string, transaction *model.Transaction) (model.Payee, error) {
+	transaction.Payee = nil
+
+	if transaction.PayeeID != nil && *transaction.PayeeID != "" {
+		payee, err := h.payeeRepo.FindByID(ctx, *transaction.PayeeID)
+		if err != nil || payee.UserID != userID {
+			return model.Payee{}, model.ErrForbidden
+		}
+
+		return payee, nil
+	}
+
+	transaction.PayeeID = nil
+
+	if transaction.Description == "" {
+		return model.Payee{}, nil
+	}
+
+	payees, err := h.payeeRepo.FindAll(ctx, model.PayeeQueryInput{UserID: userID})
+	if err != nil {
+		return model.Payee{}, err
+	}
+
+	payee, ok := model.MatchPayee(payees, transaction.Description)
+	if ok {
+		transaction.PayeeID = &payee.ID
+	}
+
+	return payee, nil
+}
+
+func (h *httpService) payeeError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, model.ErrInvalidPayeeName),
+		errors.Is(err, model.ErrUnknownCategory):
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	case errors.Is(err, model.ErrPayeeExists),
+		errors.Is(err, model.ErrPayeeAliasTaken):
+		return c.JSON(http.StatusConflict, response{Message: err.Error()})
+	}
+
+	logrus.WithField("ctx", utils.Dump(c.Request().Context())).Errorf("Error saving payee: %v", err)
+	return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+}
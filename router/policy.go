@@ -0,0 +1,151 @@
+package router
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/notblessy/anggar-service/model"
+)
+
+// canViewTransaction allows the creator and every share participant.
+func canViewTransaction(session jwtClaims, transaction model.Transaction) error {
+	if transaction.IsParticipant(session.ID) {
+		return nil
+	}
+
+	return model.ErrForbidden
+}
+
+// canModifyTransaction allows only the creator of the transaction.
+func canModifyTransaction(session jwtClaims, transaction model.Transaction) error {
+	if transaction.UserID == session.ID {
+		return nil
+	}
+
+	return model.ErrForbidden
+}
+
+// canModifyShare allows only the creator of the parent transaction.
+func canModifyShare(session jwtClaims, share model.TransactionShare) error {
+	if share.Transaction.UserID == session.ID {
+		return nil
+	}
+
+	return model.ErrForbidden
+}
+
+// canAccessWallet allows only the wallet owner.
+func canAccessWallet(session jwtClaims, wallet model.Wallet) error {
+	if wallet.UserID == session.ID {
+		return nil
+	}
+
+	return model.ErrForbidden
+}
+
+// canAccessScope allows only the scope owner.
+func canAccessScope(session jwtClaims, scope model.Scope) error {
+	if scope.UserID == session.ID {
+		return nil
+	}
+
+	return model.ErrForbidden
+}
+
+// canAccessRecurring allows only the owner of the recurring rule.
+func canAccessRecurring(session jwtClaims, recurring model.RecurringTransaction) error {
+	if recurring.UserID == session.ID {
+		return nil
+	}
+
+	return model.ErrForbidden
+}
+
+// canAccessTag allows only the owner of the tag.
+func canAccessTag(session jwtClaims, tag model.Tag) error {
+	if tag.UserID == session.ID {
+		return nil
+	}
+
+	return model.ErrForbidden
+}
+
+// canAccessPayee allows only the owner of the payee.
+func canAccessPayee(session jwtClaims, payee model.Payee) error {
+	if payee.UserID == session.ID {
+		return nil
+	}
+
+	return model.ErrForbidden
+}
+
+// canAccessGoal allows only the owner of the savings goal.
+func canAccessGoal(session jwtClaims, goal model.Goal) error {
+	if goal.UserID == session.ID {
+		return nil
+	}
+
+	return model.ErrForbidden
+}
+
+// canAccessAsset allows only the owner of the asset or liability.
+func canAccessAsset(session jwtClaims, asset model.Asset) error {
+	if asset.UserID == session.ID {
+		return nil
+	}
+
+	return model.ErrForbidden
+}
+
+// canAccessCategory allows only the owner of the category. Defaults are
+// copied to each user, so they have an owner too.
+func canAccessCategory(session jwtClaims, category model.Category) error {
+	if category.UserID == session.ID {
+		return nil
+	}
+
+	return model.ErrForbidden
+}
+
+// canAccessImport allows only the user who uploaded the statement.
+func canAccessImport(session jwtClaims, batch model.ImportBatch) error {
+	if batch.UserID == session.ID {
+		return nil
+	}
+
+	return model.ErrForbidden
+}
+
+// canAccessImportMapping allows only the owner of the column mapping.
+func canAccessImportMapping(session jwtClaims, mapping model.ImportMapping) error {
+	if mapping.UserID == session.ID {
+		return nil
+	}
+
+	return model.ErrForbidden
+}
+
+// canUseWallet checks that a wallet referenced by a request exists and belongs
+// to the session user. An empty id means no wallet and is allowed.
+func (h *httpService) canUseWallet(ctx context.Context, session jwtClaims, walletID string) error {
+	if walletID == "" {
+		return nil
+	}
+
+	wallet, err := h.walletRepo.FindByID(ctx, walletID)
+	if err != nil {
+		return model.ErrForbidden
+	}
+
+	// archived wallets keep their history but take nothing new
+	if wallet.ArchivedAt != nil {
+		return model.ErrForbidden
+	}
+
+	return canAccessWallet(session, wallet)
+}
+
+func forbidden(c echo.Context) error {
+	return c.JSON(http.StatusForbidden, response{Message: model.ErrForbidden.Error()})
+}
@@ -0,0 +1,162 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/notblessy/anggar-service/model"
+)
+
+// The people a policy is checked against. The group member shares other
+// expenses with the owner but takes no part in the record under test.
+const (
+	owner       = "owner"
+	participant = "participant"
+	member      = "member"
+	stranger    = "stranger"
+)
+
+func sessionOf(userID string) jwtClaims {
+	return jwtClaims{ID: userID, Role: model.RoleUser}
+}
+
+func sharedTransaction() model.Transaction {
+	return model.Transaction{
+		ID:       "t1",
+		UserID:   owner,
+		WalletID: "w1",
+		IsShared: true,
+		TransactionShares: []model.TransactionShare{
+			{ID: "s1", TransactionID: "t1", UserID: owner},
+			{ID: "s2", TransactionID: "t1", UserID: participant},
+		},
+	}
+}
+
+func TestPolicies(t *testing.T) {
+	transaction := sharedTransaction()
+	share := transaction.TransactionShares[1]
+	share.Transaction = transaction
+
+	tests := []struct {
+		name    string
+		check   func(jwtClaims) error
+		allowed map[string]bool
+	}{
+		{
+			name:    "view transaction",
+			check:   func(s jwtClaims) error { return canViewTransaction(s, transaction) },
+			allowed: map[string]bool{owner: true, participant: true},
+		},
+		{
+			name:    "modify transaction",
+			check:   func(s jwtClaims) error { return canModifyTransaction(s, transaction) },
+			allowed: map[string]bool{owner: true},
+		},
+		{
+			name:    "modify share",
+			check:   func(s jwtClaims) error { return canModifyShare(s, share) },
+			allowed: map[string]bool{owner: true},
+		},
+		{
+			name:    "access wallet",
+			check:   func(s jwtClaims) error { return canAccessWallet(s, model.Wallet{ID: "w1", UserID: owner}) },
+			allowed: map[string]bool{owner: true},
+		},
+		{
+			name:    "access scope",
+			check:   func(s jwtClaims) error { return canAccessScope(s, model.Scope{ID: 1, UserID: owner}) },
+			allowed: map[string]bool{owner: true},
+		},
+		{
+			name: "access recurring",
+			check: func(s jwtClaims) error {
+				return canAccessRecurring(s, model.RecurringTransaction{ID: "r1", UserID: owner})
+			},
+			allowed: map[string]bool{owner: true},
+		},
+		{
+			name:    "access import",
+			check:   func(s jwtClaims) error { return canAccessImport(s, model.ImportBatch{ID: "i1", UserID: owner}) },
+			allowed: map[string]bool{owner: true},
+		},
+		{
+			name: "access import mapping",
+			check: func(s jwtClaims) error {
+				return canAccessImportMapping(s, model.ImportMapping{ID: "m1", UserID: owner})
+			},
+			allowed: map[string]bool{owner: true},
+		},
+	}
+
+	for _, tt := range tests {
+		for _, user := range []string{owner, participant, member, stranger} {
+			t.Run(tt.name+"/"+user, func(t *testing.T) {
+				err := tt.check(sessionOf(user))
+
+				if tt.allowed[user] && err != nil {
+					t.Fatalf("expected access, got %v", err)
+				}
+
+				if !tt.allowed[user] && !errors.Is(err, model.ErrForbidden) {
+					t.Fatalf("expected ErrForbidden, got %v", err)
+				}
+			})
+		}
+	}
+}
+
+// walletStub serves FindByID from a map; the other methods are not used.
+type walletStub struct {
+	model.WalletRepository
+	wallets map[string]model.Wallet
+}
+
+func (s walletStub) FindByID(_ context.Context, id string) (model.Wallet, error) {
+	wallet, ok := s.wallets[id]
+	if !ok {
+		return model.Wallet{}, errors.New("record not found")
+	}
+
+	return wallet, nil
+}
+
+func TestCanUseWallet(t *testing.T) {
+	archivedAt := time.Now()
+
+	h := &httpService{walletRepo: walletStub{wallets: map[string]model.Wallet{
+		"w1":       {ID: "w1", UserID: owner},
+		"archived": {ID: "archived", UserID: owner, ArchivedAt: &archivedAt},
+	}}}
+
+	tests := []struct {
+		name     string
+		user     string
+		walletID string
+		allowed  bool
+	}{
+		{"owner", owner, "w1", true},
+		{"participant", participant, "w1", false},
+		{"group member", member, "w1", false},
+		{"stranger", stranger, "w1", false},
+		{"no wallet", stranger, "", true},
+		{"unknown wallet", owner, "missing", false},
+		{"archived wallet", owner, "archived", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := h.canUseWallet(context.Background(), sessionOf(tt.user), tt.walletID)
+
+			if tt.allowed && err != nil {
+				t.Fatalf("expected access, got %v", err)
+			}
+
+			if !tt.allowed && !errors.Is(err, model.ErrForbidden) {
+				t.Fatalf("expected ErrForbidden, got %v", err)
+			}
+		})
+	}
+}
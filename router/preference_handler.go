@@ -0,0 +1,97 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/notblessy/anggar-service/model"
+	"github.com/notblessy/anggar-service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+func (h *httpService) findPreferenceHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	preference, err := h.preferenceRepo.Find(c.Request().Context(), session.ID)
+	if err != nil {
+		logger.Errorf("Error getting preference: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true, Data: preference})
+}
+
+func (h *httpService) updatePreferenceHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	var input model.PreferenceInput
+	if err := c.Bind(&input); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	preference, err := h.preferenceRepo.Find(c.Request().Context(), session.ID)
+	if err != nil {
+		logger.Errorf("Error getting preference: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	if input.BaseCurrency != "" {
+		preference.BaseCurrency = strings.ToUpper(input.BaseCurrency)
+	}
+
+	if input.Timezone != "" {
+		if _, err := time.LoadLocation(input.Timezone); err != nil {
+			return c.JSON(http.StatusBadRequest, response{Message: "unknown timezone " + input.Timezone})
+		}
+
+		preference.Timezone = input.Timezone
+	}
+
+	if input.Locale != "" {
+		preference.Locale = input.Locale
+	}
+
+	if input.WeekStart != nil {
+		preference.WeekStart = *input.WeekStart
+	}
+
+	if input.DefaultWalletID != nil {
+		if err := h.canUseWallet(c.Request().Context(), session, *input.DefaultWalletID); err != nil {
+			return forbidden(c)
+		}
+
+		preference.DefaultWalletID = *input.DefaultWalletID
+	}
+
+	err = h.preferenceRepo.Upsert(c.Request().Context(), &preference)
+	if errors.Is(err, model.ErrBaseCurrencyInUse) {
+		return c.JSON(http.StatusConflict, response{Message: err.Error()})
+	}
+
+	if err != nil {
+		logger.Errorf("Error saving preference: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true, Data: preference})
+}
@@ -0,0 +1,302 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/notblessy/anggar-service/model"
+	"github.com/notblessy/anggar-service/utils"
+	"github.com/oklog/ulid/v2"
+	"github.com/sirupsen/logrus"
+)
+
+const defaultUpcomingDays = 30
+
+func (h *httpService) findAllRecurringHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	var query model.RecurringQueryInput
+	if err := c.Bind(&query); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	query.UserID = session.ID
+
+	recurrings, total, err := h.recurringRepo.FindAll(c.Request().Context(), query)
+	if err != nil {
+		logger.Errorf("Error getting recurring transactions: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{
+		Success: true,
+		Data:    withPaging(recurrings, total, query.PageOrDefault(), query.SizeOrDefault()),
+	})
+}
+
+func (h *httpService) createRecurringHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	var input model.RecurringTransactionInput
+	if err := c.Bind(&input); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	recurring := model.RecurringTransaction{
+		ID:       ulid.Make().String(),
+		UserID:   session.ID,
+		IsActive: true,
+	}
+
+	if err := h.applyRecurringInput(c.Request().Context(), session, &recurring, input, time.Time{}); err != nil {
+		return h.recurringInputError(c, err)
+	}
+
+	if err := h.recurringRepo.Create(c.Request().Context(), &recurring); err != nil {
+		logger.Errorf("Error creating recurring transaction: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusCreated, response{Success: true, Data: recurring})
+}
+
+func (h *httpService) findRecurringByIDHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	recurring, err := h.recurringRepo.FindByID(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		logger.Errorf("Error finding recurring transaction: %v", err)
+		return c.JSON(http.StatusNotFound, response{Message: err.Error()})
+	}
+
+	if err := canAccessRecurring(session, recurring); err != nil {
+		return forbidden(c)
+	}
+
+	occurrences, err := h.recurringRepo.FindOccurrences(c.Request().Context(), recurring.ID)
+	if err != nil {
+		logger.Errorf("Error finding occurrences: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{
+		Success: true,
+		Data: map[string]interface{}{
+			"recurring":   recurring,
+			"occurrences": occurrences,
+		},
+	})
+}
+
+func (h *httpService) updateRecurringHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	var input model.RecurringTransactionInput
+	if err := c.Bind(&input); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	recurring, err := h.recurringRepo.FindByID(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		logger.Errorf("Error finding recurring transaction: %v", err)
+		return c.JSON(http.StatusNotFound, response{Message: err.Error()})
+	}
+
+	if err := canAccessRecurring(session, recurring); err != nil {
+		return forbidden(c)
+	}
+
+	// Resume after the last generated occurrence so an edit never re-creates
+	// transactions that already exist.
+	occurrences, err := h.recurringRepo.FindOccurrences(c.Request().Context(), recurring.ID)
+	if err != nil {
+		logger.Errorf("Error finding occurrences: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	var lastRun time.Time
+	if len(occurrences) > 0 {
+		lastRun = occurrences[0].OccurrenceAt
+	}
+
+	if err := h.applyRecurringInput(c.Request().Context(), session, &recurring, input, lastRun); err != nil {
+		return h.recurringInputError(c, err)
+	}
+
+	if err := h.recurringRepo.Update(c.Request().Context(), &recurring); err != nil {
+		logger.Errorf("Error updating recurring transaction: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true, Data: recurring})
+}
+
+func (h *httpService) deleteRecurringHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	recurring, err := h.recurringRepo.FindByID(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		logger.Errorf("Error finding recurring transaction: %v", err)
+		return c.JSON(http.StatusNotFound, response{Message: err.Error()})
+	}
+
+	if err := canAccessRecurring(session, recurring); err != nil {
+		return forbidden(c)
+	}
+
+	if err := h.recurringRepo.Delete(c.Request().Context(), recurring.ID); err != nil {
+		logger.Errorf("Error deleting recurring transaction: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true})
+}
+
+// upcomingBillHandler lists what the user's active rules will charge or pay
+// over the next ?days= days (30 by default).
+func (h *httpService) upcomingBillHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	days := defaultUpcomingDays
+	if raw := c.QueryParam("days"); raw != "" {
+		days, err = strconv.Atoi(raw)
+		if err != nil || days < 1 || days > 366 {
+			return c.JSON(http.StatusBadRequest, response{Message: "days must be between 1 and 366"})
+		}
+	}
+
+	bills, err := h.recurringRepo.Upcoming(c.Request().Context(), session.ID, time.Now().AddDate(0, 0, days))
+	if err != nil {
+		logger.Errorf("Error getting upcoming bills: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true, Data: bills})
+}
+
+// applyRecurringInput copies the input onto recurring, normalizes the
+// schedule into a single RRULE and computes the next run after lastRun
+// (or from the start when nothing was generated yet).
+func (h *httpService) applyRecurringInput(ctx context.Context, session jwtClaims, recurring *model.RecurringTransaction, input model.RecurringTransactionInput, lastRun time.Time) error {
+	if !input.Amount.IsPositive() {
+		return errors.New("amount must be positive")
+	}
+
+	preference, err := h.preferenceRepo.Find(ctx, session.ID)
+	if err != nil {
+		return err
+	}
+
+	if input.WalletID == "" {
+		input.WalletID = preference.DefaultWalletID
+	}
+
+	if err := h.canUseWallet(ctx, session, input.WalletID); err != nil {
+		return err
+	}
+
+	if err := h.validCategory(ctx, session.ID, input.Category); err != nil {
+		return err
+	}
+
+	rule, err := input.Schedule()
+	if err != nil {
+		return err
+	}
+
+	currency := strings.ToUpper(input.Currency)
+	if currency == "" {
+		currency = preference.BaseCurrency
+	}
+
+	recurring.WalletID = input.WalletID
+	recurring.Category = input.Category
+	recurring.TransactionType = input.TransactionType
+	recurring.Description = input.Description
+	recurring.Amount = input.Amount
+	recurring.Currency = currency
+	recurring.Frequency = input.Frequency
+	recurring.Interval = rule.Interval
+	recurring.DayOfMonth = input.DayOfMonth
+	recurring.RRule = rule.String()
+	recurring.StartAt = input.StartAt
+	recurring.EndAt = input.EndAt
+	recurring.RemindDaysBefore = input.RemindDaysBefore
+	recurring.RemindedFor = nil
+
+	if input.IsActive != nil {
+		recurring.IsActive = *input.IsActive
+	}
+
+	after := input.StartAt.Add(-time.Second)
+	if lastRun.After(after) {
+		after = lastRun
+	}
+
+	recurring.NextRunAt = nil
+	if next, ok := recurring.NextAfter(after, preference.Location()); ok {
+		recurring.NextRunAt = &next
+	}
+
+	return nil
+}
+
+func (h *httpService) recurringInputError(c echo.Context, err error) error {
+	if errors.Is(err, model.ErrForbidden) {
+		return forbidden(c)
+	}
+
+	return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+}
@@ -2,20 +2,45 @@ package router
 
 import (
 	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
 	"github.com/notblessy/anggar-service/model"
 	"gorm.io/gorm"
 )
 
 type httpService struct {
-	db              *gorm.DB
-	userRepo        model.UserRepository
-	walletRepo      model.WalletRepository
-	scopeRepo       model.ScopeRepository
-	transactionRepo model.TransactionRepository
+	db               *gorm.DB
+	userRepo         model.UserRepository
+	walletRepo       model.WalletRepository
+	scopeRepo        model.ScopeRepository
+	transactionRepo  model.TransactionRepository
+	adminRepo        model.AdminRepository
+	sessionRepo      model.SessionRepository
+	apiTokenRepo     model.APITokenRepository
+	mfaRepo          model.MFARepository
+	preferenceRepo   model.PreferenceRepository
+	exchangeRateRepo model.ExchangeRateRepository
+	recurringRepo    model.RecurringTransactionRepository
+	importRepo       model.ImportRepository
+	exportRepo       model.ExportRepository
+	accountRepo      model.AccountRepository
+	tagRepo          model.TagRepository
+	categoryRepo     model.CategoryRepository
+	payeeRepo        model.PayeeRepository
+	attachmentRepo   model.AttachmentRepository
+	auditRepo        model.AuditRepository
+	trashRepo        model.TrashRepository
+	creditCardRepo   model.CreditCardRepository
+	goalRepo         model.GoalRepository
+	netWorthRepo     model.NetWorthRepository
+	fileStorage      model.FileStorage
+	mailer           model.Mailer
+	oidcProviders    map[string]model.OIDCProvider
 }
 
 func NewHTTPService() *httpService {
-	return &httpService{}
+	return &httpService{
+		oidcProviders: make(map[string]model.OIDCProvider),
+	}
 }
 
 func (h *httpService) RegisterPostgres(db *gorm.DB) {
@@ -38,22 +63,150 @@ func (h *httpService) RegisterTransactionRepository(repo model.TransactionReposi
 	h.transactionRepo = repo
 }
 
+func (h *httpService) RegisterAdminRepository(repo model.AdminRepository) {
+	h.adminRepo = repo
+}
+
+func (h *httpService) RegisterSessionRepository(repo model.SessionRepository) {
+	h.sessionRepo = repo
+}
+
+func (h *httpService) RegisterAPITokenRepository(repo model.APITokenRepository) {
+	h.apiTokenRepo = repo
+}
+
+func (h *httpService) RegisterMFARepository(repo model.MFARepository) {
+	h.mfaRepo = repo
+}
+
+func (h *httpService) RegisterPreferenceRepository(repo model.PreferenceRepository) {
+	h.preferenceRepo = repo
+}
+
+func (h *httpService) RegisterExchangeRateRepository(repo model.ExchangeRateRepository) {
+	h.exchangeRateRepo = repo
+}
+
+func (h *httpService) RegisterRecurringTransactionRepository(repo model.RecurringTransactionRepository) {
+	h.recurringRepo = repo
+}
+
+func (h *httpService) RegisterImportRepository(repo model.ImportRepository) {
+	h.importRepo = repo
+}
+
+func (h *httpService) RegisterExportRepository(repo model.ExportRepository) {
+	h.exportRepo = repo
+}
+
+func (h *httpService) RegisterAccountRepository(repo model.AccountRepository) {
+	h.accountRepo = repo
+}
+
+func (h *httpService) RegisterTagRepository(repo model.TagRepository) {
+	h.tagRepo = repo
+}
+
+func (h *httpService) RegisterCategoryRepository(repo model.CategoryRepository) {
+	h.categoryRepo = repo
+}
+
+func (h *httpService) RegisterPayeeRepository(repo model.PayeeRepository) {
+	h.payeeRepo = repo
+}
+
+func (h *httpService) RegisterAttachmentRepository(repo model.AttachmentRepository) {
+	h.attachmentRepo = repo
+}
+
+func (h *httpService) RegisterAuditRepository(repo model.AuditRepository) {
+	h.auditRepo = repo
+}
+
+func (h *httpService) RegisterTrashRepository(repo model.TrashRepository) {
+	h.trashRepo = repo
+}
+
+func (h *httpService) RegisterCreditCardRepository(repo model.CreditCardRepository) {
+	h.creditCardRepo = repo
+}
+
+func (h *httpService) RegisterGoalRepository(repo model.GoalRepository) {
+	h.goalRepo = repo
+}
+
+func (h *httpService) RegisterNetWorthRepository(repo model.NetWorthRepository) {
+	h.netWorthRepo = repo
+}
+
+func (h *httpService) RegisterFileStorage(storage model.FileStorage) {
+	h.fileStorage = storage
+}
+
+func (h *httpService) RegisterMailer(mailer model.Mailer) {
+	h.mailer = mailer
+}
+
+func (h *httpService) RegisterOIDCProvider(provider model.OIDCProvider) {
+	h.oidcProviders[provider.Name()] = provider
+}
+
 func (h *httpService) Router(e *echo.Echo) {
 	e.GET("/ping", h.ping)
 	e.GET("/health", h.health)
 
 	v1 := e.Group("/api/v1")
 
+	jwtMiddleware := NewJWTMiddleware(h.sessionRepo, h.apiTokenRepo)
+
 	auth := v1.Group("/auth")
 	auth.POST("/google", h.loginWithGoogleHandler)
+	auth.POST("/register", h.registerHandler)
+	auth.POST("/login", h.loginWithPasswordHandler)
+	auth.POST("/verify-email", h.verifyEmailHandler)
+	auth.POST("/verify-email/resend", h.resendVerificationHandler)
+	auth.POST("/password/forgot", h.forgotPasswordHandler)
+	auth.POST("/password/reset", h.resetPasswordHandler)
+	auth.GET("/oidc", h.findOIDCProviderHandler)
+	auth.GET("/oidc/:provider/authorize", h.oidcAuthorizeHandler)
+	auth.POST("/oidc/:provider", h.loginWithOIDCHandler)
+	auth.POST("/mfa/verify", h.verifyMFAChallengeHandler)
+	auth.POST("/refresh", h.refreshTokenHandler)
+	auth.POST("/logout", h.logoutHandler, jwtMiddleware.ValidateJWT, DenyAPIToken)
 
 	protected := v1.Group("")
-	protected.Use(NewJWTMiddleware().ValidateJWT)
-	users := protected.Group("/users")
+	protected.Use(jwtMiddleware.ValidateJWT, RequireRole(model.RoleUser, model.RoleAdmin))
+	users := protected.Group("/users", RequireTokenScope(""))
 	users.GET("/me", h.profileHandler)
 	users.GET("/options", h.findUserOptionHandler)
+	users.GET("/me/preferences", h.findPreferenceHandler)
+	users.PUT("/me/preferences", h.updatePreferenceHandler)
+	users.PUT("/me/password", h.setPasswordHandler)
+	users.GET("/me/identities", h.findIdentityHandler, DenyAPIToken)
+	users.POST("/me/identities/google", h.linkGoogleHandler)
+	users.POST("/me/identities/oidc/:provider", h.linkOIDCHandler)
+	users.GET("/me/mfa", h.mfaStatusHandler, DenyAPIToken)
+	users.POST("/me/mfa/totp", h.enrollTOTPHandler)
+	users.POST("/me/mfa/totp/confirm", h.confirmTOTPHandler)
+	users.DELETE("/me/mfa/totp", h.disableTOTPHandler)
+	users.POST("/me/mfa/recovery-codes", h.regenerateRecoveryCodeHandler)
+	users.GET("/me/archive", h.accountArchiveHandler, DenyAPIToken)
+	users.POST("/me/archive", h.restoreAccountHandler, DenyAPIToken)
+	users.GET("/me/deletion", h.findAccountDeletionHandler)
+	users.POST("/me/deletion", h.requestAccountDeletionHandler, DenyAPIToken)
+	users.DELETE("/me/deletion", h.cancelAccountDeletionHandler, DenyAPIToken)
 
-	scope := protected.Group("/scopes")
+	sessions := protected.Group("/sessions", DenyAPIToken)
+	sessions.GET("", h.findSessionHandler)
+	sessions.DELETE("", h.revokeAllSessionHandler)
+	sessions.DELETE("/:id", h.revokeSessionHandler)
+
+	tokens := protected.Group("/tokens", DenyAPIToken)
+	tokens.GET("", h.findAllAPITokenHandler)
+	tokens.POST("", h.createAPITokenHandler)
+	tokens.DELETE("/:id", h.revokeAPITokenHandler)
+
+	scope := protected.Group("/scopes", RequireTokenScope("scopes"))
 	scope.GET("/overviews", h.findScopeOverviews)
 	scope.GET("", h.findAllScopeHandler)
 	scope.POST("", h.createScopeHandler)
@@ -61,25 +214,128 @@ func (h *httpService) Router(e *echo.Echo) {
 	scope.PUT("/:id", h.updateScopeHandler)
 	scope.DELETE("/:id", h.deleteScopeHandler)
 
-	wallet := protected.Group("/wallets")
+	wallet := protected.Group("/wallets", RequireTokenScope("wallets"))
 	wallet.GET("", h.findAllWalletHandler)
 	wallet.POST("", h.createWalletHandler)
 	wallet.GET("/:id", h.findWalletByIDHandler)
 	wallet.PUT("/:id", h.updateWalletHandler)
 	wallet.DELETE("/:id", h.deleteWalletHandler)
+	wallet.POST("/:id/unarchive", h.unarchiveWalletHandler)
+	wallet.GET("/:id/statement", h.creditCardStatementHandler)
+	wallet.POST("/:id/payments", h.payCreditCardHandler)
 	wallet.GET("/options", h.findWalletOptionHandler)
 
-	transaction := protected.Group("/transactions")
+	goals := protected.Group("/goals", RequireTokenScope("wallets"))
+	goals.GET("", h.findAllGoalHandler)
+	goals.POST("", h.createGoalHandler)
+	goals.GET("/:id", h.findGoalByIDHandler)
+	goals.PUT("/:id", h.updateGoalHandler)
+	goals.DELETE("/:id", h.deleteGoalHandler)
+	goals.GET("/:id/contributions", h.findAllGoalContributionHandler)
+	goals.POST("/:id/contributions", h.createGoalContributionHandler)
+	goals.DELETE("/:id/contributions/:contribution_id", h.deleteGoalContributionHandler)
+
+	netWorth := protected.Group("/net-worth", RequireTokenScope("wallets"))
+	netWorth.GET("", h.netWorthHandler)
+
+	assets := protected.Group("/assets", RequireTokenScope("wallets"))
+	assets.GET("", h.findAllAssetHandler)
+	assets.POST("", h.createAssetHandler)
+	assets.PUT("/:id", h.updateAssetHandler)
+	assets.DELETE("/:id", h.deleteAssetHandler)
+
+	transaction := protected.Group("/transactions", RequireTokenScope("transactions"))
 	transaction.GET("", h.findAllTransactionHandler)
 	transaction.POST("", h.createTransactionHandler)
 	transaction.GET("/:id", h.findTransactionByIDHandler)
 	transaction.PUT("/:id", h.updateTransactionHandler)
 	transaction.DELETE("/:id", h.deleteTransactionHandler)
 	transaction.GET("/summary", h.currentMonthSummaryHandler)
+	transaction.GET("/:id/attachments", h.findAllAttachmentHandler)
+	transaction.POST("/:id/attachments", h.uploadAttachmentHandler, middleware.BodyLimit(attachmentBodyLimit))
+	transaction.GET("/:id/attachments/:attachment_id", h.downloadAttachmentHandler)
+	transaction.GET("/:id/attachments/:attachment_id/thumbnail", h.attachmentThumbnailHandler)
+	transaction.DELETE("/:id/attachments/:attachment_id", h.deleteAttachmentHandler)
+	transaction.GET("/:id/history", h.transactionHistoryHandler)
+
+	activity := protected.Group("/activity", RequireTokenScope("transactions"))
+	activity.GET("", h.activityFeedHandler)
+
+	categories := protected.Group("/categories", RequireTokenScope("transactions"))
+	categories.GET("", h.findAllCategoryHandler)
+	categories.POST("", h.createCategoryHandler)
+	categories.GET("/accuracy", h.categoryAccuracyHandler)
+	categories.PUT("/:id", h.updateCategoryHandler)
+	categories.POST("/:id/merge", h.mergeCategoryHandler)
+	categories.DELETE("/:id", h.deleteCategoryHandler)
+
+	tags := protected.Group("/tags", RequireTokenScope("transactions"))
+	tags.GET("", h.findAllTagHandler)
+	tags.POST("", h.createTagHandler)
+	tags.GET("/report", h.tagReportHandler)
+	tags.PUT("/:id", h.updateTagHandler)
+	tags.DELETE("/:id", h.deleteTagHandler)
 
-	shares := protected.Group("/transaction-shares")
+	payees := protected.Group("/payees", RequireTokenScope("transactions"))
+	payees.GET("", h.findAllPayeeHandler)
+	payees.POST("", h.createPayeeHandler)
+	payees.GET("/report", h.payeeReportHandler)
+	payees.GET("/:id", h.findPayeeByIDHandler)
+	payees.PUT("/:id", h.updatePayeeHandler)
+	payees.DELETE("/:id", h.deletePayeeHandler)
+
+	recurring := protected.Group("/recurring-transactions", RequireTokenScope("transactions"))
+	recurring.GET("", h.findAllRecurringHandler)
+	recurring.POST("", h.createRecurringHandler)
+	recurring.GET("/upcoming", h.upcomingBillHandler)
+	recurring.GET("/:id", h.findRecurringByIDHandler)
+	recurring.PUT("/:id", h.updateRecurringHandler)
+	recurring.DELETE("/:id", h.deleteRecurringHandler)
+
+	imports := protected.Group("/imports", RequireTokenScope("transactions"))
+	imports.GET("/mappings", h.findImportMappingHandler)
+	imports.POST("/mappings", h.createImportMappingHandler)
+	imports.PUT("/mappings/:id", h.updateImportMappingHandler)
+	imports.DELETE("/mappings/:id", h.deleteImportMappingHandler)
+	imports.GET("", h.findAllImportHandler)
+	imports.POST("", h.previewImportHandler, middleware.BodyLimit(importBodyLimit))
+	imports.GET("/:id", h.findImportByIDHandler)
+	imports.POST("/:id/commit", h.commitImportHandler)
+	imports.DELETE("/:id", h.undoImportHandler)
+
+	exports := protected.Group("/exports", RequireTokenScope("transactions"))
+	exports.GET("/transactions", h.exportTransactionHandler)
+	exports.GET("/statements", h.exportStatementHandler)
+	exports.GET("/jobs", h.findExportJobHandler)
+	exports.GET("/jobs/:id", h.findExportJobByIDHandler)
+	exports.GET("/jobs/:id/download", h.downloadExportJobHandler)
+
+	trash := protected.Group("/trash", RequireTokenScope(""))
+	trash.GET("", h.findAllTrashHandler)
+	trash.POST("/:type/:id/restore", h.restoreTrashHandler)
+	trash.DELETE("/:type/:id", h.purgeTrashHandler)
+
+	shares := protected.Group("/transaction-shares", RequireTokenScope("transactions"))
 	shares.PUT("/:id", h.updateShareHandler)
 	shares.DELETE("/:id", h.deleteShareHandler)
+
+	rates := protected.Group("/exchange-rates", RequireTokenScope(""))
+	rates.GET("", h.findAllExchangeRateHandler)
+	rates.GET("/convert", h.convertExchangeRateHandler)
+	rates.POST("", h.createExchangeRateHandler, DenyAPIToken, RequireRole(model.RoleAdmin))
+	rates.DELETE("/:id", h.deleteExchangeRateHandler, DenyAPIToken, RequireRole(model.RoleAdmin))
+
+	admin := protected.Group("/admin", DenyAPIToken, RequireRole(model.RoleAdmin))
+	admin.GET("/stats", h.systemStatsHandler)
+	admin.GET("/users", h.findAllUserHandler)
+	admin.PUT("/users/:id/role", h.updateUserRoleHandler)
+	admin.DELETE("/users/:id", h.deleteUserHandler)
+	admin.DELETE("/users/:id/mfa", h.resetUserMFAHandler)
+	admin.GET("/allowed-emails", h.findAllowedEmailHandler)
+	admin.POST("/allowed-emails", h.createAllowedEmailHandler)
+	admin.DELETE("/allowed-emails/:id", h.deleteAllowedEmailHandler)
+	admin.GET("/bot-links", h.findBotLinkHandler)
+	admin.DELETE("/bot-links/:user_id", h.deleteBotLinkHandler)
 }
 
 func (h *httpService) ping(c echo.Context) error {
@@ -0,0 +1,72 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/notblessy/anggar-service/utils"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+func (h *httpService) findSessionHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	sessions, err := h.sessionRepo.FindActive(c.Request().Context(), session.ID)
+	if err != nil {
+		logger.Errorf("Error getting sessions: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == session.SessionID
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true, Data: sessions})
+}
+
+func (h *httpService) revokeSessionHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	err = h.sessionRepo.Revoke(c.Request().Context(), session.ID, c.Param("id"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.JSON(http.StatusNotFound, response{Message: "session not found"})
+	}
+
+	if err != nil {
+		logger.Errorf("Error revoking session: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true})
+}
+
+func (h *httpService) revokeAllSessionHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	if err := h.sessionRepo.RevokeAll(c.Request().Context(), session.ID); err != nil {
+		logger.Errorf("Error revoking sessions: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true})
+}
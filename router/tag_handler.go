@@ -0,0 +1,219 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/notblessy/anggar-service/model"
+	"github.com/notblessy/anggar-service/utils"
+	"github.com/oklog/ulid/v2"
+	"github.com/sirupsen/logrus"
+)
+
+func (h *httpService) findAllTagHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	var query model.TagQueryInput
+	if err := c.Bind(&query); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	query.UserID = session.ID
+
+	tags, err := h.tagRepo.FindAll(c.Request().Context(), query)
+	if err != nil {
+		logger.Errorf("Error getting tags: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true, Data: tags})
+}
+
+func (h *httpService) createTagHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	var input model.TagInput
+	if err := c.Bind(&input); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	tag := model.Tag{
+		ID:     ulid.Make().String(),
+		UserID: session.ID,
+		Name:   model.NormalizeTag(input.Name),
+		Color:  input.Color,
+	}
+
+	if err := h.checkTagName(c.Request().Context(), tag); err != nil {
+		return h.tagInputError(c, err)
+	}
+
+	if err := h.tagRepo.Create(c.Request().Context(), &tag); err != nil {
+		logger.Errorf("Error creating tag: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true, Data: tag})
+}
+
+func (h *httpService) updateTagHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	var input model.TagInput
+	if err := c.Bind(&input); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	if err := c.Validate(&input); err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	tag, err := h.tagRepo.FindByID(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		logger.Errorf("Error finding tag: %v", err)
+		return c.JSON(http.StatusNotFound, response{Message: err.Error()})
+	}
+
+	if err := canAccessTag(session, tag); err != nil {
+		return forbidden(c)
+	}
+
+	tag.Name = model.NormalizeTag(input.Name)
+	tag.Color = input.Color
+
+	if err := h.checkTagName(c.Request().Context(), tag); err != nil {
+		return h.tagInputError(c, err)
+	}
+
+	if err := h.tagRepo.Update(c.Request().Context(), tag.ID, tag); err != nil {
+		logger.Errorf("Error updating tag: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true, Data: tag})
+}
+
+func (h *httpService) deleteTagHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	tag, err := h.tagRepo.FindByID(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		logger.Errorf("Error finding tag: %v", err)
+		return c.JSON(http.StatusNotFound, response{Message: err.Error()})
+	}
+
+	if err := canAccessTag(session, tag); err != nil {
+		return forbidden(c)
+	}
+
+	if err := h.tagRepo.Delete(c.Request().Context(), tag.ID); err != nil {
+		logger.Errorf("Error deleting tag: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true})
+}
+
+// tagReportHandler totals income and expense per tag over a period.
+func (h *httpService) tagReportHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	var query model.TagReportQueryInput
+	if err := c.Bind(&query); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	preference, err := h.preferenceRepo.Find(c.Request().Context(), session.ID)
+	if err != nil {
+		logger.Errorf("Error getting preference: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	query.UserID = session.ID
+	query.Timezone = preference.Timezone
+
+	if query.StartDate == "" || query.EndDate == "" {
+		query.StartDate, query.EndDate = preference.PeriodRange(query.Period, preference.Now())
+	}
+
+	reports, err := h.tagRepo.Report(c.Request().Context(), query)
+	if err != nil {
+		logger.Errorf("Error getting tag report: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true, Data: reports})
+}
+
+// checkTagName rejects names that normalize to nothing or clash with another
+// of the user's tags.
+func (h *httpService) checkTagName(ctx context.Context, tag model.Tag) error {
+	if tag.Name == "" {
+		return model.ErrInvalidTag
+	}
+
+	tags, err := h.tagRepo.FindAll(ctx, model.TagQueryInput{UserID: tag.UserID})
+	if err != nil {
+		return err
+	}
+
+	for _, existing := range tags {
+		if existing.Name == tag.Name && existing.ID != tag.ID {
+			return model.ErrTagExists
+		}
+	}
+
+	return nil
+}
+
+func (h *httpService) tagInputError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, model.ErrInvalidTag):
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	case errors.Is(err, model.ErrTagExists):
+		return c.JSON(http.StatusConflict, response{Message: err.Error()})
+	}
+
+	logrus.WithField("ctx", utils.Dump(c.Request().Context())).Errorf("Error checking tag: %v", err)
+	return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+}
@@ -1,6 +1,7 @@
 package router
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/labstack/echo/v4"
@@ -20,6 +21,21 @@ func (h *httpService) findAllTransactionHandler(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
 	}
 
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	preference, err := h.preferenceRepo.Find(c.Request().Context(), session.ID)
+	if err != nil {
+		logger.Errorf("Error getting preference: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	query.ParticipantID = session.ID
+	query.Timezone = preference.Timezone
+
 	transactions, total, err := h.transactionRepo.FindAll(c.Request().Context(), query)
 	if err != nil {
 		logger.Errorf("Error getting transactions: %v", err)
@@ -27,9 +43,10 @@ func (h *httpService) findAllTransactionHandler(c echo.Context) error {
 	}
 
 	results := make(map[string][]model.Transaction)
+	loc := preference.Location()
 
 	for _, tx := range transactions {
-		dateKey := tx.SpentAt.Format("2006-01-02")
+		dateKey := tx.SpentAt.In(loc).Format("2006-01-02")
 		results[dateKey] = append(results[dateKey], tx)
 	}
 
@@ -57,19 +74,67 @@ func (h *httpService) createTransactionHandler(c echo.Context) error {
 
 	transaction.UserID = session.ID
 
+	preference, err := h.preferenceRepo.Find(c.Request().Context(), session.ID)
+	if err != nil {
+		logger.Errorf("Error getting preference: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	if transaction.WalletID == "" {
+		transaction.WalletID = preference.DefaultWalletID
+	}
+
+	if err := h.canUseWallet(c.Request().Context(), session, transaction.WalletID); err != nil {
+		return forbidden(c)
+	}
+
+	payee, err := h.linkPayee(c.Request().Context(), session.ID, &transaction)
+	if errors.Is(err, model.ErrForbidden) {
+		return forbidden(c)
+	}
+
+	if err != nil {
+		logger.Errorf("Error finding payee: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	if transaction.Category == "" {
+		transaction.Category = payee.DefaultCategory
+	}
+
+	if err := h.validCategory(c.Request().Context(), session.ID, transaction.Category); err != nil {
+		return h.categoryError(c, err)
+	}
+
+	if err := model.ConvertTransaction(c.Request().Context(), h.exchangeRateRepo, &transaction, preference.BaseCurrency); err != nil {
+		return h.conversionError(c, err)
+	}
+
 	transaction.ID = ulid.Make().String()
 
+	// Tags are sent by name and resolved after the transaction exists.
+	tagNames := model.TagNames(transaction.Tags)
+	transaction.Tags = nil
+
 	for i := range transaction.TransactionShares {
 		transaction.TransactionShares[i].ID = ulid.Make().String()
 		transaction.TransactionShares[i].TransactionID = transaction.ID
 	}
 
-	err = h.transactionRepo.Create(c.Request().Context(), &transaction)
+	err = h.transactionRepo.Create(c.Request().Context(), &transaction, auditActor(session))
 	if err != nil {
 		logger.Errorf("Error creating transaction: %v", err)
 		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
 	}
 
+	if len(tagNames) > 0 {
+		transaction.Tags, err = h.tagRepo.SetTransactionTags(c.Request().Context(), session.ID, transaction.ID, tagNames)
+		if err != nil {
+			logger.Errorf("Error tagging transaction: %v", err)
+			return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+		}
+	}
+
 	return c.JSON(http.StatusOK, response{
 		Success: true,
 		Data:    transaction,
@@ -79,12 +144,22 @@ func (h *httpService) createTransactionHandler(c echo.Context) error {
 func (h *httpService) findTransactionByIDHandler(c echo.Context) error {
 	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
 
-	id := utils.ParseID(c.Param("id"))
+	id := c.Param("id")
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
 
 	transaction, err := h.transactionRepo.FindByID(c.Request().Context(), id)
 	if err != nil {
 		logger.Errorf("Error finding transaction: %v", err)
-		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+		return c.JSON(http.StatusNotFound, response{Message: err.Error()})
+	}
+
+	if err := canViewTransaction(session, transaction); err != nil {
+		return forbidden(c)
 	}
 
 	return c.JSON(http.StatusOK, response{
@@ -96,7 +171,7 @@ func (h *httpService) findTransactionByIDHandler(c echo.Context) error {
 func (h *httpService) updateTransactionHandler(c echo.Context) error {
 	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
 
-	id := utils.ParseID(c.Param("id"))
+	id := c.Param("id")
 
 	var transaction model.Transaction
 	err := c.Bind(&transaction)
@@ -111,21 +186,110 @@ func (h *httpService) updateTransactionHandler(c echo.Context) error {
 		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
 	}
 
+	existing, err := h.transactionRepo.FindByID(c.Request().Context(), id)
+	if err != nil {
+		logger.Errorf("Error finding transaction: %v", err)
+		return c.JSON(http.StatusNotFound, response{Message: err.Error()})
+	}
+
+	if err := canModifyTransaction(session, existing); err != nil {
+		return forbidden(c)
+	}
+
+	if transaction.WalletID != existing.WalletID {
+		if err := h.canUseWallet(c.Request().Context(), session, transaction.WalletID); err != nil {
+			return forbidden(c)
+		}
+	}
+
+	if transaction.Category != existing.Category {
+		if err := h.validCategory(c.Request().Context(), session.ID, transaction.Category); err != nil {
+			return h.categoryError(c, err)
+		}
+	}
+
+	// A new description is matched again unless the payee is given; the
+	// category stays as it is.
+	if transaction.PayeeID != nil || (transaction.Description != "" && transaction.Description != existing.Description) {
+		_, err := h.linkPayee(c.Request().Context(), session.ID, &transaction)
+		if errors.Is(err, model.ErrForbidden) {
+			return forbidden(c)
+		}
+
+		if err != nil {
+			logger.Errorf("Error finding payee: %v", err)
+			return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+		}
+	}
+
+	transaction.Payee = nil
+
+	// Shares are changed through the share endpoints, a round-tripped list is ignored.
+	transaction.TransactionShares = nil
+
 	transaction.UserID = session.ID
 
-	err = h.transactionRepo.Update(c.Request().Context(), id, transaction)
+	if transaction.Currency == "" {
+		transaction.Currency = existing.Currency
+	}
+
+	if !transaction.Amount.IsZero() || !transaction.OriginalAmount.IsZero() || transaction.Currency != existing.Currency {
+		// A currency change alone converts the amount already entered.
+		if transaction.Amount.IsZero() && transaction.OriginalAmount.IsZero() {
+			transaction.OriginalAmount = existing.OriginalAmount
+			if transaction.OriginalAmount.IsZero() {
+				transaction.OriginalAmount = existing.Amount
+			}
+		}
+
+		preference, err := h.preferenceRepo.Find(c.Request().Context(), session.ID)
+		if err != nil {
+			logger.Errorf("Error getting preference: %v", err)
+			return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+		}
+
+		if transaction.SpentAt.IsZero() {
+			transaction.SpentAt = existing.SpentAt
+		}
+
+		if err := model.ConvertTransaction(c.Request().Context(), h.exchangeRateRepo, &transaction, preference.BaseCurrency); err != nil {
+			return h.conversionError(c, err)
+		}
+	}
+
+	// A tags field replaces the user's tags, leaving it out keeps them.
+	retag := transaction.Tags != nil
+	tagNames := model.TagNames(transaction.Tags)
+	transaction.Tags = nil
+
+	err = h.transactionRepo.Update(c.Request().Context(), id, transaction, auditActor(session))
 	if err != nil {
 		logger.Errorf("Error updating transaction: %v", err)
 		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
 	}
 
+	if retag {
+		transaction.Tags, err = h.tagRepo.SetTransactionTags(c.Request().Context(), session.ID, id, tagNames)
+		if err != nil {
+			logger.Errorf("Error tagging transaction: %v", err)
+			return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+		}
+	}
+
+	// The update is saved either way, a lost correction only costs a lesson.
+	if transaction.Category != "" && transaction.Category != existing.Category {
+		if err := h.categoryRepo.Learn(c.Request().Context(), session.ID, id, transaction.Category); err != nil {
+			logger.Errorf("Error learning category: %v", err)
+		}
+	}
+
 	return c.JSON(http.StatusOK, response{Success: true, Data: transaction})
 }
 
 func (h *httpService) deleteTransactionHandler(c echo.Context) error {
 	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
 
-	id := utils.ParseID(c.Param("id"))
+	id := c.Param("id")
 
 	session, err := authSession(c)
 	if err != nil {
@@ -133,11 +297,17 @@ func (h *httpService) deleteTransactionHandler(c echo.Context) error {
 		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
 	}
 
-	if session.ID == "" {
-		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	transaction, err := h.transactionRepo.FindByID(c.Request().Context(), id)
+	if err != nil {
+		logger.Errorf("Error finding transaction: %v", err)
+		return c.JSON(http.StatusNotFound, response{Message: err.Error()})
 	}
 
-	err = h.transactionRepo.Delete(c.Request().Context(), id)
+	if err := canModifyTransaction(session, transaction); err != nil {
+		return forbidden(c)
+	}
+
+	err = h.transactionRepo.Delete(c.Request().Context(), id, auditActor(session))
 	if err != nil {
 		logger.Errorf("Error deleting transaction: %v", err)
 		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
@@ -157,7 +327,36 @@ func (h *httpService) updateShareHandler(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
 	}
 
-	if err := h.transactionRepo.UpdateShare(c.Request().Context(), id, share); err != nil {
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	existing, err := h.transactionRepo.FindShareByID(c.Request().Context(), id)
+	if err != nil {
+		logger.Errorf("Error finding share: %v", err)
+		return c.JSON(http.StatusNotFound, response{Message: err.Error()})
+	}
+
+	if err := canModifyShare(session, existing); err != nil {
+		return forbidden(c)
+	}
+
+	// Only the split can change. A field left out keeps its value.
+	if share.Amount.IsZero() {
+		share.Amount = existing.Amount
+	}
+
+	if share.Percentage.IsZero() {
+		share.Percentage = existing.Percentage
+	}
+
+	share.ID = existing.ID
+	share.TransactionID = existing.TransactionID
+	share.UserID = existing.UserID
+
+	if err := h.transactionRepo.UpdateShare(c.Request().Context(), id, share, auditActor(session)); err != nil {
 		logger.Errorf("Error updating share: %v", err)
 		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
 	}
@@ -170,7 +369,23 @@ func (h *httpService) deleteShareHandler(c echo.Context) error {
 
 	id := c.Param("id")
 
-	if err := h.transactionRepo.DeleteShare(c.Request().Context(), id); err != nil {
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	share, err := h.transactionRepo.FindShareByID(c.Request().Context(), id)
+	if err != nil {
+		logger.Errorf("Error finding share: %v", err)
+		return c.JSON(http.StatusNotFound, response{Message: err.Error()})
+	}
+
+	if err := canModifyShare(session, share); err != nil {
+		return forbidden(c)
+	}
+
+	if err := h.transactionRepo.DeleteShare(c.Request().Context(), id, auditActor(session)); err != nil {
 		logger.Errorf("Error deleting share: %v", err)
 		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
 	}
@@ -194,7 +409,18 @@ func (h *httpService) currentMonthSummaryHandler(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
 	}
 
+	preference, err := h.preferenceRepo.Find(c.Request().Context(), session.ID)
+	if err != nil {
+		logger.Errorf("Error getting preference: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
 	query.UserID = session.ID
+	query.Timezone = preference.Timezone
+
+	if query.StartDate == "" || query.EndDate == "" {
+		query.StartDate, query.EndDate = preference.PeriodRange(query.Period, preference.Now())
+	}
 
 	summary, err := h.transactionRepo.CurrentMonthSummary(c.Request().Context(), query)
 	if err != nil {
@@ -207,3 +433,14 @@ func (h *httpService) currentMonthSummaryHandler(c echo.Context) error {
 		Data:    summary,
 	})
 }
+
+// conversionError reports a missing exchange rate as a client error so the
+// user knows to add one, and anything else as a server error.
+func (h *httpService) conversionError(c echo.Context, err error) error {
+	if errors.Is(err, model.ErrRateNotFound) {
+		return c.JSON(http.StatusUnprocessableEntity, response{Message: err.Error()})
+	}
+
+	logrus.WithField("ctx", utils.Dump(c.Request().Context())).Errorf("Error converting transaction: %v", err)
+	return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+}
@@ -0,0 +1,64 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/notblessy/anggar-service/model"
+	"github.com/notblessy/anggar-service/repository"
+	"github.com/shopspring/decimal"
+)
+
+// passwordHash stands in for the creator's bcrypt hash.
+const passwordHash = "$2a$10$creator-hash-that-must-stay-private"
+
+// Share participants see the creator on every transaction, but never their password.
+func TestTransactionListingsHidePasswords(t *testing.T) {
+	db := newTestDB(t, &model.User{}, &model.UserPreference{}, &model.Payee{}, &model.Tag{},
+		&model.Transaction{}, &model.TransactionShare{}, &model.AuditLog{})
+
+	db.Create(&model.User{ID: owner, Name: "Owner", Email: "owner@example.com", Password: passwordHash})
+	db.Create(&model.User{ID: participant, Name: "Participant", Email: "participant@example.com", Password: passwordHash})
+
+	transaction := sharedTransaction()
+	transaction.Amount = decimal.NewFromInt(100)
+	if err := db.Create(&transaction).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	preferenceRepo := repository.NewPreferenceRepository(db)
+
+	h := &httpService{
+		transactionRepo: repository.NewTransactionRepository(db),
+		preferenceRepo:  preferenceRepo,
+		exportRepo:      repository.NewExportRepository(db, preferenceRepo),
+	}
+
+	e := echo.New()
+	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set("user", sessionOf(participant))
+			return next(c)
+		}
+	})
+	e.GET("/transactions", h.findAllTransactionHandler)
+	e.GET("/exports/transactions", h.exportTransactionHandler)
+
+	for _, path := range []string{"/transactions", "/exports/transactions?format=jsonl"} {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+		body := rec.Body.String()
+
+		if rec.Code != http.StatusOK || !strings.Contains(body, "owner@example.com") {
+			t.Fatalf("%s: got %d without the creator:\n%s", path, rec.Code, body)
+		}
+
+		if strings.Contains(body, "password") || strings.Contains(body, passwordHash) {
+			t.Fatalf("%s: the response carries a password:\n%s", path, body)
+		}
+	}
+}
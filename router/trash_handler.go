@@ -0,0 +1,122 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/notblessy/anggar-service/model"
+	"github.com/notblessy/anggar-service/utils"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+func (h *httpService) findAllTrashHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	var query model.TrashQueryInput
+	if err := c.Bind(&query); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
+	if query.Type != "" && !model.TrashTypes[query.Type] {
+		return c.JSON(http.StatusBadRequest, response{Message: model.ErrUnknownTrashType.Error()})
+	}
+
+	query.UserID = session.ID
+
+	results, total, err := h.trashRepo.FindAll(c.Request().Context(), query)
+	if err != nil {
+		logger.Errorf("Error getting trash: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, response{
+		Success: true,
+		Data:    withPaging(results, total, query.PageOrDefault(), query.SizeOrDefault()),
+	})
+}
+
+func (h *httpService) restoreTrashHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	item, err := h.findTrashItem(c, session)
+	if err != nil {
+		return h.trashError(c, err)
+	}
+
+	if err := h.trashRepo.Restore(c.Request().Context(), item, auditActor(session)); err != nil {
+		return h.trashError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true, Data: item})
+}
+
+func (h *httpService) purgeTrashHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	item, err := h.findTrashItem(c, session)
+	if err != nil {
+		return h.trashError(c, err)
+	}
+
+	if err := h.trashRepo.Purge(c.Request().Context(), item); err != nil {
+		return h.trashError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, response{Success: true})
+}
+
+// findTrashItem loads the item in the path if it belongs to the user.
+func (h *httpService) findTrashItem(c echo.Context, session jwtClaims) (model.TrashItem, error) {
+	itemType := c.Param("type")
+	if !model.TrashTypes[itemType] {
+		return model.TrashItem{}, model.ErrUnknownTrashType
+	}
+
+	item, err := h.trashRepo.FindByID(c.Request().Context(), itemType, c.Param("id"))
+	if err != nil {
+		return model.TrashItem{}, err
+	}
+
+	if item.UserID != session.ID {
+		return model.TrashItem{}, model.ErrForbidden
+	}
+
+	return item, nil
+}
+
+func (h *httpService) trashError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, model.ErrUnknownTrashType):
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	case errors.Is(err, model.ErrForbidden):
+		return forbidden(c)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.JSON(http.StatusNotFound, response{Message: err.Error()})
+	case errors.Is(err, model.ErrTrashParentDeleted):
+		return c.JSON(http.StatusConflict, response{Message: err.Error()})
+	}
+
+	logrus.WithField("ctx", utils.Dump(c.Request().Context())).Errorf("Error updating trash: %v", err)
+	return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+}
@@ -20,6 +20,14 @@ func (h *httpService) findAllWalletHandler(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
 	}
 
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	query.UserID = session.ID
+
 	wallets, total, err := h.walletRepo.FindAll(c.Request().Context(), query)
 	if err != nil {
 		logger.Errorf("Error getting wallets: %v", err)
@@ -42,7 +50,22 @@ func (h *httpService) createWalletHandler(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
 	}
 
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
 	wallet.ID = ulid.Make().String()
+	wallet.UserID = session.ID
+
+	if wallet.Type == "" {
+		wallet.Type = model.WalletTypeCash
+	}
+
+	if err := wallet.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
 
 	if err := h.walletRepo.Create(c.Request().Context(), &wallet); err != nil {
 		logger.Errorf("Error creating wallet: %v", err)
@@ -69,8 +92,8 @@ func (h *httpService) findWalletByIDHandler(c echo.Context) error {
 		return c.JSON(http.StatusNotFound, response{Message: err.Error()})
 	}
 
-	if wallet.UserID != session.ID {
-		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	if err := canAccessWallet(session, wallet); err != nil {
+		return forbidden(c)
 	}
 
 	return c.JSON(http.StatusOK, response{Success: true, Data: wallet})
@@ -88,7 +111,46 @@ func (h *httpService) updateWalletHandler(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
 	}
 
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	existing, err := h.walletRepo.FindByID(c.Request().Context(), id)
+	if err != nil {
+		logger.Errorf("Error getting wallet: %v", err)
+		return c.JSON(http.StatusNotFound, response{Message: err.Error()})
+	}
+
+	if err := canAccessWallet(session, existing); err != nil {
+		return forbidden(c)
+	}
+
 	wallet.ID = id
+	wallet.UserID = session.ID
+
+	// fields left out keep their values, so check the wallet they add up to
+	merged := existing
+	if wallet.Type != "" {
+		merged.Type = wallet.Type
+	}
+
+	if !wallet.CreditLimit.IsZero() {
+		merged.CreditLimit = wallet.CreditLimit
+	}
+
+	if wallet.StatementClosingDay != 0 {
+		merged.StatementClosingDay = wallet.StatementClosingDay
+	}
+
+	if wallet.PaymentDueDay != 0 {
+		merged.PaymentDueDay = wallet.PaymentDueDay
+	}
+
+	if err := merged.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
 
 	if err := h.walletRepo.Update(c.Request().Context(), id, wallet); err != nil {
 		logger.Errorf("Error updating wallet: %v", err)
@@ -98,11 +160,19 @@ func (h *httpService) updateWalletHandler(c echo.Context) error {
 	return c.JSON(http.StatusOK, response{Success: true, Data: wallet})
 }
 
+// deleteWalletHandler takes a mode query parameter saying what happens to
+// the wallet's transactions; see model.WalletDeleteInput.
 func (h *httpService) deleteWalletHandler(c echo.Context) error {
 	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
 
 	id := c.Param("id")
 
+	var input model.WalletDeleteInput
+	if err := c.Bind(&input); err != nil {
+		logger.Errorf("Error parsing request: %v", err)
+		return c.JSON(http.StatusBadRequest, response{Message: err.Error()})
+	}
+
 	session, err := authSession(c)
 	if err != nil {
 		logger.Errorf("Error getting session: %v", err)
@@ -115,16 +185,66 @@ func (h *httpService) deleteWalletHandler(c echo.Context) error {
 		return c.JSON(http.StatusNotFound, response{Message: err.Error()})
 	}
 
-	if wallet.UserID != session.ID {
-		return c.JSON(http.StatusUnauthorized, response{Message: "You are not authorized to delete this wallet"})
+	if err := canAccessWallet(session, wallet); err != nil {
+		return forbidden(c)
+	}
+
+	if input.Mode == "" {
+		input.Mode = model.WalletDeleteCascade
 	}
 
-	if err := h.walletRepo.Delete(c.Request().Context(), id); err != nil {
+	switch input.Mode {
+	case model.WalletDeleteMove:
+		if input.TargetWalletID == "" || input.TargetWalletID == id {
+			return c.JSON(http.StatusBadRequest, response{Message: model.ErrInvalidTargetWallet.Error()})
+		}
+
+		if err := h.canUseWallet(c.Request().Context(), session, input.TargetWalletID); err != nil {
+			return forbidden(c)
+		}
+	case model.WalletDeleteArchive, model.WalletDeleteCascade:
+	default:
+		return c.JSON(http.StatusBadRequest, response{Message: model.ErrInvalidWalletDeleteMode.Error()})
+	}
+
+	result, err := h.walletRepo.Delete(c.Request().Context(), id, input)
+	if err != nil {
 		logger.Errorf("Error deleting wallet: %v", err)
 		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
 	}
 
-	return c.JSON(http.StatusOK, response{Success: true})
+	return c.JSON(http.StatusOK, response{Success: true, Data: result})
+}
+
+func (h *httpService) unarchiveWalletHandler(c echo.Context) error {
+	logger := logrus.WithField("ctx", utils.Dump(c.Request().Context()))
+
+	id := c.Param("id")
+
+	session, err := authSession(c)
+	if err != nil {
+		logger.Errorf("Error getting session: %v", err)
+		return c.JSON(http.StatusUnauthorized, response{Message: "unauthorized"})
+	}
+
+	wallet, err := h.walletRepo.FindByID(c.Request().Context(), id)
+	if err != nil {
+		logger.Errorf("Error getting wallet: %v", err)
+		return c.JSON(http.StatusNotFound, response{Message: err.Error()})
+	}
+
+	if err := canAccessWallet(session, wallet); err != nil {
+		return forbidden(c)
+	}
+
+	if err := h.walletRepo.Unarchive(c.Request().Context(), id); err != nil {
+		logger.Errorf("Error unarchiving wallet: %v", err)
+		return c.JSON(http.StatusInternalServerError, response{Message: err.Error()})
+	}
+
+	wallet.ArchivedAt = nil
+
+	return c.JSON(http.StatusOK, response{Success: true, Data: wallet})
 }
 
 func (h *httpService) findWalletOptionHandler(c echo.Context) error {
@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+var currencySymbols = map[string]string{
+	"IDR": "Rp",
+	"USD": "$",
+	"SGD": "S$",
+	"MYR": "RM",
+	"AUD": "A$",
+	"EUR": "€",
+	"GBP": "£",
+	"JPY": "¥",
+	"CNY": "¥",
+	"KRW": "₩",
+	"THB": "฿",
+	"INR": "₹",
+}
+
+// Currencies whose minor unit is not used in everyday amounts.
+var zeroDecimalCurrencies = map[string]bool{
+	"IDR": true,
+	"JPY": true,
+	"KRW": true,
+	"VND": true,
+}
+
+// Locales, by language, that group thousands with "." and use "," for decimals.
+var dotGroupingLanguages = map[string]bool{
+	"id": true,
+	"de": true,
+	"nl": true,
+	"es": true,
+	"it": true,
+	"pt": true,
+	"tr": true,
+	"vi": true,
+}
+
+// FormatMoney renders amount with the currency symbol and the separators of locale,
+// e.g. Rp50.000 for IDR in id-ID or $1,250.50 for USD in en-US.
+func FormatMoney(amount decimal.Decimal, currency, locale string) string {
+	currency = strings.ToUpper(currency)
+
+	places := int32(2)
+	if zeroDecimalCurrencies[currency] {
+		places = 0
+	}
+
+	group, point := ",", "."
+	if dotGroupingLanguages[strings.ToLower(strings.SplitN(strings.ReplaceAll(locale, "_", "-"), "-", 2)[0])] {
+		group, point = ".", ","
+	}
+
+	sign := ""
+	if amount.IsNegative() {
+		sign = "-"
+		amount = amount.Neg()
+	}
+
+	fixed := amount.StringFixed(places)
+	intPart, fracPart := fixed, ""
+	if i := strings.IndexByte(fixed, '.'); i >= 0 {
+		intPart, fracPart = fixed[:i], fixed[i+1:]
+	}
+
+	var groups []string
+	for i := len(intPart); i > 0; i -= 3 {
+		start := i - 3
+		if start < 0 {
+			start = 0
+		}
+		groups = append([]string{intPart[start:i]}, groups...)
+	}
+
+	formatted := strings.Join(groups, group)
+	if fracPart != "" {
+		formatted += point + fracPart
+	}
+
+	symbol, ok := currencySymbols[currency]
+	if !ok {
+		return sign + currency + " " + formatted
+	}
+
+	return sign + symbol + formatted
+}
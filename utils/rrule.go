@@ -0,0 +1,413 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FreqDaily   = "DAILY"
+	FreqWeekly  = "WEEKLY"
+	FreqMonthly = "MONTHLY"
+	FreqYearly  = "YEARLY"
+)
+
+// rruleHorizonYears bounds how far ahead Next looks for a matching day, so an
+// impossible rule (e.g. the 30th of February) cannot loop forever.
+const rruleHorizonYears = 10
+
+var rruleWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// RRuleDay is a BYDAY entry. N selects the nth weekday of the month (negative
+// counts from the end); zero means every such weekday.
+type RRuleDay struct {
+	N       int
+	Weekday time.Weekday
+}
+
+// RRule is the subset of RFC 5545 recurrence rules needed for bills and
+// income: FREQ, INTERVAL, BYDAY, BYMONTHDAY, BYMONTH, COUNT and UNTIL.
+//
+// Unlike RFC 5545, a month day past the end of a month is clamped to its last
+// day, so "monthly on the 31st" still charges in February.
+type RRule struct {
+	Freq       string
+	Interval   int
+	ByDay      []RRuleDay
+	ByMonthDay []int
+	ByMonth    []time.Month
+	Count      int
+	Until      time.Time
+}
+
+// ParseRRule parses a rule such as "FREQ=MONTHLY;BYMONTHDAY=25", with or
+// without the "RRULE:" prefix.
+func ParseRRule(s string) (RRule, error) {
+	rule := RRule{Interval: 1}
+
+	s = strings.TrimPrefix(strings.TrimSpace(strings.ToUpper(s)), "RRULE:")
+
+	for _, part := range strings.Split(s, ";") {
+		if part == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return RRule{}, fmt.Errorf("invalid rrule part %q", part)
+		}
+
+		switch key {
+		case "FREQ":
+			switch value {
+			case FreqDaily, FreqWeekly, FreqMonthly, FreqYearly:
+				rule.Freq = value
+			default:
+				return RRule{}, fmt.Errorf("unsupported rrule frequency %q", value)
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return RRule{}, fmt.Errorf("invalid rrule interval %q", value)
+			}
+
+			rule.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return RRule{}, fmt.Errorf("invalid rrule count %q", value)
+			}
+
+			rule.Count = n
+		case "UNTIL":
+			until, err := parseRRuleTime(value)
+			if err != nil {
+				return RRule{}, err
+			}
+
+			rule.Until = until
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				if len(day) < 2 {
+					return RRule{}, fmt.Errorf("invalid rrule day %q", day)
+				}
+
+				weekday, ok := rruleWeekdays[day[len(day)-2:]]
+				if !ok {
+					return RRule{}, fmt.Errorf("invalid rrule day %q", day)
+				}
+
+				n := 0
+				if prefix := day[:len(day)-2]; prefix != "" {
+					var err error
+					if n, err = strconv.Atoi(prefix); err != nil || n == 0 || n > 5 || n < -5 {
+						return RRule{}, fmt.Errorf("invalid rrule day %q", day)
+					}
+				}
+
+				rule.ByDay = append(rule.ByDay, RRuleDay{N: n, Weekday: weekday})
+			}
+		case "BYMONTHDAY":
+			for _, day := range strings.Split(value, ",") {
+				n, err := strconv.Atoi(day)
+				if err != nil || n == 0 || n > 31 || n < -31 {
+					return RRule{}, fmt.Errorf("invalid rrule month day %q", day)
+				}
+
+				rule.ByMonthDay = append(rule.ByMonthDay, n)
+			}
+		case "BYMONTH":
+			for _, month := range strings.Split(value, ",") {
+				n, err := strconv.Atoi(month)
+				if err != nil || n < 1 || n > 12 {
+					return RRule{}, fmt.Errorf("invalid rrule month %q", month)
+				}
+
+				rule.ByMonth = append(rule.ByMonth, time.Month(n))
+			}
+		case "WKST":
+			// weeks always start on Monday here, which is the RFC default
+		default:
+			return RRule{}, fmt.Errorf("unsupported rrule part %q", key)
+		}
+	}
+
+	if rule.Freq == "" {
+		return RRule{}, fmt.Errorf("rrule is missing FREQ")
+	}
+
+	return rule, nil
+}
+
+func parseRRuleTime(value string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405", "20060102"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid rrule until %q", value)
+}
+
+// String formats the rule back into RFC 5545 form.
+func (r RRule) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+
+	if len(r.ByDay) > 0 {
+		days := make([]string, 0, len(r.ByDay))
+		for _, day := range r.ByDay {
+			code := strings.ToUpper(day.Weekday.String()[:2])
+			if day.N != 0 {
+				code = strconv.Itoa(day.N) + code
+			}
+
+			days = append(days, code)
+		}
+
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, 0, len(r.ByMonthDay))
+		for _, day := range r.ByMonthDay {
+			days = append(days, strconv.Itoa(day))
+		}
+
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+
+	if len(r.ByMonth) > 0 {
+		months := make([]string, 0, len(r.ByMonth))
+		for _, month := range r.ByMonth {
+			months = append(months, strconv.Itoa(int(month)))
+		}
+
+		parts = append(parts, "BYMONTH="+strings.Join(months, ","))
+	}
+
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+
+	return strings.Join(parts, ";")
+}
+
+// Next returns the first occurrence strictly after after, for a series that
+// starts at start. Occurrences keep the clock time and location of start.
+func (r RRule) Next(start, after time.Time) (time.Time, bool) {
+	loc := start.Location()
+	after = after.In(loc)
+
+	day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, loc)
+	horizon := day.AddDate(rruleHorizonYears, 0, 0)
+	if later := time.Date(after.Year(), after.Month(), after.Day(), 0, 0, 0, 0, loc).AddDate(rruleHorizonYears, 0, 0); later.After(horizon) {
+		horizon = later
+	}
+
+	// Without COUNT there is nothing to tally, so skip straight to after.
+	if r.Count == 0 {
+		if skip := time.Date(after.Year(), after.Month(), after.Day(), 0, 0, 0, 0, loc); skip.After(day) {
+			day = skip
+		}
+	}
+
+	seen := 0
+
+	for ; !day.After(horizon); day = day.AddDate(0, 0, 1) {
+		if !r.matches(start, day) {
+			continue
+		}
+
+		occurrence := time.Date(day.Year(), day.Month(), day.Day(), start.Hour(), start.Minute(), start.Second(), 0, loc)
+		if occurrence.Before(start) {
+			continue
+		}
+
+		if !r.Until.IsZero() && occurrence.After(r.Until) {
+			return time.Time{}, false
+		}
+
+		seen++
+		if r.Count > 0 && seen > r.Count {
+			return time.Time{}, false
+		}
+
+		if occurrence.After(after) {
+			return occurrence, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// Between lists the occurrences in (from, to].
+func (r RRule) Between(start, from, to time.Time) []time.Time {
+	var occurrences []time.Time
+
+	for {
+		next, ok := r.Next(start, from)
+		if !ok || next.After(to) {
+			return occurrences
+		}
+
+		occurrences = append(occurrences, next)
+		from = next
+	}
+}
+
+func (r RRule) matches(start, day time.Time) bool {
+	interval := r.Interval
+	if interval < 1 {
+		interval = 1
+	}
+
+	if len(r.ByMonth) > 0 && !containsMonth(r.ByMonth, day.Month()) {
+		return false
+	}
+
+	switch r.Freq {
+	case FreqDaily:
+		if daysBetween(start, day)%interval != 0 {
+			return false
+		}
+
+		return r.matchesMonthDay(day, 0) && r.matchesWeekday(day, false)
+	case FreqWeekly:
+		if weeksBetween(start, day)%interval != 0 {
+			return false
+		}
+
+		if len(r.ByDay) == 0 {
+			return day.Weekday() == start.Weekday()
+		}
+
+		return r.matchesWeekday(day, false)
+	case FreqMonthly:
+		if monthsBetween(start, day)%interval != 0 {
+			return false
+		}
+	case FreqYearly:
+		if (day.Year()-start.Year())%interval != 0 {
+			return false
+		}
+
+		if len(r.ByMonth) == 0 && day.Month() != start.Month() {
+			return false
+		}
+	default:
+		return false
+	}
+
+	if len(r.ByDay) == 0 && len(r.ByMonthDay) == 0 {
+		return r.matchesMonthDay(day, start.Day())
+	}
+
+	return r.matchesMonthDay(day, 0) && r.matchesWeekday(day, true)
+}
+
+// matchesMonthDay checks BYMONTHDAY, or fallback when no BYMONTHDAY is set.
+// Days past the end of the month land on its last day.
+func (r RRule) matchesMonthDay(day time.Time, fallback int) bool {
+	days := r.ByMonthDay
+	if len(days) == 0 {
+		if fallback == 0 {
+			return true
+		}
+
+		days = []int{fallback}
+	}
+
+	last := daysInMonth(day)
+
+	for _, n := range days {
+		target := n
+		if n < 0 {
+			target = last + n + 1
+		}
+
+		if target > last {
+			target = last
+		}
+
+		if target == day.Day() {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r RRule) matchesWeekday(day time.Time, inMonth bool) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+
+	for _, byDay := range r.ByDay {
+		if byDay.Weekday != day.Weekday() {
+			continue
+		}
+
+		if byDay.N == 0 || !inMonth {
+			return true
+		}
+
+		nth := (day.Day()-1)/7 + 1
+		fromEnd := -((daysInMonth(day)-day.Day())/7 + 1)
+
+		if byDay.N == nth || byDay.N == fromEnd {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsMonth(months []time.Month, month time.Month) bool {
+	for _, m := range months {
+		if m == month {
+			return true
+		}
+	}
+
+	return false
+}
+
+func daysInMonth(t time.Time) int {
+	return time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, t.Location()).Day()
+}
+
+func daysBetween(start, day time.Time) int {
+	a := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	b := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+
+	return int(b.Sub(a).Hours() / 24)
+}
+
+// weeksBetween counts Monday-started weeks from start to day.
+func weeksBetween(start, day time.Time) int {
+	offset := (int(start.Weekday()) + 6) % 7
+
+	return (daysBetween(start, day) + offset) / 7
+}
+
+func monthsBetween(start, day time.Time) int {
+	return (day.Year()-start.Year())*12 + int(day.Month()) - int(start.Month())
+}
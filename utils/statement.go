@@ -0,0 +1,534 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/shopspring/decimal"
+)
+
+// StatementEntry is one line of a bank statement. Amount is signed: money
+// leaving the account is negative.
+type StatementEntry struct {
+	Line        int             `json:"line"`
+	Date        time.Time       `json:"date"`
+	Description string          `json:"description"`
+	Amount      decimal.Decimal `json:"amount"`
+	Currency    string          `json:"currency,omitempty"`
+	Reference   string          `json:"reference,omitempty"`
+}
+
+// CSVStatementOptions describes how a bank lays out its CSV export. Columns
+// are header names, or 1-based column numbers for files without a header.
+type CSVStatementOptions struct {
+	Delimiter         rune
+	HasHeader         bool
+	SkipRows          int
+	DateColumn        string
+	DateFormat        string
+	DescriptionColumn string
+	AmountColumn      string // signed amount, or ...
+	DebitColumn       string // ... separate money-out and
+	CreditColumn      string // money-in columns
+	DecimalSeparator  string
+	InvertSign        bool // for banks that print money out as positive
+}
+
+// ParseCSVStatement reads entries from a CSV export laid out as in opts.
+func ParseCSVStatement(r io.Reader, opts CSVStatementOptions) ([]StatementEntry, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.LazyQuotes = true
+
+	if opts.Delimiter != 0 {
+		reader.Comma = opts.Delimiter
+	}
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.SkipRows >= len(records) {
+		return nil, nil
+	}
+
+	records = records[opts.SkipRows:]
+	line := opts.SkipRows
+
+	var header []string
+	if opts.HasHeader && len(records) > 0 {
+		header = records[0]
+		records = records[1:]
+		line++
+	}
+
+	dateColumn, err := csvColumn(header, opts.DateColumn)
+	if err != nil {
+		return nil, err
+	}
+
+	descriptionColumn, err := csvColumn(header, opts.DescriptionColumn)
+	if err != nil {
+		return nil, err
+	}
+
+	amountColumn, debitColumn, creditColumn := -1, -1, -1
+
+	if opts.AmountColumn != "" {
+		if amountColumn, err = csvColumn(header, opts.AmountColumn); err != nil {
+			return nil, err
+		}
+	} else {
+		if debitColumn, err = csvColumn(header, opts.DebitColumn); err != nil {
+			return nil, err
+		}
+
+		if creditColumn, err = csvColumn(header, opts.CreditColumn); err != nil {
+			return nil, err
+		}
+	}
+
+	dateFormat := opts.DateFormat
+	if dateFormat == "" {
+		dateFormat = "2006-01-02"
+	}
+
+	var entries []StatementEntry
+
+	for _, record := range records {
+		line++
+
+		if isBlankRecord(record) {
+			continue
+		}
+
+		date, err := time.Parse(dateFormat, field(record, dateColumn))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid date %q", line, field(record, dateColumn))
+		}
+
+		var amount decimal.Decimal
+
+		if amountColumn >= 0 {
+			amount, err = ParseStatementAmount(field(record, amountColumn), opts.DecimalSeparator)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+		} else {
+			debit, err := ParseStatementAmount(field(record, debitColumn), opts.DecimalSeparator)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+
+			credit, err := ParseStatementAmount(field(record, creditColumn), opts.DecimalSeparator)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+
+			amount = credit.Abs().Sub(debit.Abs())
+		}
+
+		if opts.InvertSign {
+			amount = amount.Neg()
+		}
+
+		entries = append(entries, StatementEntry{
+			Line:        line,
+			Date:        date,
+			Description: strings.TrimSpace(field(record, descriptionColumn)),
+			Amount:      amount,
+		})
+	}
+
+	return entries, nil
+}
+
+func csvColumn(header []string, spec string) (int, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return -1, fmt.Errorf("csv column mapping is incomplete")
+	}
+
+	if n, err := strconv.Atoi(spec); err == nil {
+		if n < 1 {
+			return -1, fmt.Errorf("csv column %q must be 1 or more", spec)
+		}
+
+		return n - 1, nil
+	}
+
+	for i, name := range header {
+		if strings.EqualFold(strings.TrimSpace(name), spec) {
+			return i, nil
+		}
+	}
+
+	return -1, fmt.Errorf("csv column %q not found in header", spec)
+}
+
+func field(record []string, i int) string {
+	if i < 0 || i >= len(record) {
+		return ""
+	}
+
+	return strings.TrimSpace(record[i])
+}
+
+func isBlankRecord(record []string) bool {
+	for _, value := range record {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ParseStatementAmount reads amounts the way banks print them: with currency
+// symbols, thousands separators, (parentheses) or a trailing minus for money
+// out, and the DB/DR/CR suffixes used by Indonesian banks. An empty string is zero.
+func ParseStatementAmount(s, decimalSeparator string) (decimal.Decimal, error) {
+	raw := s
+	s = strings.ToUpper(strings.TrimSpace(s))
+
+	if s == "" || s == "-" {
+		return decimal.Zero, nil
+	}
+
+	negative := false
+
+	switch {
+	case hasMarkerSuffix(s, "DB"), hasMarkerSuffix(s, "DR"):
+		negative = true
+		s = strings.TrimSpace(s[:len(s)-2])
+	case hasMarkerSuffix(s, "CR"):
+		s = strings.TrimSpace(s[:len(s)-2])
+	}
+
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		negative = true
+		s = s[1 : len(s)-1]
+	}
+
+	if strings.HasSuffix(s, "-") {
+		negative = true
+		s = s[:len(s)-1]
+	}
+
+	if strings.HasPrefix(s, "-") {
+		negative = !negative
+		s = s[1:]
+	}
+
+	if decimalSeparator == "" {
+		decimalSeparator = "."
+	}
+
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case unicode.IsDigit(r):
+			b.WriteRune(r)
+		case string(r) == decimalSeparator:
+			b.WriteRune('.')
+		}
+	}
+
+	if b.Len() == 0 {
+		return decimal.Zero, fmt.Errorf("invalid amount %q", raw)
+	}
+
+	amount, err := decimal.NewFromString(b.String())
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("invalid amount %q", raw)
+	}
+
+	if negative {
+		amount = amount.Neg()
+	}
+
+	return amount, nil
+}
+
+// hasMarkerSuffix matches a debit/credit marker, but not the tail of a
+// currency code such as "IDR".
+func hasMarkerSuffix(s, marker string) bool {
+	if !strings.HasSuffix(s, marker) {
+		return false
+	}
+
+	rest := []rune(s[:len(s)-len(marker)])
+
+	return len(rest) > 0 && !unicode.IsLetter(rest[len(rest)-1])
+}
+
+var ofxTag = regexp.MustCompile(`<(/?)([A-Za-z0-9.]+)>([^<]*)`)
+
+// ParseOFXStatement reads the bank transactions of an OFX or QFX file. Both
+// the SGML (1.x, unclosed tags) and XML (2.x) flavours are accepted.
+func ParseOFXStatement(r io.Reader) ([]StatementEntry, error) {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		entries  []StatementEntry
+		current  *StatementEntry
+		currency string
+		name     string
+		memo     string
+		count    int
+	)
+
+	flush := func() error {
+		current.Description = joinDescription(name, memo)
+		current.Currency = currency
+
+		if current.Date.IsZero() {
+			return fmt.Errorf("transaction %d: missing DTPOSTED", current.Line)
+		}
+
+		entries = append(entries, *current)
+		current = nil
+
+		return nil
+	}
+
+	for _, match := range ofxTag.FindAllStringSubmatch(string(body), -1) {
+		closing, tag, value := match[1] == "/", strings.ToUpper(match[2]), strings.TrimSpace(match[3])
+
+		switch {
+		case tag == "CURDEF" && !closing:
+			currency = strings.ToUpper(value)
+		case tag == "STMTTRN" && !closing:
+			// SGML files may leave STMTTRN unclosed, so a new one ends the previous.
+			if current != nil {
+				if err := flush(); err != nil {
+					return nil, err
+				}
+			}
+
+			count++
+			current = &StatementEntry{Line: count}
+			name, memo = "", ""
+		case (tag == "STMTTRN" || tag == "BANKTRANLIST") && closing && current != nil:
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		case current == nil || closing:
+			continue
+		case tag == "DTPOSTED":
+			date, err := parseOFXDate(value)
+			if err != nil {
+				return nil, fmt.Errorf("transaction %d: %w", current.Line, err)
+			}
+
+			current.Date = date
+		case tag == "TRNAMT":
+			amount, err := ParseStatementAmount(value, ofxDecimalSeparator(value))
+			if err != nil {
+				return nil, fmt.Errorf("transaction %d: %w", current.Line, err)
+			}
+
+			current.Amount = amount
+		case tag == "NAME":
+			name = value
+		case tag == "MEMO":
+			memo = value
+		case tag == "FITID":
+			current.Reference = value
+		}
+	}
+
+	if current != nil {
+		if err := flush(); err != nil {
+			return nil, err
+		}
+	}
+
+	return entries, nil
+}
+
+func parseOFXDate(value string) (time.Time, error) {
+	digits := value
+	if i := strings.IndexAny(digits, ".[ "); i >= 0 {
+		digits = digits[:i]
+	}
+
+	switch {
+	case len(digits) >= 14:
+		return time.Parse("20060102150405", digits[:14])
+	case len(digits) >= 8:
+		return time.Parse("20060102", digits[:8])
+	}
+
+	return time.Time{}, fmt.Errorf("invalid OFX date %q", value)
+}
+
+// ofxDecimalSeparator guesses the separator, since some European banks
+// export TRNAMT with a comma.
+func ofxDecimalSeparator(value string) string {
+	if strings.Contains(value, ",") && !strings.Contains(value, ".") {
+		return ","
+	}
+
+	return "."
+}
+
+func joinDescription(name, memo string) string {
+	switch {
+	case name == "":
+		return memo
+	case memo == "" || strings.EqualFold(name, memo):
+		return name
+	}
+
+	return name + " " + memo
+}
+
+// ParseQIFStatement reads a bank or cash QIF file. dateFormat defaults to the
+// US layout most tools write; the Quicken "'" year separator is accepted.
+func ParseQIFStatement(r io.Reader, dateFormat, decimalSeparator string) ([]StatementEntry, error) {
+	if dateFormat == "" {
+		dateFormat = "01/02/2006"
+	}
+
+	scanner := bufio.NewScanner(r)
+
+	var (
+		entries []StatementEntry
+		current StatementEntry
+		payee   string
+		memo    string
+		line    int
+		started bool
+	)
+
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+
+		if text == "" || strings.HasPrefix(text, "!") {
+			continue
+		}
+
+		if !started {
+			current = StatementEntry{Line: line}
+			payee, memo = "", ""
+			started = true
+		}
+
+		code, value := text[0], strings.TrimSpace(text[1:])
+
+		switch code {
+		case 'D':
+			date, err := parseQIFDate(value, dateFormat)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+
+			current.Date = date
+		case 'T', 'U':
+			amount, err := ParseStatementAmount(value, decimalSeparator)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+
+			current.Amount = amount
+		case 'P':
+			payee = value
+		case 'M':
+			memo = value
+		case 'N':
+			current.Reference = value
+		case '^':
+			if current.Date.IsZero() {
+				return nil, fmt.Errorf("line %d: record without date", line)
+			}
+
+			current.Description = joinDescription(payee, memo)
+			entries = append(entries, current)
+			started = false
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func parseQIFDate(value, dateFormat string) (time.Time, error) {
+	normalized := strings.ReplaceAll(strings.ReplaceAll(value, "'", "/"), " ", "")
+
+	if date, err := time.Parse(dateFormat, normalized); err == nil {
+		return date, nil
+	}
+
+	// Two-digit years, e.g. 12/31/99 or 31/12'99.
+	short := strings.Replace(dateFormat, "2006", "06", 1)
+	if date, err := time.Parse(short, normalized); err == nil {
+		return date, nil
+	}
+
+	return time.Time{}, fmt.Errorf("invalid date %q", value)
+}
+
+// Similarity scores how alike two transaction descriptions are, from 0 to 1,
+// as the share of the shorter text's character bigrams found in the longer
+// one. Digits and punctuation are dropped first, so the transfer codes and
+// reference numbers banks wrap around a merchant name do not count against it.
+func Similarity(a, b string) float64 {
+	a, b = normalizeDescription(a), normalizeDescription(b)
+
+	if a == b {
+		return 1
+	}
+
+	if len(a) < 2 || len(b) < 2 {
+		return 0
+	}
+
+	bigrams := make(map[string]int)
+	for i := 0; i < len(a)-1; i++ {
+		bigrams[a[i:i+2]]++
+	}
+
+	matches := 0
+	for i := 0; i < len(b)-1; i++ {
+		if bigrams[b[i:i+2]] > 0 {
+			bigrams[b[i:i+2]]--
+			matches++
+		}
+	}
+
+	shorter := len(a)
+	if len(b) < shorter {
+		shorter = len(b)
+	}
+
+	return float64(matches) / float64(shorter-1)
+}
+
+func normalizeDescription(s string) string {
+	var b strings.Builder
+
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsSpace(r) {
+			b.WriteRune(r)
+		}
+	}
+
+	return strings.Join(strings.Fields(b.String()), " ")
+}
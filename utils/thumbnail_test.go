@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image/jpeg"
+	"testing"
+)
+
+// a 1x1 lossless WebP
+const tinyWebP = "UklGRhoAAABXRUJQVlA4TA0AAAAvAAAAEAcQERGIiP4HAA=="
+
+func TestThumbnailWebP(t *testing.T) {
+	data, err := base64.StdEncoding.DecodeString(tinyWebP)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	thumbnail, err := Thumbnail(data, 320)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := jpeg.DecodeConfig(bytes.NewReader(thumbnail))
+	if err != nil {
+		t.Fatalf("thumbnail is not a JPEG: %v", err)
+	}
+
+	if config.Width != 1 || config.Height != 1 {
+		t.Fatalf("got %dx%d, want 1x1", config.Width, config.Height)
+	}
+}